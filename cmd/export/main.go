@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/export"
+	"vibeta/internal/logging"
+)
+
+// export xuất tin nhắn của một conversation ra JSON lines, HTML hoặc text, đọc trực tiếp
+// từ database cấu hình bởi DB_*. Không có -user thì không kiểm tra quyền, dành cho
+// người vận hành có quyền truy cập database (ví dụ yêu cầu của bộ phận compliance).
+//
+// Cách dùng:
+//
+//	go run ./cmd/export -conversation ID [-format jsonl|html|text] [-from RFC3339] [-to RFC3339] [-o FILE] [-user ID]
+func main() {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	conversationID := flags.String("conversation", "", "conversation cần export")
+	format := flags.String("format", string(export.FormatJSONL), "jsonl, html hoặc text")
+	from := flags.String("from", "", "chỉ lấy tin nhắn từ thời điểm này (RFC3339, bao gồm)")
+	to := flags.String("to", "", "chỉ lấy tin nhắn trước thời điểm này (RFC3339, không bao gồm)")
+	output := flags.String("o", "", "file đích, mặc định stdout")
+	userID := flags.String("user", "", "chỉ export nếu user là thành viên của conversation")
+	configFlags := config.BindFlags(flags)
+	flags.Parse(os.Args[1:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-export", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	if *conversationID == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	options := export.Options{ConversationID: *conversationID}
+	if options.Format, err = export.ParseFormat(*format); err != nil {
+		logging.Fatal("Format không hợp lệ", logging.Err(err))
+	}
+	if options.From, err = parseTime(*from); err != nil {
+		logging.Fatal("-from không hợp lệ, cần RFC3339", logging.Err(err))
+	}
+	if options.To, err = parseTime(*to); err != nil {
+		logging.Fatal("-to không hợp lệ, cần RFC3339", logging.Err(err))
+	}
+
+	database, err := db.Connect(cfg.Database.Driver, cfg.Database.DSN(), cfg.Database.SlowQueryThreshold)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối database", logging.Err(err))
+	}
+	store := db.NewStore(database)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if *userID != "" {
+		member, err := store.Participants.IsParticipant(ctx, *conversationID, *userID)
+		if err != nil {
+			logging.Fatal("Lỗi kiểm tra thành viên", logging.Err(err))
+		}
+		if !member {
+			logging.Fatal("User không phải thành viên của conversation", "conversation_id", *conversationID, "user_id", *userID)
+		}
+	}
+
+	writer := os.Stdout
+	if *output != "" {
+		if writer, err = os.Create(*output); err != nil {
+			logging.Fatal("Không tạo được file export", "path", *output, logging.Err(err))
+		}
+	}
+
+	count, err := export.NewExporter(store).Export(ctx, writer, options)
+	if errors.Is(err, db.ErrNotFound) {
+		logging.Fatal("Không tìm thấy conversation", "conversation_id", *conversationID)
+	}
+	if err != nil {
+		logging.Fatal("Export thất bại", "messages", count, logging.Err(err))
+	}
+	if err := writer.Close(); err != nil {
+		logging.Fatal("Lỗi ghi file export", logging.Err(err))
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Đã export %d tin nhắn vào %s\n", count, *output)
+	}
+}
+
+// parseTime đọc thời gian RFC3339, chuỗi rỗng trả về zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
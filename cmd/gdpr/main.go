@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/gdpr"
+	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+)
+
+// gdpr xử lý yêu cầu của chủ thể dữ liệu cho một user: export toàn bộ dữ liệu thành file zip,
+// hoặc xóa dữ liệu. Event user_erased được ghi vào outbox và được publish bởi outbox relay
+// của WebSocket server.
+//
+// Cách dùng:
+//
+//	go run ./cmd/gdpr export -user ID [-o FILE]
+//	go run ./cmd/gdpr erase -user ID [-mode redact|delete] -yes
+func main() {
+	flags := flag.NewFlagSet("gdpr", flag.ExitOnError)
+	userID := flags.String("user", "", "user ID")
+	output := flags.String("o", "", "export: file zip đích, mặc định stdout")
+	mode := flags.String("mode", string(db.ErasureRedact), "erase: redact (xóa trắng nội dung tin nhắn) hoặc delete (xóa hẳn tin nhắn)")
+	confirm := flags.Bool("yes", false, "erase: xác nhận xóa, không thể hoàn tác")
+	actor := flags.String("actor", audit.CLIActor(), "người thực hiện, ghi vào audit log")
+	configFlags := config.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Cách dùng: gdpr <export|erase> -user ID [flags]")
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-gdpr", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	if *userID == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	database, err := db.Connect(cfg.Database.Driver, cfg.Database.DSN(), cfg.Database.SlowQueryThreshold)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối database", logging.Err(err))
+	}
+	store := db.NewStore(database)
+	recorder, err := audit.Setup(cfg, store)
+	if err != nil {
+		logging.Fatal("Lỗi khởi tạo audit log", logging.Err(err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	switch command {
+	case "export":
+		manifest := exportUser(ctx, store, *userID, *output)
+		record(ctx, recorder, *actor, audit.ActionUserExport, *userID, map[string]interface{}{"files": manifest.Files})
+	case "erase":
+		erasureMode, err := gdpr.ParseErasureMode(*mode)
+		if err != nil {
+			logging.Fatal("Mode không hợp lệ", logging.Err(err))
+		}
+		if !*confirm {
+			logging.Fatal("Xóa dữ liệu không thể hoàn tác, chạy lại với -yes để xác nhận", logging.KeyUserID, *userID)
+		}
+		result := eraseUser(ctx, cfg, store, *userID, erasureMode)
+		record(ctx, recorder, *actor, audit.ActionUserErase, *userID, map[string]interface{}{
+			"mode":          erasureMode,
+			"messages":      result.Messages,
+			"held_messages": result.HeldMessages,
+			"reactions":     result.Reactions,
+			"memberships":   result.Memberships,
+			"profile":       result.Profile,
+		})
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func exportUser(ctx context.Context, store *db.Store, userID, output string) *gdpr.Manifest {
+	// Kiểm tra user trước khi tạo file để không để lại file rỗng
+	if _, err := store.Users.Get(ctx, userID); errors.Is(err, db.ErrNotFound) {
+		logging.Fatal("Không tìm thấy user", logging.KeyUserID, userID)
+	} else if err != nil {
+		logging.Fatal("Lỗi đọc user", logging.Err(err))
+	}
+
+	writer := os.Stdout
+	if output != "" {
+		var err error
+		if writer, err = os.Create(output); err != nil {
+			logging.Fatal("Không tạo được file export", "path", output, logging.Err(err))
+		}
+	}
+
+	manifest, err := gdpr.NewExporter(store).Export(ctx, writer, userID)
+	if err != nil {
+		logging.Fatal("Export thất bại", logging.KeyUserID, userID, logging.Err(err))
+	}
+	if err := writer.Close(); err != nil {
+		logging.Fatal("Lỗi ghi file export", logging.Err(err))
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Đã export dữ liệu của %s vào %s: %v\n", userID, output, manifest.Files)
+	}
+	return manifest
+}
+
+func eraseUser(ctx context.Context, cfg *config.Config, store *db.Store, userID string, mode db.ErasureMode) *db.ErasureResult {
+	serviceConfig, err := kafka.NewServiceConfig(cfg.Kafka)
+	if err != nil {
+		logging.Fatal("Lỗi cấu hình Kafka", logging.Err(err))
+	}
+	outbox, err := kafka.NewOutboxRelay(store.Outbox, serviceConfig)
+	if err != nil {
+		logging.Fatal("Lỗi khởi tạo outbox", logging.Err(err))
+	}
+
+	result, err := gdpr.NewEraser(store, outbox).Erase(ctx, userID, mode)
+	if err != nil {
+		logging.Fatal("Xóa dữ liệu thất bại", logging.KeyUserID, userID, logging.Err(err))
+	}
+
+	fmt.Printf("Đã xóa dữ liệu của %s (mode %s)\n", userID, mode)
+	if !result.Profile {
+		fmt.Printf("Hồ sơ:                 không có bản ghi users, bỏ qua\n")
+	}
+	fmt.Printf("Tin nhắn:              %d\n", result.Messages)
+	fmt.Printf("Tin nhắn legal hold:   %d (giữ nguyên)\n", result.HeldMessages)
+	fmt.Printf("Reaction:              %d\n", result.Reactions)
+	fmt.Printf("Rời conversation:      %d\n", result.Memberships)
+	return result
+}
+
+// record ghi yêu cầu đã xử lý vào audit log. Hồ sơ trước khi xóa không được sao vào audit log.
+func record(ctx context.Context, recorder *audit.Recorder, actor, action, userID string, after any) {
+	err := recorder.Record(ctx, audit.Entry{
+		ActorID:    actor,
+		Action:     action,
+		TargetType: audit.TargetUser,
+		TargetID:   userID,
+		After:      after,
+	})
+	if err != nil {
+		logging.Fatal("Đã xử lý yêu cầu nhưng lỗi ghi audit log", logging.KeyUserID, userID, logging.Err(err))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/importer"
+	"vibeta/internal/logging"
+)
+
+// import nhập lịch sử chat từ bản export Slack (file zip) hoặc Mattermost (bulk import
+// JSONL hoặc file zip của mmctl export) vào database cấu hình bởi DB_*. Chạy lại cùng
+// bản export không tạo trùng user, conversation, tin nhắn hay reaction.
+//
+// Cách dùng:
+//
+//	go run ./cmd/import -source slack -file export.zip
+//	go run ./cmd/import -source mattermost -file import.jsonl
+func main() {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	source := flags.String("source", "", "nguồn export: slack hoặc mattermost")
+	file := flags.String("file", "", "đường dẫn bản export")
+	actor := flags.String("actor", audit.CLIActor(), "người thực hiện, ghi vào audit log")
+	configFlags := config.BindFlags(flags)
+	flags.Parse(os.Args[1:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-import", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	if *source == "" || *file == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	parsedSource, err := importer.ParseSource(*source)
+	if err != nil {
+		logging.Fatal("Nguồn không hợp lệ", logging.Err(err))
+	}
+
+	database, err := db.Connect(cfg.Database.Driver, cfg.Database.DSN(), cfg.Database.SlowQueryThreshold)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối database", logging.Err(err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	store := db.NewStore(database)
+	recorder, err := audit.Setup(cfg, store)
+	if err != nil {
+		logging.Fatal("Lỗi khởi tạo audit log", logging.Err(err))
+	}
+
+	stats, err := importer.Import(ctx, store, parsedSource, *file)
+	if err != nil {
+		logging.Fatal("Import thất bại", "path", *file, "users", stats.Users, "conversations", stats.Conversations,
+			"messages", stats.Messages, logging.Err(err))
+	}
+
+	err = recorder.Record(ctx, audit.Entry{
+		ActorID:    *actor,
+		Action:     audit.ActionImport,
+		TargetType: audit.TargetWorkspace,
+		TargetID:   string(parsedSource),
+		After: map[string]interface{}{
+			"file":          filepath.Base(*file),
+			"users":         stats.Users,
+			"matched_users": stats.MatchedUsers,
+			"conversations": stats.Conversations,
+			"messages":      stats.Messages,
+			"reactions":     stats.Reactions,
+			"skipped":       stats.Skipped,
+		},
+	})
+	if err != nil {
+		logging.Fatal("Đã import nhưng lỗi ghi audit log", logging.Err(err))
+	}
+
+	fmt.Printf("User mới:             %d\n", stats.Users)
+	fmt.Printf("User gộp theo email:  %d\n", stats.MatchedUsers)
+	fmt.Printf("Conversation mới:     %d\n", stats.Conversations)
+	fmt.Printf("Tin nhắn đã xử lý:    %d\n", stats.Messages)
+	fmt.Printf("Reaction đã xử lý:    %d\n", stats.Reactions)
+	fmt.Printf("Tin nhắn bỏ qua:      %d\n", stats.Skipped)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"vibeta/internal/config"
+	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+)
+
+// kafka-topics tạo các topic theo cấu hình routing (KAFKA_MESSAGE_TOPIC, KAFKA_TOPIC_ROUTES,
+// KAFKA_TOPIC_PARTITIONS, KAFKA_REPLICATION_FACTOR) thay cho script docker exec.
+//
+// Cách dùng:
+//
+//	go run ./cmd/kafka-topics create [-grow]
+//	go run ./cmd/kafka-topics list
+func main() {
+	flags := flag.NewFlagSet("kafka-topics", flag.ExitOnError)
+	grow := flags.Bool("grow", false, "Tăng số partition của topic đã tồn tại nếu ít hơn cấu hình")
+	configFlags := config.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Cách dùng: kafka-topics <create|list> [flags]")
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-kafka-topics", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	serviceConfig, err := kafka.NewServiceConfig(cfg.Kafka)
+	if err != nil {
+		logging.Fatal("Cấu hình Kafka không hợp lệ", logging.Err(err))
+	}
+
+	admin, err := kafka.NewTopicAdmin(serviceConfig.KafkaBrokers, serviceConfig.Security)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối Kafka", logging.Err(err))
+	}
+	defer admin.Close()
+
+	switch command {
+	case "create":
+		createTopics(admin, serviceConfig, *grow)
+	case "list":
+		listTopics(admin)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// createTopics tạo các topic còn thiếu và báo cáo topic có ít partition hơn cấu hình
+func createTopics(admin *kafka.TopicAdmin, serviceConfig *kafka.ServiceConfig, grow bool) {
+	results, err := admin.EnsureTopics(serviceConfig.Topics, grow)
+	for _, result := range results {
+		switch result.Action {
+		case kafka.TopicCreated:
+			slog.Info("Đã tạo topic", logging.KeyTopic, result.Name, "partitions", result.Partitions)
+		case kafka.TopicExists:
+			slog.Info("Topic đã tồn tại", logging.KeyTopic, result.Name, "partitions", result.Partitions)
+		case kafka.TopicGrown:
+			slog.Info("Đã tăng partition của topic", logging.KeyTopic, result.Name, "partitions", result.Partitions)
+		case kafka.TopicTooFewPartitions:
+			slog.Warn("Topic có ít partition hơn cấu hình (chạy lại với -grow để tăng)",
+				logging.KeyTopic, result.Name, "partitions", result.Partitions, "configured", result.Configured)
+		}
+	}
+	if err != nil {
+		logging.Fatal("Lỗi tạo topic", logging.Err(err))
+	}
+}
+
+// listTopics in các topic hiện có trên cluster
+func listTopics(admin *kafka.TopicAdmin) {
+	topics, err := admin.ListTopics()
+	if err != nil {
+		logging.Fatal("Lỗi lấy danh sách topic", logging.Err(err))
+	}
+
+	for _, name := range kafka.SortedTopicNames(topics) {
+		topic := topics[name]
+		fmt.Printf("%-30s partitions=%d replication=%d\n", topic.Name, topic.Partitions, topic.ReplicationFactor)
+	}
+}
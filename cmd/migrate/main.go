@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+)
+
+// migrate áp dụng các SQL migration nhúng trong binary (internal/db/migrations) vào
+// database cấu hình bởi DB_*. Không fallback sang SQLite khi không kết nối được PostgreSQL.
+//
+// Cách dùng:
+//
+//	go run ./cmd/migrate up [-to VERSION]
+//	go run ./cmd/migrate down [-steps N]
+//	go run ./cmd/migrate status
+func main() {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := flags.Int("to", 0, "up: version đích (mặc định mới nhất)")
+	steps := flags.Int("steps", 1, "down: số migration cần hoàn tác")
+	configFlags := config.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Cách dùng: migrate <up|down|status> [flags]")
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-migrate", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	database, err := db.Connect(cfg.Database.Driver, cfg.Database.DSN(), cfg.Database.SlowQueryThreshold)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối database", logging.Err(err))
+	}
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		logging.Fatal("Lỗi load migration", logging.Err(err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx, *to)
+		if err != nil {
+			logging.Fatal("Migrate up thất bại", "applied", len(applied), logging.Err(err))
+		}
+		fmt.Printf("Đã áp dụng %d migration\n", len(applied))
+	case "down":
+		if *steps < 1 {
+			logging.Fatal("-steps phải lớn hơn 0")
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			logging.Fatal("Migrate down thất bại", "reverted", len(reverted), logging.Err(err))
+		}
+		fmt.Printf("Đã hoàn tác %d migration\n", len(reverted))
+	case "status":
+		printStatus(ctx, migrator)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// printStatus in trạng thái từng migration
+func printStatus(ctx context.Context, migrator *db.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		logging.Fatal("Lỗi đọc trạng thái migration", logging.Err(err))
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(writer, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	writer.Flush()
+}
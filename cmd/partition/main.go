@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+)
+
+// partition chuyển bảng messages trên PostgreSQL sang partition theo tháng và quản lý
+// các partition. Sau khi chuyển, worker tự tạo sẵn partition cho DB_PARTITION_PREMAKE_MONTHS
+// tháng tiếp theo.
+//
+// Cách dùng:
+//
+//	go run ./cmd/partition convert [-drop-old]
+//	go run ./cmd/partition ensure
+//	go run ./cmd/partition status
+func main() {
+	flags := flag.NewFlagSet("partition", flag.ExitOnError)
+	dropOld := flags.Bool("drop-old", false, "convert: xóa bảng cũ (messages_unpartitioned) sau khi chuyển")
+	configFlags := config.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Cách dùng: partition <convert|ensure|status> [flags]")
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-partition", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	database, err := db.Connect(cfg.Database.Driver, cfg.Database.DSN(), cfg.Database.SlowQueryThreshold)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối database", logging.Err(err))
+	}
+
+	partitions, err := db.NewPartitionManager(database, cfg.Database.PartitionPremakeMonths)
+	if err != nil {
+		logging.Fatal("Không thể quản lý partition", "driver", database.Dialect(), logging.Err(err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	switch command {
+	case "convert":
+		// Bảng partition được tạo theo cấu trúc hiện tại của messages nên schema phải mới nhất
+		migrator, err := db.NewMigrator(database)
+		if err != nil {
+			logging.Fatal("Lỗi load migration", logging.Err(err))
+		}
+		if pending, err := migrator.Pending(ctx); err != nil {
+			logging.Fatal("Lỗi đọc trạng thái migration", logging.Err(err))
+		} else if len(pending) > 0 {
+			logging.Fatal("Chạy `go run ./cmd/migrate up` trước khi chuyển", logging.Err(db.ErrPendingMigrations))
+		}
+
+		err = partitions.Convert(ctx, db.ConvertOptions{DropOld: *dropOld})
+		if errors.Is(err, db.ErrAlreadyPartitioned) {
+			fmt.Println("Bảng messages đã được partition")
+			return
+		}
+		if err != nil {
+			logging.Fatal("Chuyển bảng messages thất bại", logging.Err(err))
+		}
+		fmt.Println("Đã chuyển bảng messages sang partition theo tháng")
+		if !*dropOld {
+			fmt.Println("Bảng cũ được giữ lại với tên messages_unpartitioned, xóa khi không cần nữa")
+		}
+	case "ensure":
+		created, err := partitions.Ensure(ctx, time.Now())
+		if err != nil {
+			logging.Fatal("Tạo partition thất bại", logging.Err(err))
+		}
+		fmt.Printf("Đã tạo %d partition %v\n", len(created), created)
+	case "status":
+		printStatus(ctx, partitions)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// printStatus in các partition của bảng messages
+func printStatus(ctx context.Context, partitions *db.PartitionManager) {
+	partitioned, err := partitions.Partitioned(ctx)
+	if err != nil {
+		logging.Fatal("Lỗi đọc trạng thái partition", logging.Err(err))
+	}
+	if !partitioned {
+		fmt.Println("Bảng messages chưa được partition")
+		return
+	}
+
+	list, err := partitions.List(ctx)
+	if err != nil {
+		logging.Fatal("Lỗi đọc danh sách partition", logging.Err(err))
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "PARTITION\tBOUND\tROWS (ƯỚC LƯỢNG)")
+	for _, partition := range list {
+		fmt.Fprintf(writer, "%s\t%s\t%d\n", partition.Name, partition.Bound, partition.EstimatedRows)
+	}
+	writer.Flush()
+}
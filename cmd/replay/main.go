@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+)
+
+// replay đọc lại các event trong topic Kafka và ghi vào database đích qua
+// MessageProcessor, dùng để sửa hoặc backfill dữ liệu sau một lần deploy lỗi.
+//
+// Ví dụ:
+//
+//	go run ./cmd/replay -dry-run -from-time 2024-05-01T00:00:00Z -to-time 2024-05-02T00:00:00Z
+//	go run ./cmd/replay -db-driver sqlite -db-dsn rebuild.db -conversation conv_123
+func main() {
+	var (
+		brokers        = flag.String("brokers", "", "Danh sách Kafka brokers, phân cách bởi dấu phẩy (mặc định KAFKA_BROKERS)")
+		topic          = flag.String("topic", "", "Topic cần replay (mặc định KAFKA_MESSAGE_TOPIC)")
+		partitions     = flag.String("partitions", "", "Danh sách partition cần replay (mặc định tất cả), ví dụ 0,2")
+		fromOffset     = flag.Int64("from-offset", kafka.OffsetOldest, "Offset bắt đầu trên mỗi partition (mặc định đầu partition)")
+		toOffset       = flag.Int64("to-offset", kafka.OffsetNewest, "Offset kết thúc, không bao gồm (mặc định high water mark)")
+		fromTime       = flag.String("from-time", "", "Thời điểm bắt đầu (RFC3339), không dùng cùng -from-offset/-to-offset")
+		toTime         = flag.String("to-time", "", "Thời điểm kết thúc (RFC3339), không bao gồm")
+		conversationID = flag.String("conversation", "", "Chỉ replay event của conversation này")
+		dbDriver       = flag.String("db-driver", "", "Driver của database đích: postgres hoặc sqlite (mặc định DB_DRIVER)")
+		dbDSN          = flag.String("db-dsn", "", "DSN của database đích (bắt buộc khi không dry-run)")
+		dryRun         = flag.Bool("dry-run", false, "Chỉ đọc và decode event, không ghi database")
+		progressEvery  = flag.Duration("progress", 5*time.Second, "Chu kỳ báo cáo tiến độ")
+		configFlags    = config.BindFlags(flag.CommandLine)
+	)
+	flag.Parse()
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-replay", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	replayConfig := &kafka.ReplayConfig{
+		Brokers:          cfg.Kafka.Brokers,
+		Topic:            cfg.Kafka.MessageTopic,
+		StartOffset:      *fromOffset,
+		EndOffset:        *toOffset,
+		ConversationID:   *conversationID,
+		DryRun:           *dryRun,
+		ProgressInterval: *progressEvery,
+		Progress:         printProgress,
+	}
+	if *brokers != "" {
+		replayConfig.Brokers = strings.Split(*brokers, ",")
+	}
+	if *topic != "" {
+		replayConfig.Topic = *topic
+	}
+	if *dbDriver == "" {
+		*dbDriver = cfg.Database.Driver
+	}
+
+	if replayConfig.Partitions, err = parsePartitions(*partitions); err != nil {
+		logging.Fatal("-partitions không hợp lệ", logging.Err(err))
+	}
+	if replayConfig.StartTime, err = parseTime(*fromTime); err != nil {
+		logging.Fatal("-from-time không hợp lệ", logging.Err(err))
+	}
+	if replayConfig.EndTime, err = parseTime(*toTime); err != nil {
+		logging.Fatal("-to-time không hợp lệ", logging.Err(err))
+	}
+	if replayConfig.Security, err = kafka.NewSecurityConfig(cfg.Kafka); err != nil {
+		logging.Fatal("Cấu hình Kafka không hợp lệ", logging.Err(err))
+	}
+
+	var store *db.Store
+	if !*dryRun {
+		if *dbDSN == "" {
+			logging.Fatal("-db-dsn bắt buộc khi không chạy -dry-run")
+		}
+		database, err := db.OpenDatabase(*dbDriver, *dbDSN, cfg.Database.SlowQueryThreshold)
+		if err != nil {
+			logging.Fatal("Lỗi kết nối database đích", logging.Err(err))
+		}
+		store = db.NewStore(database)
+	}
+
+	replayer, err := kafka.NewReplayer(replayConfig, store)
+	if err != nil {
+		logging.Fatal("Lỗi khởi tạo replay", logging.Err(err))
+	}
+	defer replayer.Close()
+
+	// Ctrl+C dừng replay, các event đã xử lý vẫn được giữ lại
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	start := time.Now()
+	stats, err := replayer.Run(ctx)
+	if err != nil {
+		logging.Fatal("Replay dừng", "duration", time.Since(start).Round(time.Millisecond), logging.Err(err))
+	}
+
+	slog.Info("Replay hoàn tất", "duration", time.Since(start).Round(time.Millisecond),
+		"read", stats.Read, "processed", stats.Processed, "filtered", stats.Filtered,
+		"decode_errors", stats.DecodeErrors, "process_errors", stats.ProcessErrors)
+	if stats.ProcessErrors > 0 {
+		os.Exit(2)
+	}
+}
+
+// printProgress in tiến độ replay
+func printProgress(stats kafka.ReplayStats) {
+	percent := 100.0
+	if stats.Total > 0 {
+		percent = float64(stats.Read) / float64(stats.Total) * 100
+	}
+	slog.Info("Tiến độ replay", "read", stats.Read, "total", stats.Total,
+		"percent", fmt.Sprintf("%.1f", percent), "processed", stats.Processed, "filtered", stats.Filtered,
+		"errors", stats.DecodeErrors+stats.ProcessErrors)
+}
+
+// parsePartitions parse danh sách partition phân cách bởi dấu phẩy
+func parsePartitions(value string) ([]int32, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var partitions []int32
+	for _, part := range strings.Split(value, ",") {
+		partition, err := strconv.ParseInt(strings.TrimSpace(part), 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("partition %q không hợp lệ", part)
+		}
+		partitions = append(partitions, int32(partition))
+	}
+	return partitions, nil
+}
+
+// parseTime parse thời điểm RFC3339, chuỗi rỗng trả về zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/retention"
+)
+
+// retention quản lý retention và legal hold của conversation, hoặc chạy job purge một lần.
+// Retention mặc định của workspace cấu hình bằng RETENTION_DEFAULT_DAYS.
+//
+// Cách dùng:
+//
+//	go run ./cmd/retention policies
+//	go run ./cmd/retention set -conversation ID -days N    (0 là giữ vĩnh viễn)
+//	go run ./cmd/retention set -conversation ID -default   (dùng mặc định của workspace)
+//	go run ./cmd/retention hold -conversation ID [-release]
+//	go run ./cmd/retention purge
+func main() {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	conversationID := flags.String("conversation", "", "set/hold: conversation ID")
+	days := flags.Int("days", -1, "set: số ngày giữ tin nhắn, 0 là giữ vĩnh viễn")
+	useDefault := flags.Bool("default", false, "set: bỏ retention riêng, dùng mặc định của workspace")
+	release := flags.Bool("release", false, "hold: gỡ legal hold")
+	actor := flags.String("actor", audit.CLIActor(), "người thực hiện, ghi vào audit log")
+	configFlags := config.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Cách dùng: retention <policies|set|hold|purge> [flags]")
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-retention", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	database, err := db.Connect(cfg.Database.Driver, cfg.Database.DSN(), cfg.Database.SlowQueryThreshold)
+	if err != nil {
+		logging.Fatal("Lỗi kết nối database", logging.Err(err))
+	}
+	store := db.NewStore(database)
+	recorder, err := audit.Setup(cfg, store)
+	if err != nil {
+		logging.Fatal("Lỗi khởi tạo audit log", logging.Err(err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	switch command {
+	case "policies":
+		printPolicies(ctx, store.Retention, cfg.Retention)
+	case "set":
+		requireConversation(*conversationID)
+		var value *int
+		switch {
+		case *useDefault:
+		case *days >= 0:
+			value = days
+		default:
+			logging.Fatal("Cần -days N hoặc -default")
+		}
+		before := currentPolicy(ctx, store, *conversationID)
+		checkUpdate(store.Retention.SetRetention(ctx, *conversationID, value), *conversationID)
+		recordChange(ctx, recorder, *actor, audit.ActionRetentionSet, *conversationID, before,
+			map[string]interface{}{"retention_days": value})
+		fmt.Printf("Đã đặt retention cho %s: %s\n", *conversationID, formatDays(value, cfg.Retention))
+	case "hold":
+		requireConversation(*conversationID)
+		before := currentPolicy(ctx, store, *conversationID)
+		checkUpdate(store.Retention.SetLegalHold(ctx, *conversationID, !*release), *conversationID)
+		recordChange(ctx, recorder, *actor, audit.ActionLegalHoldSet, *conversationID, before,
+			map[string]interface{}{"legal_hold": !*release})
+		if *release {
+			fmt.Printf("Đã gỡ legal hold cho %s\n", *conversationID)
+		} else {
+			fmt.Printf("Đã đặt legal hold cho %s\n", *conversationID)
+		}
+	case "purge":
+		result, err := retention.NewPurger(store.Retention, cfg.Retention).PurgeOnce(ctx)
+		if err != nil {
+			logging.Fatal("Purge thất bại", "expired", result.Expired, "deleted", result.Deleted, logging.Err(err))
+		}
+		err = recorder.Record(ctx, audit.Entry{
+			ActorID:    *actor,
+			Action:     audit.ActionRetentionPurge,
+			TargetType: audit.TargetWorkspace,
+			TargetID:   "default",
+			After:      map[string]interface{}{"expired": result.Expired, "deleted": result.Deleted},
+		})
+		if err != nil {
+			logging.Fatal("Lỗi ghi audit log", logging.Err(err))
+		}
+		fmt.Printf("Đã xóa vĩnh viễn %d tin nhắn hết hạn, %d tin nhắn đã xóa\n", result.Expired, result.Deleted)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func requireConversation(conversationID string) {
+	if conversationID == "" {
+		logging.Fatal("Thiếu -conversation")
+	}
+}
+
+// checkUpdate thoát với thông báo rõ ràng khi cập nhật conversation thất bại
+func checkUpdate(err error, conversationID string) {
+	if errors.Is(err, db.ErrNotFound) {
+		logging.Fatal("Không tìm thấy conversation", "conversation_id", conversationID)
+	}
+	if err != nil {
+		logging.Fatal("Lỗi cập nhật conversation", "conversation_id", conversationID, logging.Err(err))
+	}
+}
+
+// currentPolicy đọc retention hiện tại của conversation để ghi vào audit log
+func currentPolicy(ctx context.Context, store *db.Store, conversationID string) map[string]interface{} {
+	conversation, err := store.Conversations.Get(ctx, conversationID)
+	checkUpdate(err, conversationID)
+	return map[string]interface{}{"retention_days": conversation.RetentionDays, "legal_hold": conversation.LegalHold}
+}
+
+// recordChange ghi thay đổi retention của conversation vào audit log. Thay đổi đã được áp dụng
+// nên lỗi ghi audit log chỉ dừng chương trình với mã lỗi để operator biết và ghi bù.
+func recordChange(ctx context.Context, recorder *audit.Recorder, actor, action, conversationID string, before, after map[string]interface{}) {
+	err := recorder.Record(ctx, audit.Entry{
+		ActorID:        actor,
+		Action:         action,
+		TargetType:     audit.TargetConversation,
+		TargetID:       conversationID,
+		ConversationID: conversationID,
+		Before:         before,
+		After:          after,
+	})
+	if err != nil {
+		logging.Fatal("Đã cập nhật conversation nhưng lỗi ghi audit log", "conversation_id", conversationID, logging.Err(err))
+	}
+}
+
+// printPolicies in các conversation có retention riêng hoặc đang legal hold
+func printPolicies(ctx context.Context, repository db.RetentionRepository, settings config.RetentionConfig) {
+	policies, err := repository.ListPolicies(ctx)
+	if err != nil {
+		logging.Fatal("Lỗi đọc retention", logging.Err(err))
+	}
+
+	defaultDays := settings.DefaultDays
+	fmt.Printf("Mặc định của workspace: %s\n\n", formatDays(&defaultDays, settings))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CONVERSATION\tRETENTION\tLEGAL HOLD")
+	for _, policy := range policies {
+		fmt.Fprintf(writer, "%s\t%s\t%t\n", policy.ConversationID, formatDays(policy.RetentionDays, settings), policy.LegalHold)
+	}
+	writer.Flush()
+}
+
+// formatDays hiển thị retention, nil là mặc định của workspace
+func formatDays(days *int, settings config.RetentionConfig) string {
+	if days == nil {
+		if settings.DefaultDays == 0 {
+			return "mặc định (vĩnh viễn)"
+		}
+		return "mặc định (" + strconv.Itoa(settings.DefaultDays) + " ngày)"
+	}
+	if *days == 0 {
+		return "vĩnh viễn"
+	}
+	return strconv.Itoa(*days) + " ngày"
+}
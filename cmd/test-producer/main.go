@@ -1,28 +1,67 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"vibeta/internal/config"
 	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
 	"vibeta/internal/models"
+	"vibeta/internal/tracing"
 )
 
+// customPayload là event type không có trong registry, dùng để test validation
+type customPayload struct {
+	Field string `json:"custom_field"`
+}
+
+func (customPayload) EventType() kafka.EventType { return "custom_event" }
+
 func main() {
-	log.Println("Testing Kafka Producer...")
+	configFlags := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+
+	if err := logging.Setup("vibeta-test-producer", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+	slog.Info("Testing Kafka Producer...")
+
+	// TLS/SASL lấy từ cùng các biến KAFKA_* như server và worker
+	security, err := kafka.NewSecurityConfig(cfg.Kafka)
+	if err != nil {
+		logging.Fatal("Cấu hình Kafka không hợp lệ", logging.Err(err))
+	}
 
 	// Test config
-	config := &kafka.ProducerConfig{
-		Brokers: []string{"localhost:9092"},
-		Topic:   "chat_messages",
+	producerConfig := &kafka.ProducerConfig{
+		Brokers:  cfg.Kafka.Brokers,
+		Topic:    cfg.Kafka.MessageTopic,
+		Encoding: cfg.Kafka.EventEncoding,
+		Security: security,
 	}
 
 	// Create producer
-	producer, err := kafka.NewProducer(config)
+	ctx := context.Background()
+	shutdownTracing, err := tracing.Setup(ctx, "vibeta-test-producer", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Lỗi cấu hình tracing", logging.Err(err))
+	}
+	defer shutdownTracing(ctx)
+
+	producer, err := kafka.NewProducer(producerConfig)
 	if err != nil {
-		log.Fatalf("Failed to create producer: %v", err)
+		logging.Fatal("Failed to create producer", logging.Err(err))
 	}
 	defer producer.Close()
 
@@ -38,40 +77,29 @@ func main() {
 	}
 
 	// Send message
-	err = producer.PublishChatMessage(wsMsg, "test_user")
+	err = producer.PublishChatMessage(ctx, wsMsg, "test_user")
 	if err != nil {
-		log.Printf("Failed to send message: %v", err)
+		slog.Error("Failed to send message", logging.Err(err))
 	} else {
-		log.Println("Message sent successfully!")
+		slog.Info("Message sent successfully!")
 	}
 
 	// Test reaction
-	err = producer.PublishReaction("test_msg_001", "test_user", "😀", "add", "test_conversation")
+	err = producer.PublishReaction(ctx, "test_msg_001", "test_user", "😀", "add", "test_conversation")
 	if err != nil {
-		log.Printf("Failed to send reaction: %v", err)
+		slog.Error("Failed to send reaction", logging.Err(err))
 	} else {
-		log.Println("Reaction sent successfully!")
+		slog.Info("Reaction sent successfully!")
 	}
 
-	// Test with custom event
-	event := &kafka.MessageEvent{
-		Type:           "custom_event",
-		MessageID:      "custom_001",
-		ConversationID: "test_conversation",
-		SenderID:       "test_user",
-		Content:        "Custom event content",
-		MessageType:    "custom",
-		Metadata: map[string]interface{}{
-			"custom_field": "custom_value",
-		},
-		Timestamp: time.Now(),
-	}
+	// Event type chưa đăng ký trong registry phải bị producer từ chối
+	event := kafka.NewEvent("test_conversation", customPayload{Field: "custom_value"})
 
-	err = producer.PublishMessage(event)
-	if err != nil {
-		log.Printf("Failed to send custom event: %v", err)
+	err = producer.Publish(ctx, event)
+	if errors.Is(err, kafka.ErrUnknownEventType) {
+		slog.Info("Custom event rejected as expected", logging.Err(err))
 	} else {
-		log.Println("Custom event sent successfully!")
+		slog.Error("Custom event was not rejected", logging.Err(err))
 	}
 
 	// Health check simulation
@@ -84,5 +112,5 @@ func main() {
 	healthBytes, _ := json.Marshal(healthData)
 	fmt.Printf("Health check data: %s\n", string(healthBytes))
 
-	log.Println("Kafka producer test completed!")
+	slog.Info("Kafka producer test completed!")
 }
@@ -2,32 +2,72 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"vibeta/internal/config"
 	"vibeta/internal/db"
+	"vibeta/internal/health"
 	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+	"vibeta/internal/metrics"
+	"vibeta/internal/retention"
+	"vibeta/internal/tracing"
 )
 
 func main() {
-	log.Println("Starting Message Worker Service...")
+	configFlags := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-worker", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+	slog.Info("Starting Message Worker Service...")
+
+	// Tracing: span của worker nối tiếp trace từ Kafka headers
+	shutdownTracing, err := tracing.Setup(context.Background(), "vibeta-worker", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Lỗi cấu hình tracing", logging.Err(err))
+	}
+
+	// Bus in-memory chỉ có ý nghĩa khi producer và consumer cùng process
+	if cfg.Kafka.Bus == kafka.BusMemory {
+		logging.Fatal("MESSAGE_BUS=memory chỉ dùng cho chế độ all-in-one của WebSocket server (make run-allinone)")
+	}
+
+	serviceConfig, err := kafka.NewServiceConfig(cfg.Kafka)
+	if err != nil {
+		logging.Fatal("Lỗi cấu hình Kafka", logging.Err(err))
+	}
 
 	// Khởi tạo database
-	database := db.NewDatabase()
-	if database == nil {
-		log.Fatal("Không thể kết nối database")
+	database, err := db.NewDatabase(context.Background(), cfg.Database)
+	if err != nil {
+		logging.Fatal("Không thể kết nối database", "driver", cfg.Database.Driver, logging.Err(err))
 	}
+	store := db.NewStore(database)
 
 	// Khởi tạo Kafka message service (chỉ consumer)
-	os.Setenv("KAFKA_ENABLE_PRODUCER", "false") // Worker chỉ cần consumer
-	os.Setenv("KAFKA_ENABLE_CONSUMER", "true")
+	serviceConfig.EnableConsumer = true
 
-	messageService, err := kafka.NewMessageService(database)
+	messageService, err := kafka.NewMessageService(store, serviceConfig)
 	if err != nil {
-		log.Fatalf("Lỗi khởi tạo message service: %v", err)
+		logging.Fatal("Lỗi khởi tạo message service", logging.Err(err))
 	}
 	defer messageService.Close()
 
@@ -37,19 +77,37 @@ func main() {
 
 	// Khởi động consumer
 	if err := messageService.StartConsumer(ctx); err != nil {
-		log.Fatalf("Lỗi khởi động consumer: %v", err)
+		logging.Fatal("Lỗi khởi động consumer", logging.Err(err))
+	}
+
+	// Xóa vĩnh viễn tin nhắn hết hạn lưu trữ
+	if cfg.Retention.Enabled {
+		go retention.NewPurger(store.Retention, cfg.Retention).Run(ctx)
 	}
 
+	// Tạo sẵn partition theo tháng nếu bảng messages trên PostgreSQL đã được partition
+	if partitions, err := db.NewPartitionManager(database, cfg.Database.PartitionPremakeMonths); err == nil {
+		go partitions.Run(ctx, cfg.Database.PartitionCheckInterval)
+	}
+
+	// Health server: /livez, /readyz và /health (kèm consumer lag)
+	healthServer := newHealthServer(store, messageService, cfg.Worker.HealthAddr)
+	go func() {
+		slog.Info("Worker health server đang chạy", "addr", healthServer.Addr)
+		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Lỗi health server", logging.Err(err))
+		}
+	}()
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("Message Worker Service đã khởi động. Đang lắng nghe messages...")
-	log.Println("Nhấn Ctrl+C để dừng...")
+	slog.Info("Message Worker Service đã khởi động. Đang lắng nghe messages...")
 
 	// Chờ signal để shutdown
 	<-sigChan
-	log.Println("Nhận được signal shutdown...")
+	slog.Info("Nhận được signal shutdown...")
 
 	// Graceful shutdown với timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -58,6 +116,10 @@ func main() {
 	// Cancel main context
 	cancel()
 
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Health server shutdown error", logging.Err(err))
+	}
+
 	// Đợi service shutdown hoàn tất
 	done := make(chan bool, 1)
 	go func() {
@@ -67,8 +129,43 @@ func main() {
 
 	select {
 	case <-done:
-		log.Println("Message Worker Service đã shutdown thành công")
+		slog.Info("Message Worker Service đã shutdown thành công")
 	case <-shutdownCtx.Done():
-		log.Println("Shutdown timeout, force exit")
+		slog.Warn("Shutdown timeout, force exit")
+	}
+
+	// Flush các span còn lại sau khi pool đã xử lý xong
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Tracing shutdown error", logging.Err(err))
+	}
+}
+
+// newHealthServer tạo HTTP server phục vụ liveness/readiness của worker.
+// Worker chỉ ready khi cả database và Kafka đều sẵn sàng.
+func newHealthServer(store *db.Store, messageService *kafka.MessageService, addr string) *http.Server {
+	checker := health.NewChecker(5 * time.Second)
+	checker.AddReadinessCheck("database", store.Ping)
+	checker.AddReadinessCheck("kafka", messageService.CheckConnectivity)
+
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		report := messageService.HealthCheck(ctx)
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != kafka.HealthStatusHealthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+
+	return &http.Server{
+		Addr:     addr,
+		Handler:  logging.Middleware(mux),
+		ErrorLog: logging.StdLogger(slog.LevelError),
 	}
 }
@@ -0,0 +1,204 @@
+// Package audit ghi nhật ký chỉ thêm (append-only) của các thao tác quản trị và liên quan
+// bảo mật: ai làm gì, trên đối tượng nào, trạng thái trước/sau và thông tin request.
+// Bản ghi được lưu vào bảng audit_logs, tùy chọn kèm event audit_logged qua outbox.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// Các action được ghi vào audit log
+const (
+	ActionConversationCreate = "conversation.create"
+	ActionConversationExport = "conversation.export"
+	ActionMessageDelete      = "message.delete"
+	ActionRetentionSet       = "retention.set"
+	ActionLegalHoldSet       = "legal_hold.set"
+	ActionRetentionPurge     = "retention.purge"
+	ActionUserExport         = "user.export"
+	ActionUserErase          = "user.erase"
+	ActionImport             = "import.run"
+	ActionClientDisconnect   = "client.disconnect"
+	ActionAnnouncement       = "announcement.broadcast"
+	ActionConversationRename = "conversation.rename"
+	ActionMemberAdd          = "member.add"
+	ActionMemberRemove       = "member.remove"
+	ActionRoleChange         = "role.change"
+	ActionMessagePin         = "message.pin"
+	ActionMessageUnpin       = "message.unpin"
+)
+
+// Loại đối tượng bị tác động
+const (
+	TargetConversation = "conversation"
+	TargetMessage      = "message"
+	TargetUser         = "user"
+	TargetWorkspace    = "workspace"
+	TargetConnection   = "connection"
+)
+
+// Entry một thao tác cần ghi
+type Entry struct {
+	ActorID        string
+	Action         string
+	TargetType     string
+	TargetID       string
+	ConversationID string
+	// Before và After được encode JSON, nil để bỏ trống
+	Before any
+	After  any
+}
+
+// Request thông tin request gây ra thao tác
+type Request struct {
+	ID        string
+	IP        string
+	UserAgent string
+}
+
+// requestKey key lưu Request trong context
+type requestKey struct{}
+
+// WithRequest gắn thông tin request vào context để Recorder ghi cùng bản ghi
+func WithRequest(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+// RequestFromContext trả về thông tin request đã gắn bằng WithRequest
+func RequestFromContext(ctx context.Context) Request {
+	request, _ := ctx.Value(requestKey{}).(Request)
+	return request
+}
+
+// NewRequest đọc request ID (do logging.Middleware gắn), IP và User-Agent của HTTP request.
+// IP lấy từ kết nối TCP, không tin header X-Forwarded-For do client tự đặt được.
+func NewRequest(r *http.Request) Request {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return Request{
+		ID:        logging.RequestID(r.Context()),
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// CLIActor actor của công cụ dòng lệnh: "cli:<user hệ điều hành>"
+func CLIActor() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return "cli:" + current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return "cli:" + name
+	}
+	return "cli:unknown"
+}
+
+// Recorder ghi bản ghi audit vào database và tùy chọn vào outbox
+type Recorder struct {
+	repository db.AuditRepository
+	outbox     *kafka.OutboxRelay
+}
+
+// NewRecorder tạo recorder; outbox nil thì chỉ ghi vào bảng audit_logs
+func NewRecorder(repository db.AuditRepository, outbox *kafka.OutboxRelay) *Recorder {
+	return &Recorder{repository: repository, outbox: outbox}
+}
+
+// Setup tạo recorder cho công cụ dòng lệnh, kèm outbox relay khi AUDIT_KAFKA_ENABLED=true
+func Setup(cfg *config.Config, store *db.Store) (*Recorder, error) {
+	if !cfg.Audit.KafkaEnabled {
+		return NewRecorder(store.Audit, nil), nil
+	}
+
+	serviceConfig, err := kafka.NewServiceConfig(cfg.Kafka)
+	if err != nil {
+		return nil, err
+	}
+	outbox, err := kafka.NewOutboxRelay(store.Outbox, serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(store.Audit, outbox), nil
+}
+
+// Record ghi một thao tác kèm thông tin request trong ctx. Event audit_logged (nếu bật)
+// được lưu cùng transaction với bản ghi.
+func (r *Recorder) Record(ctx context.Context, entry Entry) error {
+	before, err := encodeState(entry.Before)
+	if err != nil {
+		return fmt.Errorf("lỗi encode trạng thái trước: %w", err)
+	}
+	after, err := encodeState(entry.After)
+	if err != nil {
+		return fmt.Errorf("lỗi encode trạng thái sau: %w", err)
+	}
+
+	request := RequestFromContext(ctx)
+	if request.ID == "" {
+		request.ID = logging.RequestID(ctx)
+	}
+	log := &models.AuditLog{
+		OccurredAt:     time.Now().UTC(),
+		ActorID:        entry.ActorID,
+		Action:         entry.Action,
+		TargetType:     entry.TargetType,
+		TargetID:       entry.TargetID,
+		ConversationID: entry.ConversationID,
+		Before:         before,
+		After:          after,
+		RequestID:      request.ID,
+		IP:             request.IP,
+		UserAgent:      request.UserAgent,
+	}
+
+	var event *models.OutboxEvent
+	if r.outbox != nil {
+		envelope := kafka.NewEvent(entry.ConversationID, &kafka.AuditLoggedPayload{
+			ActorID:    log.ActorID,
+			Action:     log.Action,
+			TargetType: log.TargetType,
+			TargetID:   log.TargetID,
+			Before:     log.Before,
+			After:      log.After,
+			RequestID:  log.RequestID,
+			IP:         log.IP,
+			UserAgent:  log.UserAgent,
+		})
+		envelope.Timestamp = log.OccurredAt
+		if event, err = r.outbox.NewOutboxEvent(ctx, envelope); err != nil {
+			return fmt.Errorf("lỗi tạo event audit_logged: %w", err)
+		}
+	}
+
+	if err := r.repository.Append(ctx, log, event); err != nil {
+		return fmt.Errorf("lỗi ghi audit log %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// encodeState encode trạng thái thành JSON, nil thành chuỗi rỗng
+func encodeState(state any) (string, error) {
+	if state == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
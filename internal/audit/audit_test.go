@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/db/dbtest"
+	"vibeta/internal/kafka"
+	"vibeta/internal/models"
+)
+
+// recordedEntries trả về các bản ghi audit, mới nhất trước
+func recordedEntries(t *testing.T, store *db.Store) []models.AuditLog {
+	t.Helper()
+	page, err := store.Audit.List(context.Background(), db.AuditQuery{})
+	if err != nil {
+		t.Fatalf("Audit.List: %v", err)
+	}
+	return page.Entries
+}
+
+func TestNewRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/announcements", nil)
+	r.RemoteAddr = "203.0.113.7:52100"
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	request := NewRequest(r)
+	if request.IP != "203.0.113.7" {
+		t.Errorf("IP = %q, muốn IP của kết nối 203.0.113.7 thay vì X-Forwarded-For", request.IP)
+	}
+	if request.UserAgent != "curl/8.0" {
+		t.Errorf("UserAgent = %q, muốn curl/8.0", request.UserAgent)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	store := dbtest.NewStore(t)
+	recorder := NewRecorder(store.Audit, nil)
+
+	ctx := WithRequest(context.Background(), Request{ID: "req-1", IP: "203.0.113.7", UserAgent: "curl/8.0"})
+	err := recorder.Record(ctx, Entry{
+		ActorID:        "admin:ops",
+		Action:         ActionConversationRename,
+		TargetType:     TargetConversation,
+		TargetID:       "project",
+		ConversationID: "project",
+		Before:         map[string]string{"name": "Cũ"},
+		After:          map[string]string{"name": "Mới"},
+	})
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := recorder.Record(context.Background(), Entry{
+		ActorID: "cli:ops", Action: ActionRetentionPurge, TargetType: TargetWorkspace, TargetID: "default",
+	}); err != nil {
+		t.Fatalf("Record không có request: %v", err)
+	}
+
+	entries := recordedEntries(t, store)
+	if len(entries) != 2 {
+		t.Fatalf("có %d bản ghi audit, muốn 2", len(entries))
+	}
+
+	cli, rename := entries[0], entries[1]
+	if rename.ActorID != "admin:ops" || rename.Action != ActionConversationRename || rename.TargetType != TargetConversation ||
+		rename.TargetID != "project" || rename.ConversationID != "project" {
+		t.Errorf("bản ghi đổi tên = %+v", rename)
+	}
+	if rename.Before != `{"name":"Cũ"}` || rename.After != `{"name":"Mới"}` {
+		t.Errorf("Before, After = %s, %s, muốn JSON trạng thái trước và sau", rename.Before, rename.After)
+	}
+	if rename.RequestID != "req-1" || rename.IP != "203.0.113.7" || rename.UserAgent != "curl/8.0" {
+		t.Errorf("thông tin request = %q, %q, %q, muốn req-1, 203.0.113.7, curl/8.0", rename.RequestID, rename.IP, rename.UserAgent)
+	}
+	if rename.OccurredAt.IsZero() || rename.OccurredAt.Location() != time.UTC {
+		t.Errorf("OccurredAt = %v, muốn thời gian UTC", rename.OccurredAt)
+	}
+
+	// Trạng thái nil và request không có thông tin được để trống
+	if cli.Before != "" || cli.After != "" || cli.RequestID != "" || cli.IP != "" {
+		t.Errorf("bản ghi CLI = %+v, muốn Before, After và thông tin request rỗng", cli)
+	}
+}
+
+func TestRecordWithOutbox(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore(t)
+	outbox, err := kafka.NewOutboxRelay(store.Outbox, &kafka.ServiceConfig{EventEncoding: kafka.EncodingJSON})
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	recorder := NewRecorder(store.Audit, outbox)
+
+	if err := recorder.Record(ctx, Entry{
+		ActorID: "admin:ops", Action: ActionClientDisconnect, TargetType: TargetUser, TargetID: "member",
+	}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	if entries := recordedEntries(t, store); len(entries) != 1 {
+		t.Fatalf("có %d bản ghi audit, muốn 1", len(entries))
+	}
+	events, err := store.Outbox.ClaimBatch(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	if len(events) != 1 || events[0].EventType != string(kafka.EventTypeAuditLogged) {
+		t.Fatalf("outbox có %d event, muốn một event %s", len(events), kafka.EventTypeAuditLogged)
+	}
+}
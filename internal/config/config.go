@@ -0,0 +1,424 @@
+// Package config load cấu hình của WebSocket server, worker và các tool dòng lệnh.
+//
+// Thứ tự ưu tiên (cao tới thấp):
+//
+//  1. Environment variables của process
+//  2. File .env (mặc định ./.env nếu có, hoặc ENV_FILE / -env-file)
+//  3. File YAML (CONFIG_FILE / -config), không bắt buộc
+//  4. Giá trị mặc định trong Default
+//
+// Mỗi field có tag `env` là tên biến môi trường và tag `yaml` là key trong file YAML,
+// xem config.example.yaml. Field có tag `secret:"true"` bị che khi Dump.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Các giá trị của ENV
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Các giá trị của DB_DRIVER
+const (
+	DBDriverPostgres     = "postgres"
+	DBDriverSQLite       = "sqlite"
+	DBDriverSQLiteMemory = "sqlite-memory" // SQLite in-memory, mất dữ liệu khi process dừng
+)
+
+// sqliteMemoryDSN DSN của database SQLite in-memory
+const sqliteMemoryDSN = "file::memory:?cache=shared"
+
+// Config cấu hình đầy đủ của ứng dụng
+type Config struct {
+	Env string `yaml:"env" env:"ENV"`
+
+	Server    ServerConfig    `yaml:"server"`
+	Worker    WorkerConfig    `yaml:"worker"`
+	Hub       HubConfig       `yaml:"hub"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Retention RetentionConfig `yaml:"retention"`
+	Audit     AuditConfig     `yaml:"audit"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+}
+
+// ServerConfig cấu hình HTTP/WebSocket server
+type ServerConfig struct {
+	Port int `yaml:"port" env:"SERVER_PORT"`
+}
+
+// Addr địa chỉ listen của server
+func (c ServerConfig) Addr() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+// WorkerConfig cấu hình message worker
+type WorkerConfig struct {
+	HealthAddr string `yaml:"health_addr" env:"WORKER_HEALTH_ADDR"`
+}
+
+// HubConfig cấu hình WebSocket hub
+type HubConfig struct {
+	ReadBufferSize  int `yaml:"read_buffer_size" env:"HUB_READ_BUFFER_SIZE"`
+	WriteBufferSize int `yaml:"write_buffer_size" env:"HUB_WRITE_BUFFER_SIZE"`
+	SendBufferSize  int `yaml:"send_buffer_size" env:"HUB_SEND_BUFFER_SIZE"` // Số frame chờ gửi mỗi client trước khi bị ngắt
+	HistoryLimit    int `yaml:"history_limit" env:"HUB_HISTORY_LIMIT"`       // Số tin nhắn gửi lại khi join conversation
+}
+
+// AdminConfig cấu hình admin API (/api/admin/*) của WebSocket server
+type AdminConfig struct {
+	// Tokens map tên admin -> bearer token, rỗng thì admin API bị tắt.
+	// Tên admin được ghi làm actor trong audit log.
+	Tokens map[string]string `yaml:"tokens" env:"ADMIN_TOKENS" secret:"true"`
+	// WorkerHealthURLs địa chỉ /health của các worker để admin API tổng hợp trạng thái consumer
+	WorkerHealthURLs []string `yaml:"worker_health_urls" env:"ADMIN_WORKER_HEALTH_URLS"`
+}
+
+// minAdminTokenLength độ dài tối thiểu của admin token
+const minAdminTokenLength = 16
+
+// DatabaseConfig cấu hình database
+type DatabaseConfig struct {
+	Driver     string `yaml:"driver" env:"DB_DRIVER"` // postgres, sqlite hoặc sqlite-memory
+	Host       string `yaml:"host" env:"DB_HOST"`
+	Port       int    `yaml:"port" env:"DB_PORT"`
+	User       string `yaml:"user" env:"DB_USER"`
+	Password   string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name       string `yaml:"name" env:"DB_NAME"`
+	SSLMode    string `yaml:"ssl_mode" env:"DB_SSL_MODE"`
+	TimeZone   string `yaml:"timezone" env:"DB_TIMEZONE"`
+	SQLitePath string `yaml:"sqlite_path" env:"DB_SQLITE_PATH"`
+
+	// Câu SQL chậm hơn ngưỡng này được log ở level warn, 0 để tắt
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD"`
+
+	// AutoMigrate áp dụng migration còn thiếu khi khởi động; tắt thì binary chỉ kiểm tra
+	// schema đã ở version mới nhất và migration được chạy riêng bằng cmd/migrate
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+
+	// Connection pool, 0 là không giới hạn. SQLite in-memory luôn dùng một kết nối.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	// Số lần thử lại khi không kết nối được PostgreSQL lúc khởi động. Thời gian chờ
+	// bắt đầu từ ConnectBackoff và tăng gấp đôi sau mỗi lần, tối đa 30s.
+	ConnectRetries int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES"`
+	ConnectBackoff time.Duration `yaml:"connect_backoff" env:"DB_CONNECT_BACKOFF"`
+
+	// SQLiteFallback dùng SQLite (SQLitePath) khi hết lượt thử kết nối PostgreSQL.
+	// Chỉ dành cho development, không được bật khi ENV=production.
+	SQLiteFallback bool `yaml:"sqlite_fallback" env:"DB_SQLITE_FALLBACK"`
+
+	// Khi bảng messages trên PostgreSQL đã được partition theo tháng (cmd/partition),
+	// worker kiểm tra mỗi PartitionCheckInterval và tạo sẵn partition cho
+	// PartitionPremakeMonths tháng tiếp theo
+	PartitionPremakeMonths int           `yaml:"partition_premake_months" env:"DB_PARTITION_PREMAKE_MONTHS"`
+	PartitionCheckInterval time.Duration `yaml:"partition_check_interval" env:"DB_PARTITION_CHECK_INTERVAL"`
+
+	// ReplicaDSNs DSN của các read replica PostgreSQL. Truy vấn chỉ đọc (lịch sử, danh sách,
+	// tìm kiếm) được chia round robin cho các replica khỏe; replica không ping được hoặc trễ
+	// quá ReplicaMaxLag bị bỏ qua cho tới lần kiểm tra sau. User vừa ghi đọc từ primary
+	// trong ReadYourWritesWindow để thấy ngay thay đổi của mình.
+	ReplicaDSNs           []string      `yaml:"replica_dsns" env:"DB_REPLICA_DSNS" secret:"true"`
+	ReplicaHealthInterval time.Duration `yaml:"replica_health_interval" env:"DB_REPLICA_HEALTH_INTERVAL"`
+	ReplicaMaxLag         time.Duration `yaml:"replica_max_lag" env:"DB_REPLICA_MAX_LAG"`
+	ReadYourWritesWindow  time.Duration `yaml:"read_your_writes_window" env:"DB_READ_YOUR_WRITES_WINDOW"`
+}
+
+// DSN DSN theo Driver: PostgresDSN với postgres, SQLitePath với sqlite
+func (c DatabaseConfig) DSN() string {
+	switch c.Driver {
+	case DBDriverSQLite:
+		return c.SQLitePath
+	case DBDriverSQLiteMemory:
+		return sqliteMemoryDSN
+	}
+	return c.PostgresDSN()
+}
+
+// PostgresDSN DSN kết nối PostgreSQL dạng key=value. Mọi giá trị được đặt trong nháy đơn
+// và escape theo quy tắc của libpq, để password chứa khoảng trắng, ' hoặc = vẫn đúng.
+func (c DatabaseConfig) PostgresDSN() string {
+	pairs := []struct{ key, value string }{
+		{"host", c.Host},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.Name},
+		{"port", strconv.Itoa(c.Port)},
+		{"sslmode", c.SSLMode},
+		{"TimeZone", c.TimeZone},
+	}
+	parts := make([]string, len(pairs))
+	for i, pair := range pairs {
+		parts[i] = pair.key + "='" + dsnValueEscaper.Replace(pair.value) + "'"
+	}
+	return strings.Join(parts, " ")
+}
+
+// dsnValueEscaper escape \ và ' trong giá trị đặt giữa nháy đơn của DSN key=value
+var dsnValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// RetentionConfig cấu hình job xóa vĩnh viễn tin nhắn hết hạn lưu trữ trong worker.
+// DefaultDays áp dụng cho cả workspace; conversation có thể đặt retention riêng hoặc
+// legal hold (cmd/retention) để không bao giờ bị xóa.
+type RetentionConfig struct {
+	Enabled bool `yaml:"enabled" env:"RETENTION_ENABLED"`
+	// Số ngày giữ tin nhắn, 0 là giữ vĩnh viễn
+	DefaultDays int `yaml:"default_days" env:"RETENTION_DEFAULT_DAYS"`
+	// Tin nhắn đã xóa mềm quá khoảng này bị xóa vĩnh viễn, 0 để giữ lại
+	DeletedGrace time.Duration `yaml:"deleted_grace" env:"RETENTION_DELETED_GRACE"`
+
+	Interval   time.Duration `yaml:"interval" env:"RETENTION_INTERVAL"`
+	BatchSize  int           `yaml:"batch_size" env:"RETENTION_BATCH_SIZE"`
+	BatchPause time.Duration `yaml:"batch_pause" env:"RETENTION_BATCH_PAUSE"` // Nghỉ giữa các batch để giảm tải database
+}
+
+// AuditConfig cấu hình audit log của các thao tác quản trị và liên quan bảo mật.
+// Bản ghi luôn được lưu vào bảng audit_logs; Kafka là bản sao tùy chọn cho hệ thống bên ngoài.
+type AuditConfig struct {
+	// KafkaEnabled ghi thêm event audit_logged vào outbox cùng transaction với bản ghi,
+	// topic chọn theo KAFKA_TOPIC_ROUTES (mặc định chat_audit)
+	KafkaEnabled bool `yaml:"kafka_enabled" env:"AUDIT_KAFKA_ENABLED"`
+}
+
+// KafkaConfig cấu hình message bus, topic, outbox và bảo mật Kafka.
+// Các giá trị enum (bus, encoding, producer mode) được kiểm tra bởi kafka.NewServiceConfig.
+type KafkaConfig struct {
+	Bus           string   `yaml:"bus" env:"MESSAGE_BUS"` // kafka hoặc memory
+	Brokers       []string `yaml:"brokers" env:"KAFKA_BROKERS"`
+	MessageTopic  string   `yaml:"message_topic" env:"KAFKA_MESSAGE_TOPIC"`
+	ConsumerGroup string   `yaml:"consumer_group" env:"KAFKA_CONSUMER_GROUP"`
+	WorkerCount   int      `yaml:"worker_count" env:"KAFKA_WORKER_COUNT"`
+	EventEncoding string   `yaml:"event_encoding" env:"KAFKA_EVENT_ENCODING"`
+	ProducerMode  string   `yaml:"producer_mode" env:"KAFKA_PRODUCER_MODE"`
+	MaxInFlight   int      `yaml:"producer_max_in_flight" env:"KAFKA_PRODUCER_MAX_IN_FLIGHT"`
+
+	// Routing theo event type và cấu hình từng topic
+	TopicRoutes       map[string]string `yaml:"topic_routes" env:"KAFKA_TOPIC_ROUTES"`
+	TopicPartitions   map[string]int    `yaml:"topic_partitions" env:"KAFKA_TOPIC_PARTITIONS"`
+	TopicWorkers      map[string]int    `yaml:"topic_workers" env:"KAFKA_TOPIC_WORKERS"`
+	TopicPriorities   map[string]int    `yaml:"topic_priorities" env:"KAFKA_TOPIC_PRIORITIES"`
+	ReplicationFactor int               `yaml:"replication_factor" env:"KAFKA_REPLICATION_FACTOR"`
+
+	// Outbox relay
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+	OutboxBatchSize    int           `yaml:"outbox_batch_size" env:"OUTBOX_BATCH_SIZE"`
+	// OutboxLease thời gian relay giữ các event đã claim, phải đủ để publish một batch
+	OutboxLease time.Duration `yaml:"outbox_lease" env:"OUTBOX_LEASE"`
+	// Event publish lỗi được thử lại sau OutboxRetryBackoff, nhân đôi sau mỗi lần lỗi và tối đa
+	// OutboxMaxRetryBackoff; sau OutboxMaxAttempts lần lỗi event chuyển sang dead-letter
+	OutboxRetryBackoff    time.Duration `yaml:"outbox_retry_backoff" env:"OUTBOX_RETRY_BACKOFF"`
+	OutboxMaxRetryBackoff time.Duration `yaml:"outbox_max_retry_backoff" env:"OUTBOX_MAX_RETRY_BACKOFF"`
+	OutboxMaxAttempts     int           `yaml:"outbox_max_attempts" env:"OUTBOX_MAX_ATTEMPTS"`
+
+	// TLS, SASL và client ID
+	ClientID              string `yaml:"client_id" env:"KAFKA_CLIENT_ID"`
+	TLSEnabled            bool   `yaml:"tls_enabled" env:"KAFKA_TLS_ENABLED"`
+	TLSCAFile             string `yaml:"tls_ca_file" env:"KAFKA_TLS_CA_FILE"`
+	TLSCertFile           string `yaml:"tls_cert_file" env:"KAFKA_TLS_CERT_FILE"`
+	TLSKeyFile            string `yaml:"tls_key_file" env:"KAFKA_TLS_KEY_FILE"`
+	TLSServerName         string `yaml:"tls_server_name" env:"KAFKA_TLS_SERVER_NAME"`
+	TLSInsecureSkipVerify bool   `yaml:"tls_insecure_skip_verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY"`
+	SASLMechanism         string `yaml:"sasl_mechanism" env:"KAFKA_SASL_MECHANISM"`
+	SASLUsername          string `yaml:"sasl_username" env:"KAFKA_SASL_USERNAME"`
+	SASLPassword          string `yaml:"sasl_password" env:"KAFKA_SASL_PASSWORD" secret:"true"`
+	SASLPasswordFile      string `yaml:"sasl_password_file" env:"KAFKA_SASL_PASSWORD_FILE"`
+}
+
+// LogConfig cấu hình structured logging
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`   // debug, info, warn, error
+	Format string `yaml:"format" env:"LOG_FORMAT"` // json hoặc text
+}
+
+// TracingConfig cấu hình OpenTelemetry tracing. Các biến OTEL_EXPORTER_OTLP_* khác
+// (headers, TLS, endpoint riêng cho traces) vẫn được exporter đọc trực tiếp từ environment.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"` // otlp hoặc none
+	Endpoint     string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	SamplerRatio float64 `yaml:"sampler_ratio" env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+// Default trả về cấu hình mặc định cho môi trường development
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			Port: 8080,
+		},
+		Worker: WorkerConfig{
+			HealthAddr: ":8081",
+		},
+		Hub: HubConfig{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			SendBufferSize:  256,
+			HistoryLimit:    50,
+		},
+		Database: DatabaseConfig{
+			Driver:             DBDriverPostgres,
+			Host:               "localhost",
+			Port:               5432,
+			User:               "postgres",
+			Password:           "postgres",
+			Name:               "vibeta_chat",
+			SSLMode:            "disable",
+			TimeZone:           "Asia/Ho_Chi_Minh",
+			SQLitePath:         "vibeta_chat.db",
+			SlowQueryThreshold: 200 * time.Millisecond,
+			AutoMigrate:        true,
+			MaxOpenConns:       25,
+			MaxIdleConns:       10,
+			ConnMaxLifetime:    30 * time.Minute,
+			ConnMaxIdleTime:    5 * time.Minute,
+			ConnectRetries:     5,
+			ConnectBackoff:     time.Second,
+
+			PartitionPremakeMonths: 3,
+			PartitionCheckInterval: 6 * time.Hour,
+
+			ReplicaHealthInterval: 5 * time.Second,
+			ReplicaMaxLag:         30 * time.Second,
+			ReadYourWritesWindow:  10 * time.Second,
+		},
+		Retention: RetentionConfig{
+			Enabled:      true,
+			DeletedGrace: 30 * 24 * time.Hour,
+			Interval:     time.Hour,
+			BatchSize:    500,
+			BatchPause:   100 * time.Millisecond,
+		},
+		Kafka: KafkaConfig{
+			Bus:                   "kafka",
+			Brokers:               []string{"localhost:9092"},
+			MessageTopic:          "chat_messages",
+			ConsumerGroup:         "chat_message_processors",
+			WorkerCount:           4,
+			EventEncoding:         "json",
+			ProducerMode:          "sync",
+			MaxInFlight:           1024,
+			TopicRoutes:           map[string]string{"reaction": "chat_reactions", "audit_logged": "chat_audit"},
+			ReplicationFactor:     1,
+			OutboxPollInterval:    2 * time.Second,
+			OutboxBatchSize:       100,
+			OutboxLease:           time.Minute,
+			OutboxRetryBackoff:    time.Second,
+			OutboxMaxRetryBackoff: 10 * time.Minute,
+			OutboxMaxAttempts:     20,
+			ClientID:              "vibeta",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			SamplerRatio: 1,
+		},
+	}
+}
+
+// IsProduction cho biết ứng dụng chạy ở môi trường production
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// Validate kiểm tra các giá trị không phụ thuộc package khác.
+// Lỗi nêu tên biến môi trường tương ứng; mọi lỗi được gộp bằng errors.Join.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Env == EnvDevelopment || c.Env == EnvStaging || c.Env == EnvProduction,
+		"ENV=%q không hợp lệ (hỗ trợ %s, %s, %s)", c.Env, EnvDevelopment, EnvStaging, EnvProduction)
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "SERVER_PORT=%d phải trong khoảng 1..65535", c.Server.Port)
+	check(c.Worker.HealthAddr != "", "WORKER_HEALTH_ADDR không được rỗng")
+
+	check(c.Hub.ReadBufferSize > 0, "HUB_READ_BUFFER_SIZE phải lớn hơn 0")
+	check(c.Hub.WriteBufferSize > 0, "HUB_WRITE_BUFFER_SIZE phải lớn hơn 0")
+	check(c.Hub.SendBufferSize > 0, "HUB_SEND_BUFFER_SIZE phải lớn hơn 0")
+	check(c.Hub.HistoryLimit >= 0, "HUB_HISTORY_LIMIT không được âm")
+
+	names := make([]string, 0, len(c.Admin.Tokens))
+	for name := range c.Admin.Tokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check(name != "", "ADMIN_TOKENS: tên admin không được rỗng")
+		check(len(c.Admin.Tokens[name]) >= minAdminTokenLength,
+			"ADMIN_TOKENS: token của %q phải dài ít nhất %d ký tự", name, minAdminTokenLength)
+	}
+
+	check(c.Database.Driver == DBDriverPostgres || c.Database.Driver == DBDriverSQLite || c.Database.Driver == DBDriverSQLiteMemory,
+		"DB_DRIVER=%q không hợp lệ (hỗ trợ %s, %s, %s)", c.Database.Driver, DBDriverPostgres, DBDriverSQLite, DBDriverSQLiteMemory)
+	if c.Database.Driver == DBDriverPostgres {
+		check(c.Database.Host != "", "DB_HOST không được rỗng")
+		check(c.Database.Port > 0 && c.Database.Port <= 65535, "DB_PORT=%d phải trong khoảng 1..65535", c.Database.Port)
+		check(c.Database.Name != "", "DB_NAME không được rỗng")
+	}
+	check(c.Database.SQLitePath != "", "DB_SQLITE_PATH không được rỗng")
+	check(c.Database.SlowQueryThreshold >= 0, "DB_SLOW_QUERY_THRESHOLD không được âm")
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS không được âm")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS không được âm")
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME không được âm")
+	check(c.Database.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME không được âm")
+	check(c.Database.ConnectRetries >= 0, "DB_CONNECT_RETRIES không được âm")
+	check(c.Database.ConnectBackoff > 0, "DB_CONNECT_BACKOFF phải lớn hơn 0")
+	check(c.Database.PartitionPremakeMonths > 0, "DB_PARTITION_PREMAKE_MONTHS phải lớn hơn 0")
+	check(c.Database.PartitionCheckInterval > 0, "DB_PARTITION_CHECK_INTERVAL phải lớn hơn 0")
+	check(len(c.Database.ReplicaDSNs) == 0 || c.Database.Driver == DBDriverPostgres,
+		"DB_REPLICA_DSNS chỉ dùng với DB_DRIVER=%s", DBDriverPostgres)
+	check(c.Database.ReplicaHealthInterval > 0, "DB_REPLICA_HEALTH_INTERVAL phải lớn hơn 0")
+	check(c.Database.ReplicaMaxLag >= 0, "DB_REPLICA_MAX_LAG không được âm")
+	check(c.Database.ReadYourWritesWindow >= 0, "DB_READ_YOUR_WRITES_WINDOW không được âm")
+	if c.IsProduction() {
+		// Production không được âm thầm ghi vào file local hay bộ nhớ process
+		check(!c.Database.SQLiteFallback, "DB_SQLITE_FALLBACK không được bật khi ENV=production")
+		check(c.Database.Driver != DBDriverSQLiteMemory, "DB_DRIVER=%s không được dùng khi ENV=production", DBDriverSQLiteMemory)
+	}
+
+	check(c.Retention.DefaultDays >= 0, "RETENTION_DEFAULT_DAYS không được âm")
+	check(c.Retention.DeletedGrace >= 0, "RETENTION_DELETED_GRACE không được âm")
+	check(c.Retention.Interval > 0, "RETENTION_INTERVAL phải lớn hơn 0")
+	check(c.Retention.BatchSize > 0, "RETENTION_BATCH_SIZE phải lớn hơn 0")
+	check(c.Retention.BatchPause >= 0, "RETENTION_BATCH_PAUSE không được âm")
+
+	check(len(c.Kafka.Brokers) > 0, "KAFKA_BROKERS không được rỗng")
+	check(c.Kafka.MessageTopic != "", "KAFKA_MESSAGE_TOPIC không được rỗng")
+	check(c.Kafka.ConsumerGroup != "", "KAFKA_CONSUMER_GROUP không được rỗng")
+	check(c.Kafka.WorkerCount > 0, "KAFKA_WORKER_COUNT phải lớn hơn 0")
+	check(c.Kafka.MaxInFlight > 0, "KAFKA_PRODUCER_MAX_IN_FLIGHT phải lớn hơn 0")
+	check(c.Kafka.ReplicationFactor > 0 && c.Kafka.ReplicationFactor <= 32767,
+		"KAFKA_REPLICATION_FACTOR=%d phải trong khoảng 1..32767", c.Kafka.ReplicationFactor)
+	check(c.Kafka.OutboxPollInterval > 0, "OUTBOX_POLL_INTERVAL phải lớn hơn 0")
+	check(c.Kafka.OutboxBatchSize > 0, "OUTBOX_BATCH_SIZE phải lớn hơn 0")
+	check(c.Kafka.OutboxLease > 0, "OUTBOX_LEASE phải lớn hơn 0")
+	check(c.Kafka.OutboxRetryBackoff > 0, "OUTBOX_RETRY_BACKOFF phải lớn hơn 0")
+	check(c.Kafka.OutboxMaxRetryBackoff >= c.Kafka.OutboxRetryBackoff,
+		"OUTBOX_MAX_RETRY_BACKOFF=%s không được nhỏ hơn OUTBOX_RETRY_BACKOFF=%s", c.Kafka.OutboxMaxRetryBackoff, c.Kafka.OutboxRetryBackoff)
+	check(c.Kafka.OutboxMaxAttempts > 0, "OUTBOX_MAX_ATTEMPTS phải lớn hơn 0")
+
+	check(c.Tracing.SamplerRatio >= 0 && c.Tracing.SamplerRatio <= 1,
+		"OTEL_TRACES_SAMPLER_ARG=%v phải trong khoảng 0..1", c.Tracing.SamplerRatio)
+
+	return errors.Join(errs...)
+}
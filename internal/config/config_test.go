@@ -0,0 +1,89 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestValidateJoinsErrors(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default().Validate: %v", err)
+	}
+
+	config := Default()
+	config.Env = "qa"
+	config.Server.Port = 0
+	config.Database.Host = ""
+	config.Admin.Tokens = map[string]string{"alice": "ngắn"}
+	config.Kafka.OutboxRetryBackoff = time.Minute
+	config.Kafka.OutboxMaxRetryBackoff = time.Second
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate không trả lỗi")
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Validate = %T, muốn lỗi gộp bằng errors.Join", err)
+	}
+
+	want := []string{"ENV=", "SERVER_PORT=0", "DB_HOST", `ADMIN_TOKENS: token của "alice"`, "OUTBOX_MAX_RETRY_BACKOFF"}
+	if got := len(joined.Unwrap()); got != len(want) {
+		t.Errorf("Validate trả %d lỗi, muốn %d:\n%v", got, len(want), err)
+	}
+	for _, key := range want {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("lỗi không nêu %s:\n%v", key, err)
+		}
+	}
+}
+
+func TestValidateProductionRejectsSQLite(t *testing.T) {
+	config := Default()
+	config.Env = EnvProduction
+	config.Database.Driver = DBDriverSQLiteMemory
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "DB_DRIVER") {
+		t.Errorf("Validate = %v, muốn lỗi DB_DRIVER khi ENV=production dùng sqlite-memory", err)
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+	}{
+		{name: "đơn giản", password: "postgres"},
+		{name: "khoảng trắng", password: "mật khẩu có khoảng trắng"},
+		{name: "nháy đơn", password: "it's"},
+		{name: "dấu bằng", password: "a=b dbname=other"},
+		{name: "backslash", password: `c:\path\'x`},
+		{name: "rỗng", password: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Default().Database
+			config.Host = "db.internal"
+			config.User = "chat user"
+			config.Password = tt.password
+			config.Name = "vibeta_chat"
+			config.Port = 6543
+
+			parsed, err := pgconn.ParseConfig(config.PostgresDSN())
+			if err != nil {
+				t.Fatalf("ParseConfig(%q): %v", config.PostgresDSN(), err)
+			}
+			if parsed.Password != tt.password || parsed.User != config.User || parsed.Database != config.Name ||
+				parsed.Host != config.Host || parsed.Port != uint16(config.Port) {
+				t.Errorf("DSN %q parse thành password=%q user=%q dbname=%q host=%q port=%d",
+					config.PostgresDSN(), parsed.Password, parsed.User, parsed.Database, parsed.Host, parsed.Port)
+			}
+			if got := parsed.RuntimeParams["TimeZone"]; got != config.TimeZone {
+				t.Errorf("TimeZone = %q, muốn %q", got, config.TimeZone)
+			}
+		})
+	}
+}
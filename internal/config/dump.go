@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted giá trị thay cho secret khi Dump
+const redacted = "******"
+
+// Dump ghi cấu hình hiệu lực dạng KEY=value theo từng section, secret được che
+func (c *Config) Dump(w io.Writer) {
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		field := root.Type().Field(i)
+		value := root.Field(i)
+
+		if field.Type.Kind() != reflect.Struct {
+			fmt.Fprintf(w, "%s=%s\n", field.Tag.Get("env"), formatValue(value))
+			continue
+		}
+
+		fmt.Fprintf(w, "\n# %s\n", field.Tag.Get("yaml"))
+		for j := 0; j < value.NumField(); j++ {
+			item := value.Type().Field(j)
+			formatted := formatValue(value.Field(j))
+			if item.Tag.Get("secret") == "true" && formatted != "" {
+				formatted = redacted
+			}
+			fmt.Fprintf(w, "%s=%s\n", item.Tag.Get("env"), formatted)
+		}
+	}
+}
+
+// formatValue định dạng giá trị theo cùng cú pháp với environment variable
+func formatValue(value reflect.Value) string {
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(value.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		pairs := make([]string, 0, value.Len())
+		for _, key := range sortedKeys(value) {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", key, value.MapIndex(reflect.ValueOf(key)).Interface()))
+		}
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
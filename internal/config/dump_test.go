@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumpRedactsSecrets(t *testing.T) {
+	config := Default()
+	config.Database.Password = "db-secret"
+	config.Database.ReplicaDSNs = []string{"host=replica password=replica-secret"}
+	config.Admin.Tokens = map[string]string{"alice": "admin-token-secret"}
+	config.Kafka.SASLPassword = ""
+	config.Kafka.SASLUsername = "vibeta"
+
+	var out strings.Builder
+	config.Dump(&out)
+	dump := out.String()
+
+	for _, secret := range []string{"db-secret", "replica-secret", "admin-token-secret"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("Dump lộ secret %q:\n%s", secret, dump)
+		}
+	}
+
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(dump, "\n") {
+		lines[line] = true
+	}
+	for _, want := range []string{
+		"DB_PASSWORD=" + redacted,
+		"DB_REPLICA_DSNS=" + redacted,
+		"ADMIN_TOKENS=" + redacted,
+		"KAFKA_SASL_PASSWORD=", // secret rỗng không bị che, để thấy là chưa đặt
+		"KAFKA_SASL_USERNAME=vibeta",
+		"OUTBOX_POLL_INTERVAL=2s",
+		"KAFKA_TOPIC_ROUTES=audit_logged=chat_audit,reaction=chat_reactions",
+	} {
+		if !lines[want] {
+			t.Errorf("Dump thiếu dòng %q:\n%s", want, dump)
+		}
+	}
+}
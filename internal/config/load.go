@@ -0,0 +1,274 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvFile file .env được đọc nếu tồn tại khi không chỉ định ENV_FILE
+const DefaultEnvFile = ".env"
+
+// Options chỉ định các file cấu hình. Giá trị rỗng lấy từ CONFIG_FILE / ENV_FILE.
+type Options struct {
+	File    string // File YAML, không bắt buộc
+	EnvFile string // File .env; bắt buộc tồn tại nếu được chỉ định
+}
+
+// Flags các flag dòng lệnh chung cho binary dùng config
+type Flags struct {
+	File    string
+	EnvFile string
+	Print   bool
+}
+
+// BindFlags đăng ký -config, -env-file và -print-config vào flag set
+func BindFlags(flags *flag.FlagSet) *Flags {
+	f := &Flags{}
+	flags.StringVar(&f.File, "config", "", "File cấu hình YAML (mặc định $CONFIG_FILE)")
+	flags.StringVar(&f.EnvFile, "env-file", "", "File .env (mặc định $ENV_FILE hoặc ./.env nếu có)")
+	flags.BoolVar(&f.Print, "print-config", false, "In cấu hình hiệu lực (che secrets) rồi thoát")
+	return f
+}
+
+// Options chuyển flags thành Options cho Load
+func (f *Flags) Options() Options {
+	return Options{File: f.File, EnvFile: f.EnvFile}
+}
+
+// Load đọc cấu hình theo thứ tự default → YAML → .env → environment và validate
+func Load(options Options) (*Config, error) {
+	config := Default()
+
+	file := firstNonEmpty(options.File, os.Getenv("CONFIG_FILE"))
+	if file != "" {
+		if err := loadYAML(file, config); err != nil {
+			return nil, err
+		}
+	}
+
+	envFile := firstNonEmpty(options.EnvFile, os.Getenv("ENV_FILE"))
+	dotenv, err := loadDotEnv(envFile)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(key string) (string, bool) {
+		if value := os.Getenv(key); value != "" {
+			return value, true
+		}
+		value, ok := dotenv[key]
+		return value, ok && value != ""
+	}
+	if err := applyEnv(reflect.ValueOf(config).Elem(), lookup); err != nil {
+		return nil, fmt.Errorf("cấu hình không hợp lệ:\n%w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("cấu hình không hợp lệ:\n%w", err)
+	}
+	return config, nil
+}
+
+// loadYAML ghi đè config bằng các key có trong file YAML; key không biết là lỗi
+func loadYAML(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("không đọc được file cấu hình %s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("file cấu hình %s không hợp lệ: %w", path, err)
+	}
+	return nil
+}
+
+// loadDotEnv đọc file .env dạng KEY=value. path rỗng dùng ./.env nếu tồn tại.
+func loadDotEnv(path string) (map[string]string, error) {
+	required := path != ""
+	if !required {
+		path = DefaultEnvFile
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("không đọc được file env %s: %w", path, err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: dòng không hợp lệ, cần dạng KEY=value", path, lineNumber)
+		}
+		values[key] = parseDotEnvValue(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("không đọc được file env %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// parseDotEnvValue bỏ dấu nháy bao quanh, hoặc comment " # ..." sau giá trị không có nháy
+func parseDotEnvValue(value string) string {
+	if len(value) >= 2 {
+		if quote := value[0]; (quote == '"' || quote == '\'') && value[len(value)-1] == quote {
+			return value[1 : len(value)-1]
+		}
+	}
+	if strings.HasPrefix(value, "#") {
+		return ""
+	}
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = value[:index]
+	}
+	return strings.TrimSpace(value)
+}
+
+// applyEnv ghi đè các field có tag env bằng giá trị từ lookup
+func applyEnv(value reflect.Value, lookup func(string) (string, bool)) error {
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		target := value.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			if err := applyEnv(target, lookup); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setValue(target, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", key, raw, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue parse chuỗi vào field theo kiểu của field
+func setValue(target reflect.Value, raw string) error {
+	if target.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("phải là duration (ví dụ 500ms, 2s)")
+		}
+		target.SetInt(int64(duration))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("phải là số nguyên")
+		}
+		target.SetInt(int64(number))
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("phải là số")
+		}
+		target.SetFloat(number)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("phải là giá trị bool")
+		}
+		target.SetBool(parsed)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		target.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		return setMap(target, raw)
+	default:
+		return fmt.Errorf("kiểu %s không được hỗ trợ", target.Type())
+	}
+	return nil
+}
+
+// setMap parse chuỗi dạng "key=value,key=value" vào map[string]string hoặc map[string]int
+func setMap(target reflect.Value, raw string) error {
+	result := reflect.MakeMap(target.Type())
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return fmt.Errorf("cặp %q không hợp lệ, cần dạng key=value", pair)
+		}
+
+		item := reflect.New(target.Type().Elem()).Elem()
+		if err := setValue(item, value); err != nil {
+			return fmt.Errorf("giá trị của %s: %w", key, err)
+		}
+		result.SetMapIndex(reflect.ValueOf(key), item)
+	}
+	target.Set(result)
+	return nil
+}
+
+// sortedKeys trả về các key của map đã sắp xếp
+func sortedKeys(value reflect.Value) []string {
+	keys := make([]string, 0, value.Len())
+	for _, key := range value.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile ghi nội dung vào file trong thư mục tạm của test và trả về đường dẫn
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile %s: %v", name, err)
+	}
+	return path
+}
+
+// clearEnv xóa các biến môi trường test dùng, để môi trường của máy chạy test không ảnh hưởng
+func clearEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range append(keys, "CONFIG_FILE", "ENV_FILE") {
+		t.Setenv(key, "")
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t, "SERVER_PORT", "DB_NAME", "DB_USER", "LOG_LEVEL", "KAFKA_TOPIC_ROUTES", "OUTBOX_POLL_INTERVAL")
+
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  port: 8081
+database:
+  name: from_yaml
+  user: yaml_user
+log:
+  level: warn
+`)
+	envFile := writeFile(t, ".env", `
+# comment
+SERVER_PORT=8082
+export DB_NAME="from dotenv"
+DB_USER= # chỉ có comment, không ghi đè YAML
+OUTBOX_POLL_INTERVAL=5s # comment sau giá trị
+`)
+	t.Setenv("SERVER_PORT", "8083")
+	t.Setenv("KAFKA_TOPIC_ROUTES", "reaction=reactions, audit_logged=audit")
+
+	config, err := Load(Options{File: yamlFile, EnvFile: envFile})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "environment thắng .env và YAML", got: config.Server.Port, want: 8083},
+		{name: ".env thắng YAML", got: config.Database.Name, want: "from dotenv"},
+		{name: "giá trị rỗng trong .env không ghi đè", got: config.Database.User, want: "yaml_user"},
+		{name: "YAML thắng mặc định", got: config.Log.Level, want: "warn"},
+		{name: "comment sau giá trị .env", got: config.Kafka.OutboxPollInterval.String(), want: "5s"},
+		{name: "mặc định", got: config.Kafka.OutboxBatchSize, want: Default().Kafka.OutboxBatchSize},
+		{name: "map từ environment", got: config.Kafka.TopicRoutes["audit_logged"], want: "audit"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v, muốn %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		envFile string // đường dẫn .env, rỗng nếu không dùng
+		wantErr []string
+	}{
+		{name: "key YAML không biết", yaml: "server:\n  prot: 8080\n", wantErr: []string{"prot"}},
+		{name: "file .env chỉ định không tồn tại", envFile: filepath.Join(os.TempDir(), "vibeta-missing.env"), wantErr: []string{"vibeta-missing.env"}},
+		{
+			name:    "mọi giá trị env sai được báo cùng lúc",
+			env:     map[string]string{"SERVER_PORT": "abc", "OUTBOX_POLL_INTERVAL": "2", "KAFKA_TOPIC_ROUTES": "reaction"},
+			wantErr: []string{`SERVER_PORT="abc"`, `OUTBOX_POLL_INTERVAL="2"`, `KAFKA_TOPIC_ROUTES="reaction"`},
+		},
+		{
+			name:    "Validate sau khi load",
+			env:     map[string]string{"SERVER_PORT": "70000", "DB_DRIVER": "mysql"},
+			wantErr: []string{"SERVER_PORT=70000", `DB_DRIVER="mysql"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t, "SERVER_PORT", "OUTBOX_POLL_INTERVAL", "KAFKA_TOPIC_ROUTES", "DB_DRIVER")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			options := Options{EnvFile: tt.envFile}
+			if tt.yaml != "" {
+				options.File = writeFile(t, "config.yaml", tt.yaml)
+			}
+
+			_, err := Load(options)
+			if err == nil {
+				t.Fatal("Load không trả lỗi")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("lỗi %q không nêu %s", err, want)
+				}
+			}
+		})
+	}
+}
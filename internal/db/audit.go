@@ -0,0 +1,72 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+// Giới hạn số bản ghi mỗi trang audit log
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 500
+)
+
+// ErrInvalidAuditQuery được trả về khi khoảng thời gian hoặc cursor của truy vấn audit log không hợp lệ
+var ErrInvalidAuditQuery = errors.New("truy vấn audit log không hợp lệ")
+
+// AuditQuery điều kiện lọc audit log, field rỗng để bỏ qua
+type AuditQuery struct {
+	ActorID        string
+	Action         string
+	TargetType     string
+	TargetID       string
+	ConversationID string
+	From           time.Time // Bao gồm, zero để bỏ qua
+	To             time.Time // Không bao gồm, zero để bỏ qua
+
+	Limit  int
+	Cursor string // NextCursor của trang trước, rỗng cho trang đầu
+}
+
+// AuditPage một trang audit log, sắp xếp mới nhất trước
+type AuditPage struct {
+	Entries []models.AuditLog `json:"entries"`
+	// NextCursor rỗng khi không còn trang sau
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// normalize kiểm tra truy vấn, chuẩn hóa limit và trả về ID của bản ghi cuối trang trước (0 cho trang đầu)
+func (q *AuditQuery) normalize() (uint, error) {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return 0, fmt.Errorf("%w: from phải trước to", ErrInvalidAuditQuery)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultAuditLimit
+	}
+	q.Limit = min(q.Limit, MaxAuditLimit)
+
+	if q.Cursor == "" {
+		return 0, nil
+	}
+	beforeID, err := strconv.ParseUint(q.Cursor, 10, 64)
+	if err != nil || beforeID == 0 {
+		return 0, fmt.Errorf("%w: cursor", ErrInvalidAuditQuery)
+	}
+	return uint(beforeID), nil
+}
+
+// newAuditPage tạo trang từ tối đa limit+1 bản ghi; bản ghi thừa cho biết còn trang sau
+func newAuditPage(entries []models.AuditLog, limit int) *AuditPage {
+	page := &AuditPage{Entries: entries}
+	if len(entries) <= limit {
+		return page
+	}
+
+	page.Entries = entries[:limit]
+	page.NextCursor = strconv.FormatUint(uint64(page.Entries[limit-1].ID), 10)
+	return page
+}
@@ -1,132 +1,235 @@
 package db
 
 import (
-	"log"
-	"vibeta/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"vibeta/internal/config"
+	"vibeta/internal/logging"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
+// Database giữ kết nối GORM tới PostgreSQL hoặc SQLite. Truy cập dữ liệu qua
+// các repository của Store (NewStore), không dùng trực tiếp *gorm.DB.
 type Database struct {
-	DB *gorm.DB
+	db    *gorm.DB
+	reads *readRouter
+	// fts5 SQLite được build với FTS5 (go-sqlite3 tag sqlite_fts5). Không có FTS5 thì
+	// message_search là bảng thường và tìm kiếm dùng LIKE.
+	fts5 bool
 }
 
-// NewDatabase tạo kết nối database mới
-func NewDatabase() *Database {
-	// Kết nối đến PostgreSQL
-	// TODO: Thay đổi connection string theo cấu hình của bạn
-	// Hiện tại sử dụng SQLite cho demo đơn giản
-	db, err := gorm.Open(postgres.Open("host=localhost user=postgres password=postgres dbname=vibeta_chat port=5432 sslmode=disable TimeZone=Asia/Ho_Chi_Minh"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+// maxConnectBackoff giới hạn thời gian chờ giữa các lần thử kết nối PostgreSQL
+const maxConnectBackoff = 30 * time.Second
 
-	if err != nil {
-		// Fallback to SQLite for development
-		log.Println("Không thể kết nối PostgreSQL, sử dụng SQLite cho development")
-		return NewSQLiteDatabase()
-	}
-
-	// Auto migrate các tables
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Conversation{},
-		&models.Message{},
-		&models.ConversationParticipant{},
+// NewDatabase kết nối database theo DB_DRIVER, cấu hình connection pool và migrate
+// (hoặc kiểm tra schema nếu tắt DB_AUTO_MIGRATE). PostgreSQL được thử lại với backoff
+// khi khởi động; hết lượt thử thì trả lỗi, chỉ dùng SQLite khi bật DB_SQLITE_FALLBACK.
+func NewDatabase(ctx context.Context, settings config.DatabaseConfig) (*Database, error) {
+	var (
+		database *Database
+		err      error
 	)
 
+	switch settings.Driver {
+	case config.DBDriverPostgres:
+		database, err = connectWithRetry(ctx, settings)
+		if err != nil && settings.SQLiteFallback {
+			slog.Warn("Không thể kết nối PostgreSQL, DB_SQLITE_FALLBACK bật nên dùng SQLite",
+				"host", settings.Host, "port", settings.Port, "path", settings.SQLitePath, logging.Err(err))
+			database, err = Connect(config.DBDriverSQLite, settings.SQLitePath, settings.SlowQueryThreshold)
+		}
+	case config.DBDriverSQLite, config.DBDriverSQLiteMemory:
+		database, err = Connect(settings.Driver, settings.DSN(), settings.SlowQueryThreshold)
+	default:
+		err = fmt.Errorf("database driver không hỗ trợ: %q", settings.Driver)
+	}
 	if err != nil {
-		log.Fatal("Không thể migrate database:", err)
+		return nil, err
+	}
+
+	if err := database.configurePool(settings); err != nil {
+		return nil, err
+	}
+	if err := migrateOnStartup(database, settings.AutoMigrate); err != nil {
+		return nil, fmt.Errorf("không thể migrate database: %w", err)
 	}
 
-	log.Println("PostgreSQL database đã kết nối và migrate thành công")
+	if len(settings.ReplicaDSNs) > 0 && database.Dialect() == config.DBDriverPostgres {
+		database.reads = newReadRouter(database.db, settings.ReadYourWritesWindow)
+		if err := database.reads.connectReplicas(settings); err != nil {
+			return nil, fmt.Errorf("không thể kết nối read replica: %w", err)
+		}
+		// Kiểm tra sức khỏe replica trong suốt vòng đời process
+		go database.reads.monitor(context.Background(), settings.ReplicaHealthInterval, settings.ReplicaMaxLag)
+		slog.Info("Đọc lịch sử, danh sách và tìm kiếm từ read replica", "replicas", len(settings.ReplicaDSNs),
+			"read_your_writes_window", settings.ReadYourWritesWindow)
+	}
 
-	return &Database{DB: db}
+	switch {
+	case database.Dialect() == config.DBDriverPostgres:
+		slog.Info("PostgreSQL database đã kết nối", "host", settings.Host, "database", settings.Name)
+	case settings.Driver == config.DBDriverSQLiteMemory:
+		slog.Info("SQLite in-memory database đã khởi tạo, dữ liệu mất khi process dừng")
+	default:
+		slog.Info("SQLite database đã kết nối", "path", settings.SQLitePath)
+	}
+	return database, nil
 }
 
-// NewSQLiteDatabase tạo database SQLite cho development
-func NewSQLiteDatabase() *Database {
-	db, err := gorm.Open(sqlite.Open("vibeta_chat.db"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+// connectWithRetry kết nối PostgreSQL, thử lại ConnectRetries lần với backoff tăng gấp đôi
+func connectWithRetry(ctx context.Context, settings config.DatabaseConfig) (*Database, error) {
+	backoff := settings.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		database, err := Connect(config.DBDriverPostgres, settings.PostgresDSN(), settings.SlowQueryThreshold)
+		if err == nil {
+			return database, nil
+		}
+		if attempt >= settings.ConnectRetries {
+			return nil, fmt.Errorf("thất bại sau %d lần thử: %w", attempt+1, err)
+		}
+
+		slog.Warn("Không thể kết nối PostgreSQL, thử lại",
+			"host", settings.Host, "port", settings.Port,
+			"attempt", attempt+1, "retries", settings.ConnectRetries, "backoff", backoff, logging.Err(err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("dừng kết nối PostgreSQL: %w", ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
 
+// configurePool áp dụng giới hạn connection pool. SQLite in-memory chỉ dùng một kết nối
+// không bao giờ đóng, vì database biến mất khi kết nối cuối cùng đóng lại.
+func (d *Database) configurePool(settings config.DatabaseConfig) error {
+	sqlDB, err := d.db.DB()
 	if err != nil {
-		log.Fatal("Không thể tạo SQLite database:", err)
+		return err
 	}
 
-	// Auto migrate các tables
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Conversation{},
-		&models.Message{},
-		&models.ConversationParticipant{},
-	)
+	if settings.Driver == config.DBDriverSQLiteMemory {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+		return nil
+	}
+
+	sqlDB.SetMaxOpenConns(settings.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(settings.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(settings.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(settings.ConnMaxIdleTime)
+	return nil
+}
 
+// OpenDatabase kết nối tới database chỉ định bằng driver (postgres, sqlite hoặc
+// sqlite-memory) và DSN rồi migrate lên version mới nhất, không fallback.
+// Dùng cho các tool như cmd/replay cần ghi vào database đích.
+func OpenDatabase(driver, dsn string, slowQueryThreshold time.Duration) (*Database, error) {
+	database, err := Connect(driver, dsn, slowQueryThreshold)
 	if err != nil {
-		log.Fatal("Không thể migrate SQLite database:", err)
+		return nil, err
 	}
 
-	log.Println("SQLite database đã được tạo và migrate thành công")
-
-	return &Database{DB: db}
+	if err := migrateOnStartup(database, true); err != nil {
+		return nil, fmt.Errorf("không thể migrate %s database: %w", driver, err)
+	}
+	return database, nil
 }
 
-// SaveMessage lưu tin nhắn vào database
-func (d *Database) SaveMessage(message *models.Message) error {
-	return d.DB.Create(message).Error
+// NamedMemoryDSN trả về DSN của database SQLite in-memory tên name, dùng chung giữa các
+// kết nối trong process. Ký tự ngoài chữ cái và chữ số trong name được thay bằng "_".
+func NamedMemoryDSN(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	return "file:" + name + "?mode=memory&cache=shared"
 }
 
-// GetMessages lấy tin nhắn từ database theo conversation ID
-func (d *Database) GetMessages(conversationID string, limit int, offset int) ([]models.Message, error) {
-	var messages []models.Message
-	err := d.DB.Where("conversation_id = ?", conversationID).
-		Order("created_at ASC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error
+// Connect kết nối tới database chỉ định bằng driver và DSN, không migrate và không
+// thử lại. Với sqlite-memory, DSN rỗng dùng database in-memory dùng chung của process.
+func Connect(driver, dsn string, slowQueryThreshold time.Duration) (*Database, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case config.DBDriverPostgres:
+		dialector = postgres.Open(dsn)
+	case config.DBDriverSQLite:
+		dialector = sqlite.Open(dsn)
+	case config.DBDriverSQLiteMemory:
+		if dsn == "" {
+			dsn = config.DatabaseConfig{Driver: driver}.DSN()
+		}
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("database driver không hỗ trợ: %q (hỗ trợ %s, %s, %s)",
+			driver, config.DBDriverPostgres, config.DBDriverSQLite, config.DBDriverSQLiteMemory)
+	}
 
-	return messages, err
-}
+	db, err := gorm.Open(dialector, newGormConfig(slowQueryThreshold))
+	if err != nil {
+		return nil, fmt.Errorf("không thể kết nối %s database: %w", driver, err)
+	}
 
-// SaveConversation lưu cuộc trò chuyện vào database
-func (d *Database) SaveConversation(conv *models.Conversation) error {
-	return d.DB.Create(conv).Error
+	database := &Database{db: db, reads: newReadRouter(db, 0)}
+	if driver != config.DBDriverPostgres {
+		database.fts5 = sqliteHasFTS5(db)
+	}
+	return database, nil
 }
 
-// GetConversations lấy danh sách cuộc trò chuyện của user
-func (d *Database) GetConversations(userID string) ([]models.Conversation, error) {
-	var conversations []models.Conversation
-	err := d.DB.Joins("JOIN conversation_participants cp ON cp.conversation_id = conversations.id").
-		Where("cp.user_id = ?", userID).
-		Find(&conversations).Error
-
-	return conversations, err
+// newGormConfig cấu hình GORM ghi log qua slog với ngưỡng slow query.
+// TranslateError để lỗi trùng khóa của từng driver thành gorm.ErrDuplicatedKey.
+func newGormConfig(slowQueryThreshold time.Duration) *gorm.Config {
+	return &gorm.Config{
+		Logger:         logging.NewGormLogger(slowQueryThreshold),
+		TranslateError: true,
+	}
 }
 
-// SaveUser lưu thông tin user
-func (d *Database) SaveUser(user *models.User) error {
-	return d.DB.Create(user).Error
+// Dialect tên dialect của database: "postgres" hoặc "sqlite"
+func (d *Database) Dialect() string {
+	return d.db.Dialector.Name()
 }
 
-// GetUser lấy thông tin user
-func (d *Database) GetUser(userID string) (*models.User, error) {
-	var user models.User
-	err := d.DB.Where("id = ?", userID).First(&user).Error
-	return &user, err
+// Ping kiểm tra kết nối tới database
+func (d *Database) Ping(ctx context.Context) error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
 }
 
-// AddParticipantToConversation thêm participant vào conversation
-func (d *Database) AddParticipantToConversation(conversationID, userID string) error {
-	participant := &models.ConversationParticipant{
-		ConversationID: conversationID,
-		UserID:         userID,
+// Close đóng kết nối tới primary và các read replica
+func (d *Database) Close() error {
+	var errs []error
+	if d.reads != nil {
+		for _, member := range d.reads.replicas {
+			errs = append(errs, closeDB(member.db))
+		}
 	}
-	return d.DB.Create(participant).Error
+	errs = append(errs, closeDB(d.db))
+	return errors.Join(errs...)
 }
 
-// UpdateMessage cập nhật tin nhắn (cho reactions)
-func (d *Database) UpdateMessage(messageID string, message *models.Message) error {
-	return d.DB.Where("id = ?", messageID).Updates(message).Error
+func closeDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
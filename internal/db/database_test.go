@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/models"
+)
+
+// newTestDatabase mở database SQLite in-memory riêng cho test, chưa migrate.
+// Mỗi test một database đặt theo tên test để dữ liệu không lẫn giữa các test.
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	database, err := Connect(config.DBDriverSQLiteMemory, NamedMemoryDSN(t.Name()), 0)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := database.configurePool(config.DatabaseConfig{Driver: config.DBDriverSQLiteMemory}); err != nil {
+		t.Fatalf("configurePool: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// newTestStore mở database SQLite in-memory đã migrate lên version mới nhất
+func newTestStore(t *testing.T) (*Database, *Store) {
+	t.Helper()
+
+	database := newTestDatabase(t)
+	if err := migrateOnStartup(database, true); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database, NewStore(database)
+}
+
+// forEachSearchBackend chạy test với message_search là bảng ảo FTS5 và bảng thường tìm bằng
+// LIKE. Bản FTS5 bị bỏ qua khi go-sqlite3 được build không có tag sqlite_fts5.
+func forEachSearchBackend(t *testing.T, test func(t *testing.T, store *Store)) {
+	for _, fts5 := range []bool{true, false} {
+		name := "like"
+		if fts5 {
+			name = "fts5"
+		}
+		t.Run(name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if fts5 && !database.fts5 {
+				t.Skip("SQLite không có FTS5, chạy với -tags sqlite_fts5")
+			}
+			database.fts5 = fts5
+			if err := migrateOnStartup(database, true); err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+			test(t, NewStore(database))
+		})
+	}
+}
+
+// seedConversation tạo conversation nhóm cùng user và thành viên còn thiếu, user đầu tiên là owner
+func seedConversation(t *testing.T, store *Store, conversationID string, userIDs ...string) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, userID := range userIDs {
+		if _, err := store.Users.Get(ctx, userID); err == nil {
+			continue
+		}
+		user := &models.User{ID: userID, Username: userID, Email: userID + "@example.com", FullName: userID}
+		if err := store.Users.Create(ctx, user); err != nil {
+			t.Fatalf("Users.Create %s: %v", userID, err)
+		}
+	}
+
+	conversation := &models.Conversation{ID: conversationID, Type: models.ConversationTypeGroup, Name: conversationID, CreatedBy: userIDs[0]}
+	if err := store.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Conversations.Create %s: %v", conversationID, err)
+	}
+	for i, userID := range userIDs {
+		role := models.RoleMember
+		if i == 0 {
+			role = models.RoleOwner
+		}
+		if err := store.Participants.Add(ctx, conversationID, userID, role); err != nil {
+			t.Fatalf("Participants.Add %s: %v", userID, err)
+		}
+	}
+}
+
+// seedMessage lưu tin nhắn và index nội dung như worker
+func seedMessage(t *testing.T, store *Store, messageID, conversationID, senderID, content string, createdAt time.Time) *models.Message {
+	t.Helper()
+	ctx := context.Background()
+
+	message := &models.Message{
+		ID:             messageID,
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Content:        content,
+		Type:           models.MessageTypeText,
+		Status:         models.MessageStatusSent,
+		CreatedAt:      createdAt,
+	}
+	if err := store.Messages.Create(ctx, message); err != nil {
+		t.Fatalf("Messages.Create %s: %v", messageID, err)
+	}
+	if err := store.Search.Index(ctx, message); err != nil {
+		t.Fatalf("Search.Index %s: %v", messageID, err)
+	}
+	return message
+}
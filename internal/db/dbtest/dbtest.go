@@ -0,0 +1,26 @@
+// Package dbtest mở database SQLite in-memory đã migrate cho test của các package dùng db.
+package dbtest
+
+import (
+	"testing"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+)
+
+// NewStore mở database SQLite in-memory riêng cho test, đặt tên theo t.Name() để dữ liệu
+// không lẫn giữa các test, và migrate lên version mới nhất. Database được đóng khi test kết thúc.
+func NewStore(t testing.TB) *db.Store {
+	t.Helper()
+
+	database, err := db.OpenDatabase(config.DBDriverSQLiteMemory, db.NamedMemoryDSN(t.Name()), 0)
+	if err != nil {
+		t.Fatalf("OpenDatabase: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := database.Close(); err != nil {
+			t.Errorf("đóng database: %v", err)
+		}
+	})
+	return db.NewStore(database)
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+
+	"vibeta/internal/metrics"
+	"vibeta/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type gormAuditRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+}
+
+func (r *gormAuditRepository) Append(ctx context.Context, entry *models.AuditLog, event *models.OutboxEvent) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		if event != nil {
+			return tx.Create(event).Error
+		}
+		return nil
+	})
+	return metrics.DBWrite("append_audit_log", err)
+}
+
+func (r *gormAuditRepository) List(ctx context.Context, query AuditQuery) (*AuditPage, error) {
+	beforeID, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := r.reads.reader(ctx)
+	filters := []struct{ column, value string }{
+		{"actor_id", query.ActorID},
+		{"action", query.Action},
+		{"target_type", query.TargetType},
+		{"target_id", query.TargetID},
+		{"conversation_id", query.ConversationID},
+	}
+	for _, filter := range filters {
+		if filter.value != "" {
+			tx = tx.Where(filter.column+" = ?", filter.value)
+		}
+	}
+	// occurred_at luôn được ghi theo UTC
+	if !query.From.IsZero() {
+		tx = tx.Where("occurred_at >= ?", query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("occurred_at < ?", query.To.UTC())
+	}
+	if beforeID > 0 {
+		tx = tx.Where("id < ?", beforeID)
+	}
+
+	var entries []models.AuditLog
+	if err := tx.Order("id DESC").Limit(query.Limit + 1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return newAuditPage(entries, query.Limit), nil
+}
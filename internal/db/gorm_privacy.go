@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"vibeta/internal/metrics"
+	"vibeta/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Điều kiện chọn dữ liệu trong / ngoài conversation đang legal hold, nhận tham số true
+const (
+	inHeld  = "conversation_id IN (SELECT id FROM conversations WHERE legal_hold = ?)"
+	notHeld = "conversation_id NOT IN (SELECT id FROM conversations WHERE legal_hold = ?)"
+)
+
+type gormPrivacyRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPrivacyRepository) Memberships(ctx context.Context, userID string) ([]models.ConversationParticipant, error) {
+	var participants []models.ConversationParticipant
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("joined_at ASC, id ASC").Find(&participants).Error
+	return participants, err
+}
+
+func (r *gormPrivacyRepository) ListSentMessages(ctx context.Context, userID string, afterCreatedAt time.Time, afterID string, limit int) ([]models.Message, error) {
+	tx := r.db.WithContext(ctx).Unscoped().Where("sender_id = ?", userID)
+	if afterID != "" {
+		tx = tx.Where("created_at > ? OR (created_at = ? AND id > ?)", afterCreatedAt, afterCreatedAt, afterID)
+	}
+
+	var messages []models.Message
+	err := tx.Order("created_at ASC, id ASC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+func (r *gormPrivacyRepository) ListReactions(ctx context.Context, userID string) ([]models.MessageReaction, error) {
+	var reactions []models.MessageReaction
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&reactions).Error
+	return reactions, err
+}
+
+func (r *gormPrivacyRepository) ListReadStates(ctx context.Context, userID string) ([]models.ReadState, error) {
+	var states []models.ReadState
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("conversation_id ASC").Find(&states).Error
+	return states, err
+}
+
+func (r *gormPrivacyRepository) Erase(ctx context.Context, erasure Erasure) (*ErasureResult, error) {
+	result := &ErasureResult{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		profile := tx.Model(&models.User{}).Where("id = ?", erasure.UserID).Updates(map[string]interface{}{
+			"username":  erasure.Username,
+			"email":     erasure.Email,
+			"full_name": erasure.FullName,
+			"avatar":    "",
+			"status":    models.UserStatusOffline,
+		})
+		if profile.Error != nil {
+			return profile.Error
+		}
+		result.Profile = profile.RowsAffected > 0
+
+		var held int64
+		if err := tx.Unscoped().Model(&models.Message{}).Where("sender_id = ?", erasure.UserID).Where(inHeld, true).
+			Count(&held).Error; err != nil {
+			return err
+		}
+		result.HeldMessages = int(held)
+
+		// Tin nhắn của user ngoài conversation legal hold, tạo mới mỗi lần dùng làm subquery
+		sent := func() *gorm.DB {
+			return tx.Unscoped().Model(&models.Message{}).Where("sender_id = ?", erasure.UserID).Where(notHeld, true)
+		}
+		if err := tx.Exec("DELETE FROM message_search WHERE message_id IN (?)", sent().Select("id")).Error; err != nil {
+			return err
+		}
+
+		var messages *gorm.DB
+		if erasure.Mode == ErasureDelete {
+			reactions := tx.Where("message_id IN (?)", sent().Select("id")).Delete(&models.MessageReaction{})
+			if reactions.Error != nil {
+				return reactions.Error
+			}
+			result.Reactions += int(reactions.RowsAffected)
+			if err := tx.Where("message_id IN (?)", sent().Select("id")).Delete(&models.PinnedMessage{}).Error; err != nil {
+				return err
+			}
+			messages = sent().Delete(&models.Message{})
+		} else {
+			messages = sent().Updates(map[string]interface{}{"content": "", "attachments": "", "reactions": ""})
+		}
+		if messages.Error != nil {
+			return messages.Error
+		}
+		result.Messages = int(messages.RowsAffected)
+
+		reactions := tx.Where("user_id = ?", erasure.UserID).Where(notHeld, true).Delete(&models.MessageReaction{})
+		if reactions.Error != nil {
+			return reactions.Error
+		}
+		result.Reactions += int(reactions.RowsAffected)
+
+		if err := tx.Where("user_id = ?", erasure.UserID).Delete(&models.ReadState{}).Error; err != nil {
+			return err
+		}
+		memberships := tx.Model(&models.ConversationParticipant{}).
+			Where("user_id = ? AND left_at IS NULL", erasure.UserID).
+			Update("left_at", time.Now())
+		if memberships.Error != nil {
+			return memberships.Error
+		}
+		result.Memberships = int(memberships.RowsAffected)
+
+		if erasure.Event != nil {
+			return tx.Create(erasure.Event).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, metrics.DBWrite("erase_user", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,514 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vibeta/internal/metrics"
+	"vibeta/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewStore tạo Store dùng GORM trên database (PostgreSQL hoặc SQLite)
+func NewStore(database *Database) *Store {
+	return &Store{
+		Users:           &gormUserRepository{db: database.db},
+		Conversations:   &gormConversationRepository{db: database.db, reads: database.reads},
+		Participants:    &gormParticipantRepository{db: database.db},
+		Pins:            &gormPinRepository{db: database.db},
+		Messages:        &gormMessageRepository{db: database.db, reads: database.reads, locator: newMessageLocator(database)},
+		Reactions:       &gormReactionRepository{db: database.db},
+		ReadStates:      &gormReadStateRepository{db: database.db},
+		Outbox:          &gormOutboxRepository{db: database.db},
+		ProcessedEvents: &gormProcessedEventRepository{db: database.db},
+		Search:          newGormSearchRepository(database),
+		Retention:       &gormRetentionRepository{db: database.db, partitionKey: database.Dialect() == "postgres"},
+		Privacy:         &gormPrivacyRepository{db: database.db},
+		Audit:           &gormAuditRepository{db: database.db, reads: database.reads},
+		ping:            database.Ping,
+		markWrite:       database.reads.markWrite,
+	}
+}
+
+// translateError chuyển lỗi của GORM thành ErrNotFound / ErrDuplicate
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	}
+	return err
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *models.User) error {
+	return metrics.DBWrite("save_user", translateError(r.db.WithContext(ctx).Create(user).Error))
+}
+
+func (r *gormUserRepository) Get(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) UpdateStatus(ctx context.Context, userID string, status models.UserStatus, lastActive time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"status": status, "last_active": lastActive}).Error
+	return metrics.DBWrite("update_user_status", err)
+}
+
+type gormConversationRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+}
+
+func (r *gormConversationRepository) Create(ctx context.Context, conversation *models.Conversation) error {
+	return metrics.DBWrite("save_conversation", translateError(r.db.WithContext(ctx).Create(conversation).Error))
+}
+
+func (r *gormConversationRepository) Get(ctx context.Context, conversationID string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := r.db.WithContext(ctx).Where("id = ?", conversationID).First(&conversation).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &conversation, nil
+}
+
+func (r *gormConversationRepository) ListByUser(ctx context.Context, userID string) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	err := r.reads.reader(WithUser(ctx, userID)).
+		Joins("JOIN conversation_participants cp ON cp.conversation_id = conversations.id").
+		Where("cp.user_id = ? AND cp.left_at IS NULL", userID).
+		Find(&conversations).Error
+	return conversations, err
+}
+
+func (r *gormConversationRepository) Update(ctx context.Context, conversationID string, changes *models.Conversation) error {
+	// Updates với struct chỉ ghi các field khác zero value
+	result := r.db.WithContext(ctx).Model(&models.Conversation{}).Where("id = ?", conversationID).
+		Updates(&models.Conversation{Name: changes.Name, Description: changes.Description, Avatar: changes.Avatar})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return metrics.DBWrite("update_conversation", result.Error)
+}
+
+type gormParticipantRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormParticipantRepository) Add(ctx context.Context, conversationID, userID string, role models.ParticipantRole) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID).
+			Count(&count).Error
+		if err != nil || count > 0 {
+			return err
+		}
+		return tx.Create(&models.ConversationParticipant{
+			ConversationID: conversationID,
+			UserID:         userID,
+			Role:           role,
+			JoinedAt:       time.Now(),
+		}).Error
+	})
+	return metrics.DBWrite("add_participant", err)
+}
+
+func (r *gormParticipantRepository) Remove(ctx context.Context, conversationID, userID string) error {
+	err := r.db.WithContext(ctx).Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID).
+		Update("left_at", time.Now()).Error
+	return metrics.DBWrite("remove_participant", err)
+}
+
+func (r *gormParticipantRepository) List(ctx context.Context, conversationID string) ([]models.ConversationParticipant, error) {
+	var participants []models.ConversationParticipant
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND left_at IS NULL", conversationID).
+		Order("joined_at ASC").
+		Find(&participants).Error
+	return participants, err
+}
+
+func (r *gormParticipantRepository) IsParticipant(ctx context.Context, conversationID, userID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormParticipantRepository) Get(ctx context.Context, conversationID, userID string) (*models.ConversationParticipant, error) {
+	var participant models.ConversationParticipant
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID).
+		First(&participant).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &participant, nil
+}
+
+func (r *gormParticipantRepository) SetRoles(ctx context.Context, conversationID string, roles map[string]models.ParticipantRole) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for userID, role := range roles {
+			result := tx.Model(&models.ConversationParticipant{}).
+				Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID).
+				Update("role", role)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: thành viên %s", ErrNotFound, userID)
+			}
+		}
+		return nil
+	})
+	return metrics.DBWrite("set_participant_roles", err)
+}
+
+type gormPinRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPinRepository) Pin(ctx context.Context, pin *models.PinnedMessage) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(pin)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return fmt.Errorf("%w: tin nhắn %s đã được ghim", ErrDuplicate, pin.MessageID)
+	}
+	return metrics.DBWrite("pin_message", result.Error)
+}
+
+func (r *gormPinRepository) Unpin(ctx context.Context, conversationID, messageID string) error {
+	result := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND message_id = ?", conversationID, messageID).
+		Delete(&models.PinnedMessage{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return metrics.DBWrite("unpin_message", result.Error)
+}
+
+func (r *gormPinRepository) List(ctx context.Context, conversationID string) ([]models.PinnedMessage, error) {
+	var pins []models.PinnedMessage
+	err := r.db.WithContext(ctx).Where("conversation_id = ?", conversationID).
+		Order("pinned_at DESC").
+		Find(&pins).Error
+	return pins, err
+}
+
+type gormMessageRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+	// locator thêm created_at vào điều kiện theo id, để PostgreSQL chỉ quét partition
+	// chứa tin nhắn nếu bảng messages được partition theo tháng
+	locator *messageLocator
+}
+
+func (r *gormMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	return metrics.DBWrite("save_message", translateError(r.db.WithContext(ctx).Create(message).Error))
+}
+
+func (r *gormMessageRepository) CreateIfAbsent(ctx context.Context, message *models.Message) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(message)
+	err := translateError(result.Error)
+	// Bảng messages đã partition: ON CONFLICT chỉ bắt trùng (id, created_at), trùng id với
+	// created_at khác bị trigger của message_ids báo ErrDuplicate
+	if (err == nil && result.RowsAffected > 0) || (err != nil && !errors.Is(err, ErrDuplicate)) {
+		return metrics.DBWrite("save_message", err)
+	}
+
+	// ID đã tồn tại (kể cả tin nhắn đã xóa mềm): chỉ bỏ qua nếu là cùng người gửi
+	tx, err := r.locator.where(ctx, r.db.WithContext(ctx).Unscoped(), message.ID)
+	if err != nil {
+		return metrics.DBWrite("save_message", err)
+	}
+	var existing models.Message
+	if err := tx.Select("sender_id").First(&existing).Error; err != nil {
+		return metrics.DBWrite("save_message", translateError(err))
+	}
+	return metrics.DBWrite("save_message", checkSameSender(message, &existing))
+}
+
+func (r *gormMessageRepository) Get(ctx context.Context, messageID string) (*models.Message, error) {
+	tx, err := r.locator.where(ctx, r.db.WithContext(ctx), messageID)
+	if err != nil {
+		return nil, err
+	}
+	var message models.Message
+	if err := tx.First(&message).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &message, nil
+}
+
+func (r *gormMessageRepository) ListByConversation(ctx context.Context, conversationID string, limit, offset int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.reads.reader(ctx).Where("conversation_id = ?", conversationID).
+		Order("created_at ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *gormMessageRepository) Update(ctx context.Context, messageID string, message *models.Message) error {
+	tx := r.db.WithContext(ctx)
+	switch {
+	case r.locator == nil:
+		tx = tx.Where("id = ?", messageID)
+	case !message.CreatedAt.IsZero():
+		tx = tx.Where("id = ? AND created_at = ?", messageID, message.CreatedAt)
+	default:
+		var err error
+		if tx, err = r.locator.where(ctx, tx, messageID); err != nil {
+			return metrics.DBWrite("update_message", err)
+		}
+	}
+	return metrics.DBWrite("update_message", tx.Omit("created_at").Updates(message).Error)
+}
+
+func (r *gormMessageRepository) Delete(ctx context.Context, messageID string) error {
+	tx, err := r.locator.where(ctx, r.db.WithContext(ctx), messageID)
+	if err != nil {
+		return metrics.DBWrite("delete_message", err)
+	}
+	return metrics.DBWrite("delete_message", tx.Delete(&models.Message{}).Error)
+}
+
+func (r *gormMessageRepository) ListRange(ctx context.Context, query MessageRange) ([]models.Message, error) {
+	tx := r.reads.reader(ctx).Where("conversation_id = ?", query.ConversationID)
+	if !query.From.IsZero() {
+		tx = tx.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("created_at < ?", query.To)
+	}
+	if query.AfterID != "" {
+		tx = tx.Where("(created_at > ? OR (created_at = ? AND id > ?))", query.AfterCreatedAt, query.AfterCreatedAt, query.AfterID)
+	}
+
+	var messages []models.Message
+	err := tx.Order("created_at ASC, id ASC").Limit(query.Limit).Find(&messages).Error
+	return messages, err
+}
+
+type gormReactionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReactionRepository) Add(ctx context.Context, reaction *models.MessageReaction) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reaction).Error
+	return metrics.DBWrite("add_reaction", err)
+}
+
+func (r *gormReactionRepository) Remove(ctx context.Context, messageID, userID, emoji string) error {
+	err := r.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&models.MessageReaction{}).Error
+	return metrics.DBWrite("remove_reaction", err)
+}
+
+func (r *gormReactionRepository) ListByMessage(ctx context.Context, messageID string) ([]models.MessageReaction, error) {
+	var reactions []models.MessageReaction
+	err := r.db.WithContext(ctx).Where("message_id = ?", messageID).Order("created_at ASC").Find(&reactions).Error
+	return reactions, err
+}
+
+func (r *gormReactionRepository) ListByMessages(ctx context.Context, messageIDs []string) ([]models.MessageReaction, error) {
+	var reactions []models.MessageReaction
+	if len(messageIDs) == 0 {
+		return reactions, nil
+	}
+	err := r.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Order("created_at ASC").Find(&reactions).Error
+	return reactions, err
+}
+
+type gormReadStateRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReadStateRepository) MarkRead(ctx context.Context, conversationID, userID, messageID string, readAt time.Time) error {
+	state := &models.ReadState{
+		ConversationID:    conversationID,
+		UserID:            userID,
+		LastReadMessageID: messageID,
+		LastReadAt:        readAt,
+		UpdatedAt:         time.Now(),
+	}
+
+	// Upsert chỉ tiến về phía trước để event đến trễ không kéo lùi vị trí đã đọc
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_read_message_id", "last_read_at", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "conversation_read_states.last_read_at < excluded.last_read_at"},
+		}},
+	}).Create(state).Error
+	return metrics.DBWrite("mark_read", err)
+}
+
+func (r *gormReadStateRepository) Get(ctx context.Context, conversationID, userID string) (*models.ReadState, error) {
+	var state models.ReadState
+	err := r.db.WithContext(ctx).Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&state).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &state, nil
+}
+
+func (r *gormReadStateRepository) UnreadCount(ctx context.Context, conversationID, userID string) (int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("conversation_id = ? AND sender_id <> ?", conversationID, userID)
+
+	state, err := r.Get(ctx, conversationID, userID)
+	switch {
+	case err == nil:
+		query = query.Where("created_at > ?", state.LastReadAt)
+	case !errors.Is(err, ErrNotFound):
+		return 0, err
+	}
+
+	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+type gormOutboxRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormOutboxRepository) SaveWithMessage(ctx context.Context, message *models.Message, event *models.OutboxEvent) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	return metrics.DBWrite("save_message_with_outbox", translateError(err))
+}
+
+func (r *gormOutboxRepository) Save(ctx context.Context, event *models.OutboxEvent) error {
+	return metrics.DBWrite("save_outbox_event", translateError(r.db.WithContext(ctx).Create(event).Error))
+}
+
+// outboxClaimLockKey khóa advisory tuần tự hóa ClaimBatch trên PostgreSQL
+const outboxClaimLockKey = 0x6f7574626f78 // "outbox"
+
+// ClaimBatch chọn event và ghi lease trong một transaction ngắn, relay publish sau khi
+// transaction đã commit. Trên PostgreSQL các lần claim được tuần tự hóa bằng advisory lock,
+// để hai relay không cùng lúc claim hai event liên tiếp của một partition_key.
+// Thời gian lease được ghi theo UTC để so sánh đúng trên SQLite.
+func (r *gormOutboxRepository) ClaimBatch(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", outboxClaimLockKey).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+		err := tx.Where("published_at IS NULL AND dead_lettered_at IS NULL").
+			Where("(locked_until IS NULL OR locked_until <= ?)", now).
+			Where("(next_attempt_at IS NULL OR next_attempt_at <= ?)", now).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM outbox_events earlier
+				WHERE earlier.partition_key = outbox_events.partition_key AND earlier.id < outbox_events.id
+				AND earlier.published_at IS NULL AND earlier.dead_lettered_at IS NULL
+				AND (earlier.locked_until > ? OR earlier.next_attempt_at > ?))`, now, now).
+			Order("id ASC").Limit(limit).Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		lockedUntil := now.Add(lease)
+		ids := make([]uint, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+			events[i].LockedUntil = &lockedUntil
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("locked_until", lockedUntil).Error
+	})
+	if err := metrics.DBWrite("claim_outbox_batch", err); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *gormOutboxRepository) MarkPublished(ctx context.Context, id uint) error {
+	err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"published_at": time.Now().UTC(),
+		"locked_until": nil,
+	}).Error
+	return metrics.DBWrite("mark_outbox_published", err)
+}
+
+func (r *gormOutboxRepository) MarkFailed(ctx context.Context, id uint, lastError string, nextAttemptAt time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt.UTC(),
+		"locked_until":    nil,
+	}).Error
+	return metrics.DBWrite("mark_outbox_failed", err)
+}
+
+func (r *gormOutboxRepository) MarkDeadLettered(ctx context.Context, id uint, lastError string) error {
+	err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":         gorm.Expr("attempts + 1"),
+		"last_error":       lastError,
+		"dead_lettered_at": time.Now().UTC(),
+		"locked_until":     nil,
+	}).Error
+	return metrics.DBWrite("mark_outbox_dead_lettered", err)
+}
+
+func (r *gormOutboxRepository) Release(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("locked_until", nil).Error
+	return metrics.DBWrite("release_outbox_events", err)
+}
+
+type gormProcessedEventRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormProcessedEventRepository) IsProcessed(ctx context.Context, eventID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ProcessedEvent{}).Where("event_id = ?", eventID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormProcessedEventRepository) MarkProcessed(ctx context.Context, event *models.ProcessedEvent) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(event).Error
+	return metrics.DBWrite("mark_event_processed", err)
+}
+
+func (r *gormProcessedEventRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("processed_at < ?", before).Delete(&models.ProcessedEvent{})
+	return result.RowsAffected, metrics.DBWrite("purge_processed_events", result.Error)
+}
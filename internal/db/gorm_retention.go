@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"vibeta/internal/metrics"
+	"vibeta/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type gormRetentionRepository struct {
+	db *gorm.DB
+	// partitionKey giới hạn lệnh xóa theo khoảng created_at của batch, để PostgreSQL chỉ quét
+	// các partition chứa batch nếu bảng messages được partition theo tháng
+	partitionKey bool
+}
+
+func (r *gormRetentionRepository) SetRetention(ctx context.Context, conversationID string, days *int) error {
+	return r.updateConversation(ctx, "set_retention", conversationID, "retention_days", days)
+}
+
+func (r *gormRetentionRepository) SetLegalHold(ctx context.Context, conversationID string, hold bool) error {
+	return r.updateConversation(ctx, "set_legal_hold", conversationID, "legal_hold", hold)
+}
+
+// updateConversation cập nhật một cột của conversation, ErrNotFound nếu không có dòng nào
+func (r *gormRetentionRepository) updateConversation(ctx context.Context, operation, conversationID, column string, value interface{}) error {
+	result := r.db.WithContext(ctx).Model(&models.Conversation{}).Where("id = ?", conversationID).Update(column, value)
+	if result.Error != nil {
+		return metrics.DBWrite(operation, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormRetentionRepository) ListPolicies(ctx context.Context) ([]RetentionPolicy, error) {
+	var conversations []models.Conversation
+	err := r.db.WithContext(ctx).Select("id", "retention_days", "legal_hold").
+		Where("legal_hold = ? OR retention_days IS NOT NULL", true).
+		Order("id ASC").
+		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]RetentionPolicy, 0, len(conversations))
+	for _, conversation := range conversations {
+		policies = append(policies, RetentionPolicy{
+			ConversationID: conversation.ID,
+			RetentionDays:  conversation.RetentionDays,
+			LegalHold:      conversation.LegalHold,
+		})
+	}
+	return policies, nil
+}
+
+func (r *gormRetentionRepository) PurgeExpired(ctx context.Context, conversationID string, before time.Time, limit int) (int, error) {
+	query := r.db.Unscoped().Model(&models.Message{}).Where("created_at < ?", before)
+	if conversationID != "" {
+		query = query.Where("conversation_id = ?", conversationID).
+			Where("conversation_id NOT IN (SELECT id FROM conversations WHERE legal_hold = ?)", true)
+	} else {
+		query = query.Where("conversation_id NOT IN (SELECT id FROM conversations WHERE legal_hold = ? OR retention_days IS NOT NULL)", true)
+	}
+	return r.purge(ctx, "purge_expired_messages", query, limit)
+}
+
+func (r *gormRetentionRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	query := r.db.Unscoped().Model(&models.Message{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Where("conversation_id NOT IN (SELECT id FROM conversations WHERE legal_hold = ?)", true)
+	return r.purge(ctx, "purge_deleted_messages", query, limit)
+}
+
+// purge xóa vĩnh viễn tối đa limit tin nhắn khớp query cùng reaction và search index
+// trong một transaction
+func (r *gormRetentionRepository) purge(ctx context.Context, operation string, query *gorm.DB, limit int) (int, error) {
+	var batch []struct {
+		ID        string
+		CreatedAt time.Time
+	}
+	if err := query.WithContext(ctx).Select("id", "created_at").Order("created_at ASC").Limit(limit).Find(&batch).Error; err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(batch))
+	for i := range batch {
+		ids[i] = batch[i].ID
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_id IN ?", ids).Delete(&models.MessageReaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id IN ?", ids).Delete(&models.PinnedMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM message_search WHERE message_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		messages := tx.Unscoped().Where("id IN ?", ids)
+		if r.partitionKey {
+			messages = messages.Where("created_at BETWEEN ? AND ?", batch[0].CreatedAt, batch[len(batch)-1].CreatedAt)
+		}
+		return messages.Delete(&models.Message{}).Error
+	})
+	if err != nil {
+		return 0, metrics.DBWrite(operation, err)
+	}
+	return len(ids), nil
+}
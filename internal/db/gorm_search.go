@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"vibeta/internal/metrics"
+	"vibeta/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sqliteSearchTimeFormat định dạng created_at trong bảng FTS5, so sánh chuỗi được như thời gian.
+// Khớp với strftime('%Y-%m-%dT%H:%M:%fZ') dùng khi backfill trong migration.
+const sqliteSearchTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// newGormSearchRepository chọn cách index theo dialect: tsvector + GIN trên PostgreSQL,
+// bảng ảo FTS5 trên SQLite, hoặc bảng thường tìm bằng LIKE khi SQLite không có FTS5
+func newGormSearchRepository(database *Database) SearchRepository {
+	if database.Dialect() == "postgres" {
+		return &postgresSearchRepository{db: database.db, reads: database.reads}
+	}
+	return &sqliteSearchRepository{db: database.db, reads: database.reads, fts5: database.sqliteSearchFTS5()}
+}
+
+// sqliteHasFTS5 kiểm tra SQLite có được build với FTS5 hay không
+func sqliteHasFTS5(db *gorm.DB) bool {
+	var enabled bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error; err != nil {
+		return false
+	}
+	return enabled
+}
+
+// sqliteSearchFTS5 cho biết message_search là bảng ảo FTS5 theo schema hiện có. Bảng chưa
+// được tạo thì theo việc SQLite có FTS5 hay không, giống migration sẽ tạo.
+func (d *Database) sqliteSearchFTS5() bool {
+	var schema string
+	err := d.db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'message_search'").Scan(&schema).Error
+	if err != nil || schema == "" {
+		return d.fts5
+	}
+	return strings.Contains(strings.ToLower(schema), "using fts5")
+}
+
+// searchDialect phần SQL khác nhau giữa các dialect khi tìm kiếm
+type searchDialect struct {
+	match       string // Điều kiện khớp từ khóa
+	matchArgs   []any
+	snippet     string // Biểu thức sinh snippet
+	snippetArgs []any
+	// highlight đánh dấu từ khớp trong snippet nếu database không tự đánh dấu, nil để bỏ qua
+	highlight func(snippet string) string
+	timeValue func(t time.Time) any
+	parseTime func(value any) (time.Time, error)
+}
+
+type postgresSearchRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+}
+
+func (r *postgresSearchRepository) Index(ctx context.Context, message *models.Message) error {
+	err := r.db.WithContext(ctx).Exec(`INSERT INTO message_search
+		(message_id, conversation_id, sender_id, type, content, created_at, document)
+		VALUES (?, ?, ?, ?, ?, ?, to_tsvector('simple', ?))
+		ON CONFLICT (message_id) DO UPDATE SET
+			type = excluded.type, content = excluded.content, document = excluded.document`,
+		message.ID, message.ConversationID, message.SenderID, message.Type, message.Content, message.CreatedAt, message.Content,
+	).Error
+	return metrics.DBWrite("index_message", err)
+}
+
+func (r *postgresSearchRepository) Remove(ctx context.Context, messageID string) error {
+	err := r.db.WithContext(ctx).Exec("DELETE FROM message_search WHERE message_id = ?", messageID).Error
+	return metrics.DBWrite("unindex_message", err)
+}
+
+func (r *postgresSearchRepository) RemoveBySender(ctx context.Context, senderID string) error {
+	err := r.db.WithContext(ctx).Exec("DELETE FROM message_search WHERE sender_id = ? AND "+notHeld, senderID, true).Error
+	return metrics.DBWrite("unindex_sender", err)
+}
+
+func (r *postgresSearchRepository) Search(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=30, MinWords=10, MaxFragments=2", highlightStart, highlightStop)
+	return searchMessages(r.reads.reader(WithUser(ctx, query.UserID)), query, searchDialect{
+		match:       "message_search.document @@ plainto_tsquery('simple', ?)",
+		matchArgs:   []any{strings.Join(searchTerms(query.Text), " ")},
+		snippet:     "ts_headline('simple', message_search.content, plainto_tsquery('simple', ?), ?)",
+		snippetArgs: []any{strings.Join(searchTerms(query.Text), " "), options},
+		timeValue:   func(t time.Time) any { return t },
+		parseTime: func(value any) (time.Time, error) {
+			t, ok := value.(time.Time)
+			if !ok {
+				return time.Time{}, fmt.Errorf("created_at có kiểu %T", value)
+			}
+			return t, nil
+		},
+	})
+}
+
+type sqliteSearchRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+	fts5  bool // false thì message_search là bảng thường, tìm bằng LIKE
+}
+
+// Index xóa rồi ghi lại vì bảng FTS5 không có unique constraint để upsert
+func (r *sqliteSearchRepository) Index(ctx context.Context, message *models.Message) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM message_search WHERE message_id = ?", message.ID).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO message_search
+			(content, message_id, conversation_id, sender_id, type, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			message.Content, message.ID, message.ConversationID, message.SenderID, message.Type,
+			message.CreatedAt.UTC().Format(sqliteSearchTimeFormat),
+		).Error
+	})
+	return metrics.DBWrite("index_message", err)
+}
+
+func (r *sqliteSearchRepository) Remove(ctx context.Context, messageID string) error {
+	err := r.db.WithContext(ctx).Exec("DELETE FROM message_search WHERE message_id = ?", messageID).Error
+	return metrics.DBWrite("unindex_message", err)
+}
+
+func (r *sqliteSearchRepository) RemoveBySender(ctx context.Context, senderID string) error {
+	err := r.db.WithContext(ctx).Exec("DELETE FROM message_search WHERE sender_id = ? AND "+notHeld, senderID, true).Error
+	return metrics.DBWrite("unindex_sender", err)
+}
+
+func (r *sqliteSearchRepository) Search(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+	terms := searchTerms(query.Text)
+	dialect := searchDialect{
+		timeValue: func(t time.Time) any { return t.UTC().Format(sqliteSearchTimeFormat) },
+		parseTime: func(value any) (time.Time, error) {
+			text, ok := value.(string)
+			if !ok {
+				return time.Time{}, fmt.Errorf("created_at có kiểu %T", value)
+			}
+			return time.Parse(sqliteSearchTimeFormat, text)
+		},
+	}
+
+	if r.fts5 {
+		// Mỗi từ được đặt trong ngoặc kép để FTS5 coi là chuỗi, không phải cú pháp truy vấn
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + term + `"`
+		}
+		dialect.match = "message_search MATCH ?"
+		dialect.matchArgs = []any{strings.Join(quoted, " ")}
+		dialect.snippet = "snippet(message_search, 0, ?, ?, '…', 16)"
+		dialect.snippetArgs = []any{highlightStart, highlightStop}
+	} else {
+		// Không có FTS5: mỗi từ phải xuất hiện trong nội dung, không phân biệt hoa thường với ASCII
+		conditions := make([]string, len(terms))
+		for i, term := range terms {
+			conditions[i] = `message_search.content LIKE ? ESCAPE '\'`
+			dialect.matchArgs = append(dialect.matchArgs, "%"+likeEscaper.Replace(term)+"%")
+		}
+		dialect.match = strings.Join(conditions, " AND ")
+		dialect.snippet = "message_search.content"
+		dialect.highlight = func(content string) string { return likeSnippet(content, terms) }
+	}
+
+	return searchMessages(r.reads.reader(WithUser(ctx, query.UserID)), query, dialect)
+}
+
+// likeEscaper escape ký tự đại diện của LIKE trong từ khóa
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likeSnippetWords số từ tối đa của snippet khi tìm bằng LIKE, như snippet() của FTS5
+const likeSnippetWords = 16
+
+// likeSnippet cắt đoạn nội dung quanh từ khớp đầu tiên và đánh dấu các từ chứa từ khóa,
+// thay cho snippet() của FTS5
+func likeSnippet(content string, terms []string) string {
+	words := strings.Fields(content)
+	matches := func(word string) bool {
+		word = strings.ToLower(word)
+		for _, term := range terms {
+			if strings.Contains(word, strings.ToLower(term)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first := 0
+	for i, word := range words {
+		if matches(word) {
+			first = i
+			break
+		}
+	}
+	start := max(0, min(first-likeSnippetWords/4, len(words)-likeSnippetWords))
+	end := min(len(words), start+likeSnippetWords)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i, word := range words[start:end] {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if matches(word) {
+			word = highlightStart + word + highlightStop
+		}
+		b.WriteString(word)
+	}
+	if end < len(words) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// searchMessages chạy truy vấn tìm kiếm chung cho các dialect, sắp xếp theo
+// (created_at, message_id) giảm dần để phân trang bằng cursor
+func searchMessages(db *gorm.DB, query SearchQuery, dialect searchDialect) (*SearchPage, error) {
+	if err := query.normalize(); err != nil {
+		return nil, err
+	}
+	cursor, err := query.decodeCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.Table("message_search").
+		Select("message_search.message_id, message_search.conversation_id, message_search.sender_id, "+
+			"message_search.type, message_search.created_at, "+dialect.snippet+" AS snippet", dialect.snippetArgs...).
+		Where(dialect.match, dialect.matchArgs...).
+		Where(`EXISTS (SELECT 1 FROM conversation_participants cp
+			WHERE cp.conversation_id = message_search.conversation_id AND cp.user_id = ? AND cp.left_at IS NULL)`, query.UserID)
+
+	if query.ConversationID != "" {
+		tx = tx.Where("message_search.conversation_id = ?", query.ConversationID)
+	}
+	if query.SenderID != "" {
+		tx = tx.Where("message_search.sender_id = ?", query.SenderID)
+	}
+	if query.Type != "" {
+		tx = tx.Where("message_search.type = ?", query.Type)
+	}
+	if !query.From.IsZero() {
+		tx = tx.Where("message_search.created_at >= ?", dialect.timeValue(query.From))
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("message_search.created_at < ?", dialect.timeValue(query.To))
+	}
+	if cursor != nil {
+		createdAt := dialect.timeValue(cursor.CreatedAt)
+		tx = tx.Where("(message_search.created_at < ? OR (message_search.created_at = ? AND message_search.message_id < ?))",
+			createdAt, createdAt, cursor.MessageID)
+	}
+
+	rows, err := tx.Order("message_search.created_at DESC, message_search.message_id DESC").
+		Limit(query.Limit + 1).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var (
+			result    SearchResult
+			createdAt any
+		)
+		if err := rows.Scan(&result.MessageID, &result.ConversationID, &result.SenderID, &result.Type, &createdAt, &result.Snippet); err != nil {
+			return nil, err
+		}
+		if result.CreatedAt, err = dialect.parseTime(createdAt); err != nil {
+			return nil, fmt.Errorf("lỗi đọc kết quả tìm kiếm %s: %w", result.MessageID, err)
+		}
+		if dialect.highlight != nil {
+			result.Snippet = dialect.highlight(result.Snippet)
+		}
+		result.Snippet = renderSnippet(result.Snippet)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newSearchPage(results, query.Limit), nil
+}
@@ -0,0 +1,1136 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"vibeta/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// NewMemoryStore tạo Store lưu toàn bộ dữ liệu trong bộ nhớ của process.
+// Dùng cho test và công cụ không cần database; dữ liệu mất khi process dừng.
+func NewMemoryStore() *Store {
+	backend := &memoryBackend{
+		users:         make(map[string]models.User),
+		conversations: make(map[string]models.Conversation),
+		messages:      make(map[string]models.Message),
+		reactions:     make(map[reactionKey]models.MessageReaction),
+		readStates:    make(map[readStateKey]models.ReadState),
+		searchIndex:   make(map[string]models.Message),
+		pins:          make(map[pinKey]models.PinnedMessage),
+		processed:     make(map[string]models.ProcessedEvent),
+	}
+	return &Store{
+		Users:           memoryUserRepository{backend},
+		Conversations:   memoryConversationRepository{backend},
+		Participants:    memoryParticipantRepository{backend},
+		Pins:            memoryPinRepository{backend},
+		Messages:        memoryMessageRepository{backend},
+		Reactions:       memoryReactionRepository{backend},
+		ReadStates:      memoryReadStateRepository{backend},
+		Outbox:          memoryOutboxRepository{backend},
+		ProcessedEvents: memoryProcessedEventRepository{backend},
+		Search:          memorySearchRepository{backend},
+		Retention:       memoryRetentionRepository{backend},
+		Privacy:         memoryPrivacyRepository{backend},
+		Audit:           memoryAuditRepository{backend},
+	}
+}
+
+type reactionKey struct {
+	messageID, userID, emoji string
+}
+
+type readStateKey struct {
+	conversationID, userID string
+}
+
+type pinKey struct {
+	conversationID, messageID string
+}
+
+// memoryBackend dữ liệu dùng chung của các memory repository, bảo vệ bởi một mutex
+type memoryBackend struct {
+	mu            sync.Mutex
+	users         map[string]models.User
+	conversations map[string]models.Conversation
+	participants  []models.ConversationParticipant
+	messages      map[string]models.Message
+	reactions     map[reactionKey]models.MessageReaction
+	readStates    map[readStateKey]models.ReadState
+	outbox        []models.OutboxEvent
+	searchIndex   map[string]models.Message
+	auditLogs     []models.AuditLog
+	pins          map[pinKey]models.PinnedMessage
+	processed     map[string]models.ProcessedEvent
+
+	nextParticipantID uint
+	nextOutboxID      uint
+	nextAuditID       uint
+}
+
+// activeParticipant trả về index của participant chưa rời conversation, -1 nếu không có
+func (b *memoryBackend) activeParticipant(conversationID, userID string) int {
+	for i, participant := range b.participants {
+		if participant.ConversationID == conversationID && participant.UserID == userID && participant.LeftAt == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// createMessage lưu tin nhắn, b.mu phải đang được giữ
+func (b *memoryBackend) createMessage(message *models.Message) error {
+	if _, exists := b.messages[message.ID]; exists {
+		return fmt.Errorf("%w: message %s", ErrDuplicate, message.ID)
+	}
+	now := time.Now()
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = now
+	}
+	if message.UpdatedAt.IsZero() {
+		message.UpdatedAt = now
+	}
+	b.messages[message.ID] = *message
+	return nil
+}
+
+// createOutboxEvent lưu outbox event, b.mu phải đang được giữ
+func (b *memoryBackend) createOutboxEvent(event *models.OutboxEvent) error {
+	for _, existing := range b.outbox {
+		if existing.EventID == event.EventID {
+			return fmt.Errorf("%w: outbox event %s", ErrDuplicate, event.EventID)
+		}
+	}
+	b.nextOutboxID++
+	event.ID = b.nextOutboxID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	b.outbox = append(b.outbox, *event)
+	return nil
+}
+
+type memoryUserRepository struct{ *memoryBackend }
+
+func (r memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.ID]; exists {
+		return fmt.Errorf("%w: user %s", ErrDuplicate, user.ID)
+	}
+	for _, existing := range r.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return fmt.Errorf("%w: username hoặc email của user %s", ErrDuplicate, user.ID)
+		}
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUserRepository) Get(ctx context.Context, userID string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r memoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUserRepository) UpdateStatus(ctx context.Context, userID string, status models.UserStatus, lastActive time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.Status = status
+		user.LastActive = lastActive
+		user.UpdatedAt = time.Now()
+		r.users[userID] = user
+	}
+	return nil
+}
+
+type memoryConversationRepository struct{ *memoryBackend }
+
+func (r memoryConversationRepository) Create(ctx context.Context, conversation *models.Conversation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.conversations[conversation.ID]; exists {
+		return fmt.Errorf("%w: conversation %s", ErrDuplicate, conversation.ID)
+	}
+	r.conversations[conversation.ID] = *conversation
+	return nil
+}
+
+func (r memoryConversationRepository) Get(ctx context.Context, conversationID string) (*models.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &conversation, nil
+}
+
+func (r memoryConversationRepository) ListByUser(ctx context.Context, userID string) ([]models.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var conversations []models.Conversation
+	for _, participant := range r.participants {
+		if participant.UserID != userID || participant.LeftAt != nil {
+			continue
+		}
+		if conversation, ok := r.conversations[participant.ConversationID]; ok {
+			conversations = append(conversations, conversation)
+		}
+	}
+	return conversations, nil
+}
+
+func (r memoryConversationRepository) Update(ctx context.Context, conversationID string, changes *models.Conversation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok {
+		return ErrNotFound
+	}
+	if changes.Name != "" {
+		conversation.Name = changes.Name
+	}
+	if changes.Description != "" {
+		conversation.Description = changes.Description
+	}
+	if changes.Avatar != "" {
+		conversation.Avatar = changes.Avatar
+	}
+	conversation.UpdatedAt = time.Now()
+	r.conversations[conversationID] = conversation
+	return nil
+}
+
+type memoryParticipantRepository struct{ *memoryBackend }
+
+func (r memoryParticipantRepository) Add(ctx context.Context, conversationID, userID string, role models.ParticipantRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.activeParticipant(conversationID, userID) >= 0 {
+		return nil
+	}
+	r.nextParticipantID++
+	r.participants = append(r.participants, models.ConversationParticipant{
+		ID:             r.nextParticipantID,
+		ConversationID: conversationID,
+		UserID:         userID,
+		Role:           role,
+		JoinedAt:       time.Now(),
+	})
+	return nil
+}
+
+func (r memoryParticipantRepository) Remove(ctx context.Context, conversationID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.activeParticipant(conversationID, userID); i >= 0 {
+		now := time.Now()
+		r.participants[i].LeftAt = &now
+	}
+	return nil
+}
+
+func (r memoryParticipantRepository) List(ctx context.Context, conversationID string) ([]models.ConversationParticipant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var participants []models.ConversationParticipant
+	for _, participant := range r.participants {
+		if participant.ConversationID == conversationID && participant.LeftAt == nil {
+			participants = append(participants, participant)
+		}
+	}
+	return participants, nil
+}
+
+func (r memoryParticipantRepository) IsParticipant(ctx context.Context, conversationID, userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.activeParticipant(conversationID, userID) >= 0, nil
+}
+
+func (r memoryParticipantRepository) Get(ctx context.Context, conversationID, userID string) (*models.ConversationParticipant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.activeParticipant(conversationID, userID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	participant := r.participants[i]
+	return &participant, nil
+}
+
+func (r memoryParticipantRepository) SetRoles(ctx context.Context, conversationID string, roles map[string]models.ParticipantRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Kiểm tra hết trước khi sửa để không áp dụng một nửa
+	indexes := make(map[string]int, len(roles))
+	for userID := range roles {
+		i := r.activeParticipant(conversationID, userID)
+		if i < 0 {
+			return fmt.Errorf("%w: thành viên %s", ErrNotFound, userID)
+		}
+		indexes[userID] = i
+	}
+	for userID, role := range roles {
+		r.participants[indexes[userID]].Role = role
+	}
+	return nil
+}
+
+type memoryPinRepository struct{ *memoryBackend }
+
+func (r memoryPinRepository) Pin(ctx context.Context, pin *models.PinnedMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := pinKey{pin.ConversationID, pin.MessageID}
+	if _, exists := r.pins[key]; exists {
+		return fmt.Errorf("%w: tin nhắn %s đã được ghim", ErrDuplicate, pin.MessageID)
+	}
+	r.pins[key] = *pin
+	return nil
+}
+
+func (r memoryPinRepository) Unpin(ctx context.Context, conversationID, messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := pinKey{conversationID, messageID}
+	if _, exists := r.pins[key]; !exists {
+		return ErrNotFound
+	}
+	delete(r.pins, key)
+	return nil
+}
+
+func (r memoryPinRepository) List(ctx context.Context, conversationID string) ([]models.PinnedMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pins []models.PinnedMessage
+	for _, pin := range r.pins {
+		if pin.ConversationID == conversationID {
+			pins = append(pins, pin)
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].PinnedAt.After(pins[j].PinnedAt) })
+	return pins, nil
+}
+
+type memoryMessageRepository struct{ *memoryBackend }
+
+func (r memoryMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createMessage(message)
+}
+
+func (r memoryMessageRepository) CreateIfAbsent(ctx context.Context, message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, exists := r.messages[message.ID]; exists {
+		return checkSameSender(message, &existing)
+	}
+	return r.createMessage(message)
+}
+
+func (r memoryMessageRepository) Get(ctx context.Context, messageID string) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message, ok := r.messages[messageID]
+	if !ok || message.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return &message, nil
+}
+
+func (r memoryMessageRepository) ListByConversation(ctx context.Context, conversationID string, limit, offset int) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []models.Message
+	for _, message := range r.messages {
+		if message.ConversationID == conversationID && !message.DeletedAt.Valid {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+
+	if offset >= len(messages) {
+		return nil, nil
+	}
+	messages = messages[offset:]
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (r memoryMessageRepository) ListRange(ctx context.Context, query MessageRange) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []models.Message
+	for _, message := range r.messages {
+		switch {
+		case message.ConversationID != query.ConversationID || message.DeletedAt.Valid:
+		case !query.From.IsZero() && message.CreatedAt.Before(query.From):
+		case !query.To.IsZero() && !message.CreatedAt.Before(query.To):
+		case query.AfterID != "" && !messageAfter(message, query.AfterCreatedAt, query.AfterID):
+		default:
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messageAfter(messages[j], messages[i].CreatedAt, messages[i].ID)
+	})
+
+	if query.Limit > 0 && query.Limit < len(messages) {
+		messages = messages[:query.Limit]
+	}
+	return messages, nil
+}
+
+// messageAfter cho biết message đứng sau vị trí (createdAt, id) theo thứ tự (created_at, id)
+func messageAfter(message models.Message, createdAt time.Time, id string) bool {
+	if !message.CreatedAt.Equal(createdAt) {
+		return message.CreatedAt.After(createdAt)
+	}
+	return message.ID > id
+}
+
+// Update giống GORM Updates với struct: chỉ ghi các field khác zero value
+func (r memoryMessageRepository) Update(ctx context.Context, messageID string, message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.messages[messageID]
+	if !ok {
+		return nil
+	}
+	if message.Content != "" {
+		existing.Content = message.Content
+	}
+	if message.Type != "" {
+		existing.Type = message.Type
+	}
+	if message.Status != "" {
+		existing.Status = message.Status
+	}
+	if message.ReplyToID != "" {
+		existing.ReplyToID = message.ReplyToID
+	}
+	if message.Attachments != "" {
+		existing.Attachments = message.Attachments
+	}
+	if message.Reactions != "" {
+		existing.Reactions = message.Reactions
+	}
+	if message.EditedAt != nil {
+		existing.EditedAt = message.EditedAt
+	}
+	existing.UpdatedAt = time.Now()
+	r.messages[messageID] = existing
+	return nil
+}
+
+// Delete xóa mềm như GORM: tin nhắn vẫn còn cho tới khi bị job retention xóa vĩnh viễn
+func (r memoryMessageRepository) Delete(ctx context.Context, messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if message, ok := r.messages[messageID]; ok && !message.DeletedAt.Valid {
+		message.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		r.messages[messageID] = message
+	}
+	return nil
+}
+
+type memoryReactionRepository struct{ *memoryBackend }
+
+func (r memoryReactionRepository) Add(ctx context.Context, reaction *models.MessageReaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := reactionKey{reaction.MessageID, reaction.UserID, reaction.Emoji}
+	if _, exists := r.reactions[key]; exists {
+		return nil
+	}
+	if reaction.CreatedAt.IsZero() {
+		reaction.CreatedAt = time.Now()
+	}
+	r.reactions[key] = *reaction
+	return nil
+}
+
+func (r memoryReactionRepository) Remove(ctx context.Context, messageID, userID, emoji string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.reactions, reactionKey{messageID, userID, emoji})
+	return nil
+}
+
+func (r memoryReactionRepository) ListByMessage(ctx context.Context, messageID string) ([]models.MessageReaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reactions []models.MessageReaction
+	for key, reaction := range r.reactions {
+		if key.messageID == messageID {
+			reactions = append(reactions, reaction)
+		}
+	}
+	sort.Slice(reactions, func(i, j int) bool { return reactions[i].CreatedAt.Before(reactions[j].CreatedAt) })
+	return reactions, nil
+}
+
+func (r memoryReactionRepository) ListByMessages(ctx context.Context, messageIDs []string) ([]models.MessageReaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		wanted[id] = true
+	}
+
+	var reactions []models.MessageReaction
+	for key, reaction := range r.reactions {
+		if wanted[key.messageID] {
+			reactions = append(reactions, reaction)
+		}
+	}
+	sort.Slice(reactions, func(i, j int) bool { return reactions[i].CreatedAt.Before(reactions[j].CreatedAt) })
+	return reactions, nil
+}
+
+type memoryReadStateRepository struct{ *memoryBackend }
+
+func (r memoryReadStateRepository) MarkRead(ctx context.Context, conversationID, userID, messageID string, readAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := readStateKey{conversationID, userID}
+	if existing, ok := r.readStates[key]; ok && !existing.LastReadAt.Before(readAt) {
+		return nil
+	}
+	r.readStates[key] = models.ReadState{
+		ConversationID:    conversationID,
+		UserID:            userID,
+		LastReadMessageID: messageID,
+		LastReadAt:        readAt,
+		UpdatedAt:         time.Now(),
+	}
+	return nil
+}
+
+func (r memoryReadStateRepository) Get(ctx context.Context, conversationID, userID string) (*models.ReadState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.readStates[readStateKey{conversationID, userID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &state, nil
+}
+
+func (r memoryReadStateRepository) UnreadCount(ctx context.Context, conversationID, userID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.readStates[readStateKey{conversationID, userID}]
+	var count int64
+	for _, message := range r.messages {
+		if message.ConversationID == conversationID && message.SenderID != userID && message.CreatedAt.After(state.LastReadAt) && !message.DeletedAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryOutboxRepository struct{ *memoryBackend }
+
+func (r memoryOutboxRepository) SaveWithMessage(ctx context.Context, message *models.Message, event *models.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Kiểm tra trước khi ghi để giữ tính nguyên tử như transaction
+	for _, existing := range r.outbox {
+		if existing.EventID == event.EventID {
+			return fmt.Errorf("%w: outbox event %s", ErrDuplicate, event.EventID)
+		}
+	}
+	if err := r.createMessage(message); err != nil {
+		return err
+	}
+	return r.createOutboxEvent(event)
+}
+
+func (r memoryOutboxRepository) Save(ctx context.Context, event *models.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createOutboxEvent(event)
+}
+
+func (r memoryOutboxRepository) ClaimBatch(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	lockedUntil := now.Add(lease)
+	// blocked các partition_key có event trước đó đang bị lease hoặc chờ thử lại
+	blocked := make(map[string]bool)
+	var events []models.OutboxEvent
+	for i := range r.outbox {
+		if len(events) >= limit {
+			break
+		}
+		event := &r.outbox[i]
+		if event.PublishedAt != nil || event.DeadLetteredAt != nil {
+			continue
+		}
+		leased := event.LockedUntil != nil && event.LockedUntil.After(now)
+		waiting := event.NextAttemptAt != nil && event.NextAttemptAt.After(now)
+		if leased || waiting || blocked[event.PartitionKey] {
+			blocked[event.PartitionKey] = true
+			continue
+		}
+
+		event.LockedUntil = &lockedUntil
+		events = append(events, *event)
+	}
+	return events, nil
+}
+
+// outboxEvent trả về outbox event theo ID, b.mu phải đang được giữ
+func (r memoryOutboxRepository) outboxEvent(id uint) (*models.OutboxEvent, error) {
+	for i := range r.outbox {
+		if r.outbox[i].ID == id {
+			return &r.outbox[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: outbox event %d", ErrNotFound, id)
+}
+
+func (r memoryOutboxRepository) MarkPublished(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event, err := r.outboxEvent(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	event.PublishedAt = &now
+	event.LockedUntil = nil
+	return nil
+}
+
+func (r memoryOutboxRepository) MarkFailed(ctx context.Context, id uint, lastError string, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event, err := r.outboxEvent(id)
+	if err != nil {
+		return err
+	}
+	event.Attempts++
+	event.LastError = lastError
+	event.NextAttemptAt = &nextAttemptAt
+	event.LockedUntil = nil
+	return nil
+}
+
+func (r memoryOutboxRepository) MarkDeadLettered(ctx context.Context, id uint, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event, err := r.outboxEvent(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	event.Attempts++
+	event.LastError = lastError
+	event.DeadLetteredAt = &now
+	event.LockedUntil = nil
+	return nil
+}
+
+func (r memoryOutboxRepository) Release(ctx context.Context, ids []uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if event, err := r.outboxEvent(id); err == nil {
+			event.LockedUntil = nil
+		}
+	}
+	return nil
+}
+
+type memoryProcessedEventRepository struct{ *memoryBackend }
+
+func (r memoryProcessedEventRepository) IsProcessed(ctx context.Context, eventID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.processed[eventID]
+	return ok, nil
+}
+
+func (r memoryProcessedEventRepository) MarkProcessed(ctx context.Context, event *models.ProcessedEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.processed[event.EventID]; !ok {
+		r.processed[event.EventID] = *event
+	}
+	return nil
+}
+
+func (r memoryProcessedEventRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for eventID, event := range r.processed {
+		if event.ProcessedAt.Before(before) {
+			delete(r.processed, eventID)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+type memorySearchRepository struct{ *memoryBackend }
+
+func (r memorySearchRepository) Index(ctx context.Context, message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.searchIndex[message.ID] = *message
+	return nil
+}
+
+func (r memorySearchRepository) Remove(ctx context.Context, messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.searchIndex, messageID)
+	return nil
+}
+
+func (r memorySearchRepository) RemoveBySender(ctx context.Context, senderID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, message := range r.searchIndex {
+		if message.SenderID == senderID && !r.conversations[message.ConversationID].LegalHold {
+			delete(r.searchIndex, id)
+		}
+	}
+	return nil
+}
+
+// Search khớp từng từ (không phân biệt hoa thường) như FTS của database
+func (r memorySearchRepository) Search(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+	if err := query.normalize(); err != nil {
+		return nil, err
+	}
+	cursor, err := query.decodeCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	terms := make(map[string]bool)
+	for _, term := range searchTerms(query.Text) {
+		terms[strings.ToLower(term)] = true
+	}
+
+	var results []SearchResult
+	for _, message := range r.searchIndex {
+		switch {
+		case r.activeParticipant(message.ConversationID, query.UserID) < 0,
+			query.ConversationID != "" && message.ConversationID != query.ConversationID,
+			query.SenderID != "" && message.SenderID != query.SenderID,
+			query.Type != "" && message.Type != query.Type,
+			!query.From.IsZero() && message.CreatedAt.Before(query.From),
+			!query.To.IsZero() && !message.CreatedAt.Before(query.To),
+			cursor != nil && !searchBefore(message.CreatedAt, message.ID, cursor.CreatedAt, cursor.MessageID):
+			continue
+		}
+
+		snippet, ok := highlightTerms(message.Content, terms)
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{
+			MessageID:      message.ID,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Type:           message.Type,
+			CreatedAt:      message.CreatedAt,
+			Snippet:        renderSnippet(snippet),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return searchBefore(results[j].CreatedAt, results[j].MessageID, results[i].CreatedAt, results[i].MessageID)
+	})
+	if len(results) > query.Limit+1 {
+		results = results[:query.Limit+1]
+	}
+	return newSearchPage(results, query.Limit), nil
+}
+
+// searchBefore cho biết (t, id) đứng sau (cursorTime, cursorID) theo thứ tự giảm dần
+func searchBefore(t time.Time, id string, cursorTime time.Time, cursorID string) bool {
+	return t.Before(cursorTime) || (t.Equal(cursorTime) && id < cursorID)
+}
+
+// highlightTerms đánh dấu các từ thuộc terms trong content, ok là true nếu content chứa đủ mọi từ
+func highlightTerms(content string, terms map[string]bool) (string, bool) {
+	var (
+		builder strings.Builder
+		found   = make(map[string]bool)
+		start   = -1
+	)
+	flush := func(end int) {
+		word := content[start:end]
+		if terms[strings.ToLower(word)] {
+			found[strings.ToLower(word)] = true
+			builder.WriteString(highlightStart + word + highlightStop)
+		} else {
+			builder.WriteString(word)
+		}
+		start = -1
+	}
+
+	for i, char := range content {
+		isWord := unicode.IsLetter(char) || unicode.IsDigit(char)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord:
+			if start >= 0 {
+				flush(i)
+			}
+			builder.WriteRune(char)
+		}
+	}
+	if start >= 0 {
+		flush(len(content))
+	}
+	return builder.String(), len(found) == len(terms)
+}
+
+type memoryRetentionRepository struct{ *memoryBackend }
+
+func (r memoryRetentionRepository) SetRetention(ctx context.Context, conversationID string, days *int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok {
+		return ErrNotFound
+	}
+	conversation.RetentionDays = days
+	r.conversations[conversationID] = conversation
+	return nil
+}
+
+func (r memoryRetentionRepository) SetLegalHold(ctx context.Context, conversationID string, hold bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok {
+		return ErrNotFound
+	}
+	conversation.LegalHold = hold
+	r.conversations[conversationID] = conversation
+	return nil
+}
+
+func (r memoryRetentionRepository) ListPolicies(ctx context.Context) ([]RetentionPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var policies []RetentionPolicy
+	for _, conversation := range r.conversations {
+		if conversation.LegalHold || conversation.RetentionDays != nil {
+			policies = append(policies, RetentionPolicy{
+				ConversationID: conversation.ID,
+				RetentionDays:  conversation.RetentionDays,
+				LegalHold:      conversation.LegalHold,
+			})
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ConversationID < policies[j].ConversationID })
+	return policies, nil
+}
+
+func (r memoryRetentionRepository) PurgeExpired(ctx context.Context, conversationID string, before time.Time, limit int) (int, error) {
+	return r.purge(limit, func(message models.Message, conversation models.Conversation) bool {
+		if conversationID == "" && conversation.RetentionDays != nil {
+			return false
+		}
+		return (conversationID == "" || message.ConversationID == conversationID) && message.CreatedAt.Before(before)
+	})
+}
+
+func (r memoryRetentionRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	return r.purge(limit, func(message models.Message, conversation models.Conversation) bool {
+		return message.DeletedAt.Valid && message.DeletedAt.Time.Before(before)
+	})
+}
+
+// purge xóa vĩnh viễn tối đa limit tin nhắn cũ nhất khớp match, bỏ qua conversation legal hold
+func (r memoryRetentionRepository) purge(limit int, match func(message models.Message, conversation models.Conversation) bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []models.Message
+	for _, message := range r.messages {
+		conversation := r.conversations[message.ConversationID]
+		if !conversation.LegalHold && match(message, conversation) {
+			expired = append(expired, message)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].CreatedAt.Before(expired[j].CreatedAt) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, message := range expired {
+		delete(r.messages, message.ID)
+		delete(r.searchIndex, message.ID)
+		delete(r.pins, pinKey{message.ConversationID, message.ID})
+		for key := range r.reactions {
+			if key.messageID == message.ID {
+				delete(r.reactions, key)
+			}
+		}
+	}
+	return len(expired), nil
+}
+
+type memoryPrivacyRepository struct{ *memoryBackend }
+
+func (r memoryPrivacyRepository) Memberships(ctx context.Context, userID string) ([]models.ConversationParticipant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var participants []models.ConversationParticipant
+	for _, participant := range r.participants {
+		if participant.UserID == userID {
+			participants = append(participants, participant)
+		}
+	}
+	return participants, nil
+}
+
+func (r memoryPrivacyRepository) ListSentMessages(ctx context.Context, userID string, afterCreatedAt time.Time, afterID string, limit int) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []models.Message
+	for _, message := range r.messages {
+		if message.SenderID == userID && (afterID == "" || messageAfter(message, afterCreatedAt, afterID)) {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messageAfter(messages[j], messages[i].CreatedAt, messages[i].ID)
+	})
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (r memoryPrivacyRepository) ListReactions(ctx context.Context, userID string) ([]models.MessageReaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reactions []models.MessageReaction
+	for _, reaction := range r.reactions {
+		if reaction.UserID == userID {
+			reactions = append(reactions, reaction)
+		}
+	}
+	sort.Slice(reactions, func(i, j int) bool { return reactions[i].CreatedAt.Before(reactions[j].CreatedAt) })
+	return reactions, nil
+}
+
+func (r memoryPrivacyRepository) ListReadStates(ctx context.Context, userID string) ([]models.ReadState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var states []models.ReadState
+	for key, state := range r.readStates {
+		if key.userID == userID {
+			states = append(states, state)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ConversationID < states[j].ConversationID })
+	return states, nil
+}
+
+func (r memoryPrivacyRepository) Erase(ctx context.Context, erasure Erasure) (*ErasureResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if erasure.Event != nil {
+		if err := r.createOutboxEvent(erasure.Event); err != nil {
+			return nil, err
+		}
+	}
+	result := &ErasureResult{}
+	if user, ok := r.users[erasure.UserID]; ok {
+		user.Username, user.Email, user.FullName = erasure.Username, erasure.Email, erasure.FullName
+		user.Avatar, user.Status = "", models.UserStatusOffline
+		r.users[user.ID] = user
+		result.Profile = true
+	}
+
+	held := func(conversationID string) bool { return r.conversations[conversationID].LegalHold }
+	for id, message := range r.messages {
+		switch {
+		case message.SenderID != erasure.UserID:
+			continue
+		case held(message.ConversationID):
+			result.HeldMessages++
+			continue
+		}
+
+		delete(r.searchIndex, id)
+		if erasure.Mode == ErasureDelete {
+			for key := range r.reactions {
+				if key.messageID == id {
+					delete(r.reactions, key)
+					result.Reactions++
+				}
+			}
+			delete(r.pins, pinKey{message.ConversationID, id})
+			delete(r.messages, id)
+		} else {
+			message.Content, message.Attachments, message.Reactions = "", "", ""
+			message.UpdatedAt = time.Now()
+			r.messages[id] = message
+		}
+		result.Messages++
+	}
+
+	for key, reaction := range r.reactions {
+		if key.userID == erasure.UserID && !held(reaction.ConversationID) {
+			delete(r.reactions, key)
+			result.Reactions++
+		}
+	}
+	for key := range r.readStates {
+		if key.userID == erasure.UserID {
+			delete(r.readStates, key)
+		}
+	}
+	now := time.Now()
+	for i := range r.participants {
+		if r.participants[i].UserID == erasure.UserID && r.participants[i].LeftAt == nil {
+			r.participants[i].LeftAt = &now
+			result.Memberships++
+		}
+	}
+	return result, nil
+}
+
+type memoryAuditRepository struct{ *memoryBackend }
+
+func (r memoryAuditRepository) Append(ctx context.Context, entry *models.AuditLog, event *models.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event != nil {
+		if err := r.createOutboxEvent(event); err != nil {
+			return err
+		}
+	}
+	r.nextAuditID++
+	entry.ID = r.nextAuditID
+	r.auditLogs = append(r.auditLogs, *entry)
+	return nil
+}
+
+func (r memoryAuditRepository) List(ctx context.Context, query AuditQuery) (*AuditPage, error) {
+	beforeID, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matches := func(filter, value string) bool { return filter == "" || filter == value }
+	var entries []models.AuditLog
+	// auditLogs được thêm theo thứ tự ID, duyệt ngược để mới nhất trước
+	for i := len(r.auditLogs) - 1; i >= 0 && len(entries) <= query.Limit; i-- {
+		entry := r.auditLogs[i]
+		if (beforeID > 0 && entry.ID >= beforeID) ||
+			!matches(query.ActorID, entry.ActorID) || !matches(query.Action, entry.Action) ||
+			!matches(query.TargetType, entry.TargetType) || !matches(query.TargetID, entry.TargetID) ||
+			!matches(query.ConversationID, entry.ConversationID) ||
+			(!query.From.IsZero() && entry.OccurredAt.Before(query.From)) ||
+			(!query.To.IsZero() && !entry.OccurredAt.Before(query.To)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return newAuditPage(entries, query.Limit), nil
+}
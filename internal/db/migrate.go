@@ -0,0 +1,430 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibeta/internal/logging"
+)
+
+// migrationFiles chứa các file SQL migration, mỗi dialect một thư mục:
+//
+//	migrations/<postgres|sqlite>/<version>_<name>.<up|down>.sql
+//
+// migrations/sqlite_nofts5 chứa bản thay thế cho các version cần FTS5, dùng khi
+// go-sqlite3 được build không có tag sqlite_fts5.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// sqliteNoFTS5Migrations thư mục migration thay thế khi SQLite không có FTS5
+const sqliteNoFTS5Migrations = "sqlite_nofts5"
+
+// SchemaMigrationsTable lưu các version đã được áp dụng
+const SchemaMigrationsTable = "schema_migrations"
+
+// migrationLockKey key của pg_advisory_lock, giữ trong suốt quá trình migrate để
+// nhiều WebSocket server và worker khởi động cùng lúc không migrate chồng lên nhau
+const migrationLockKey = 7_425_310_001
+
+// sqliteBusyTimeout thời gian chờ khóa ghi của SQLite khi process khác đang migrate
+const sqliteBusyTimeout = 30 * time.Second
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrPendingMigrations schema chưa ở version mới nhất và auto migrate bị tắt
+var ErrPendingMigrations = errors.New("database còn migration chưa áp dụng")
+
+// Migration một bước thay đổi schema có thể áp dụng (Up) và hoàn tác (Down)
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus trạng thái của một migration trong database
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations đọc các migration nhúng trong binary cho dialect, sắp xếp theo version.
+// Mỗi version phải có đủ file up và down.
+func LoadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("không có migration cho dialect %q: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("tên file migration không hợp lệ: %s (cần <version>_<name>.<up|down>.sql)", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d bị trùng: %s và %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s thiếu file up hoặc down", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator áp dụng các migration nhúng trong binary và ghi version vào schema_migrations.
+// Mỗi migration chạy trong một transaction nên lỗi giữa chừng không để lại schema dở dang.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// NewMigrator tạo migrator cho database với các migration theo dialect của nó
+func NewMigrator(database *Database) (*Migrator, error) {
+	sqlDB, err := database.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	dialect := database.Dialect()
+	migrations, err := LoadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == "sqlite" && !database.fts5 {
+		replacements, err := LoadMigrations(sqliteNoFTS5Migrations)
+		if err != nil {
+			return nil, err
+		}
+		migrations = replaceMigrations(migrations, replacements)
+	}
+	return &Migrator{db: sqlDB, dialect: dialect, migrations: migrations}, nil
+}
+
+// replaceMigrations thay các migration cùng version bằng bản trong replacements
+func replaceMigrations(migrations, replacements []Migration) []Migration {
+	byVersion := make(map[int]Migration, len(replacements))
+	for _, migration := range replacements {
+		byVersion[migration.Version] = migration
+	}
+	for i, migration := range migrations {
+		if replacement, ok := byVersion[migration.Version]; ok {
+			migrations[i] = replacement
+		}
+	}
+	return migrations
+}
+
+// Migrations trả về các migration đã biết, theo thứ tự version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest version mới nhất có trong binary
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up áp dụng các migration chưa chạy tới version target (0 là mới nhất).
+// Trả về các migration đã được áp dụng bởi lần gọi này.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	if target == 0 {
+		target = m.Latest()
+	}
+
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, migration := range m.migrations {
+			if migration.Version > target {
+				break
+			}
+			done, err := m.apply(ctx, conn, migration, true)
+			if err != nil {
+				return err
+			}
+			if done {
+				applied = append(applied, migration)
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down hoàn tác steps migration mới nhất đã được áp dụng
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			done, err := m.apply(ctx, conn, migration, false)
+			if err != nil {
+				return err
+			}
+			if done {
+				reverted = append(reverted, migration)
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status trả về trạng thái của mọi migration đã biết
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	versions, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending trả về các migration chưa được áp dụng
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// withLock chạy fn trên một connection riêng, giữ khóa migrate trên PostgreSQL.
+// SQLite không có advisory lock; mỗi migration dùng BEGIN IMMEDIATE để lấy khóa ghi
+// và kiểm tra lại version trong transaction.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case "postgres":
+		slog.Debug("Chờ khóa migrate", "key", migrationLockKey)
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+			return fmt.Errorf("không lấy được khóa migrate: %w", err)
+		}
+		defer func() {
+			// Dùng context riêng để vẫn nhả khóa khi ctx đã bị cancel
+			unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+				slog.Warn("Không nhả được khóa migrate", logging.Err(err))
+			}
+		}()
+	case "sqlite":
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", sqliteBusyTimeout.Milliseconds())); err != nil {
+			return err
+		}
+	}
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable tạo bảng schema_migrations nếu chưa có
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+SchemaMigrationsTable+` (
+    version    BIGINT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("không tạo được bảng %s: %w", SchemaMigrationsTable, err)
+	}
+	return nil
+}
+
+// appliedVersions trả về version đã áp dụng và thời điểm áp dụng
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+SchemaMigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("không đọc được %s: %w", SchemaMigrationsTable, err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// apply chạy migration theo chiều up hoặc down trong một transaction và cập nhật
+// schema_migrations. Trả về false nếu migration đã ở trạng thái mong muốn
+// (một process khác vừa áp dụng xong).
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) (bool, error) {
+	begin := "BEGIN"
+	if m.dialect == "sqlite" {
+		begin = "BEGIN IMMEDIATE"
+	}
+	if _, err := conn.ExecContext(ctx, begin); err != nil {
+		return false, err
+	}
+
+	done, err := m.applyInTx(ctx, conn, migration, up)
+	if err != nil {
+		if _, rollbackErr := conn.ExecContext(context.Background(), "ROLLBACK"); rollbackErr != nil {
+			err = errors.Join(err, rollbackErr)
+		}
+		return false, err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, err
+	}
+	return done, nil
+}
+
+func (m *Migrator) applyInTx(ctx context.Context, conn *sql.Conn, migration Migration, up bool) (bool, error) {
+	var count int
+	row := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+SchemaMigrationsTable+" WHERE version = "+m.placeholder(1), migration.Version)
+	if err := row.Scan(&count); err != nil {
+		return false, err
+	}
+	if applied := count > 0; applied == up {
+		return false, nil
+	}
+
+	direction, script := "up", migration.Up
+	if !up {
+		direction, script = "down", migration.Down
+	}
+
+	start := time.Now()
+	if _, err := conn.ExecContext(ctx, script); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			err = fmt.Errorf("%w (database đã tạo message_search bằng FTS5, binary cần build với -tags sqlite_fts5, xem Makefile)", err)
+		}
+		return false, fmt.Errorf("migration %04d_%s (%s) lỗi: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	var err error
+	if up {
+		_, err = conn.ExecContext(ctx,
+			"INSERT INTO "+SchemaMigrationsTable+" (version, name, applied_at) VALUES ("+m.placeholder(1)+", "+m.placeholder(2)+", "+m.placeholder(3)+")",
+			migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = conn.ExecContext(ctx, "DELETE FROM "+SchemaMigrationsTable+" WHERE version = "+m.placeholder(1), migration.Version)
+	}
+	if err != nil {
+		return false, fmt.Errorf("không cập nhật được %s: %w", SchemaMigrationsTable, err)
+	}
+
+	slog.Info("Đã áp dụng migration", "version", migration.Version, "name", migration.Name,
+		"direction", direction, "duration", time.Since(start).Round(time.Millisecond))
+	return true, nil
+}
+
+// placeholder trả về tham số thứ n theo cú pháp của dialect
+func (m *Migrator) placeholder(n int) string {
+	if m.dialect == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// migrateOnStartup áp dụng migration khi khởi động nếu autoMigrate bật,
+// ngược lại chỉ kiểm tra schema đã ở version mới nhất
+func migrateOnStartup(database *Database, autoMigrate bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	migrator, err := NewMigrator(database)
+	if err != nil {
+		return err
+	}
+
+	if autoMigrate {
+		_, err := migrator.Up(ctx, 0)
+		return err
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d migration (mới nhất %d), chạy `go run ./cmd/migrate up` hoặc bật DB_AUTO_MIGRATE",
+			ErrPendingMigrations, len(pending), migrator.Latest())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func migrationVersions(migrations []Migration) []int {
+	versions := make([]int, len(migrations))
+	for i, migration := range migrations {
+		versions[i] = migration.Version
+	}
+	return versions
+}
+
+// sqliteTables trả về các bảng (kể cả bảng ảo FTS5) đang có trong database
+func sqliteTables(t *testing.T, database *Database) map[string]bool {
+	t.Helper()
+	var names []string
+	if err := database.db.Raw("SELECT name FROM sqlite_master WHERE type = 'table'").Scan(&names).Error; err != nil {
+		t.Fatalf("đọc sqlite_master: %v", err)
+	}
+	tables := make(map[string]bool, len(names))
+	for _, name := range names {
+		tables[name] = true
+	}
+	return tables
+}
+
+func TestLoadMigrations(t *testing.T) {
+	postgres, err := LoadMigrations("postgres")
+	if err != nil {
+		t.Fatalf("LoadMigrations(postgres): %v", err)
+	}
+	sqlite, err := LoadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("LoadMigrations(sqlite): %v", err)
+	}
+
+	for dialect, migrations := range map[string][]Migration{"postgres": postgres, "sqlite": sqlite} {
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("%s: migration thứ %d có version %d, muốn liên tiếp từ 1", dialect, i, migration.Version)
+			}
+		}
+	}
+
+	// Hai dialect phải có cùng các bước để schema_migrations có cùng ý nghĩa
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres có %d migration, sqlite có %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d: postgres %04d_%s, sqlite %04d_%s", i,
+				postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+
+	if _, err := LoadMigrations("mysql"); err == nil {
+		t.Error("LoadMigrations(mysql) không trả lỗi")
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDatabase(t)
+	migrator, err := NewMigrator(database)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	all := migrationVersions(migrator.Migrations())
+
+	applied, err := migrator.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if got := migrationVersions(applied); !reflect.DeepEqual(got, all) {
+		t.Fatalf("Up áp dụng %v, muốn %v", got, all)
+	}
+	tables := sqliteTables(t, database)
+	for _, table := range []string{"users", "conversations", "conversation_participants", "messages",
+		"message_reactions", "conversation_read_states", "outbox_events", "message_search", "audit_logs",
+		"pinned_messages", "processed_events", SchemaMigrationsTable} {
+		if !tables[table] {
+			t.Errorf("thiếu bảng %s sau Up", table)
+		}
+	}
+
+	// Up lần hai không áp dụng lại
+	if applied, err := migrator.Up(ctx, 0); err != nil || len(applied) != 0 {
+		t.Fatalf("Up lần hai áp dụng %v (err %v), muốn không có", migrationVersions(applied), err)
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("Pending = %v (err %v), muốn rỗng", migrationVersions(pending), err)
+	}
+
+	// Down hoàn tác theo thứ tự ngược lại
+	reverted, err := migrator.Down(ctx, len(all))
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	want := make([]int, len(all))
+	for i, version := range all {
+		want[len(all)-1-i] = version
+	}
+	if got := migrationVersions(reverted); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Down hoàn tác %v, muốn %v", got, want)
+	}
+	if tables := sqliteTables(t, database); tables["messages"] || tables["message_search"] {
+		t.Errorf("còn bảng sau khi Down toàn bộ: %v", tables)
+	}
+
+	// Schema dựng lại được sau khi hoàn tác
+	if applied, err := migrator.Up(ctx, 0); err != nil || len(applied) != len(all) {
+		t.Fatalf("Up sau Down áp dụng %v (err %v), muốn %v", migrationVersions(applied), err, all)
+	}
+}
+
+func TestMigratorUpToTarget(t *testing.T) {
+	ctx := context.Background()
+	migrator, err := NewMigrator(newTestDatabase(t))
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx, 3); err != nil {
+		t.Fatalf("Up(3): %v", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if applied := status.AppliedAt != nil; applied != (status.Version <= 3) {
+			t.Errorf("migration %d applied = %v", status.Version, applied)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down(1): %v", err)
+	}
+	if got := migrationVersions(reverted); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("Down(1) hoàn tác %v, muốn [3]", got)
+	}
+}
+
+func TestMigrateOnStartup(t *testing.T) {
+	tests := []struct {
+		name        string
+		autoMigrate bool
+		wantErr     error
+	}{
+		{name: "auto migrate", autoMigrate: true},
+		{name: "chỉ kiểm tra schema", autoMigrate: false, wantErr: ErrPendingMigrations},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := migrateOnStartup(newTestDatabase(t), tt.autoMigrate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("migrateOnStartup = %v, muốn %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_events_unpublished;
+DROP INDEX IF EXISTS idx_conversation_participants_user_conversation;
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id);
+DROP INDEX IF EXISTS idx_messages_conversation_created_at;
@@ -0,0 +1,19 @@
+-- Full-text index của nội dung tin nhắn, được worker cập nhật khi tin nhắn được tạo, sửa, xóa
+CREATE TABLE IF NOT EXISTS message_search (
+    message_id      TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    sender_id       TEXT NOT NULL,
+    type            TEXT NOT NULL,
+    content         TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL,
+    document        TSVECTOR NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_message_search_document ON message_search USING GIN (document);
+CREATE INDEX IF NOT EXISTS idx_message_search_conversation_created_at ON message_search (conversation_id, created_at);
+
+-- Index các tin nhắn đã có trước migration này
+INSERT INTO message_search (message_id, conversation_id, sender_id, type, content, created_at, document)
+SELECT id, conversation_id, sender_id, type, COALESCE(content, ''), created_at, to_tsvector('simple', COALESCE(content, ''))
+FROM messages
+WHERE deleted_at IS NULL AND created_at IS NOT NULL
+ON CONFLICT (message_id) DO NOTHING;
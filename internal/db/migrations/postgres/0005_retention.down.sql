@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN IF EXISTS legal_hold;
+ALTER TABLE conversations DROP COLUMN IF EXISTS retention_days;
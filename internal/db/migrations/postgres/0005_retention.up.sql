@@ -0,0 +1,3 @@
+-- Retention riêng của conversation (NULL dùng mặc định của workspace) và legal hold
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS retention_days INTEGER;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT FALSE;
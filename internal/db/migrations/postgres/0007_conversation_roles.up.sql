@@ -0,0 +1,14 @@
+-- Vai trò của thành viên; người tạo conversation đang là thành viên trở thành owner
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member';
+UPDATE conversation_participants p SET role = 'owner'
+FROM conversations c
+WHERE c.id = p.conversation_id AND c.created_by = p.user_id AND p.left_at IS NULL;
+
+-- Tin nhắn được ghim
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    conversation_id TEXT NOT NULL,
+    message_id      TEXT NOT NULL,
+    pinned_by       TEXT NOT NULL,
+    pinned_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (conversation_id, message_id)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_events_partition_key;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS dead_lettered_at;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS locked_until;
@@ -0,0 +1,5 @@
+-- Lease của relay đang publish, lịch thử lại và trạng thái dead-letter của outbox event
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_partition_key ON outbox_events (partition_key);
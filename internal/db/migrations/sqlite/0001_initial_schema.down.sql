@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS conversation_participants;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,84 @@
+-- Schema ban đầu, tương đương với AutoMigrate trước đây.
+-- Dùng IF NOT EXISTS để database đã được AutoMigrate tạo được nhận làm version 1.
+
+CREATE TABLE IF NOT EXISTS users (
+    id          TEXT PRIMARY KEY,
+    username    TEXT NOT NULL,
+    email       TEXT NOT NULL,
+    full_name   TEXT NOT NULL,
+    avatar      TEXT,
+    status      TEXT DEFAULT 'offline',
+    last_active DATETIME,
+    created_at  DATETIME,
+    updated_at  DATETIME,
+    deleted_at  DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id          TEXT PRIMARY KEY,
+    type        TEXT NOT NULL,
+    name        TEXT,
+    description TEXT,
+    avatar      TEXT,
+    created_by  TEXT NOT NULL,
+    created_at  DATETIME,
+    updated_at  DATETIME,
+    deleted_at  DATETIME,
+    CONSTRAINT fk_conversations_creator FOREIGN KEY (created_by) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_created_by ON conversations (created_by);
+CREATE INDEX IF NOT EXISTS idx_conversations_deleted_at ON conversations (deleted_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id              TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    sender_id       TEXT NOT NULL,
+    content         TEXT,
+    type            TEXT NOT NULL,
+    status          TEXT DEFAULT 'sent',
+    reply_to_id     TEXT,
+    attachments     TEXT,
+    reactions       TEXT,
+    edited_at       DATETIME,
+    created_at      DATETIME,
+    updated_at      DATETIME,
+    deleted_at      DATETIME,
+    CONSTRAINT fk_messages_sender FOREIGN KEY (sender_id) REFERENCES users (id),
+    CONSTRAINT fk_conversations_messages FOREIGN KEY (conversation_id) REFERENCES conversations (id)
+);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages (sender_id);
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to_id ON messages (reply_to_id);
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at);
+
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    user_id         TEXT NOT NULL,
+    joined_at       DATETIME DEFAULT CURRENT_TIMESTAMP,
+    left_at         DATETIME,
+    CONSTRAINT fk_conversations_participants FOREIGN KEY (conversation_id) REFERENCES conversations (id),
+    CONSTRAINT fk_conversation_participants_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_conversation_id ON conversation_participants (conversation_id);
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user_id ON conversation_participants (user_id);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id        TEXT NOT NULL,
+    event_type      TEXT NOT NULL,
+    conversation_id TEXT,
+    partition_key   TEXT,
+    payload         BLOB NOT NULL,
+    headers         TEXT,
+    attempts        INTEGER DEFAULT 0,
+    last_error      TEXT,
+    created_at      DATETIME,
+    published_at    DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_conversation_id ON outbox_events (conversation_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at);
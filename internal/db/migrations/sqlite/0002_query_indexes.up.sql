@@ -0,0 +1,12 @@
+-- Index cho các truy vấn chính:
+--   GetMessages: WHERE conversation_id = ? ORDER BY created_at
+--   GetConversations: JOIN conversation_participants theo user_id rồi conversation_id
+--   PublishOutboxBatch: WHERE published_at IS NULL ORDER BY id
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created_at ON messages (conversation_id, created_at);
+-- idx_messages_conversation_created_at đã bao gồm prefix conversation_id
+DROP INDEX IF EXISTS idx_messages_conversation_id;
+
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user_conversation ON conversation_participants (user_id, conversation_id);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS conversation_read_states;
+DROP TABLE IF EXISTS message_reactions;
@@ -0,0 +1,20 @@
+-- Reaction của user lên tin nhắn, mỗi (message, user, emoji) một dòng
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id      TEXT NOT NULL,
+    user_id         TEXT NOT NULL,
+    emoji           TEXT NOT NULL,
+    conversation_id TEXT NOT NULL,
+    created_at      DATETIME,
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+CREATE INDEX IF NOT EXISTS idx_message_reactions_conversation_id ON message_reactions (conversation_id);
+
+-- Vị trí đã đọc của user trong conversation, dùng để tính số tin chưa đọc
+CREATE TABLE IF NOT EXISTS conversation_read_states (
+    conversation_id      TEXT NOT NULL,
+    user_id              TEXT NOT NULL,
+    last_read_message_id TEXT,
+    last_read_at         DATETIME,
+    updated_at           DATETIME,
+    PRIMARY KEY (conversation_id, user_id)
+);
@@ -0,0 +1,19 @@
+-- Full-text index của nội dung tin nhắn, được worker cập nhật khi tin nhắn được tạo, sửa, xóa.
+-- Cần go-sqlite3 build với tag sqlite_fts5 (make build đã bật sẵn), không có FTS5 thì
+-- migrator dùng bản trong migrations/sqlite_nofts5.
+-- created_at lưu dạng chuỗi UTC '2006-01-02T15:04:05.000Z' để so sánh chuỗi đúng thứ tự thời gian.
+CREATE VIRTUAL TABLE IF NOT EXISTS message_search USING fts5(
+    content,
+    message_id UNINDEXED,
+    conversation_id UNINDEXED,
+    sender_id UNINDEXED,
+    type UNINDEXED,
+    created_at UNINDEXED,
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+
+-- Index các tin nhắn đã có trước migration này
+INSERT INTO message_search (content, message_id, conversation_id, sender_id, type, created_at)
+SELECT COALESCE(content, ''), id, conversation_id, sender_id, type, strftime('%Y-%m-%dT%H:%M:%fZ', created_at)
+FROM messages
+WHERE deleted_at IS NULL AND created_at IS NOT NULL;
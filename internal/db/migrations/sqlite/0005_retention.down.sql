@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN legal_hold;
+ALTER TABLE conversations DROP COLUMN retention_days;
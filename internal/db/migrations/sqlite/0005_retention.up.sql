@@ -0,0 +1,3 @@
+-- Retention riêng của conversation (NULL dùng mặc định của workspace) và legal hold
+ALTER TABLE conversations ADD COLUMN retention_days INTEGER;
+ALTER TABLE conversations ADD COLUMN legal_hold NUMERIC NOT NULL DEFAULT false;
@@ -0,0 +1,30 @@
+-- Nhật ký các thao tác quản trị và liên quan bảo mật, chỉ được thêm
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at     DATETIME NOT NULL,
+    actor_id        TEXT NOT NULL,
+    action          TEXT NOT NULL,
+    target_type     TEXT NOT NULL,
+    target_id       TEXT NOT NULL,
+    conversation_id TEXT,
+    before          TEXT,
+    after           TEXT,
+    request_id      TEXT,
+    ip              TEXT,
+    user_agent      TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_occurred_at ON audit_logs (occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_conversation_id ON audit_logs (conversation_id);
+
+-- Chặn sửa và xóa ở tầng database, kể cả từ ứng dụng
+CREATE TRIGGER IF NOT EXISTS audit_logs_no_update BEFORE UPDATE ON audit_logs
+BEGIN
+    SELECT RAISE(ABORT, 'audit_logs chỉ cho phép thêm bản ghi');
+END;
+CREATE TRIGGER IF NOT EXISTS audit_logs_no_delete BEFORE DELETE ON audit_logs
+BEGIN
+    SELECT RAISE(ABORT, 'audit_logs chỉ cho phép thêm bản ghi');
+END;
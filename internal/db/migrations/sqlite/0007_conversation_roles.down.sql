@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pinned_messages;
+ALTER TABLE conversation_participants DROP COLUMN role;
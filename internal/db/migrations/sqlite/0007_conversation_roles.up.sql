@@ -0,0 +1,15 @@
+-- Vai trò của thành viên; người tạo conversation đang là thành viên trở thành owner
+ALTER TABLE conversation_participants ADD COLUMN role TEXT NOT NULL DEFAULT 'member';
+UPDATE conversation_participants SET role = 'owner'
+WHERE left_at IS NULL AND user_id = (
+    SELECT created_by FROM conversations WHERE conversations.id = conversation_participants.conversation_id
+);
+
+-- Tin nhắn được ghim
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    conversation_id TEXT NOT NULL,
+    message_id      TEXT NOT NULL,
+    pinned_by       TEXT NOT NULL,
+    pinned_at       DATETIME NOT NULL,
+    PRIMARY KEY (conversation_id, message_id)
+);
@@ -0,0 +1,7 @@
+-- Event consumer đã xử lý, để bỏ qua event được gửi lại với cùng event_id
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id     TEXT PRIMARY KEY,
+    event_type   TEXT NOT NULL,
+    processed_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events (processed_at);
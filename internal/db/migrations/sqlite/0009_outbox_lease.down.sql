@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_events_partition_key;
+ALTER TABLE outbox_events DROP COLUMN dead_lettered_at;
+ALTER TABLE outbox_events DROP COLUMN next_attempt_at;
+ALTER TABLE outbox_events DROP COLUMN locked_until;
@@ -0,0 +1,5 @@
+-- Lease của relay đang publish, lịch thử lại và trạng thái dead-letter của outbox event
+ALTER TABLE outbox_events ADD COLUMN locked_until DATETIME;
+ALTER TABLE outbox_events ADD COLUMN next_attempt_at DATETIME;
+ALTER TABLE outbox_events ADD COLUMN dead_lettered_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_partition_key ON outbox_events (partition_key);
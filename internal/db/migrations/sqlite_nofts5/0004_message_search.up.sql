@@ -0,0 +1,21 @@
+-- Bản thay thế của migrations/sqlite/0004 khi go-sqlite3 được build không có FTS5:
+-- message_search là bảng thường và tìm kiếm dùng LIKE. Các cột giống bảng FTS5 để
+-- worker, retention và GDPR dùng chung câu lệnh.
+-- created_at lưu dạng chuỗi UTC '2006-01-02T15:04:05.000Z' để so sánh chuỗi đúng thứ tự thời gian.
+CREATE TABLE IF NOT EXISTS message_search (
+    content TEXT NOT NULL DEFAULT '',
+    message_id TEXT NOT NULL PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    sender_id TEXT NOT NULL,
+    type TEXT,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_search_conversation ON message_search (conversation_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_message_search_sender ON message_search (sender_id);
+
+-- Index các tin nhắn đã có trước migration này
+INSERT INTO message_search (content, message_id, conversation_id, sender_id, type, created_at)
+SELECT COALESCE(content, ''), id, conversation_id, sender_id, type, strftime('%Y-%m-%dT%H:%M:%fZ', created_at)
+FROM messages
+WHERE deleted_at IS NULL AND created_at IS NOT NULL;
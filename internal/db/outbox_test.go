@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+func TestProcessedEvents(t *testing.T) {
+	ctx := context.Background()
+	_, store := newTestStore(t)
+	now := time.Now().UTC()
+
+	if processed, err := store.ProcessedEvents.IsProcessed(ctx, "evt_1"); err != nil || processed {
+		t.Fatalf("IsProcessed trước khi ghi nhận = %v (err %v), muốn false", processed, err)
+	}
+
+	old := &models.ProcessedEvent{EventID: "evt_old", EventType: "reaction", ProcessedAt: now.Add(-48 * time.Hour)}
+	for _, event := range []*models.ProcessedEvent{
+		{EventID: "evt_1", EventType: "reaction", ProcessedAt: now},
+		{EventID: "evt_1", EventType: "reaction", ProcessedAt: now}, // ghi nhận lại không lỗi
+		old,
+	} {
+		if err := store.ProcessedEvents.MarkProcessed(ctx, event); err != nil {
+			t.Fatalf("MarkProcessed %s: %v", event.EventID, err)
+		}
+	}
+	if processed, err := store.ProcessedEvents.IsProcessed(ctx, "evt_1"); err != nil || !processed {
+		t.Fatalf("IsProcessed sau khi ghi nhận = %v (err %v), muốn true", processed, err)
+	}
+
+	purged, err := store.ProcessedEvents.PurgeBefore(ctx, now.Add(-24*time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeBefore = %d (err %v), muốn 1", purged, err)
+	}
+	if processed, _ := store.ProcessedEvents.IsProcessed(ctx, old.EventID); processed {
+		t.Error("ghi nhận cũ chưa bị xóa")
+	}
+	if processed, _ := store.ProcessedEvents.IsProcessed(ctx, "evt_1"); !processed {
+		t.Error("ghi nhận mới bị xóa")
+	}
+}
+
+// claimedIDs claim event và trả về event_id theo thứ tự
+func claimedIDs(t *testing.T, store *Store, lease time.Duration) []string {
+	t.Helper()
+	events, err := store.Outbox.ClaimBatch(context.Background(), 10, lease)
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+	ids := make([]string, len(events))
+	for i, event := range events {
+		if event.LockedUntil == nil {
+			t.Errorf("event %s được claim không có lease", event.EventID)
+		}
+		ids[i] = event.EventID
+	}
+	return ids
+}
+
+func TestOutboxClaimBatch(t *testing.T) {
+	_, sqliteStore := newTestStore(t)
+	for name, store := range map[string]*Store{"sqlite": sqliteStore, "memory": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ids := make(map[string]uint)
+			save := func(eventID, partitionKey string) {
+				t.Helper()
+				event := &models.OutboxEvent{EventID: eventID, EventType: "message", PartitionKey: partitionKey,
+					Payload: []byte("{}"), CreatedAt: time.Now()}
+				if err := store.Outbox.Save(ctx, event); err != nil {
+					t.Fatalf("Save %s: %v", eventID, err)
+				}
+				ids[eventID] = event.ID
+			}
+			save("a1", "a")
+			save("a2", "a")
+			save("b1", "b")
+
+			if got := claimedIDs(t, store, time.Minute); !reflect.DeepEqual(got, []string{"a1", "a2", "b1"}) {
+				t.Fatalf("claim lần đầu = %v, muốn [a1 a2 b1]", got)
+			}
+			if got := claimedIDs(t, store, time.Minute); len(got) != 0 {
+				t.Fatalf("claim khi event đang bị lease = %v, muốn rỗng", got)
+			}
+
+			// a1 publish xong, b1 lỗi chờ thử lại, a2 được trả lease
+			if err := store.Outbox.MarkPublished(ctx, ids["a1"]); err != nil {
+				t.Fatalf("MarkPublished: %v", err)
+			}
+			if err := store.Outbox.MarkFailed(ctx, ids["b1"], "broker lỗi", time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("MarkFailed: %v", err)
+			}
+			if err := store.Outbox.Release(ctx, []uint{ids["a2"]}); err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+			save("b2", "b")
+
+			// b2 bị chặn vì b1 trước nó đang chờ thử lại
+			if got := claimedIDs(t, store, -time.Second); !reflect.DeepEqual(got, []string{"a2"}) {
+				t.Fatalf("claim sau khi b1 lỗi = %v, muốn [a2]", got)
+			}
+			// Lease âm đã hết hạn nên a2 được claim lại
+			if got := claimedIDs(t, store, time.Minute); !reflect.DeepEqual(got, []string{"a2"}) {
+				t.Fatalf("claim sau khi lease hết hạn = %v, muốn [a2]", got)
+			}
+
+			// b1 hết lượt thử chuyển sang dead-letter và không chặn b2 nữa
+			if err := store.Outbox.MarkDeadLettered(ctx, ids["b1"], "broker lỗi"); err != nil {
+				t.Fatalf("MarkDeadLettered: %v", err)
+			}
+			events, err := store.Outbox.ClaimBatch(ctx, 10, time.Minute)
+			if err != nil || len(events) != 1 || events[0].EventID != "b2" {
+				t.Fatalf("claim sau dead-letter = %v (err %v), muốn [b2]", events, err)
+			}
+
+			// Event lỗi giữ số lần thử và lỗi cuối khi được claim lại
+			if err := store.Outbox.MarkFailed(ctx, ids["b2"], "timeout", time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("MarkFailed: %v", err)
+			}
+			events, err = store.Outbox.ClaimBatch(ctx, 10, time.Minute)
+			if err != nil || len(events) != 1 || events[0].Attempts != 1 || events[0].LastError != "timeout" {
+				t.Fatalf("claim lại b2 = %+v (err %v), muốn attempts 1 và lỗi timeout", events, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vibeta/internal/logging"
+
+	"gorm.io/gorm"
+)
+
+// Bảng messages trên PostgreSQL có thể được chuyển sang declarative partitioning theo
+// tháng của created_at bằng cmd/partition. Mỗi tháng là một partition messages_pYYYYMM,
+// messages_default nhận các tin nhắn nằm ngoài mọi partition (ví dụ dữ liệu import rất cũ).
+//
+// Primary key đổi thành (id, created_at) vì PostgreSQL yêu cầu unique key chứa partition key,
+// nên bảng messages không còn tự đảm bảo id là duy nhất. Bảng message_ids (primary key id)
+// được trigger của messages cập nhật khi thêm/xóa tin nhắn: tin nhắn trùng id với created_at
+// khác bị từ chối với lỗi unique violation. Đổi lại mỗi lần ghi tin nhắn thêm một insert vào
+// message_ids.
+const (
+	messagesTable              = "messages"
+	messagesPartitionedTable   = "messages_partitioned"
+	messagesUnpartitionedTable = "messages_unpartitioned"
+	messagesDefaultPartition   = "messages_default"
+	messageIDsTable            = "message_ids"
+)
+
+// partitionLockKey key của pg_advisory_xact_lock khi tạo partition, để nhiều worker
+// không tạo cùng một partition cùng lúc
+const partitionLockKey = 7_425_310_002
+
+var (
+	// ErrPartitionUnsupported partition chỉ hỗ trợ PostgreSQL
+	ErrPartitionUnsupported = errors.New("partition bảng messages chỉ hỗ trợ PostgreSQL")
+	// ErrNotPartitioned bảng messages chưa được chuyển sang partition
+	ErrNotPartitioned = errors.New("bảng messages chưa được partition, chạy `go run ./cmd/partition convert`")
+	// ErrAlreadyPartitioned bảng messages đã được partition
+	ErrAlreadyPartitioned = errors.New("bảng messages đã được partition")
+)
+
+// MessagePartition một partition của bảng messages
+type MessagePartition struct {
+	Name  string
+	Bound string // Ví dụ FOR VALUES FROM (...) TO (...), hoặc DEFAULT
+	// EstimatedRows ước lượng từ thống kê của PostgreSQL (pg_class.reltuples), -1 nếu chưa ANALYZE
+	EstimatedRows int64
+}
+
+// ConvertOptions tùy chọn khi chuyển bảng messages sang partition
+type ConvertOptions struct {
+	// DropOld xóa bảng cũ sau khi chuyển; mặc định bảng cũ được giữ lại với tên
+	// messages_unpartitioned để có thể quay lại
+	DropOld bool
+}
+
+// PartitionManager tạo và liệt kê partition theo tháng của bảng messages trên PostgreSQL
+type PartitionManager struct {
+	db            *gorm.DB
+	premakeMonths int
+}
+
+// NewPartitionManager tạo manager tạo sẵn partition cho premakeMonths tháng tiếp theo
+func NewPartitionManager(database *Database, premakeMonths int) (*PartitionManager, error) {
+	if database.Dialect() != "postgres" {
+		return nil, ErrPartitionUnsupported
+	}
+	return &PartitionManager{db: database.db, premakeMonths: premakeMonths}, nil
+}
+
+// Partitioned cho biết bảng messages đã là bảng partition hay chưa
+func (m *PartitionManager) Partitioned(ctx context.Context) (bool, error) {
+	return isPartitioned(m.db.WithContext(ctx), messagesTable)
+}
+
+// List trả về các partition của bảng messages theo tên
+func (m *PartitionManager) List(ctx context.Context) ([]MessagePartition, error) {
+	var partitions []MessagePartition
+	err := m.db.WithContext(ctx).Raw(`SELECT c.relname AS name,
+			pg_get_expr(c.relpartbound, c.oid) AS bound,
+			c.reltuples::bigint AS estimated_rows
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass(?)
+		ORDER BY c.relname`, messagesTable).
+		Scan(&partitions).Error
+	return partitions, err
+}
+
+// Ensure tạo các partition còn thiếu từ tháng chứa now tới premakeMonths tháng sau đó,
+// trả về tên các partition vừa tạo
+func (m *PartitionManager) Ensure(ctx context.Context, now time.Time) ([]string, error) {
+	var created []string
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", partitionLockKey).Error; err != nil {
+			return err
+		}
+
+		partitioned, err := isPartitioned(tx, messagesTable)
+		if err != nil {
+			return err
+		}
+		if !partitioned {
+			return ErrNotPartitioned
+		}
+
+		// Bảng đã chuyển bằng phiên bản trước chưa có message_ids
+		if err := ensureMessageIDs(tx); err != nil {
+			return err
+		}
+
+		created, err = createMonthlyPartitions(tx, messagesTable, monthStart(now), m.premakeMonths)
+		return err
+	})
+	return created, err
+}
+
+// Run kiểm tra và tạo partition mỗi interval cho tới khi ctx bị hủy. Bảng chưa được
+// partition thì bỏ qua, để có thể chạy cmd/partition convert khi worker đang chạy.
+func (m *PartitionManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := m.Ensure(ctx, time.Now())
+		switch {
+		case errors.Is(err, ErrNotPartitioned):
+			slog.Debug("Bảng messages chưa được partition, bỏ qua tạo partition")
+		case err != nil && ctx.Err() == nil:
+			slog.Error("Lỗi tạo partition bảng messages", logging.Err(err))
+		case len(created) > 0:
+			slog.Info("Đã tạo partition bảng messages", "partitions", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Convert chuyển bảng messages hiện có sang partition theo tháng trong một transaction:
+// tạo bảng partition cùng cấu trúc, tạo partition từ tháng của tin nhắn cũ nhất tới
+// premakeMonths tháng sau hiện tại, copy dữ liệu từng tháng, đổi tên bảng rồi tạo lại index.
+//
+// Bảng cũ bị khóa SHARE trong lúc chuyển: vẫn đọc được, ghi phải chờ tới khi xong.
+func (m *PartitionManager) Convert(ctx context.Context, options ConvertOptions) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", partitionLockKey).Error; err != nil {
+			return err
+		}
+
+		partitioned, err := isPartitioned(tx, messagesTable)
+		if err != nil {
+			return err
+		}
+		if partitioned {
+			return ErrAlreadyPartitioned
+		}
+		if exists, err := tableExists(tx, messagesUnpartitionedTable); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("bảng %s đã tồn tại, xóa hoặc đổi tên trước khi chuyển", messagesUnpartitionedTable)
+		}
+
+		if err := tx.Exec("LOCK TABLE " + messagesTable + " IN SHARE MODE").Error; err != nil {
+			return err
+		}
+
+		// created_at là partition key nên không được NULL
+		if err := tx.Exec("UPDATE " + messagesTable + " SET created_at = COALESCE(updated_at, now()) WHERE created_at IS NULL").Error; err != nil {
+			return err
+		}
+
+		// LIKE giữ nguyên cột, thứ tự cột và default, kể cả cột do migration sau này thêm vào
+		statements := []string{
+			"CREATE TABLE " + messagesPartitionedTable + " (LIKE " + messagesTable + " INCLUDING DEFAULTS) PARTITION BY RANGE (created_at)",
+			"ALTER TABLE " + messagesPartitionedTable + " ALTER COLUMN created_at SET NOT NULL",
+			"ALTER TABLE " + messagesPartitionedTable + " ADD PRIMARY KEY (id, created_at)",
+			"ALTER TABLE " + messagesPartitionedTable + " ADD CONSTRAINT fk_messages_sender FOREIGN KEY (sender_id) REFERENCES users (id)",
+			"ALTER TABLE " + messagesPartitionedTable + " ADD CONSTRAINT fk_conversations_messages FOREIGN KEY (conversation_id) REFERENCES conversations (id)",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		var oldest sql.NullTime
+		if err := tx.Raw("SELECT MIN(created_at) FROM " + messagesTable).Row().Scan(&oldest); err != nil {
+			return err
+		}
+		now := monthStart(time.Now())
+		from := now
+		if oldest.Valid && oldest.Time.Before(now) {
+			from = monthStart(oldest.Time)
+		}
+		months := monthsBetween(from, now) + m.premakeMonths
+		if _, err := createMonthlyPartitions(tx, messagesPartitionedTable, from, months); err != nil {
+			return err
+		}
+		if err := tx.Exec("CREATE TABLE " + messagesDefaultPartition + " PARTITION OF " + messagesPartitionedTable + " DEFAULT").Error; err != nil {
+			return err
+		}
+
+		// Copy từng tháng để log được tiến độ với bảng lớn
+		var copied int64
+		for month := from; !month.After(now); month = month.AddDate(0, 1, 0) {
+			result := tx.Exec("INSERT INTO "+messagesPartitionedTable+" SELECT * FROM "+messagesTable+" WHERE created_at >= ? AND created_at < ?",
+				month, month.AddDate(0, 1, 0))
+			if result.Error != nil {
+				return fmt.Errorf("lỗi copy tin nhắn tháng %s: %w", month.Format("2006-01"), result.Error)
+			}
+			copied += result.RowsAffected
+			slog.Info("Đã copy tin nhắn sang bảng partition", "month", month.Format("2006-01"), "rows", result.RowsAffected)
+		}
+		result := tx.Exec("INSERT INTO "+messagesPartitionedTable+" SELECT * FROM "+messagesTable+" WHERE created_at >= ?", now.AddDate(0, 1, 0))
+		if result.Error != nil {
+			return result.Error
+		}
+		copied += result.RowsAffected
+
+		var total int64
+		if err := tx.Raw("SELECT COUNT(*) FROM " + messagesTable).Scan(&total).Error; err != nil {
+			return err
+		}
+		if copied != total {
+			return fmt.Errorf("copy được %d/%d tin nhắn, hủy chuyển đổi", copied, total)
+		}
+
+		if err := swapPartitionedTable(tx); err != nil {
+			return err
+		}
+		if err := ensureMessageIDs(tx); err != nil {
+			return err
+		}
+
+		if options.DropOld {
+			if err := tx.Exec("DROP TABLE " + messagesUnpartitionedTable).Error; err != nil {
+				return err
+			}
+		}
+
+		slog.Info("Đã chuyển bảng messages sang partition theo tháng", "rows", copied,
+			"from", from.Format("2006-01"), "drop_old", options.DropOld)
+		return nil
+	})
+}
+
+// swapPartitionedTable đổi tên bảng cũ thành messages_unpartitioned, bảng partition thành
+// messages và tạo lại trên bảng mới các index (trừ primary key) mà bảng cũ đang có
+func swapPartitionedTable(tx *gorm.DB) error {
+	var indexes []struct {
+		IndexName string
+		IndexDef  string
+	}
+	err := tx.Raw(`SELECT i.indexname AS index_name, i.indexdef AS index_def
+		FROM pg_indexes i
+		WHERE i.schemaname = current_schema() AND i.tablename = ?
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conname = i.indexname AND c.contype = 'p')`,
+		messagesTable).Scan(&indexes).Error
+	if err != nil {
+		return err
+	}
+
+	// Bảng do AutoMigrate cũ tạo có thể đặt tên primary key khác messages_pkey
+	var primaryKey string
+	err = tx.Raw("SELECT conname FROM pg_constraint WHERE conrelid = to_regclass(?) AND contype = 'p'", messagesTable).
+		Row().Scan(&primaryKey)
+	if err != nil {
+		return fmt.Errorf("không đọc được primary key của %s: %w", messagesTable, err)
+	}
+
+	statements := []string{
+		"ALTER TABLE " + messagesTable + " RENAME TO " + messagesUnpartitionedTable,
+		"ALTER TABLE " + messagesUnpartitionedTable + " RENAME CONSTRAINT " + primaryKey + " TO " + messagesUnpartitionedTable + "_pkey",
+	}
+	for _, index := range indexes {
+		statements = append(statements, fmt.Sprintf("ALTER INDEX %s RENAME TO %s_old", index.IndexName, index.IndexName))
+	}
+	statements = append(statements,
+		"ALTER TABLE "+messagesPartitionedTable+" RENAME TO "+messagesTable,
+		"ALTER TABLE "+messagesTable+" RENAME CONSTRAINT "+messagesPartitionedTable+"_pkey TO messages_pkey",
+	)
+	// indexdef tham chiếu bảng theo tên nên sau khi đổi tên sẽ tạo index trên bảng partition
+	for _, index := range indexes {
+		statements = append(statements, index.IndexDef)
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// messageIDsRecheck khoảng thời gian giữa hai lần kiểm tra bảng message_ids khi bảng chưa có
+const messageIDsRecheck = time.Minute
+
+// messageLocator tra created_at của tin nhắn theo id trong message_ids, để truy vấn messages
+// theo id kèm partition key và PostgreSQL chỉ quét partition chứa tin nhắn thay vì mọi partition.
+// message_ids chỉ có sau cmd/partition convert, có thể chạy khi server đang chạy, nên khi chưa
+// có bảng thì được kiểm tra lại sau mỗi messageIDsRecheck. nil trên SQLite.
+type messageLocator struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	available bool
+	checkedAt time.Time
+}
+
+func newMessageLocator(database *Database) *messageLocator {
+	if database.Dialect() != "postgres" {
+		return nil
+	}
+	return &messageLocator{db: database.db}
+}
+
+// enabled cho biết bảng message_ids đã có
+func (l *messageLocator) enabled(ctx context.Context) (bool, error) {
+	if l == nil {
+		return false, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.available || time.Since(l.checkedAt) < messageIDsRecheck {
+		return l.available, nil
+	}
+	exists, err := tableExists(l.db.WithContext(ctx), messageIDsTable)
+	if err != nil {
+		return false, fmt.Errorf("lỗi kiểm tra bảng %s: %w", messageIDsTable, err)
+	}
+	l.available, l.checkedAt = exists, time.Now()
+	return exists, nil
+}
+
+// where thêm điều kiện id của tin nhắn vào tx, kèm created_at khi tra được trong message_ids
+func (l *messageLocator) where(ctx context.Context, tx *gorm.DB, messageID string) (*gorm.DB, error) {
+	tx = tx.Where("id = ?", messageID)
+	enabled, err := l.enabled(ctx)
+	if err != nil || !enabled {
+		return tx, err
+	}
+
+	var createdAt []time.Time
+	err = l.db.WithContext(ctx).Table(messageIDsTable).Where("id = ?", messageID).Limit(1).Pluck("created_at", &createdAt).Error
+	if err != nil {
+		return nil, fmt.Errorf("lỗi tra %s: %w", messageIDsTable, err)
+	}
+	if len(createdAt) > 0 {
+		tx = tx.Where("created_at = ?", createdAt[0])
+	}
+	return tx, nil
+}
+
+// ensureMessageIDs tạo bảng message_ids và trigger giữ id của messages là duy nhất nếu chưa có.
+// Bảng messages phải đã là bảng partition.
+func ensureMessageIDs(tx *gorm.DB) error {
+	exists, err := tableExists(tx, messageIDsTable)
+	if err != nil || exists {
+		return err
+	}
+
+	// Chặn ghi vào messages giữa lúc copy id và lúc trigger có hiệu lực
+	statements := []string{
+		"LOCK TABLE " + messagesTable + " IN SHARE ROW EXCLUSIVE MODE",
+		"CREATE TABLE " + messageIDsTable + " (id TEXT PRIMARY KEY, created_at TIMESTAMPTZ NOT NULL)",
+		"INSERT INTO " + messageIDsTable + " (id, created_at) SELECT id, created_at FROM " + messagesTable,
+		`CREATE OR REPLACE FUNCTION messages_track_id() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'INSERT' THEN
+				INSERT INTO ` + messageIDsTable + ` (id, created_at) VALUES (NEW.id, NEW.created_at);
+				RETURN NEW;
+			END IF;
+			DELETE FROM ` + messageIDsTable + ` WHERE id = OLD.id AND created_at = OLD.created_at;
+			RETURN OLD;
+		END;
+		$$ LANGUAGE plpgsql`,
+		"CREATE TRIGGER messages_track_id AFTER INSERT OR DELETE ON " + messagesTable +
+			" FOR EACH ROW EXECUTE FUNCTION messages_track_id()",
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("lỗi tạo %s: %w", messageIDsTable, err)
+		}
+	}
+	return nil
+}
+
+// createMonthlyPartitions tạo partition cho months+1 tháng bắt đầu từ from nếu chưa có
+func createMonthlyPartitions(tx *gorm.DB, parent string, from time.Time, months int) ([]string, error) {
+	var created []string
+	for i := 0; i <= months; i++ {
+		start := from.AddDate(0, i, 0)
+		name := partitionName(start)
+
+		exists, err := tableExists(tx, name)
+		if err != nil {
+			return created, err
+		}
+		if exists {
+			continue
+		}
+
+		if err := createPartition(tx, parent, name, start, start.AddDate(0, 1, 0)); err != nil {
+			return created, fmt.Errorf("không tạo được partition %s: %w", name, err)
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// createPartition tạo partition [start, end) của parent. PostgreSQL không cho tạo partition
+// khi messages_default đang chứa dòng thuộc khoảng đó, nên các dòng này được chuyển sang
+// bảng mới trước rồi mới gắn bảng vào parent.
+func createPartition(tx *gorm.DB, parent, name string, start, end time.Time) error {
+	bound := fmt.Sprintf("FOR VALUES FROM ('%s') TO ('%s')", start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+	var pending bool
+	hasDefault, err := tableExists(tx, messagesDefaultPartition)
+	if err != nil {
+		return err
+	}
+	if hasDefault {
+		err := tx.Raw("SELECT EXISTS (SELECT 1 FROM "+messagesDefaultPartition+" WHERE created_at >= ? AND created_at < ?)", start, end).
+			Row().Scan(&pending)
+		if err != nil {
+			return err
+		}
+	}
+	if !pending {
+		return tx.Exec(fmt.Sprintf("CREATE TABLE %s PARTITION OF %s %s", name, parent, bound)).Error
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS)", name, parent),
+		fmt.Sprintf("WITH moved AS (DELETE FROM %s WHERE created_at >= '%s' AND created_at < '%s' RETURNING *) INSERT INTO %s SELECT * FROM moved",
+			messagesDefaultPartition, start.Format(time.RFC3339), end.Format(time.RFC3339), name),
+		fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s %s", parent, name, bound),
+	}
+	// Trigger của messages đã xóa id của các dòng bị chuyển khỏi messages_default
+	if exists, err := tableExists(tx, messageIDsTable); err != nil {
+		return err
+	} else if exists {
+		statements = append(statements, fmt.Sprintf("INSERT INTO %s (id, created_at) SELECT id, created_at FROM %s",
+			messageIDsTable, name))
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	slog.Info("Đã chuyển tin nhắn từ partition default sang partition mới", "partition", name)
+	return nil
+}
+
+// partitionName tên partition của tháng, ví dụ messages_p202610
+func partitionName(month time.Time) string {
+	return messagesTable + "_p" + month.Format("200601")
+}
+
+// monthStart đầu tháng theo UTC, ranh giới của các partition
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// monthsBetween số tháng từ from tới to, cả hai là đầu tháng
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+func isPartitioned(tx *gorm.DB, table string) (bool, error) {
+	var kind string
+	err := tx.Raw("SELECT COALESCE((SELECT relkind::text FROM pg_class WHERE oid = to_regclass(?)), '')", table).
+		Row().Scan(&kind)
+	return kind == "p", err
+}
+
+func tableExists(tx *gorm.DB, table string) (bool, error) {
+	var exists bool
+	err := tx.Raw("SELECT to_regclass(?) IS NOT NULL", table).Row().Scan(&exists)
+	return exists, err
+}
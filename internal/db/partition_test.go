@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Phần SQL của partition chỉ chạy được trên PostgreSQL; test ở đây chỉ kiểm tra
+// cách tính ranh giới tháng và việc từ chối dialect khác.
+
+func TestMonthStart(t *testing.T) {
+	hcm := time.FixedZone("ICT", 7*60*60)
+
+	tests := []struct {
+		name string
+		in   time.Time
+		want time.Time
+	}{
+		{name: "giữa tháng", in: time.Date(2024, 5, 17, 13, 45, 0, 0, time.UTC), want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "đúng đầu tháng", in: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "cuối năm", in: time.Date(2024, 12, 31, 23, 59, 59, 999, time.UTC), want: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)},
+		// 01/06 06:00 giờ Việt Nam vẫn là 31/05 theo UTC
+		{name: "theo UTC, không theo múi giờ", in: time.Date(2024, 6, 1, 6, 0, 0, 0, hcm), want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := monthStart(tt.in); !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("monthStart(%v) = %v, muốn %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthsBetween(t *testing.T) {
+	month := func(year int, m time.Month) time.Time { return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{name: "cùng tháng", from: month(2024, 5), to: month(2024, 5), want: 0},
+		{name: "tháng sau", from: month(2024, 5), to: month(2024, 6), want: 1},
+		{name: "qua năm", from: month(2023, 11), to: month(2024, 2), want: 3},
+		{name: "nhiều năm", from: month(2021, 1), to: month(2024, 1), want: 36},
+		{name: "ngược chiều", from: month(2024, 3), to: month(2024, 1), want: -2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := monthsBetween(tt.from, tt.to); got != tt.want {
+				t.Errorf("monthsBetween(%v, %v) = %d, muốn %d", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionName(t *testing.T) {
+	tests := []struct {
+		month time.Time
+		want  string
+	}{
+		{month: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), want: "messages_p202401"},
+		{month: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), want: "messages_p202412"},
+		{month: monthStart(time.Date(2030, 7, 19, 8, 0, 0, 0, time.UTC)), want: "messages_p203007"},
+	}
+
+	for _, tt := range tests {
+		if got := partitionName(tt.month); got != tt.want {
+			t.Errorf("partitionName(%v) = %q, muốn %q", tt.month, got, tt.want)
+		}
+	}
+}
+
+// Tên partition theo thứ tự tháng cũng theo thứ tự chữ, List sắp xếp theo tên dựa vào điều này
+func TestPartitionNamesSortByMonth(t *testing.T) {
+	start := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	previous := partitionName(start)
+	for i := 1; i < 30; i++ {
+		name := partitionName(start.AddDate(0, i, 0))
+		if name <= previous {
+			t.Fatalf("partition %s đứng trước %s", name, previous)
+		}
+		previous = name
+	}
+}
+
+func TestNewPartitionManagerRequiresPostgres(t *testing.T) {
+	database, _ := newTestStore(t)
+	if _, err := NewPartitionManager(database, 3); !errors.Is(err, ErrPartitionUnsupported) {
+		t.Fatalf("NewPartitionManager(sqlite) = %v, muốn ErrPartitionUnsupported", err)
+	}
+}
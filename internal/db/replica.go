@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/logging"
+	"vibeta/internal/metrics"
+
+	"gorm.io/gorm"
+)
+
+// replicaLagQuery độ trễ (giây) của replica so với primary. Replica đã replay hết WAL
+// nhận được có độ trễ 0, vì pg_last_xact_replay_timestamp đứng yên khi primary không ghi.
+const replicaLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// userContextKey key lưu user thực hiện truy vấn trong context
+type userContextKey struct{}
+
+// WithUser gắn user thực hiện truy vấn vào ctx. Truy vấn đọc của user vừa ghi (Store.MarkWrite)
+// được đưa về primary để user thấy ngay thay đổi của mình dù replica còn trễ.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, userID)
+}
+
+func userFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userContextKey{}).(string)
+	return userID
+}
+
+// replica một read replica và trạng thái sức khỏe lần kiểm tra gần nhất
+type replica struct {
+	name    string // replica-<index>, không dùng DSN để không lộ mật khẩu trong log và metric
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+// readRouter chọn kết nối cho truy vấn chỉ đọc: round robin giữa các replica khỏe,
+// primary khi không có replica khỏe hoặc user vừa ghi
+type readRouter struct {
+	primary  *gorm.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	window  time.Duration
+	mu      sync.Mutex
+	writers map[string]time.Time // user -> thời điểm hết read-your-writes
+}
+
+func newReadRouter(primary *gorm.DB, window time.Duration) *readRouter {
+	return &readRouter{primary: primary, window: window, writers: make(map[string]time.Time)}
+}
+
+// connectReplicas kết nối các replica. Replica không kết nối được lúc khởi động làm
+// process dừng, giống primary, vì thường là lỗi cấu hình.
+func (r *readRouter) connectReplicas(settings config.DatabaseConfig) error {
+	for i, dsn := range settings.ReplicaDSNs {
+		database, err := Connect(config.DBDriverPostgres, dsn, settings.SlowQueryThreshold)
+		if err != nil {
+			return fmt.Errorf("replica-%d: %w", i, err)
+		}
+		if err := database.configurePool(settings); err != nil {
+			return fmt.Errorf("replica-%d: %w", i, err)
+		}
+
+		member := &replica{name: fmt.Sprintf("replica-%d", i), db: database.db}
+		member.healthy.Store(true)
+		r.replicas = append(r.replicas, member)
+	}
+	return nil
+}
+
+// reader trả về kết nối cho truy vấn chỉ đọc
+func (r *readRouter) reader(ctx context.Context) *gorm.DB {
+	if len(r.replicas) == 0 {
+		return r.primary.WithContext(ctx)
+	}
+
+	if userID := userFromContext(ctx); userID != "" && r.recentlyWrote(userID) {
+		metrics.DBRead("primary")
+		return r.primary.WithContext(ctx)
+	}
+
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		member := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if member.healthy.Load() {
+			metrics.DBRead("replica")
+			return member.db.WithContext(ctx)
+		}
+	}
+
+	metrics.DBRead("primary")
+	return r.primary.WithContext(ctx)
+}
+
+// markWrite bắt đầu cửa sổ read-your-writes của user trong process này (xem Store.MarkWrite)
+func (r *readRouter) markWrite(userID string) {
+	if len(r.replicas) == 0 || userID == "" || r.window <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.writers[userID] = now.Add(r.window)
+
+	// Dọn các user đã hết cửa sổ khi map lớn dần
+	if len(r.writers) > 1024 {
+		for id, until := range r.writers {
+			if now.After(until) {
+				delete(r.writers, id)
+			}
+		}
+	}
+}
+
+func (r *readRouter) recentlyWrote(userID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, ok := r.writers[userID]
+	if ok && time.Now().After(until) {
+		delete(r.writers, userID)
+		return false
+	}
+	return ok
+}
+
+// monitor kiểm tra sức khỏe các replica mỗi interval cho tới khi ctx bị hủy
+func (r *readRouter) monitor(ctx context.Context, interval, maxLag time.Duration) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, member := range r.replicas {
+			r.check(ctx, member, interval, maxLag)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check cập nhật trạng thái của replica: không khỏe khi không truy vấn được hoặc trễ quá maxLag
+// (maxLag bằng 0 là không giới hạn), log khi trạng thái thay đổi
+func (r *readRouter) check(ctx context.Context, member *replica, timeout, maxLag time.Duration) {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lagSeconds float64
+	err := member.db.WithContext(checkCtx).Raw(replicaLagQuery).Row().Scan(&lagSeconds)
+	lag := time.Duration(lagSeconds * float64(time.Second))
+
+	healthy := err == nil && (maxLag == 0 || lag <= maxLag)
+	metrics.SetReplicaHealth(member.name, healthy, lag)
+	if member.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	switch {
+	case healthy:
+		slog.Info("Read replica đã khỏe lại", "replica", member.name, "lag", lag)
+	case err != nil:
+		slog.Warn("Read replica không phản hồi, đọc từ replica khác hoặc primary", "replica", member.name, logging.Err(err))
+	default:
+		slog.Warn("Read replica trễ quá giới hạn, đọc từ replica khác hoặc primary",
+			"replica", member.name, "lag", lag, "max_lag", maxLag)
+	}
+}
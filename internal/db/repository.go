@@ -0,0 +1,290 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+var (
+	// ErrNotFound được trả về khi bản ghi không tồn tại
+	ErrNotFound = errors.New("không tìm thấy bản ghi")
+	// ErrDuplicate được trả về khi tạo bản ghi trùng khóa
+	ErrDuplicate = errors.New("bản ghi đã tồn tại")
+)
+
+// checkSameSender kiểm tra tin nhắn trùng ID với tin nhắn đã lưu là cùng một tin nhắn
+// được gửi lại, không phải tin nhắn của người khác dùng lại ID
+func checkSameSender(message, existing *models.Message) error {
+	if existing.SenderID != message.SenderID {
+		return fmt.Errorf("%w: message %s đã thuộc người gửi khác", ErrDuplicate, message.ID)
+	}
+	return nil
+}
+
+// UserRepository truy cập người dùng
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, userID string) (*models.User, error)
+	// GetByEmail tìm user theo email, trả về ErrNotFound nếu không có
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdateStatus(ctx context.Context, userID string, status models.UserStatus, lastActive time.Time) error
+}
+
+// ConversationRepository truy cập cuộc trò chuyện
+type ConversationRepository interface {
+	Create(ctx context.Context, conversation *models.Conversation) error
+	Get(ctx context.Context, conversationID string) (*models.Conversation, error)
+	// ListByUser trả về các conversation user đang tham gia
+	ListByUser(ctx context.Context, userID string) ([]models.Conversation, error)
+	// Update ghi các field Name, Description, Avatar khác zero value, trả về ErrNotFound nếu không có conversation
+	Update(ctx context.Context, conversationID string, changes *models.Conversation) error
+}
+
+// ParticipantRepository quản lý thành viên của conversation
+type ParticipantRepository interface {
+	// Add thêm user vào conversation với vai trò role, không làm gì nếu user đang là thành viên
+	Add(ctx context.Context, conversationID, userID string, role models.ParticipantRole) error
+	// Remove đánh dấu user đã rời conversation
+	Remove(ctx context.Context, conversationID, userID string) error
+	// List trả về các thành viên chưa rời conversation
+	List(ctx context.Context, conversationID string) ([]models.ConversationParticipant, error)
+	IsParticipant(ctx context.Context, conversationID, userID string) (bool, error)
+	// Get trả về thành viên chưa rời conversation, ErrNotFound nếu user không phải thành viên
+	Get(ctx context.Context, conversationID, userID string) (*models.ConversationParticipant, error)
+	// SetRoles đổi vai trò của các thành viên trong một transaction (chuyển quyền sở hữu đổi
+	// vai trò hai người cùng lúc). Trả về ErrNotFound nếu có user không phải thành viên.
+	SetRoles(ctx context.Context, conversationID string, roles map[string]models.ParticipantRole) error
+}
+
+// PinRepository quản lý tin nhắn được ghim
+type PinRepository interface {
+	// Pin ghim tin nhắn, trả về ErrDuplicate nếu tin nhắn đã được ghim
+	Pin(ctx context.Context, pin *models.PinnedMessage) error
+	// Unpin bỏ ghim tin nhắn, trả về ErrNotFound nếu tin nhắn chưa được ghim
+	Unpin(ctx context.Context, conversationID, messageID string) error
+	// List trả về tin nhắn được ghim của conversation, mới ghim trước
+	List(ctx context.Context, conversationID string) ([]models.PinnedMessage, error)
+}
+
+// MessageRepository truy cập tin nhắn
+type MessageRepository interface {
+	Create(ctx context.Context, message *models.Message) error
+	// CreateIfAbsent lưu tin nhắn, bỏ qua nếu message ID đã tồn tại với cùng người gửi.
+	// Dùng cho consumer vì cùng một event có thể được nhận nhiều lần. Trả về ErrDuplicate
+	// nếu message ID đã thuộc tin nhắn của người gửi khác.
+	CreateIfAbsent(ctx context.Context, message *models.Message) error
+	Get(ctx context.Context, messageID string) (*models.Message, error)
+	// ListByConversation trả về tin nhắn theo thứ tự thời gian tăng dần
+	ListByConversation(ctx context.Context, conversationID string, limit, offset int) ([]models.Message, error)
+	// Update ghi các field khác zero value của message. message.CreatedAt (nếu có) chỉ dùng
+	// để tìm đúng partition của tin nhắn, không bị cập nhật.
+	Update(ctx context.Context, messageID string, message *models.Message) error
+	// Delete xóa mềm tin nhắn, không lỗi nếu tin nhắn không tồn tại
+	Delete(ctx context.Context, messageID string) error
+	// ListRange trả về tối đa query.Limit tin nhắn chưa xóa theo thứ tự (created_at, id) tăng dần,
+	// dùng để đọc lần lượt cả conversation theo từng trang mà không giữ hết trong bộ nhớ
+	ListRange(ctx context.Context, query MessageRange) ([]models.Message, error)
+}
+
+// MessageRange điều kiện đọc tin nhắn của một conversation theo khoảng thời gian
+type MessageRange struct {
+	ConversationID string
+	From           time.Time // Bao gồm, zero để bỏ qua
+	To             time.Time // Không bao gồm, zero để bỏ qua
+	// AfterCreatedAt và AfterID là tin nhắn cuối của trang trước, AfterID rỗng cho trang đầu
+	AfterCreatedAt time.Time
+	AfterID        string
+	Limit          int
+}
+
+// ReactionRepository truy cập reaction của tin nhắn
+type ReactionRepository interface {
+	// Add thêm reaction, không làm gì nếu reaction đã tồn tại
+	Add(ctx context.Context, reaction *models.MessageReaction) error
+	// Remove xóa reaction, không lỗi nếu reaction không tồn tại
+	Remove(ctx context.Context, messageID, userID, emoji string) error
+	ListByMessage(ctx context.Context, messageID string) ([]models.MessageReaction, error)
+	// ListByMessages trả về reaction của nhiều tin nhắn, sắp xếp theo thời gian
+	ListByMessages(ctx context.Context, messageIDs []string) ([]models.MessageReaction, error)
+}
+
+// ReadStateRepository lưu vị trí đã đọc của user trong conversation
+type ReadStateRepository interface {
+	// MarkRead cập nhật vị trí đã đọc, bỏ qua nếu readAt cũ hơn vị trí hiện tại
+	MarkRead(ctx context.Context, conversationID, userID, messageID string, readAt time.Time) error
+	Get(ctx context.Context, conversationID, userID string) (*models.ReadState, error)
+	// UnreadCount đếm tin nhắn của người khác gửi sau vị trí đã đọc
+	UnreadCount(ctx context.Context, conversationID, userID string) (int64, error)
+}
+
+// OutboxRepository lưu và publish outbox event (transactional outbox)
+type OutboxRepository interface {
+	// SaveWithMessage lưu tin nhắn và outbox event trong cùng một transaction
+	SaveWithMessage(ctx context.Context, message *models.Message, event *models.OutboxEvent) error
+	// Save lưu một outbox event không kèm dữ liệu nghiệp vụ
+	Save(ctx context.Context, event *models.OutboxEvent) error
+	// ClaimBatch claim tối đa limit event chưa publish theo thứ tự ghi bằng lease tới
+	// now+lease, để relay publish ngoài transaction mà relay khác không lấy trùng.
+	// Event có event trước đó cùng partition_key đang bị lease hoặc chờ thử lại
+	// không được claim, để giữ thứ tự trong partition.
+	ClaimBatch(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	// MarkPublished đánh dấu event đã publish và bỏ lease
+	MarkPublished(ctx context.Context, id uint) error
+	// MarkFailed tăng attempts, ghi lỗi và hẹn thử lại từ nextAttemptAt
+	MarkFailed(ctx context.Context, id uint, lastError string, nextAttemptAt time.Time) error
+	// MarkDeadLettered tăng attempts, ghi lỗi và ngừng publish event
+	MarkDeadLettered(ctx context.Context, id uint, lastError string) error
+	// Release bỏ lease của các event đã claim nhưng chưa publish
+	Release(ctx context.Context, ids []uint) error
+}
+
+// ProcessedEventRepository ghi nhận event consumer đã xử lý để bỏ qua event gửi lại
+type ProcessedEventRepository interface {
+	// IsProcessed cho biết event đã được ghi nhận xử lý
+	IsProcessed(ctx context.Context, eventID string) (bool, error)
+	// MarkProcessed ghi nhận event đã xử lý, không lỗi nếu đã ghi nhận trước đó
+	MarkProcessed(ctx context.Context, event *models.ProcessedEvent) error
+	// PurgeBefore xóa các ghi nhận xử lý trước before, trả về số bản ghi đã xóa
+	PurgeBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// SearchRepository full-text index của nội dung tin nhắn. Index được cập nhật bởi
+// worker khi tin nhắn được tạo, sửa hoặc xóa.
+type SearchRepository interface {
+	// Index thêm hoặc thay thế tin nhắn trong index
+	Index(ctx context.Context, message *models.Message) error
+	// Remove xóa tin nhắn khỏi index, không lỗi nếu tin nhắn chưa được index
+	Remove(ctx context.Context, messageID string) error
+	// RemoveBySender xóa khỏi index mọi tin nhắn của sender, trừ tin nhắn trong conversation đang legal hold
+	RemoveBySender(ctx context.Context, senderID string) error
+	// Search tìm tin nhắn trong các conversation query.UserID đang tham gia.
+	// Trả về ErrInvalidSearchQuery nếu thiếu từ khóa hoặc cursor không hợp lệ.
+	Search(ctx context.Context, query SearchQuery) (*SearchPage, error)
+}
+
+// RetentionPolicy retention riêng của một conversation
+type RetentionPolicy struct {
+	ConversationID string
+	RetentionDays  *int // nil dùng mặc định của workspace, 0 là giữ vĩnh viễn
+	LegalHold      bool
+}
+
+// RetentionRepository quản lý retention của conversation và xóa vĩnh viễn tin nhắn hết hạn.
+// Tin nhắn bị xóa cùng reaction và search index của nó; conversation đang legal hold không bao giờ bị xóa.
+type RetentionRepository interface {
+	// SetRetention đặt số ngày giữ tin nhắn, nil để dùng mặc định của workspace.
+	// Trả về ErrNotFound nếu conversation không tồn tại.
+	SetRetention(ctx context.Context, conversationID string, days *int) error
+	// SetLegalHold bật hoặc tắt legal hold. Trả về ErrNotFound nếu conversation không tồn tại.
+	SetLegalHold(ctx context.Context, conversationID string, hold bool) error
+	// ListPolicies trả về các conversation có retention riêng hoặc đang legal hold
+	ListPolicies(ctx context.Context) ([]RetentionPolicy, error)
+	// PurgeExpired xóa vĩnh viễn tối đa limit tin nhắn tạo trước before trong conversationID.
+	// conversationID rỗng áp dụng cho mọi conversation không có policy riêng.
+	PurgeExpired(ctx context.Context, conversationID string, before time.Time, limit int) (int, error)
+	// PurgeDeleted xóa vĩnh viễn tối đa limit tin nhắn đã xóa mềm trước before
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// ErasureMode cách xử lý tin nhắn của user bị xóa dữ liệu
+type ErasureMode string
+
+const (
+	ErasureRedact ErasureMode = "redact" // Giữ tin nhắn để không vỡ thread, xóa trắng nội dung và attachment
+	ErasureDelete ErasureMode = "delete" // Xóa vĩnh viễn tin nhắn cùng reaction của người khác trên đó
+)
+
+// Erasure yêu cầu xóa dữ liệu cá nhân của một user
+type Erasure struct {
+	UserID string
+	Mode   ErasureMode
+	// Username, Email và FullName thay thế thông tin trong hồ sơ user, ID được giữ nguyên
+	Username string
+	Email    string
+	FullName string
+	// Event outbox event thông báo việc xóa, được lưu cùng transaction
+	Event *models.OutboxEvent
+}
+
+// ErasureResult kết quả xóa dữ liệu của một user
+type ErasureResult struct {
+	Messages     int // Tin nhắn đã xóa trắng hoặc xóa vĩnh viễn
+	HeldMessages int // Tin nhắn giữ nguyên vì conversation đang legal hold
+	Reactions    int
+	Memberships  int // Conversation user bị đưa ra khỏi
+	// Profile hồ sơ user đã được ẩn danh, false nếu không có bản ghi users
+	// (user đã bị xóa khỏi bảng users nhưng còn dữ liệu)
+	Profile bool
+}
+
+// PrivacyRepository đọc và xóa toàn bộ dữ liệu gắn với một user, phục vụ yêu cầu của
+// chủ thể dữ liệu (GDPR). Các hàm đọc bao gồm cả dữ liệu đã xóa mềm.
+type PrivacyRepository interface {
+	// Memberships trả về mọi lần tham gia conversation của user, kể cả đã rời
+	Memberships(ctx context.Context, userID string) ([]models.ConversationParticipant, error)
+	// ListSentMessages trả về tối đa limit tin nhắn user đã gửi, kể cả đã xóa mềm, theo thứ tự
+	// (created_at, id) tăng dần, bắt đầu sau tin nhắn (afterCreatedAt, afterID); afterID rỗng cho trang đầu
+	ListSentMessages(ctx context.Context, userID string, afterCreatedAt time.Time, afterID string, limit int) ([]models.Message, error)
+	// ListReactions trả về reaction user đã thả
+	ListReactions(ctx context.Context, userID string) ([]models.MessageReaction, error)
+	// ListReadStates trả về vị trí đã đọc của user trong các conversation
+	ListReadStates(ctx context.Context, userID string) ([]models.ReadState, error)
+	// Erase ẩn danh hồ sơ user, xóa trắng hoặc xóa tin nhắn, reaction, vị trí đã đọc, search index
+	// của user và đưa user ra khỏi mọi conversation trong một transaction. Dữ liệu trong conversation
+	// đang legal hold được giữ nguyên. Không có bản ghi users thì bỏ qua bước ẩn danh hồ sơ
+	// và vẫn xóa phần dữ liệu còn lại.
+	Erase(ctx context.Context, erasure Erasure) (*ErasureResult, error)
+}
+
+// AuditRepository nhật ký chỉ thêm của các thao tác quản trị và liên quan bảo mật
+type AuditRepository interface {
+	// Append ghi một bản ghi audit, cùng transaction với outbox event nếu event khác nil
+	Append(ctx context.Context, entry *models.AuditLog, event *models.OutboxEvent) error
+	// List trả về một trang audit log khớp query, mới nhất trước.
+	// Trả về ErrInvalidAuditQuery nếu khoảng thời gian hoặc cursor không hợp lệ.
+	List(ctx context.Context, query AuditQuery) (*AuditPage, error)
+}
+
+// Store gom các repository dùng chung một backend
+type Store struct {
+	Users           UserRepository
+	Conversations   ConversationRepository
+	Participants    ParticipantRepository
+	Pins            PinRepository
+	Messages        MessageRepository
+	Reactions       ReactionRepository
+	ReadStates      ReadStateRepository
+	Outbox          OutboxRepository
+	ProcessedEvents ProcessedEventRepository
+	Search          SearchRepository
+	Retention       RetentionRepository
+	Privacy         PrivacyRepository
+	Audit           AuditRepository
+
+	ping      func(ctx context.Context) error
+	markWrite func(userID string)
+}
+
+// Ping kiểm tra backend của store còn sẵn sàng
+func (s *Store) Ping(ctx context.Context) error {
+	if s.ping == nil {
+		return nil
+	}
+	return s.ping(ctx)
+}
+
+// MarkWrite ghi nhận user vừa gửi thay đổi. Khi có read replica, truy vấn đọc của user
+// (ctx gắn bằng WithUser) đi primary trong DB_READ_YOUR_WRITES_WINDOW.
+//
+// Đây chỉ là best effort: trạng thái nằm trong bộ nhớ của process gọi MarkWrite nên không
+// có hiệu lực với WebSocket server khác, và cửa sổ tính từ lúc nhận frame chứ không phải
+// lúc worker ghi xong. Nếu worker chậm hơn cửa sổ, cả primary cũng chưa có thay đổi.
+func (s *Store) MarkWrite(userID string) {
+	if s.markWrite != nil {
+		s.markWrite(userID)
+	}
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+// Giới hạn số kết quả mỗi trang tìm kiếm
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// ErrInvalidSearchQuery được trả về khi truy vấn tìm kiếm thiếu điều kiện
+// hoặc cursor phân trang không đọc được
+var ErrInvalidSearchQuery = errors.New("truy vấn tìm kiếm không hợp lệ")
+
+// Ký tự đánh dấu đoạn khớp trong snippet do database sinh ra. Snippet được escape HTML
+// rồi mới thay ký tự đánh dấu bằng <mark>, nên nội dung tin nhắn không chèn được HTML.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// SearchQuery điều kiện tìm kiếm tin nhắn
+type SearchQuery struct {
+	// UserID người tìm kiếm, chỉ trả về tin nhắn trong conversation user đang tham gia
+	UserID string
+	// Text các từ cần tìm, tin nhắn phải chứa tất cả các từ
+	Text string
+
+	ConversationID string
+	SenderID       string
+	Type           models.MessageType
+	From           time.Time // Bao gồm, zero để bỏ qua
+	To             time.Time // Không bao gồm, zero để bỏ qua
+
+	Limit  int
+	Cursor string // NextCursor của trang trước, rỗng cho trang đầu
+}
+
+// SearchResult một tin nhắn khớp truy vấn
+type SearchResult struct {
+	MessageID      string             `json:"message_id"`
+	ConversationID string             `json:"conversation_id"`
+	SenderID       string             `json:"sender_id"`
+	Type           models.MessageType `json:"type"`
+	CreatedAt      time.Time          `json:"created_at"`
+	// Snippet đoạn nội dung đã escape HTML, các từ khớp được bọc trong <mark>
+	Snippet string `json:"snippet"`
+}
+
+// SearchPage một trang kết quả, sắp xếp mới nhất trước
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	// NextCursor rỗng khi không còn trang sau
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// searchCursor vị trí của kết quả cuối cùng trong trang trước
+type searchCursor struct {
+	CreatedAt time.Time `json:"t"`
+	MessageID string    `json:"id"`
+}
+
+// normalize kiểm tra truy vấn và chuẩn hóa limit
+func (q *SearchQuery) normalize() error {
+	if q.UserID == "" {
+		return fmt.Errorf("%w: thiếu user tìm kiếm", ErrInvalidSearchQuery)
+	}
+	if len(searchTerms(q.Text)) == 0 {
+		return fmt.Errorf("%w: thiếu từ khóa", ErrInvalidSearchQuery)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return fmt.Errorf("%w: from phải trước to", ErrInvalidSearchQuery)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	q.Limit = min(q.Limit, MaxSearchLimit)
+	return nil
+}
+
+// decodeCursor đọc cursor, trả về nil với trang đầu
+func (q *SearchQuery) decodeCursor() (*searchCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor", ErrInvalidSearchQuery)
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.MessageID == "" {
+		return nil, fmt.Errorf("%w: cursor", ErrInvalidSearchQuery)
+	}
+	return &cursor, nil
+}
+
+// newSearchPage tạo trang từ tối đa limit+1 kết quả; kết quả thừa cho biết còn trang sau
+func newSearchPage(results []SearchResult, limit int) *SearchPage {
+	page := &SearchPage{Results: results}
+	if len(results) <= limit {
+		return page
+	}
+
+	page.Results = results[:limit]
+	last := page.Results[limit-1]
+	data, _ := json.Marshal(searchCursor{CreatedAt: last.CreatedAt, MessageID: last.MessageID})
+	page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	return page
+}
+
+// searchTerms tách truy vấn thành các từ, bỏ dấu ngoặc kép để không tạo cú pháp FTS
+func searchTerms(text string) []string {
+	return strings.Fields(strings.ReplaceAll(text, `"`, " "))
+}
+
+// renderSnippet escape HTML và đổi ký tự đánh dấu thành <mark>
+func renderSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
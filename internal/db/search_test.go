@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+var searchBase = time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// seedSearch tạo hai conversation: general (alice, bob) và secret (chỉ carol)
+func seedSearch(t *testing.T, store *Store) {
+	t.Helper()
+	seedConversation(t, store, "general", "alice", "bob")
+	seedConversation(t, store, "secret", "carol")
+	seedMessage(t, store, "m1", "general", "alice", "deploy server hôm nay", searchBase)
+	seedMessage(t, store, "m2", "general", "bob", "server bị lỗi rồi", searchBase.Add(time.Minute))
+	seedMessage(t, store, "m3", "general", "alice", "ăn trưa không", searchBase.Add(2*time.Minute))
+	seedMessage(t, store, "m4", "secret", "carol", "server bí mật", searchBase.Add(3*time.Minute))
+}
+
+func resultIDs(page *SearchPage) []string {
+	ids := []string{}
+	for _, result := range page.Results {
+		ids = append(ids, result.MessageID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	forEachSearchBackend(t, func(t *testing.T, store *Store) {
+		seedSearch(t, store)
+
+		tests := []struct {
+			name  string
+			query SearchQuery
+			want  []string
+		}{
+			{name: "mới nhất trước", query: SearchQuery{UserID: "bob", Text: "server"}, want: []string{"m2", "m1"}},
+			{name: "chỉ conversation đang tham gia", query: SearchQuery{UserID: "carol", Text: "server"}, want: []string{"m4"}},
+			{name: "phải khớp mọi từ", query: SearchQuery{UserID: "bob", Text: "deploy server"}, want: []string{"m1"}},
+			{name: "không phân biệt hoa thường", query: SearchQuery{UserID: "bob", Text: "SERVER"}, want: []string{"m2", "m1"}},
+			{name: "có dấu", query: SearchQuery{UserID: "alice", Text: "trưa"}, want: []string{"m3"}},
+			{name: "lọc theo người gửi", query: SearchQuery{UserID: "bob", Text: "server", SenderID: "alice"}, want: []string{"m1"}},
+			{name: "lọc theo conversation", query: SearchQuery{UserID: "bob", Text: "server", ConversationID: "secret"}, want: []string{}},
+			{
+				name:  "lọc theo thời gian",
+				query: SearchQuery{UserID: "bob", Text: "server", From: searchBase.Add(time.Minute), To: searchBase.Add(2 * time.Minute)},
+				want:  []string{"m2"},
+			},
+			{name: "ngoặc kép không thành cú pháp FTS", query: SearchQuery{UserID: "bob", Text: `"server" OR`}, want: []string{}},
+			{name: "không khớp", query: SearchQuery{UserID: "bob", Text: "kubernetes"}, want: []string{}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				page, err := store.Search.Search(context.Background(), tt.query)
+				if err != nil {
+					t.Fatalf("Search: %v", err)
+				}
+				if got := resultIDs(page); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Search = %v, muốn %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestSearchPagination(t *testing.T) {
+	forEachSearchBackend(t, func(t *testing.T, store *Store) {
+		seedSearch(t, store)
+		ctx := context.Background()
+
+		var got []string
+		query := SearchQuery{UserID: "alice", Text: "server", Limit: 1}
+		for page := 0; ; page++ {
+			if page > 3 {
+				t.Fatal("cursor không kết thúc")
+			}
+			result, err := store.Search.Search(ctx, query)
+			if err != nil {
+				t.Fatalf("Search trang %d: %v", page, err)
+			}
+			got = append(got, resultIDs(result)...)
+			if result.NextCursor == "" {
+				break
+			}
+			query.Cursor = result.NextCursor
+		}
+		if want := []string{"m2", "m1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("các trang = %v, muốn %v", got, want)
+		}
+	})
+}
+
+func TestSearchSnippetEscapesHTML(t *testing.T) {
+	forEachSearchBackend(t, func(t *testing.T, store *Store) {
+		seedConversation(t, store, "general", "alice")
+		seedMessage(t, store, "m1", "general", "alice", `<script>alert(1)</script> server`, searchBase)
+
+		page, err := store.Search.Search(context.Background(), SearchQuery{UserID: "alice", Text: "server"})
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if len(page.Results) != 1 {
+			t.Fatalf("có %d kết quả, muốn 1", len(page.Results))
+		}
+		snippet := page.Results[0].Snippet
+		if strings.Contains(snippet, "<script>") || !strings.Contains(snippet, "&lt;script&gt;") {
+			t.Errorf("snippet chưa escape HTML: %q", snippet)
+		}
+		if !strings.Contains(snippet, "<mark>server</mark>") {
+			t.Errorf("snippet không đánh dấu từ khớp: %q", snippet)
+		}
+		if !page.Results[0].CreatedAt.Equal(searchBase) {
+			t.Errorf("created_at = %v, muốn %v", page.Results[0].CreatedAt, searchBase)
+		}
+	})
+}
+
+func TestSearchIndexUpdates(t *testing.T) {
+	forEachSearchBackend(t, func(t *testing.T, store *Store) {
+		seedSearch(t, store)
+		ctx := context.Background()
+		search := func(text string) []string {
+			t.Helper()
+			page, err := store.Search.Search(ctx, SearchQuery{UserID: "alice", Text: text})
+			if err != nil {
+				t.Fatalf("Search %q: %v", text, err)
+			}
+			return resultIDs(page)
+		}
+
+		// Index lại sau khi sửa thay thế nội dung cũ, không tạo bản trùng
+		edited := &models.Message{ID: "m1", ConversationID: "general", SenderID: "alice", Content: "rollback database",
+			Type: models.MessageTypeText, CreatedAt: searchBase}
+		if err := store.Search.Index(ctx, edited); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		if got := search("deploy"); len(got) != 0 {
+			t.Errorf("nội dung cũ vẫn tìm thấy: %v", got)
+		}
+		if got := search("rollback"); !reflect.DeepEqual(got, []string{"m1"}) {
+			t.Errorf("nội dung mới = %v, muốn [m1]", got)
+		}
+
+		if err := store.Search.Remove(ctx, "m2"); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if got := search("server"); len(got) != 0 {
+			t.Errorf("tin nhắn đã xóa khỏi index vẫn tìm thấy: %v", got)
+		}
+
+		// Thành viên đã rời không tìm được tin nhắn của conversation
+		if err := store.Participants.Remove(ctx, "general", "alice"); err != nil {
+			t.Fatalf("Participants.Remove: %v", err)
+		}
+		if got := search("rollback"); len(got) != 0 {
+			t.Errorf("thành viên đã rời vẫn tìm thấy: %v", got)
+		}
+	})
+}
+
+func TestSearchInvalidQuery(t *testing.T) {
+	forEachSearchBackend(t, func(t *testing.T, store *Store) {
+		seedSearch(t, store)
+
+		tests := []struct {
+			name  string
+			query SearchQuery
+		}{
+			{name: "thiếu user", query: SearchQuery{Text: "server"}},
+			{name: "thiếu từ khóa", query: SearchQuery{UserID: "bob", Text: "  "}},
+			{name: "chỉ có ngoặc kép", query: SearchQuery{UserID: "bob", Text: `""`}},
+			{name: "from sau to", query: SearchQuery{UserID: "bob", Text: "server", From: searchBase, To: searchBase}},
+			{name: "cursor hỏng", query: SearchQuery{UserID: "bob", Text: "server", Cursor: "%%%"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := store.Search.Search(context.Background(), tt.query); !errors.Is(err, ErrInvalidSearchQuery) {
+					t.Errorf("Search = %v, muốn ErrInvalidSearchQuery", err)
+				}
+			})
+		}
+	})
+}
+
+func TestLikeSnippet(t *testing.T) {
+	long := strings.Repeat("a ", 20) + "server " + strings.Repeat("b ", 20)
+
+	tests := []struct {
+		name    string
+		content string
+		terms   []string
+		want    string
+	}{
+		{name: "ngắn", content: "deploy Server hôm nay", terms: []string{"server"}, want: "deploy \x02Server\x03 hôm nay"},
+		{name: "nhiều từ", content: "deploy server hôm nay", terms: []string{"deploy", "nay"}, want: "\x02deploy\x03 server hôm \x02nay\x03"},
+		{name: "từ khớp là một phần của từ", content: "các servers", terms: []string{"server"}, want: "các \x02servers\x03"},
+		{
+			name:    "cắt quanh từ khớp",
+			content: long,
+			terms:   []string{"server"},
+			want:    "…" + strings.Repeat("a ", 4) + "\x02server\x03" + strings.Repeat(" b", 11) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := likeSnippet(tt.content, tt.terms); got != tt.want {
+				t.Errorf("likeSnippet = %q, muốn %q", got, tt.want)
+			}
+		})
+	}
+}
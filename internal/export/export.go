@@ -0,0 +1,253 @@
+// Package export xuất tin nhắn của một conversation ra JSON lines, HTML hoặc text.
+// Tin nhắn được đọc và ghi theo từng batch nên không giữ cả conversation trong bộ nhớ.
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/models"
+)
+
+// Format định dạng bản export
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl" // Mỗi dòng một Record
+	FormatHTML  Format = "html"  // Transcript HTML một file, không tải tài nguyên ngoài
+	FormatText  Format = "text"  // Text thuần, mỗi tin nhắn một dòng
+)
+
+// batchSize số tin nhắn đọc mỗi lần
+const batchSize = 500
+
+// ErrInvalidOptions tùy chọn export không hợp lệ
+var ErrInvalidOptions = errors.New("tùy chọn export không hợp lệ")
+
+// ParseFormat đọc định dạng, chuỗi rỗng là jsonl
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", FormatJSONL:
+		return FormatJSONL, nil
+	case FormatHTML, FormatText:
+		return Format(value), nil
+	}
+	return "", fmt.Errorf("%w: format %q (hỗ trợ %s, %s, %s)", ErrInvalidOptions, value, FormatJSONL, FormatHTML, FormatText)
+}
+
+// ContentType content type HTTP của định dạng
+func (f Format) ContentType() string {
+	switch f {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Extension phần mở rộng tên file của định dạng
+func (f Format) Extension() string {
+	switch f {
+	case FormatHTML:
+		return "html"
+	case FormatText:
+		return "txt"
+	}
+	return "jsonl"
+}
+
+// Options điều kiện export
+type Options struct {
+	ConversationID string
+	Format         Format
+	From           time.Time // Bao gồm, zero để bỏ qua
+	To             time.Time // Không bao gồm, zero để bỏ qua
+}
+
+// Record một tin nhắn trong bản export, gồm mọi field của models.Message
+// (trừ relation) cùng reaction và metadata attachment
+type Record struct {
+	ID             string               `json:"id"`
+	ConversationID string               `json:"conversation_id"`
+	SenderID       string               `json:"sender_id"`
+	Content        string               `json:"content"`
+	Type           models.MessageType   `json:"type"`
+	Status         models.MessageStatus `json:"status"`
+	ReplyToID      string               `json:"reply_to_id,omitempty"`
+	// Attachments JSON attachment như đã lưu, thường là danh sách models.Attachment
+	Attachments json.RawMessage `json:"attachments,omitempty"`
+	Reactions   []Reaction      `json:"reactions"`
+	EditedAt    *time.Time      `json:"edited_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Reaction một reaction trong bản export
+type Reaction struct {
+	UserID    string    `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// attachmentList đọc metadata attachment, bỏ qua nếu không đúng định dạng models.Attachment
+func (r *Record) attachmentList() []models.Attachment {
+	var attachments []models.Attachment
+	if len(r.Attachments) > 0 {
+		json.Unmarshal(r.Attachments, &attachments)
+	}
+	return attachments
+}
+
+// encoder ghi bản export theo một định dạng
+type encoder interface {
+	begin(conversation *models.Conversation, options Options) error
+	encode(record *Record) error
+	end(count int) error
+}
+
+// flusher ResponseWriter hỗ trợ đẩy dữ liệu đã ghi tới client (http.Flusher)
+type flusher interface {
+	Flush()
+}
+
+// Exporter đọc tin nhắn từ store và ghi bản export
+type Exporter struct {
+	store *db.Store
+}
+
+// NewExporter tạo exporter đọc từ store
+func NewExporter(store *db.Store) *Exporter {
+	return &Exporter{store: store}
+}
+
+// Export ghi các tin nhắn chưa xóa của conversation trong khoảng thời gian ra w, cũ nhất trước,
+// trả về số tin nhắn đã ghi. Dữ liệu được đẩy ra sau mỗi batch; lỗi giữa chừng để lại bản
+// export dở dang nên caller cần kiểm tra quyền và conversation trước khi ghi.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, options Options) (int, error) {
+	if !options.From.IsZero() && !options.To.IsZero() && !options.From.Before(options.To) {
+		return 0, fmt.Errorf("%w: from phải trước to", ErrInvalidOptions)
+	}
+	format, err := ParseFormat(string(options.Format))
+	if err != nil {
+		return 0, err
+	}
+
+	conversation, err := e.store.Conversations.Get(ctx, options.ConversationID)
+	if err != nil {
+		return 0, err
+	}
+
+	buffered := bufio.NewWriter(w)
+	enc := newEncoder(format, buffered)
+	if err := enc.begin(conversation, options); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	query := db.MessageRange{ConversationID: options.ConversationID, From: options.From, To: options.To, Limit: batchSize}
+	for {
+		messages, err := e.store.Messages.ListRange(ctx, query)
+		if err != nil {
+			return count, fmt.Errorf("lỗi đọc tin nhắn: %w", err)
+		}
+
+		records, err := e.records(ctx, messages)
+		if err != nil {
+			return count, err
+		}
+		for i := range records {
+			if err := enc.encode(&records[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if err := buffered.Flush(); err != nil {
+			return count, err
+		}
+		if f, ok := w.(flusher); ok {
+			f.Flush()
+		}
+
+		if len(messages) < batchSize {
+			break
+		}
+		last := messages[len(messages)-1]
+		query.AfterCreatedAt, query.AfterID = last.CreatedAt, last.ID
+	}
+
+	if err := enc.end(count); err != nil {
+		return count, err
+	}
+	return count, buffered.Flush()
+}
+
+// records ghép tin nhắn với reaction của chúng
+func (e *Exporter) records(ctx context.Context, messages []models.Message) ([]Record, error) {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	reactions, err := e.store.Reactions.ListByMessages(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi đọc reaction: %w", err)
+	}
+	byMessage := make(map[string][]Reaction)
+	for _, reaction := range reactions {
+		byMessage[reaction.MessageID] = append(byMessage[reaction.MessageID], Reaction{
+			UserID:    reaction.UserID,
+			Emoji:     reaction.Emoji,
+			CreatedAt: reaction.CreatedAt,
+		})
+	}
+
+	records := make([]Record, len(messages))
+	for i, message := range messages {
+		records[i] = Record{
+			ID:             message.ID,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Content:        message.Content,
+			Type:           message.Type,
+			Status:         message.Status,
+			ReplyToID:      message.ReplyToID,
+			Reactions:      byMessage[message.ID],
+			EditedAt:       message.EditedAt,
+			CreatedAt:      message.CreatedAt,
+			UpdatedAt:      message.UpdatedAt,
+		}
+		if records[i].Reactions == nil {
+			records[i].Reactions = []Reaction{}
+		}
+		if json.Valid([]byte(message.Attachments)) {
+			records[i].Attachments = json.RawMessage(message.Attachments)
+		}
+	}
+	return records, nil
+}
+
+func newEncoder(format Format, w io.Writer) encoder {
+	switch format {
+	case FormatHTML:
+		return &htmlEncoder{w: w}
+	case FormatText:
+		return &textEncoder{w: w}
+	}
+	return &jsonlEncoder{encoder: json.NewEncoder(w)}
+}
+
+// jsonlEncoder mỗi tin nhắn một dòng JSON, không có header để mọi dòng cùng schema
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonlEncoder) begin(*models.Conversation, Options) error { return nil }
+func (e *jsonlEncoder) encode(record *Record) error               { return e.encoder.Encode(record) }
+func (e *jsonlEncoder) end(int) error                             { return nil }
@@ -0,0 +1,122 @@
+package export
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+// timeLayout định dạng thời gian trong transcript HTML và text, luôn theo UTC
+const timeLayout = "2006-01-02 15:04:05 UTC"
+
+// Transcript HTML được ghi thành ba phần để stream: header, từng tin nhắn, footer.
+// CSS nằm trong file và không có tài nguyên ngoài nên mở được khi offline.
+var htmlTemplates = template.Must(template.New("header").Funcs(template.FuncMap{
+	"time": formatTime,
+}).Parse(`<!DOCTYPE html>
+<html lang="vi">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 860px; margin: 2em auto; color: #222; }
+header { border-bottom: 1px solid #ddd; margin-bottom: 1em; }
+header dl { display: grid; grid-template-columns: max-content 1fr; gap: .2em 1em; font-size: .9em; }
+header dt { color: #666; }
+.message { padding: .6em 0; border-bottom: 1px solid #f0f0f0; }
+.meta { font-size: .85em; color: #666; }
+.sender { font-weight: 600; color: #222; }
+.content { white-space: pre-wrap; word-wrap: break-word; margin: .3em 0; }
+.system .content { font-style: italic; color: #666; }
+.attachments, .reactions { font-size: .85em; color: #444; margin: .2em 0; padding-left: 1.2em; }
+footer { margin-top: 1em; font-size: .85em; color: #666; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>Conversation</dt><dd>{{.Conversation.ID}}</dd>
+<dt>Loại</dt><dd>{{.Conversation.Type}}</dd>
+{{- if .Conversation.Description}}<dt>Mô tả</dt><dd>{{.Conversation.Description}}</dd>{{end}}
+<dt>Khoảng thời gian</dt><dd>{{if .From.IsZero}}đầu{{else}}{{time .From}}{{end}} – {{if .To.IsZero}}hiện tại{{else}}{{time .To}}{{end}}</dd>
+<dt>Xuất lúc</dt><dd>{{time .ExportedAt}}</dd>
+</dl>
+</header>
+<main>
+{{define "message"}}<article class="message {{.Type}}" id="{{.ID}}">
+<div class="meta"><span class="sender">{{.SenderID}}</span> · <time datetime="{{.CreatedAt.UTC.Format "2006-01-02T15:04:05Z07:00"}}">{{time .CreatedAt}}</time>
+{{- if .EditedAt}} · đã sửa {{time .EditedAt}}{{end}}
+{{- if .ReplyToID}} · trả lời <a href="#{{.ReplyToID}}">{{.ReplyToID}}</a>{{end}}</div>
+<div class="content">{{.Content}}</div>
+{{- with .Attachments}}
+<ul class="attachments">{{range .}}<li>📎 {{.FileName}}{{if .FileType}} ({{.FileType}}{{if .FileSize}}, {{.FileSize}} bytes{{end}}){{end}}{{if .URL}} – {{.URL}}{{end}}</li>{{end}}</ul>
+{{- end}}
+{{- with .Reactions}}
+<ul class="reactions">{{range .}}<li>{{.Emoji}} {{.UserID}}</li>{{end}}</ul>
+{{- end}}
+</article>
+{{end}}
+{{define "footer"}}</main>
+<footer>{{.}} tin nhắn</footer>
+</body>
+</html>
+{{end}}`))
+
+// htmlHeader dữ liệu của phần header
+type htmlHeader struct {
+	Title        string
+	Conversation *models.Conversation
+	From, To     time.Time
+	ExportedAt   time.Time
+}
+
+// htmlMessage dữ liệu của một tin nhắn, attachment đã được đọc từ JSON
+type htmlMessage struct {
+	*Record
+	Attachments []models.Attachment
+}
+
+type htmlEncoder struct {
+	w io.Writer
+}
+
+func (e *htmlEncoder) begin(conversation *models.Conversation, options Options) error {
+	return htmlTemplates.ExecuteTemplate(e.w, "header", htmlHeader{
+		Title:        conversationTitle(conversation),
+		Conversation: conversation,
+		From:         options.From,
+		To:           options.To,
+		ExportedAt:   time.Now(),
+	})
+}
+
+func (e *htmlEncoder) encode(record *Record) error {
+	return htmlTemplates.ExecuteTemplate(e.w, "message", htmlMessage{Record: record, Attachments: record.attachmentList()})
+}
+
+func (e *htmlEncoder) end(count int) error {
+	return htmlTemplates.ExecuteTemplate(e.w, "footer", count)
+}
+
+// conversationTitle tên hiển thị của conversation, dùng ID nếu không có tên
+func conversationTitle(conversation *models.Conversation) string {
+	if conversation.Name != "" {
+		return conversation.Name
+	}
+	return conversation.ID
+}
+
+func formatTime(t any) string {
+	switch value := t.(type) {
+	case time.Time:
+		return value.UTC().Format(timeLayout)
+	case *time.Time:
+		if value != nil {
+			return value.UTC().Format(timeLayout)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeta/internal/db/dbtest"
+	"vibeta/internal/models"
+)
+
+func TestHTMLEscapesUserContent(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore(t)
+
+	conversation := &models.Conversation{ID: "xss", Type: models.ConversationTypeGroup, CreatedBy: "alice",
+		Name: `<script>alert("tên")</script>`, Description: `mô tả "có nháy" & <b>thẻ</b>`}
+	if err := store.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Conversations.Create: %v", err)
+	}
+	message := &models.Message{
+		ID:             `m1"><script>alert(1)</script>`,
+		ConversationID: "xss",
+		SenderID:       `alice"><img src=x onerror=alert(2)>`,
+		Content:        `<script>alert("nội dung")</script>`,
+		Type:           models.MessageTypeText,
+		Attachments:    `[{"file_name":"<script>file.js</script>","url":"javascript:alert(3)"}]`,
+		CreatedAt:      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := store.Messages.Create(ctx, message); err != nil {
+		t.Fatalf("Messages.Create: %v", err)
+	}
+	if err := store.Reactions.Add(ctx, &models.MessageReaction{MessageID: message.ID, UserID: "bob", Emoji: `<script>`}); err != nil {
+		t.Fatalf("Reactions.Add: %v", err)
+	}
+
+	var out bytes.Buffer
+	count, err := NewExporter(store).Export(ctx, &out, Options{ConversationID: "xss", Format: FormatHTML})
+	if err != nil || count != 1 {
+		t.Fatalf("Export = %d (err %v), muốn 1 tin nhắn", count, err)
+	}
+	html := out.String()
+
+	// Template không có thẻ script, img hay b nên mọi thẻ như vậy đến từ dữ liệu chưa escape
+	if strings.Contains(html, "<script") || strings.Contains(html, "<img") || strings.Contains(html, "<b>") {
+		t.Errorf("HTML chứa thẻ chưa escape:\n%s", html)
+	}
+	for _, want := range []string{
+		`&lt;script&gt;alert(&#34;nội dung&#34;)&lt;/script&gt;`,
+		`&lt;script&gt;alert(&#34;tên&#34;)&lt;/script&gt;`,
+		`mô tả &#34;có nháy&#34; &amp; &lt;b&gt;thẻ&lt;/b&gt;`,
+		`id="m1&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`,
+		`alice&#34;&gt;&lt;img src=x onerror=alert(2)&gt;`,
+		`&lt;script&gt;file.js&lt;/script&gt;`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML thiếu %s", want)
+		}
+	}
+}
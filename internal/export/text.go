@@ -0,0 +1,64 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"vibeta/internal/models"
+)
+
+// textEncoder mỗi tin nhắn một dòng "[thời gian] sender: nội dung", xuống dòng trong nội dung
+// được thụt vào; sửa, trả lời, attachment và reaction ghi thành các dòng phụ
+type textEncoder struct {
+	w io.Writer
+}
+
+func (e *textEncoder) begin(conversation *models.Conversation, options Options) error {
+	from, to := "đầu", "hiện tại"
+	if !options.From.IsZero() {
+		from = formatTime(options.From)
+	}
+	if !options.To.IsZero() {
+		to = formatTime(options.To)
+	}
+	_, err := fmt.Fprintf(e.w, "Conversation: %s (%s)\nKhoảng thời gian: %s – %s\nXuất lúc: %s\n\n",
+		conversationTitle(conversation), conversation.ID, from, to, formatTime(time.Now()))
+	return err
+}
+
+func (e *textEncoder) encode(record *Record) error {
+	var line strings.Builder
+	content := strings.ReplaceAll(record.Content, "\n", "\n    ")
+	fmt.Fprintf(&line, "[%s] %s: %s\n", formatTime(record.CreatedAt), record.SenderID, content)
+
+	if record.ReplyToID != "" {
+		fmt.Fprintf(&line, "    ↳ trả lời %s\n", record.ReplyToID)
+	}
+	if record.EditedAt != nil {
+		fmt.Fprintf(&line, "    (đã sửa %s)\n", formatTime(record.EditedAt))
+	}
+	for _, attachment := range record.attachmentList() {
+		fmt.Fprintf(&line, "    📎 %s", attachment.FileName)
+		if attachment.URL != "" {
+			fmt.Fprintf(&line, " – %s", attachment.URL)
+		}
+		line.WriteString("\n")
+	}
+	if len(record.Reactions) > 0 {
+		reactions := make([]string, len(record.Reactions))
+		for i, reaction := range record.Reactions {
+			reactions[i] = reaction.Emoji + " " + reaction.UserID
+		}
+		fmt.Fprintf(&line, "    Reactions: %s\n", strings.Join(reactions, ", "))
+	}
+
+	_, err := io.WriteString(e.w, line.String())
+	return err
+}
+
+func (e *textEncoder) end(count int) error {
+	_, err := fmt.Fprintf(e.w, "\n%d tin nhắn\n", count)
+	return err
+}
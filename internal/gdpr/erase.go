@@ -0,0 +1,72 @@
+package gdpr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"vibeta/internal/db"
+	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+)
+
+// ErasedFullName tên hiển thị của user đã bị xóa dữ liệu
+const ErasedFullName = "Người dùng đã xóa"
+
+// erasedDomain domain email của user đã bị xóa dữ liệu, .invalid không bao giờ được cấp
+const erasedDomain = "erased.invalid"
+
+// ParseErasureMode đọc cách xử lý tin nhắn, chuỗi rỗng là redact
+func ParseErasureMode(value string) (db.ErasureMode, error) {
+	switch db.ErasureMode(value) {
+	case "", db.ErasureRedact:
+		return db.ErasureRedact, nil
+	case db.ErasureDelete:
+		return db.ErasureDelete, nil
+	}
+	return "", fmt.Errorf("mode %q không được hỗ trợ (hỗ trợ %s, %s)", value, db.ErasureRedact, db.ErasureDelete)
+}
+
+// Eraser xóa dữ liệu cá nhân của user và thông báo cho các consumer qua Kafka
+type Eraser struct {
+	store  *db.Store
+	outbox *kafka.OutboxRelay
+}
+
+// NewEraser tạo eraser; event user_erased được ghi vào outbox của store và relay publish sau
+func NewEraser(store *db.Store, outbox *kafka.OutboxRelay) *Eraser {
+	return &Eraser{store: store, outbox: outbox}
+}
+
+// Erase ẩn danh hồ sơ user (giữ ID để tin nhắn còn lại vẫn trỏ tới), xóa trắng hoặc xóa
+// tin nhắn theo mode, xóa reaction, vị trí đã đọc và đưa user ra khỏi các conversation.
+// Event user_erased được lưu cùng transaction. Gọi lại với cùng user không gây lỗi.
+// User không còn bản ghi users vẫn được xóa dữ liệu, result.Profile là false.
+func (e *Eraser) Erase(ctx context.Context, userID string, mode db.ErasureMode) (*db.ErasureResult, error) {
+	event, err := e.outbox.NewOutboxEvent(ctx, kafka.NewEvent("", &kafka.UserErasedPayload{UserID: userID, Mode: string(mode)}))
+	if err != nil {
+		return nil, fmt.Errorf("lỗi tạo event user_erased: %w", err)
+	}
+
+	// Username và email thay thế suy ra từ ID nên không trùng giữa các user và không chứa dữ liệu cũ
+	sum := sha256.Sum256([]byte(userID))
+	alias := "deleted-" + hex.EncodeToString(sum[:])[:16]
+	result, err := e.store.Privacy.Erase(ctx, db.Erasure{
+		UserID:   userID,
+		Mode:     mode,
+		Username: alias,
+		Email:    alias + "@" + erasedDomain,
+		FullName: ErasedFullName,
+		Event:    event,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Đã xóa dữ liệu của user", logging.KeyUserID, userID, "mode", mode, "profile", result.Profile,
+		"messages", result.Messages, "held_messages", result.HeldMessages, "reactions", result.Reactions,
+		"memberships", result.Memberships, "event_id", event.EventID)
+	return result, nil
+}
@@ -0,0 +1,315 @@
+package gdpr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/db/dbtest"
+	"vibeta/internal/kafka"
+	"vibeta/internal/models"
+)
+
+// newTestEraser mở database SQLite in-memory riêng cho test với dữ liệu mẫu:
+//
+//	general: bob (owner), alice; tin nhắn a1 của alice, b1 của bob
+//	held:    bob (owner), alice, đang legal hold; tin nhắn a2 của alice
+//
+// bob thả reaction trên a1, alice thả reaction trên b1 và đã đọc tới b1.
+func newTestEraser(t *testing.T) (*Eraser, *db.Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	store := dbtest.NewStore(t)
+
+	for _, userID := range []string{"alice", "bob"} {
+		user := &models.User{ID: userID, Username: userID, Email: userID + "@example.com", FullName: userID, Avatar: userID + ".png"}
+		if err := store.Users.Create(ctx, user); err != nil {
+			t.Fatalf("Users.Create %s: %v", userID, err)
+		}
+	}
+	for _, conversationID := range []string{"general", "held"} {
+		if err := store.Conversations.Create(ctx, &models.Conversation{ID: conversationID, Type: models.ConversationTypeGroup, CreatedBy: "bob"}); err != nil {
+			t.Fatalf("Conversations.Create %s: %v", conversationID, err)
+		}
+		if err := store.Participants.Add(ctx, conversationID, "bob", models.RoleOwner); err != nil {
+			t.Fatalf("Participants.Add: %v", err)
+		}
+		if err := store.Participants.Add(ctx, conversationID, "alice", models.RoleMember); err != nil {
+			t.Fatalf("Participants.Add: %v", err)
+		}
+	}
+	if err := store.Retention.SetLegalHold(ctx, "held", true); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+
+	now := time.Now()
+	for i, message := range []*models.Message{
+		{ID: "a1", ConversationID: "general", SenderID: "alice", Content: "địa chỉ nhà alice"},
+		{ID: "b1", ConversationID: "general", SenderID: "bob", Content: "cảm ơn alice"},
+		{ID: "a2", ConversationID: "held", SenderID: "alice", Content: "bằng chứng của alice"},
+	} {
+		message.Type = models.MessageTypeText
+		message.CreatedAt = now.Add(time.Duration(i) * time.Second)
+		if err := store.Messages.Create(ctx, message); err != nil {
+			t.Fatalf("Messages.Create %s: %v", message.ID, err)
+		}
+		if err := store.Search.Index(ctx, message); err != nil {
+			t.Fatalf("Search.Index %s: %v", message.ID, err)
+		}
+	}
+	for _, reaction := range []*models.MessageReaction{
+		{MessageID: "a1", UserID: "bob", Emoji: "👍", ConversationID: "general"},
+		{MessageID: "b1", UserID: "alice", Emoji: "❤️", ConversationID: "general"},
+	} {
+		if err := store.Reactions.Add(ctx, reaction); err != nil {
+			t.Fatalf("Reactions.Add: %v", err)
+		}
+	}
+	if err := store.ReadStates.MarkRead(ctx, "general", "alice", "b1", now); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+
+	outbox, err := kafka.NewOutboxRelay(store.Outbox, &kafka.ServiceConfig{})
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	return NewEraser(store, outbox), store
+}
+
+// outboxEvents claim và decode các event outbox chưa publish như outbox relay
+func outboxEvents(t *testing.T, store *db.Store) []*kafka.Event {
+	t.Helper()
+	ctx := context.Background()
+	claimed, err := store.Outbox.ClaimBatch(ctx, 100, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimBatch: %v", err)
+	}
+
+	var events []*kafka.Event
+	for _, event := range claimed {
+		var headers []kafka.RecordHeader
+		if err := json.Unmarshal([]byte(event.Headers), &headers); err != nil {
+			t.Fatalf("headers của outbox event %s: %v", event.EventID, err)
+		}
+		record := kafka.Record{Headers: headers, Value: event.Payload}
+		decoded, err := kafka.DecodeRecord(record.HeaderMap(), record.Value, nil)
+		if err != nil {
+			t.Fatalf("DecodeRecord %s: %v", event.EventID, err)
+		}
+		events = append(events, decoded)
+		if err := store.Outbox.MarkPublished(ctx, event.ID); err != nil {
+			t.Fatalf("MarkPublished: %v", err)
+		}
+	}
+	return events
+}
+
+// searchIDs trả về tin nhắn bob tìm thấy với từ khóa
+func searchIDs(t *testing.T, store *db.Store, text string) map[string]bool {
+	t.Helper()
+	page, err := store.Search.Search(context.Background(), db.SearchQuery{UserID: "bob", Text: text})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	ids := make(map[string]bool, len(page.Results))
+	for _, result := range page.Results {
+		ids[result.MessageID] = true
+	}
+	return ids
+}
+
+func TestErase(t *testing.T) {
+	tests := []struct {
+		mode         db.ErasureMode
+		want         db.ErasureResult
+		wantA1Exists bool // a1 được giữ lại với nội dung rỗng hay bị xóa hẳn
+		wantBobOnA1  bool
+	}{
+		{
+			mode:         db.ErasureRedact,
+			want:         db.ErasureResult{Messages: 1, HeldMessages: 1, Reactions: 1, Memberships: 2, Profile: true},
+			wantA1Exists: true,
+			wantBobOnA1:  true,
+		},
+		{
+			// Reaction của bob trên a1 bị xóa cùng tin nhắn
+			mode:        db.ErasureDelete,
+			want:        db.ErasureResult{Messages: 1, HeldMessages: 1, Reactions: 2, Memberships: 2, Profile: true},
+			wantBobOnA1: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			ctx := context.Background()
+			eraser, store := newTestEraser(t)
+
+			result, err := eraser.Erase(ctx, "alice", tt.mode)
+			if err != nil {
+				t.Fatalf("Erase: %v", err)
+			}
+			if *result != tt.want {
+				t.Errorf("Erase = %+v, muốn %+v", *result, tt.want)
+			}
+
+			// Hồ sơ bị ẩn danh nhưng giữ ID
+			user, err := store.Users.Get(ctx, "alice")
+			if err != nil {
+				t.Fatalf("Users.Get: %v", err)
+			}
+			if user.FullName != ErasedFullName || !strings.HasSuffix(user.Email, "@"+erasedDomain) ||
+				!strings.HasPrefix(user.Username, "deleted-") || user.Avatar != "" {
+				t.Errorf("hồ sơ chưa được ẩn danh: %+v", user)
+			}
+			if strings.Contains(user.Username, "alice") || strings.Contains(user.Email, "alice") {
+				t.Errorf("hồ sơ còn chứa dữ liệu cũ: %+v", user)
+			}
+
+			// Tin nhắn ngoài legal hold bị xóa trắng hoặc xóa hẳn, tin nhắn trong legal hold giữ nguyên
+			sent, err := store.Privacy.ListSentMessages(ctx, "alice", time.Time{}, "", 10)
+			if err != nil {
+				t.Fatalf("ListSentMessages: %v", err)
+			}
+			byID := make(map[string]models.Message, len(sent))
+			for _, message := range sent {
+				byID[message.ID] = message
+			}
+			a1, a1Exists := byID["a1"]
+			if a1Exists != tt.wantA1Exists || (a1Exists && a1.Content != "") {
+				t.Errorf("a1 = %+v (tồn tại %v), muốn tồn tại %v với nội dung rỗng", a1, a1Exists, tt.wantA1Exists)
+			}
+			if byID["a2"].Content != "bằng chứng của alice" {
+				t.Errorf("tin nhắn trong legal hold bị thay đổi: %+v", byID["a2"])
+			}
+			if ids := searchIDs(t, store, "địa chỉ"); ids["a1"] {
+				t.Error("a1 vẫn còn trong search index")
+			}
+			if ids := searchIDs(t, store, "bằng chứng"); !ids["a2"] {
+				t.Error("a2 trong legal hold bị xóa khỏi search index")
+			}
+
+			// Reaction, vị trí đã đọc và membership của alice bị xóa
+			if reactions, _ := store.Privacy.ListReactions(ctx, "alice"); len(reactions) != 0 {
+				t.Errorf("còn reaction của alice: %+v", reactions)
+			}
+			if states, _ := store.Privacy.ListReadStates(ctx, "alice"); len(states) != 0 {
+				t.Errorf("còn vị trí đã đọc của alice: %+v", states)
+			}
+			for _, conversationID := range []string{"general", "held"} {
+				if member, _ := store.Participants.IsParticipant(ctx, conversationID, "alice"); member {
+					t.Errorf("alice vẫn là thành viên của %s", conversationID)
+				}
+			}
+			bobReactions, err := store.Reactions.ListByMessage(ctx, "a1")
+			if err != nil {
+				t.Fatalf("ListByMessage: %v", err)
+			}
+			if got := len(bobReactions) == 1; got != tt.wantBobOnA1 {
+				t.Errorf("reaction của bob trên a1 còn = %v, muốn %v", got, tt.wantBobOnA1)
+			}
+
+			// Dữ liệu của bob không bị ảnh hưởng
+			if b1, err := store.Messages.Get(ctx, "b1"); err != nil || b1.Content != "cảm ơn alice" {
+				t.Errorf("b1 = %+v (err %v), muốn giữ nguyên", b1, err)
+			}
+
+			// Một event user_erased được ghi vào outbox cùng transaction
+			events := outboxEvents(t, store)
+			if len(events) != 1 {
+				t.Fatalf("outbox có %d event, muốn 1", len(events))
+			}
+			payload, ok := events[0].Payload.(*kafka.UserErasedPayload)
+			if events[0].Type != kafka.EventTypeUserErased || !ok {
+				t.Fatalf("event = %s %T, muốn user_erased", events[0].Type, events[0].Payload)
+			}
+			if payload.UserID != "alice" || payload.Mode != string(tt.mode) {
+				t.Errorf("payload = %+v, muốn alice %s", payload, tt.mode)
+			}
+
+			// Gọi lại với cùng user không lỗi
+			if _, err := eraser.Erase(ctx, "alice", tt.mode); err != nil {
+				t.Fatalf("Erase lần hai: %v", err)
+			}
+		})
+	}
+}
+
+// User không còn bản ghi users (ví dụ bị xóa tay khỏi bảng) vẫn được xóa phần dữ liệu còn lại
+func TestEraseWithoutProfile(t *testing.T) {
+	ctx := context.Background()
+	eraser, store := newTestEraser(t)
+
+	if err := store.Participants.Add(ctx, "general", "carol", models.RoleMember); err != nil {
+		t.Fatalf("Participants.Add: %v", err)
+	}
+	if err := store.Reactions.Add(ctx, &models.MessageReaction{MessageID: "b1", UserID: "carol", Emoji: "👍", ConversationID: "general"}); err != nil {
+		t.Fatalf("Reactions.Add: %v", err)
+	}
+	if err := store.ReadStates.MarkRead(ctx, "general", "carol", "b1", time.Now()); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	carolMessage := &models.Message{ID: "c1", ConversationID: "general", SenderID: "carol", Content: "số điện thoại của carol",
+		Type: models.MessageTypeText, CreatedAt: time.Now()}
+	if err := store.Messages.Create(ctx, carolMessage); err != nil {
+		t.Fatalf("Messages.Create: %v", err)
+	}
+	if err := store.Search.Index(ctx, carolMessage); err != nil {
+		t.Fatalf("Search.Index: %v", err)
+	}
+
+	result, err := eraser.Erase(ctx, "carol", db.ErasureRedact)
+	if err != nil {
+		t.Fatalf("Erase(carol) = %v, muốn xóa được dữ liệu còn lại", err)
+	}
+	if want := (db.ErasureResult{Messages: 1, Reactions: 1, Memberships: 1}); *result != want {
+		t.Errorf("Erase = %+v, muốn %+v", *result, want)
+	}
+	if _, err := store.Users.Get(ctx, "carol"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("Users.Get(carol) = %v, muốn ErrNotFound: không tạo hồ sơ mới", err)
+	}
+
+	if c1, err := store.Messages.Get(ctx, "c1"); err != nil || c1.Content != "" {
+		t.Errorf("c1 = %+v (err %v), muốn xóa trắng nội dung", c1, err)
+	}
+	if ids := searchIDs(t, store, "điện thoại"); ids["c1"] {
+		t.Error("c1 vẫn còn trong search index")
+	}
+	if reactions, _ := store.Privacy.ListReactions(ctx, "carol"); len(reactions) != 0 {
+		t.Errorf("còn reaction của carol: %+v", reactions)
+	}
+	if states, _ := store.Privacy.ListReadStates(ctx, "carol"); len(states) != 0 {
+		t.Errorf("còn vị trí đã đọc của carol: %+v", states)
+	}
+	if member, _ := store.Participants.IsParticipant(ctx, "general", "carol"); member {
+		t.Error("carol vẫn là thành viên của general")
+	}
+	if events := outboxEvents(t, store); len(events) != 1 || events[0].Type != kafka.EventTypeUserErased {
+		t.Errorf("outbox có %d event, muốn một event user_erased", len(events))
+	}
+}
+
+func TestParseErasureMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    db.ErasureMode
+		wantErr bool
+	}{
+		{value: "", want: db.ErasureRedact},
+		{value: "redact", want: db.ErasureRedact},
+		{value: "delete", want: db.ErasureDelete},
+		{value: "DELETE", wantErr: true},
+		{value: "purge", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseErasureMode(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseErasureMode(%q) = %q, %v; muốn %q, lỗi %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
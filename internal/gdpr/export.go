@@ -0,0 +1,259 @@
+// Package gdpr xử lý yêu cầu của chủ thể dữ liệu: export toàn bộ dữ liệu gắn với một user
+// thành file zip và xóa dữ liệu đó (ẩn danh hồ sơ, xóa trắng hoặc xóa tin nhắn).
+package gdpr
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/models"
+)
+
+// batchSize số tin nhắn đọc mỗi lần khi export
+const batchSize = 500
+
+// Các file trong archive export
+const (
+	fileManifest    = "manifest.json"
+	fileProfile     = "profile.json"
+	fileMemberships = "memberships.json"
+	fileMessages    = "messages.jsonl"
+	fileReactions   = "reactions.json"
+	fileReadStates  = "read_states.json"
+	fileUploads     = "uploads.json"
+)
+
+// Manifest mô tả nội dung archive export
+type Manifest struct {
+	UserID      string         `json:"user_id"`
+	ExportedAt  time.Time      `json:"exported_at"`
+	Files       map[string]int `json:"files"` // Tên file -> số bản ghi
+	Description string         `json:"description"`
+}
+
+// Membership một lần tham gia conversation
+type Membership struct {
+	ConversationID   string                  `json:"conversation_id"`
+	ConversationName string                  `json:"conversation_name,omitempty"`
+	ConversationType models.ConversationType `json:"conversation_type,omitempty"`
+	JoinedAt         time.Time               `json:"joined_at"`
+	LeftAt           *time.Time              `json:"left_at,omitempty"`
+}
+
+// Message một tin nhắn user đã gửi, kể cả đã xóa
+type Message struct {
+	ID             string               `json:"id"`
+	ConversationID string               `json:"conversation_id"`
+	Content        string               `json:"content"`
+	Type           models.MessageType   `json:"type"`
+	Status         models.MessageStatus `json:"status"`
+	ReplyToID      string               `json:"reply_to_id,omitempty"`
+	Attachments    json.RawMessage      `json:"attachments,omitempty"`
+	EditedAt       *time.Time           `json:"edited_at,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	DeletedAt      *time.Time           `json:"deleted_at,omitempty"`
+}
+
+// Reaction một reaction user đã thả
+type Reaction struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Emoji          string    `json:"emoji"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Upload một file user đã đính kèm. vibeta chỉ lưu metadata, nội dung file nằm ở URL.
+type Upload struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	models.Attachment
+}
+
+// Exporter gom dữ liệu của một user thành archive zip
+type Exporter struct {
+	store *db.Store
+}
+
+// NewExporter tạo exporter đọc từ store
+func NewExporter(store *db.Store) *Exporter {
+	return &Exporter{store: store}
+}
+
+// Export ghi archive zip chứa hồ sơ, các conversation đã tham gia, tin nhắn đã gửi, reaction,
+// vị trí đã đọc và metadata file đính kèm của user ra w. Tin nhắn được ghi theo từng batch.
+// Trả về ErrNotFound nếu user không tồn tại; lúc đó chưa có byte nào được ghi.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, userID string) (*Manifest, error) {
+	user, err := e.store.Users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		UserID:      userID,
+		ExportedAt:  time.Now().UTC(),
+		Files:       make(map[string]int),
+		Description: "Dữ liệu cá nhân gắn với user: hồ sơ, conversation đã tham gia, tin nhắn đã gửi (kể cả đã xóa), reaction, vị trí đã đọc và metadata file đính kèm.",
+	}
+	archive := zip.NewWriter(w)
+
+	if err := writeJSON(archive, fileProfile, user); err != nil {
+		return nil, err
+	}
+	manifest.Files[fileProfile] = 1
+
+	memberships, err := e.memberships(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeJSON(archive, fileMemberships, memberships); err != nil {
+		return nil, err
+	}
+	manifest.Files[fileMemberships] = len(memberships)
+
+	uploads, count, err := e.writeMessages(ctx, archive, userID)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files[fileMessages] = count
+
+	reactions, err := e.store.Privacy.ListReactions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi đọc reaction: %w", err)
+	}
+	records := make([]Reaction, len(reactions))
+	for i, reaction := range reactions {
+		records[i] = Reaction{
+			MessageID:      reaction.MessageID,
+			ConversationID: reaction.ConversationID,
+			Emoji:          reaction.Emoji,
+			CreatedAt:      reaction.CreatedAt,
+		}
+	}
+	if err := writeJSON(archive, fileReactions, records); err != nil {
+		return nil, err
+	}
+	manifest.Files[fileReactions] = len(records)
+
+	readStates, err := e.store.Privacy.ListReadStates(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi đọc vị trí đã đọc: %w", err)
+	}
+	if readStates == nil {
+		readStates = []models.ReadState{}
+	}
+	if err := writeJSON(archive, fileReadStates, readStates); err != nil {
+		return nil, err
+	}
+	manifest.Files[fileReadStates] = len(readStates)
+
+	if err := writeJSON(archive, fileUploads, uploads); err != nil {
+		return nil, err
+	}
+	manifest.Files[fileUploads] = len(uploads)
+
+	if err := writeJSON(archive, fileManifest, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, archive.Close()
+}
+
+func (e *Exporter) memberships(ctx context.Context, userID string) ([]Membership, error) {
+	participants, err := e.store.Privacy.Memberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi đọc thành viên: %w", err)
+	}
+
+	memberships := make([]Membership, len(participants))
+	for i, participant := range participants {
+		memberships[i] = Membership{
+			ConversationID: participant.ConversationID,
+			JoinedAt:       participant.JoinedAt,
+			LeftAt:         participant.LeftAt,
+		}
+		// Conversation đã xóa vẫn được liệt kê, chỉ thiếu tên
+		if conversation, err := e.store.Conversations.Get(ctx, participant.ConversationID); err == nil {
+			memberships[i].ConversationName = conversation.Name
+			memberships[i].ConversationType = conversation.Type
+		}
+	}
+	return memberships, nil
+}
+
+// writeMessages ghi messages.jsonl theo từng batch, trả về metadata file đính kèm và số tin nhắn
+func (e *Exporter) writeMessages(ctx context.Context, archive *zip.Writer, userID string) ([]Upload, int, error) {
+	file, err := archive.Create(fileMessages)
+	if err != nil {
+		return nil, 0, err
+	}
+	encoder := json.NewEncoder(file)
+
+	uploads := []Upload{}
+	count := 0
+	var afterCreatedAt time.Time
+	var afterID string
+	for {
+		messages, err := e.store.Privacy.ListSentMessages(ctx, userID, afterCreatedAt, afterID, batchSize)
+		if err != nil {
+			return nil, count, fmt.Errorf("lỗi đọc tin nhắn: %w", err)
+		}
+
+		for _, message := range messages {
+			record := Message{
+				ID:             message.ID,
+				ConversationID: message.ConversationID,
+				Content:        message.Content,
+				Type:           message.Type,
+				Status:         message.Status,
+				ReplyToID:      message.ReplyToID,
+				EditedAt:       message.EditedAt,
+				CreatedAt:      message.CreatedAt,
+				UpdatedAt:      message.UpdatedAt,
+			}
+			if message.DeletedAt.Valid {
+				record.DeletedAt = &message.DeletedAt.Time
+			}
+			if json.Valid([]byte(message.Attachments)) {
+				record.Attachments = json.RawMessage(message.Attachments)
+
+				var attachments []models.Attachment
+				json.Unmarshal(record.Attachments, &attachments)
+				for _, attachment := range attachments {
+					uploads = append(uploads, Upload{
+						MessageID:      message.ID,
+						ConversationID: message.ConversationID,
+						CreatedAt:      message.CreatedAt,
+						Attachment:     attachment,
+					})
+				}
+			}
+			if err := encoder.Encode(record); err != nil {
+				return nil, count, err
+			}
+			count++
+		}
+
+		if len(messages) < batchSize {
+			return uploads, count, nil
+		}
+		last := messages[len(messages)-1]
+		afterCreatedAt, afterID = last.CreatedAt, last.ID
+	}
+}
+
+// writeJSON ghi v thành một file JSON trong archive
+func writeJSON(archive *zip.Writer, name string, v any) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check kiểm tra một dependency, trả về lỗi nếu dependency chưa sẵn sàng
+type Check func(ctx context.Context) error
+
+// Checker chạy các readiness check và phục vụ endpoint /livez, /readyz
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+	started time.Time
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// CheckResult kết quả của một readiness check
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// NewChecker tạo checker với timeout cho mỗi lần chạy readiness
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		started: time.Now(),
+	}
+}
+
+// AddReadinessCheck đăng ký một check phải pass thì service mới ready
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Register gắn /livez và /readyz vào mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/livez", c.LiveHandler)
+	mux.HandleFunc("/readyz", c.ReadyHandler)
+}
+
+// LiveHandler trả về 200 khi process còn chạy, không kiểm tra dependency
+func (c *Checker) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "alive",
+		"uptime": time.Since(c.started).Round(time.Second).String(),
+	})
+}
+
+// ReadyHandler chạy tất cả readiness check song song,
+// trả về 503 nếu có check không pass
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	results, ready := c.Run(ctx)
+
+	status := http.StatusOK
+	body := map[string]interface{}{
+		"status": "ready",
+		"checks": results,
+	}
+	if !ready {
+		status = http.StatusServiceUnavailable
+		body["status"] = "not_ready"
+	}
+	writeJSON(w, status, body)
+}
+
+// Run chạy tất cả readiness check và trả về kết quả theo thứ tự đăng ký
+func (c *Checker) Run(ctx context.Context) ([]CheckResult, bool) {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, nc)
+		}(i, nc)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Status != "ok" {
+			ready = false
+		}
+	}
+	return results, ready
+}
+
+// runCheck chạy một check, coi như fail nếu vượt quá deadline của ctx
+func runCheck(ctx context.Context, nc namedCheck) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- nc.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Name:     nc.name,
+		Status:   "ok",
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
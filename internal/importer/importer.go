@@ -0,0 +1,247 @@
+// Package importer nhập lịch sử chat từ bản export của Slack (file zip) và Mattermost
+// (bulk import JSONL). User, channel và tin nhắn được ánh xạ sang models.User,
+// models.Conversation và models.Message với ID suy ra từ ID gốc, nên chạy lại cùng
+// bản export không tạo thêm dữ liệu.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"vibeta/internal/db"
+	"vibeta/internal/models"
+)
+
+// Source nguồn của bản export
+type Source string
+
+const (
+	SourceSlack      Source = "slack"      // File zip export của Slack workspace
+	SourceMattermost Source = "mattermost" // Bulk import JSONL của Mattermost, hoặc file zip chứa nó
+)
+
+// placeholderDomain domain email của user không có email trong bản export.
+// .invalid không bao giờ được cấp nên không trùng email thật.
+const placeholderDomain = "import.invalid"
+
+// systemUserKey key của user đại diện cho importer, dùng làm người tạo conversation không rõ
+// người tạo. Bắt đầu bằng "_" nên không trùng ID user Slack hay username Mattermost.
+const systemUserKey = "_system"
+
+// ErrInvalidArchive bản export không đúng định dạng
+var ErrInvalidArchive = errors.New("bản export không hợp lệ")
+
+// ParseSource đọc nguồn export
+func ParseSource(value string) (Source, error) {
+	switch Source(value) {
+	case SourceSlack, SourceMattermost:
+		return Source(value), nil
+	}
+	return "", fmt.Errorf("nguồn %q không được hỗ trợ (hỗ trợ %s, %s)", value, SourceSlack, SourceMattermost)
+}
+
+// Stats thống kê một lần import. Users và Conversations chỉ đếm bản ghi mới tạo;
+// Messages và Reactions đếm số đã xử lý, kể cả bản ghi đã có từ lần import trước.
+type Stats struct {
+	Users         int // User mới tạo
+	MatchedUsers  int // User trong bản export được gộp vào user sẵn có cùng email
+	Conversations int // Conversation mới tạo
+	Messages      int
+	Reactions     int
+	Skipped       int // Tin nhắn bỏ qua vì thiếu người gửi hoặc thời gian
+}
+
+// Import nhập bản export tại path vào store
+func Import(ctx context.Context, store *db.Store, source Source, path string) (Stats, error) {
+	switch source {
+	case SourceSlack:
+		im := newImporter(store, "slack", "Slack")
+		err := im.importSlack(ctx, path)
+		return im.stats, err
+	case SourceMattermost:
+		im := newImporter(store, "mm", "Mattermost")
+		err := im.importMattermost(ctx, path)
+		return im.stats, err
+	}
+	return Stats{}, fmt.Errorf("nguồn %q không được hỗ trợ", source)
+}
+
+// importer trạng thái chung của một lần import
+type importer struct {
+	store  *db.Store
+	prefix string            // Tiền tố ID của mọi bản ghi import từ nguồn này
+	label  string            // Tên nguồn hiển thị cho user
+	users  map[string]string // Key user trong bản export -> user ID
+	stats  Stats
+}
+
+func newImporter(store *db.Store, prefix, label string) *importer {
+	return &importer{store: store, prefix: prefix, label: label, users: make(map[string]string)}
+}
+
+// id ID của bản ghi import, cùng ID gốc luôn cho cùng kết quả
+func (im *importer) id(parts ...string) string {
+	return im.prefix + "_" + strings.Join(parts, "_")
+}
+
+// ensureUser trả về user ID ứng với key trong bản export, tạo user nếu chưa có. User đã import
+// ở lần trước được dùng lại; nếu chưa thì user sẵn có cùng email được dùng thay vì tạo mới.
+// Username đã bị user khác dùng được thêm hậu tố nguồn.
+func (im *importer) ensureUser(ctx context.Context, key string, user models.User) (string, error) {
+	if id, ok := im.users[key]; ok {
+		return id, nil
+	}
+
+	user.ID = im.id(key)
+	if _, err := im.store.Users.Get(ctx, user.ID); err == nil {
+		im.users[key] = user.ID
+		return user.ID, nil
+	} else if !errors.Is(err, db.ErrNotFound) {
+		return "", fmt.Errorf("lỗi đọc user %s: %w", user.ID, err)
+	}
+
+	if user.Email != "" {
+		existing, err := im.store.Users.GetByEmail(ctx, user.Email)
+		if err == nil {
+			im.users[key] = existing.ID
+			im.stats.MatchedUsers++
+			return existing.ID, nil
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			return "", fmt.Errorf("lỗi tìm user theo email: %w", err)
+		}
+	} else {
+		user.Email = strings.ToLower(user.ID) + "@" + placeholderDomain
+	}
+
+	if user.Username == "" {
+		user.Username = key
+	}
+	if user.FullName == "" {
+		user.FullName = user.Username
+	}
+	user.Status = models.UserStatusOffline
+
+	var err error
+	for _, username := range []string{user.Username, user.Username + "-" + im.prefix, user.ID} {
+		user.Username = username
+		if err = im.store.Users.Create(ctx, &user); !errors.Is(err, db.ErrDuplicate) {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("lỗi tạo user %s: %w", user.ID, err)
+	}
+
+	im.users[key] = user.ID
+	im.stats.Users++
+	return user.ID, nil
+}
+
+// systemUser user đại diện cho importer
+func (im *importer) systemUser(ctx context.Context) (string, error) {
+	return im.ensureUser(ctx, systemUserKey, models.User{
+		Username: im.prefix + "-import",
+		FullName: im.label + " import",
+	})
+}
+
+// placeholderUser user cho người gửi không có trong danh sách user của bản export
+func (im *importer) placeholderUser(ctx context.Context, key, name string) (string, error) {
+	if name == "" {
+		name = key
+	}
+	return im.ensureUser(ctx, key, models.User{Username: strings.ToLower(name), FullName: name})
+}
+
+// ensureConversation tạo conversation nếu chưa có và thêm các thành viên, người tạo là owner
+func (im *importer) ensureConversation(ctx context.Context, conversation *models.Conversation, members []string) error {
+	_, err := im.store.Conversations.Get(ctx, conversation.ID)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		if err := im.store.Conversations.Create(ctx, conversation); err != nil {
+			return fmt.Errorf("lỗi tạo conversation %s: %w", conversation.ID, err)
+		}
+		im.stats.Conversations++
+	case err != nil:
+		return fmt.Errorf("lỗi đọc conversation %s: %w", conversation.ID, err)
+	}
+
+	for _, member := range members {
+		role := models.RoleMember
+		if member == conversation.CreatedBy {
+			role = models.RoleOwner
+		}
+		if err := im.store.Participants.Add(ctx, conversation.ID, member, role); err != nil {
+			return fmt.Errorf("lỗi thêm thành viên %s vào %s: %w", member, conversation.ID, err)
+		}
+	}
+	return nil
+}
+
+// saveMessage lưu tin nhắn cùng reaction và đưa vào search index. Tin nhắn đã import
+// ở lần trước được giữ nguyên; reaction trùng được bỏ qua.
+func (im *importer) saveMessage(ctx context.Context, message *models.Message, reactions []models.MessageReaction) error {
+	if message.Status == "" {
+		message.Status = models.MessageStatusSent
+	}
+	if err := im.store.Messages.CreateIfAbsent(ctx, message); err != nil {
+		return fmt.Errorf("lỗi lưu tin nhắn %s: %w", message.ID, err)
+	}
+	if message.Content != "" {
+		if err := im.store.Search.Index(ctx, message); err != nil {
+			return fmt.Errorf("lỗi index tin nhắn %s: %w", message.ID, err)
+		}
+	}
+	im.stats.Messages++
+
+	for i := range reactions {
+		reactions[i].MessageID = message.ID
+		reactions[i].ConversationID = message.ConversationID
+		if reactions[i].CreatedAt.IsZero() {
+			reactions[i].CreatedAt = message.CreatedAt
+		}
+		if err := im.store.Reactions.Add(ctx, &reactions[i]); err != nil {
+			return fmt.Errorf("lỗi lưu reaction của tin nhắn %s: %w", message.ID, err)
+		}
+		im.stats.Reactions++
+	}
+	return nil
+}
+
+// skip ghi log và đếm tin nhắn không import được
+func (im *importer) skip(ctx context.Context, reason string, args ...any) {
+	im.stats.Skipped++
+	slog.DebugContext(ctx, "Bỏ qua tin nhắn: "+reason, args...)
+}
+
+// attachmentsJSON chuỗi JSON lưu trong models.Message.Attachments, rỗng nếu không có attachment
+func attachmentsJSON(attachments []models.Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(attachments)
+	return string(data)
+}
+
+// messageType loại tin nhắn theo attachment: image nếu mọi attachment là ảnh
+func messageType(attachments []models.Attachment) models.MessageType {
+	if len(attachments) == 0 {
+		return models.MessageTypeText
+	}
+	for _, attachment := range attachments {
+		if !strings.HasPrefix(attachment.FileType, "image/") {
+			return models.MessageTypeFile
+		}
+	}
+	return models.MessageTypeImage
+}
+
+// shortcode emoji theo dạng :name: như cả Slack và Mattermost dùng
+func shortcode(name string) string {
+	return ":" + strings.Trim(name, ":") + ":"
+}
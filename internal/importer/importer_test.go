@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"vibeta/internal/db"
+	"vibeta/internal/db/dbtest"
+)
+
+// slackFixture bản export Slack nhỏ: hai user, một channel có thread và reaction, một DM
+// có người gửi không nằm trong users.json
+var slackFixture = map[string]string{
+	"export/users.json": `[
+		{"id": "U1", "name": "alice", "profile": {"email": "alice@example.com", "real_name": "Alice"}},
+		{"id": "U2", "name": "bob", "profile": {"real_name": "Bob"}}
+	]`,
+	"export/channels.json": `[
+		{"id": "C1", "name": "general", "created": 1700000000, "creator": "U1", "members": ["U1", "U2"],
+		 "purpose": {"value": "Kênh chung"}}
+	]`,
+	"export/general/2023-11-14.json": `[
+		{"type": "message", "subtype": "channel_join", "user": "U2", "text": "<@U2> đã tham gia", "ts": "1700000001.000100"},
+		{"type": "message", "user": "U1", "text": "Chào <@U2>", "ts": "1700000002.000200",
+		 "reactions": [{"name": "wave", "users": ["U2"]}]},
+		{"type": "message", "user": "U2", "text": "Chào Alice", "ts": "1700000003.000300", "thread_ts": "1700000002.000200"}
+	]`,
+	"export/general/2023-11-15.json": `[
+		{"type": "message", "bot_id": "B1", "username": "deploy-bot", "text": "Đã deploy", "ts": "1700086400.000100"}
+	]`,
+	"export/dms.json": `[
+		{"id": "D1", "created": 1700000000, "members": ["U1", "U3"]}
+	]`,
+	"export/D1/2023-11-14.json": `[
+		{"type": "message", "user": "U3", "text": "Tin nhắn riêng", "ts": "1700000010.000100"},
+		{"type": "message", "text": "Không có người gửi", "ts": "1700000011.000100"}
+	]`,
+}
+
+// mattermostFixture bản bulk import Mattermost nhỏ: channel, user có membership, post có reply
+// và reaction, post vào channel chưa khai báo, kênh nhắn riêng và direct post
+const mattermostFixture = `{"type": "version", "version": 1}
+{"type": "channel", "channel": {"team": "eng", "name": "town-square", "display_name": "Town Square", "purpose": "Kênh chung"}}
+{"type": "user", "user": {"username": "carol", "email": "carol@example.com", "first_name": "Carol", "teams": [{"name": "eng", "channels": [{"name": "town-square"}]}]}}
+{"type": "user", "user": {"username": "dave", "teams": [{"name": "eng", "channels": [{"name": "town-square"}, {"name": "random"}]}]}}
+{"type": "post", "post": {"team": "eng", "channel": "town-square", "user": "carol", "message": "Xin chào", "create_at": 1700000000000, "replies": [{"user": "dave", "message": "Chào Carol", "create_at": 1700000001000}], "reactions": [{"user": "dave", "emoji_name": "wave", "create_at": 1700000002000}]}}
+{"type": "post", "post": {"team": "eng", "channel": "standup", "user": "dave", "message": "Hôm nay làm gì?", "create_at": 1700000003000}}
+{"type": "direct_channel", "direct_channel": {"members": ["dave", "carol"]}}
+{"type": "direct_post", "direct_post": {"channel_members": ["carol", "dave"], "user": "carol", "message": "Tin nhắn riêng", "create_at": 1700000004000}}
+{"type": "direct_post", "direct_post": {"channel_members": ["carol", "erin"], "user": "erin", "message": "Người ngoài", "create_at": 1700000005000}}
+`
+
+func writeSlackFixture(t *testing.T) string {
+	t.Helper()
+
+	name := filepath.Join(t.TempDir(), "slack.zip")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("tạo file zip: %v", err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for path, content := range slackFixture {
+		entry, err := writer.Create(path)
+		if err != nil {
+			t.Fatalf("thêm %s vào zip: %v", path, err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatalf("ghi %s: %v", path, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("đóng zip: %v", err)
+	}
+	return name
+}
+
+func writeMattermostFixture(t *testing.T) string {
+	t.Helper()
+
+	name := filepath.Join(t.TempDir(), "mattermost.jsonl")
+	if err := os.WriteFile(name, []byte(mattermostFixture), 0o600); err != nil {
+		t.Fatalf("ghi file jsonl: %v", err)
+	}
+	return name
+}
+
+// conversationSnapshot dữ liệu của một conversation sau khi import
+type conversationSnapshot struct {
+	Participants []string
+	Messages     []string
+	Reactions    int
+}
+
+// snapshot đọc thành viên, tin nhắn và reaction của các conversation đã biết và mọi
+// conversation mà các user đang tham gia
+func snapshot(t *testing.T, store *db.Store, userIDs, conversationIDs []string) map[string]conversationSnapshot {
+	t.Helper()
+	ctx := context.Background()
+
+	ids := append([]string(nil), conversationIDs...)
+	for _, userID := range userIDs {
+		conversations, err := store.Conversations.ListByUser(ctx, userID)
+		if err != nil {
+			t.Fatalf("ListByUser(%s): %v", userID, err)
+		}
+		for _, conversation := range conversations {
+			ids = append(ids, conversation.ID)
+		}
+	}
+
+	result := make(map[string]conversationSnapshot)
+	for _, conversationID := range ids {
+		if _, ok := result[conversationID]; ok {
+			continue
+		}
+		if _, err := store.Conversations.Get(ctx, conversationID); err != nil {
+			t.Fatalf("Conversations.Get(%s): %v", conversationID, err)
+		}
+
+		var snap conversationSnapshot
+		participants, err := store.Participants.List(ctx, conversationID)
+		if err != nil {
+			t.Fatalf("Participants.List(%s): %v", conversationID, err)
+		}
+		for _, participant := range participants {
+			snap.Participants = append(snap.Participants, participant.UserID)
+		}
+		sort.Strings(snap.Participants)
+
+		messages, err := store.Messages.ListByConversation(ctx, conversationID, 1000, 0)
+		if err != nil {
+			t.Fatalf("ListByConversation(%s): %v", conversationID, err)
+		}
+		for _, message := range messages {
+			snap.Messages = append(snap.Messages, message.ID)
+			reactions, err := store.Reactions.ListByMessage(ctx, message.ID)
+			if err != nil {
+				t.Fatalf("ListByMessage(%s): %v", message.ID, err)
+			}
+			snap.Reactions += len(reactions)
+		}
+		sort.Strings(snap.Messages)
+		result[conversationID] = snap
+	}
+	return result
+}
+
+func TestImportTwiceCreatesNoDuplicates(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		path   func(t *testing.T) string
+		users  []string // User ID mong đợi sau import
+		// Conversation không có thành viên nên không tìm được qua ListByUser
+		conversations []string
+		want          Stats // Thống kê lần import đầu
+	}{
+		{
+			name:   "slack",
+			source: SourceSlack,
+			path:   writeSlackFixture,
+			users:  []string{"slack_U1", "slack_U2", "slack_U3", "slack_B1"},
+			want:   Stats{Users: 4, Conversations: 2, Messages: 5, Reactions: 1, Skipped: 1},
+		},
+		{
+			name:   "mattermost",
+			source: SourceMattermost,
+			path:   writeMattermostFixture,
+			users:  []string{"mm_carol", "mm_dave", "mm_erin", "mm__system"},
+			// Post vào channel chưa khai báo và không ai có membership
+			conversations: []string{"mm_eng_standup"},
+			want:          Stats{Users: 4, Conversations: 5, Messages: 5, Reactions: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dbtest.NewStore(t)
+			path := tt.path(t)
+
+			first, err := Import(ctx, store, tt.source, path)
+			if err != nil {
+				t.Fatalf("Import lần đầu: %v", err)
+			}
+			if first != tt.want {
+				t.Errorf("Stats lần đầu = %+v, muốn %+v", first, tt.want)
+			}
+			for _, userID := range tt.users {
+				if _, err := store.Users.Get(ctx, userID); err != nil {
+					t.Errorf("Users.Get(%s) sau import: %v", userID, err)
+				}
+			}
+			before := snapshot(t, store, tt.users, tt.conversations)
+			if len(before) != tt.want.Conversations {
+				t.Fatalf("có %d conversation sau import, muốn %d", len(before), tt.want.Conversations)
+			}
+
+			second, err := Import(ctx, store, tt.source, path)
+			if err != nil {
+				t.Fatalf("Import lần hai: %v", err)
+			}
+			if second.Users != 0 || second.MatchedUsers != 0 || second.Conversations != 0 {
+				t.Errorf("Import lần hai tạo %d user, gộp %d user, tạo %d conversation, muốn 0",
+					second.Users, second.MatchedUsers, second.Conversations)
+			}
+			if second.Messages != first.Messages || second.Reactions != first.Reactions {
+				t.Errorf("Import lần hai xử lý %d tin nhắn, %d reaction, muốn %d, %d",
+					second.Messages, second.Reactions, first.Messages, first.Reactions)
+			}
+			if after := snapshot(t, store, tt.users, tt.conversations); !reflect.DeepEqual(after, before) {
+				t.Errorf("dữ liệu sau import lần hai = %+v, muốn giữ nguyên %+v", after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// mattermostLine một dòng của file bulk import, chỉ field ứng với Type có giá trị
+type mattermostLine struct {
+	Type          string                   `json:"type"`
+	Channel       *mattermostChannel       `json:"channel"`
+	User          *mattermostUser          `json:"user"`
+	Post          *mattermostPost          `json:"post"`
+	DirectChannel *mattermostDirectChannel `json:"direct_channel"`
+	DirectPost    *mattermostPost          `json:"direct_post"`
+}
+
+type mattermostChannel struct {
+	Team        string `json:"team"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Header      string `json:"header"`
+	Purpose     string `json:"purpose"`
+}
+
+type mattermostUser struct {
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+	Teams     []struct {
+		Name     string `json:"name"`
+		Channels []struct {
+			Name string `json:"name"`
+		} `json:"channels"`
+	} `json:"teams"`
+}
+
+type mattermostDirectChannel struct {
+	Members []string `json:"members"`
+	Header  string   `json:"header"`
+}
+
+// mattermostPost post, direct post hoặc reply. Reply không có team, channel và replies.
+type mattermostPost struct {
+	Team           string           `json:"team"`
+	Channel        string           `json:"channel"`
+	ChannelMembers []string         `json:"channel_members"`
+	User           string           `json:"user"`
+	Message        string           `json:"message"`
+	CreateAt       int64            `json:"create_at"` // Mili giây
+	EditAt         int64            `json:"edit_at"`
+	Replies        []mattermostPost `json:"replies"`
+	Reactions      []struct {
+		User      string `json:"user"`
+		EmojiName string `json:"emoji_name"`
+		CreateAt  int64  `json:"create_at"`
+	} `json:"reactions"`
+	Attachments []struct {
+		Path string `json:"path"`
+	} `json:"attachments"`
+}
+
+// mattermostImport trạng thái import một file bulk import Mattermost
+type mattermostImport struct {
+	*importer
+	channels map[string]bool // Conversation ID đã tạo hoặc kiểm tra trong lần import này
+	counts   map[string]int  // Conversation ID -> số post đã import
+}
+
+func (im *importer) importMattermost(ctx context.Context, name string) error {
+	reader, closer, err := openMattermostArchive(name)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	m := &mattermostImport{importer: im, channels: make(map[string]bool), counts: make(map[string]int)}
+	decoder := json.NewDecoder(bufio.NewReaderSize(reader, 1<<20))
+	for lineNumber := 1; ; lineNumber++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var line mattermostLine
+		if err := decoder.Decode(&line); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%w: dòng %d: %v", ErrInvalidArchive, lineNumber, err)
+		}
+		if err := m.importLine(ctx, &line); err != nil {
+			return fmt.Errorf("dòng %d (%s): %w", lineNumber, line.Type, err)
+		}
+	}
+
+	for conversationID, count := range m.counts {
+		slog.InfoContext(ctx, "Đã import channel Mattermost", logging.KeyConversationID, conversationID, "posts", count)
+	}
+	return nil
+}
+
+// openMattermostArchive mở file JSONL, hoặc file .jsonl đầu tiên trong file zip do mmctl export tạo
+func openMattermostArchive(name string) (io.Reader, io.Closer, error) {
+	if !strings.EqualFold(path.Ext(name), ".zip") {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, file, nil
+	}
+
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range archive.File {
+		if strings.HasSuffix(file.Name, ".jsonl") {
+			reader, err := file.Open()
+			if err != nil {
+				archive.Close()
+				return nil, nil, err
+			}
+			return reader, archive, nil
+		}
+	}
+	archive.Close()
+	return nil, nil, fmt.Errorf("%w: không có file .jsonl trong %s", ErrInvalidArchive, name)
+}
+
+func (m *mattermostImport) importLine(ctx context.Context, line *mattermostLine) error {
+	switch {
+	case line.Type == "channel" && line.Channel != nil:
+		_, err := m.channel(ctx, line.Channel)
+		return err
+	case line.Type == "user" && line.User != nil:
+		return m.importUser(ctx, line.User)
+	case line.Type == "direct_channel" && line.DirectChannel != nil:
+		_, err := m.directChannel(ctx, line.DirectChannel.Members, line.DirectChannel.Header)
+		return err
+	case line.Type == "post" && line.Post != nil:
+		conversationID, err := m.channel(ctx, &mattermostChannel{Team: line.Post.Team, Name: line.Post.Channel})
+		if err != nil {
+			return err
+		}
+		return m.importPost(ctx, conversationID, line.Post)
+	case line.Type == "direct_post" && line.DirectPost != nil:
+		conversationID, err := m.directChannel(ctx, line.DirectPost.ChannelMembers, "")
+		if err != nil {
+			return err
+		}
+		return m.importPost(ctx, conversationID, line.DirectPost)
+	}
+	// version, team, scheme, emoji... không có dữ liệu tương ứng
+	return nil
+}
+
+// channel conversation ID của channel trong team, tạo conversation nếu chưa có.
+// Channel chưa khai báo (post hoặc membership đứng trước dòng channel) chỉ có tên.
+func (m *mattermostImport) channel(ctx context.Context, channel *mattermostChannel) (string, error) {
+	conversationID := m.id(channel.Team, channel.Name)
+	if m.channels[conversationID] {
+		return conversationID, nil
+	}
+
+	createdBy, err := m.systemUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	conversation := &models.Conversation{
+		ID:          conversationID,
+		Type:        models.ConversationTypeGroup,
+		Name:        firstNonEmpty(channel.DisplayName, channel.Name),
+		Description: firstNonEmpty(channel.Purpose, channel.Header),
+		CreatedBy:   createdBy,
+	}
+	if err := m.ensureConversation(ctx, conversation, nil); err != nil {
+		return "", err
+	}
+	m.channels[conversationID] = true
+	return conversationID, nil
+}
+
+// directChannel conversation ID của kênh nhắn riêng giữa các username, tạo conversation và thêm
+// thành viên nếu chưa có. ID được băm từ danh sách thành viên đã sắp xếp nên không phụ thuộc thứ tự.
+func (m *mattermostImport) directChannel(ctx context.Context, usernames []string, header string) (string, error) {
+	if len(usernames) == 0 {
+		return "", fmt.Errorf("%w: kênh nhắn riêng không có thành viên", ErrInvalidArchive)
+	}
+	sorted := append([]string(nil), usernames...)
+	sort.Strings(sorted)
+	conversationID := m.id("dm", hash(strings.Join(sorted, ","))[:16])
+	if m.channels[conversationID] {
+		return conversationID, nil
+	}
+
+	members := make([]string, len(sorted))
+	for i, username := range sorted {
+		userID, err := m.userID(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		members[i] = userID
+	}
+
+	conversation := &models.Conversation{
+		ID:          conversationID,
+		Type:        models.ConversationTypeDirect,
+		Description: header,
+		CreatedBy:   members[0],
+	}
+	if len(members) > 2 {
+		conversation.Type = models.ConversationTypeGroup
+		conversation.Name = strings.Join(sorted, ", ")
+	}
+	if err := m.ensureConversation(ctx, conversation, members); err != nil {
+		return "", err
+	}
+	m.channels[conversationID] = true
+	return conversationID, nil
+}
+
+func (m *mattermostImport) importUser(ctx context.Context, user *mattermostUser) error {
+	fullName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	userID, err := m.ensureUser(ctx, user.Username, models.User{
+		Username: user.Username,
+		Email:    user.Email,
+		FullName: firstNonEmpty(fullName, user.Nickname, user.Username),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, team := range user.Teams {
+		for _, channel := range team.Channels {
+			conversationID, err := m.channel(ctx, &mattermostChannel{Team: team.Name, Name: channel.Name})
+			if err != nil {
+				return err
+			}
+			if err := m.store.Participants.Add(ctx, conversationID, userID, models.RoleMember); err != nil {
+				return fmt.Errorf("lỗi thêm thành viên %s vào %s: %w", userID, conversationID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// importPost lưu post và các reply của nó. ID tin nhắn được băm từ conversation, người gửi,
+// thời gian và nội dung vì bản bulk import không chứa ID gốc của post.
+func (m *mattermostImport) importPost(ctx context.Context, conversationID string, post *mattermostPost) error {
+	messageID, err := m.savePost(ctx, conversationID, "", post)
+	if err != nil || messageID == "" {
+		return err
+	}
+	for i := range post.Replies {
+		if _, err := m.savePost(ctx, conversationID, messageID, &post.Replies[i]); err != nil {
+			return err
+		}
+	}
+	m.counts[conversationID] += 1 + len(post.Replies)
+	return nil
+}
+
+// savePost lưu một post, trả về ID rỗng nếu post bị bỏ qua
+func (m *mattermostImport) savePost(ctx context.Context, conversationID, replyToID string, post *mattermostPost) (string, error) {
+	if post.User == "" || post.CreateAt <= 0 {
+		m.skip(ctx, "thiếu user hoặc create_at", logging.KeyConversationID, conversationID)
+		return "", nil
+	}
+	senderID, err := m.userID(ctx, post.User)
+	if err != nil {
+		return "", err
+	}
+
+	var attachments []models.Attachment
+	for _, attachment := range post.Attachments {
+		attachments = append(attachments, models.Attachment{
+			ID:       m.id(hash(attachment.Path)[:16]),
+			FileName: path.Base(attachment.Path),
+			FileType: mime.TypeByExtension(path.Ext(attachment.Path)),
+			URL:      attachment.Path,
+		})
+	}
+
+	message := &models.Message{
+		ID:             m.id(hash(conversationID, replyToID, post.User, strconv.FormatInt(post.CreateAt, 10), post.Message)[:24]),
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Content:        post.Message,
+		Type:           messageType(attachments),
+		ReplyToID:      replyToID,
+		Attachments:    attachmentsJSON(attachments),
+		CreatedAt:      time.UnixMilli(post.CreateAt).UTC(),
+	}
+	if post.EditAt > 0 {
+		editedAt := time.UnixMilli(post.EditAt).UTC()
+		message.EditedAt = &editedAt
+	}
+
+	var reactions []models.MessageReaction
+	for _, reaction := range post.Reactions {
+		userID, err := m.userID(ctx, reaction.User)
+		if err != nil {
+			return "", err
+		}
+		created := models.MessageReaction{UserID: userID, Emoji: shortcode(reaction.EmojiName)}
+		if reaction.CreateAt > 0 {
+			created.CreatedAt = time.UnixMilli(reaction.CreateAt).UTC()
+		}
+		reactions = append(reactions, created)
+	}
+
+	if err := m.saveMessage(ctx, message, reactions); err != nil {
+		return "", err
+	}
+	return message.ID, nil
+}
+
+// userID user ID của username, tạo placeholder nếu username chưa có dòng user
+func (m *mattermostImport) userID(ctx context.Context, username string) (string, error) {
+	return m.placeholderUser(ctx, username, username)
+}
+
+// hash SHA-256 dạng hex của các phần, phân tách bằng byte 0
+func hash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,392 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// slackMention mention trong nội dung tin nhắn Slack: <@U123> hoặc <@U123|name>
+var slackMention = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|[^>]*)?>`)
+
+// Các subtype là tin nhắn người dùng gửi, subtype khác (channel_join, channel_topic...) thành tin nhắn hệ thống
+var slackUserSubtypes = map[string]bool{
+	"":                 true,
+	"thread_broadcast": true,
+	"bot_message":      true,
+	"file_share":       true,
+	"me_message":       true,
+}
+
+type slackUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+	IsBot    bool   `json:"is_bot"`
+	Profile  struct {
+		Email       string `json:"email"`
+		RealName    string `json:"real_name"`
+		DisplayName string `json:"display_name"`
+		Image72     string `json:"image_72"`
+	} `json:"profile"`
+}
+
+type slackChannel struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Created int64    `json:"created"`
+	Creator string   `json:"creator"`
+	Members []string `json:"members"`
+	Topic   struct {
+		Value string `json:"value"`
+	} `json:"topic"`
+	Purpose struct {
+		Value string `json:"value"`
+	} `json:"purpose"`
+}
+
+type slackMessage struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Username string `json:"username"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+	Edited   *struct {
+		TS string `json:"ts"`
+	} `json:"edited"`
+	Reactions []struct {
+		Name  string   `json:"name"`
+		Users []string `json:"users"`
+	} `json:"reactions"`
+	Files []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Mimetype   string `json:"mimetype"`
+		Size       int64  `json:"size"`
+		URLPrivate string `json:"url_private"`
+	} `json:"files"`
+}
+
+// slackChannelList một file danh sách channel trong bản export
+type slackChannelList struct {
+	file string
+	kind models.ConversationType
+	// byID thư mục tin nhắn đặt theo ID channel (DM) thay vì tên
+	byID bool
+}
+
+var slackChannelLists = []slackChannelList{
+	{file: "channels.json", kind: models.ConversationTypeGroup},
+	{file: "groups.json", kind: models.ConversationTypeGroup},
+	{file: "mpims.json", kind: models.ConversationTypeGroup},
+	{file: "dms.json", kind: models.ConversationTypeDirect, byID: true},
+}
+
+// slackArchive file zip export của Slack. Bản export có thể nằm trong một thư mục gốc
+// nếu được nén lại, root là thư mục chứa users.json.
+type slackArchive struct {
+	reader *zip.ReadCloser
+	root   string
+	files  map[string]*zip.File
+}
+
+func openSlackArchive(name string) (*slackArchive, error) {
+	reader, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &slackArchive{reader: reader, files: make(map[string]*zip.File)}
+	for _, file := range reader.File {
+		archive.files[file.Name] = file
+		if path.Base(file.Name) == "users.json" && (archive.root == "" || len(file.Name) < len(archive.root)) {
+			archive.root = path.Dir(file.Name)
+		}
+	}
+	if archive.root == "" {
+		reader.Close()
+		return nil, fmt.Errorf("%w: không có users.json", ErrInvalidArchive)
+	}
+	return archive, nil
+}
+
+// readJSON đọc file name (tương đối với root) vào v, trả về fs.ErrNotExist nếu không có file
+func (a *slackArchive) readJSON(name string, v any) error {
+	file, ok := a.files[path.Join(a.root, name)]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
+
+// dayFiles các file tin nhắn theo ngày (YYYY-MM-DD.json) của thư mục dir, cũ nhất trước
+func (a *slackArchive) dayFiles(dir string) []string {
+	prefix := path.Join(a.root, dir) + "/"
+	var names []string
+	for name := range a.files {
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".json") && !strings.Contains(name[len(prefix):], "/") {
+			names = append(names, path.Join(dir, name[len(prefix):]))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// slackImport trạng thái import một bản export Slack
+type slackImport struct {
+	*importer
+	archive *slackArchive
+	names   map[string]string // user ID Slack -> username, dùng để đổi mention
+}
+
+func (im *importer) importSlack(ctx context.Context, name string) error {
+	archive, err := openSlackArchive(name)
+	if err != nil {
+		return err
+	}
+	defer archive.reader.Close()
+
+	s := &slackImport{importer: im, archive: archive, names: make(map[string]string)}
+	if err := s.importUsers(ctx); err != nil {
+		return err
+	}
+
+	for _, list := range slackChannelLists {
+		var channels []slackChannel
+		if err := archive.readJSON(list.file, &channels); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		for _, channel := range channels {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.importChannel(ctx, channel, list); err != nil {
+				return fmt.Errorf("channel %s: %w", channel.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *slackImport) importUsers(ctx context.Context) error {
+	var users []slackUser
+	if err := s.archive.readJSON("users.json", &users); err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		fullName := firstNonEmpty(user.Profile.RealName, user.RealName, user.Profile.DisplayName, user.Name)
+		if _, err := s.ensureUser(ctx, user.ID, models.User{
+			Username: user.Name,
+			Email:    user.Profile.Email,
+			FullName: fullName,
+			Avatar:   user.Profile.Image72,
+		}); err != nil {
+			return err
+		}
+		s.names[user.ID] = user.Name
+	}
+	return nil
+}
+
+func (s *slackImport) importChannel(ctx context.Context, channel slackChannel, list slackChannelList) error {
+	members := make([]string, 0, len(channel.Members))
+	for _, member := range channel.Members {
+		userID, err := s.senderID(ctx, member)
+		if err != nil {
+			return err
+		}
+		members = append(members, userID)
+	}
+
+	// DM không có creator, lấy thành viên đầu tiên
+	var createdBy string
+	var err error
+	switch {
+	case channel.Creator != "":
+		createdBy, err = s.senderID(ctx, channel.Creator)
+	case len(members) > 0:
+		createdBy = members[0]
+	default:
+		createdBy, err = s.systemUser(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	conversation := &models.Conversation{
+		ID:          s.id(channel.ID),
+		Type:        list.kind,
+		Name:        channel.Name,
+		Description: firstNonEmpty(channel.Purpose.Value, channel.Topic.Value),
+		CreatedBy:   createdBy,
+	}
+	if channel.Created > 0 {
+		conversation.CreatedAt = time.Unix(channel.Created, 0).UTC()
+	}
+	if err := s.ensureConversation(ctx, conversation, members); err != nil {
+		return err
+	}
+
+	dir := channel.Name
+	if list.byID || dir == "" {
+		dir = channel.ID
+	}
+	count := 0
+	for _, day := range s.archive.dayFiles(dir) {
+		var messages []slackMessage
+		if err := s.archive.readJSON(day, &messages); err != nil {
+			return err
+		}
+		for i := range messages {
+			if err := s.importMessage(ctx, channel.ID, &messages[i]); err != nil {
+				return err
+			}
+		}
+		count += len(messages)
+	}
+
+	slog.InfoContext(ctx, "Đã import channel Slack", logging.KeyConversationID, conversation.ID,
+		"name", channel.Name, "messages", count)
+	return nil
+}
+
+func (s *slackImport) importMessage(ctx context.Context, channelID string, msg *slackMessage) error {
+	if msg.Type != "" && msg.Type != "message" {
+		return nil
+	}
+	createdAt, err := parseSlackTS(msg.TS)
+	if err != nil {
+		s.skip(ctx, "ts không hợp lệ", "channel", channelID, "ts", msg.TS)
+		return nil
+	}
+
+	var senderID string
+	switch {
+	case msg.User != "":
+		senderID, err = s.senderID(ctx, msg.User)
+	case msg.BotID != "":
+		senderID, err = s.placeholderUser(ctx, msg.BotID, firstNonEmpty(msg.Username, msg.BotID))
+	default:
+		s.skip(ctx, "không có người gửi", "channel", channelID, "ts", msg.TS)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var attachments []models.Attachment
+	for _, file := range msg.Files {
+		attachments = append(attachments, models.Attachment{
+			ID:       s.id(file.ID),
+			FileName: file.Name,
+			FileSize: file.Size,
+			FileType: file.Mimetype,
+			URL:      file.URLPrivate,
+		})
+	}
+
+	message := &models.Message{
+		ID:             s.id(channelID, msg.TS),
+		ConversationID: s.id(channelID),
+		SenderID:       senderID,
+		Content:        s.replaceMentions(msg.Text),
+		Type:           messageType(attachments),
+		Attachments:    attachmentsJSON(attachments),
+		CreatedAt:      createdAt,
+	}
+	if !slackUserSubtypes[msg.Subtype] {
+		message.Type = models.MessageTypeSystem
+	}
+	if msg.ThreadTS != "" && msg.ThreadTS != msg.TS {
+		message.ReplyToID = s.id(channelID, msg.ThreadTS)
+	}
+	if msg.Edited != nil {
+		if editedAt, err := parseSlackTS(msg.Edited.TS); err == nil {
+			message.EditedAt = &editedAt
+		}
+	}
+
+	var reactions []models.MessageReaction
+	for _, reaction := range msg.Reactions {
+		for _, user := range reaction.Users {
+			userID, err := s.senderID(ctx, user)
+			if err != nil {
+				return err
+			}
+			reactions = append(reactions, models.MessageReaction{UserID: userID, Emoji: shortcode(reaction.Name)})
+		}
+	}
+	return s.saveMessage(ctx, message, reactions)
+}
+
+// senderID user ID của user Slack, tạo placeholder nếu user không có trong users.json
+// (user bên ngoài workspace qua Slack Connect, user đã bị xóa khỏi bản export)
+func (s *slackImport) senderID(ctx context.Context, slackUserID string) (string, error) {
+	return s.placeholderUser(ctx, slackUserID, slackUserID)
+}
+
+// replaceMentions đổi <@U123> thành @username
+func (s *slackImport) replaceMentions(text string) string {
+	return slackMention.ReplaceAllStringFunc(text, func(mention string) string {
+		if name, ok := s.names[slackMention.FindStringSubmatch(mention)[1]]; ok {
+			return "@" + name
+		}
+		return mention
+	})
+}
+
+// parseSlackTS đọc timestamp Slack dạng "1700000000.123456" (giây.micro giây)
+func parseSlackTS(ts string) (time.Time, error) {
+	seconds, fraction, _ := strings.Cut(ts, ".")
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var micros int64
+	if fraction != "" {
+		if len(fraction) > 6 {
+			fraction = fraction[:6]
+		}
+		if micros, err = strconv.ParseInt(fraction+strings.Repeat("0", 6-len(fraction)), 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, micros*int64(time.Microsecond)).UTC(), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Các loại message bus được hỗ trợ
+const (
+	BusKafka  = "kafka"
+	BusMemory = "memory"
+)
+
+// Offset đặc biệt dùng khi consumer group chưa commit offset nào
+const (
+	OffsetNewest int64 = -1
+	OffsetOldest int64 = -2
+)
+
+// RecordHeader là một cặp key/value header của record
+type RecordHeader struct {
+	Key   string
+	Value string
+}
+
+// Record là một message trên bus, độc lập với implementation (Kafka hay in-memory)
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []RecordHeader
+	Timestamp time.Time
+}
+
+// HeaderMap chuyển headers thành map để tra cứu
+func (r *Record) HeaderMap() map[string]string {
+	result := make(map[string]string, len(r.Headers))
+	for _, header := range r.Headers {
+		result[header.Key] = header.Value
+	}
+	return result
+}
+
+// RecordHandler xử lý một record nhận được từ subscription.
+// Record được commit sau khi handler trả về, kể cả khi có lỗi (lỗi chỉ được log);
+// handler tự chịu trách nhiệm retry. Nếu ctx đã bị hủy, record không được commit.
+type RecordHandler func(ctx context.Context, record *Record) error
+
+// Subscription mô tả một consumer group subscribe vào các topic
+type Subscription struct {
+	Group   string
+	Topics  []string
+	Handler RecordHandler
+
+	// OnAssign/OnRevoke được gọi khi consumer nhận hoặc mất partition (có thể nil)
+	OnAssign func()
+	OnRevoke func()
+}
+
+// PublishCallback nhận kết quả publish bất đồng bộ.
+// record.Partition và record.Offset được điền khi publish thành công.
+type PublishCallback func(record *Record, err error)
+
+// MessageBus trừu tượng hóa publish/subscribe với consumer group và offset.
+// Có hai implementation: SaramaBus (Kafka) và MemoryBus (in-process).
+type MessageBus interface {
+	// Publish gửi record và đợi xác nhận, trả về partition và offset
+	Publish(ctx context.Context, record *Record) (int32, int64, error)
+
+	// PublishAsync gửi record mà không đợi, kết quả trả về qua callback
+	PublishAsync(record *Record, callback PublishCallback) error
+
+	// Subscribe consume các topic trong consumer group, block cho đến khi ctx bị hủy.
+	// Mỗi record chỉ được giao cho một thành viên trong group, theo thứ tự trong partition.
+	Subscribe(ctx context.Context, sub Subscription) error
+
+	Close() error
+}
+
+// NewMessageBus tạo message bus theo cấu hình (MESSAGE_BUS). Với MESSAGE_BUS=memory đây là
+// SharedMemoryBus dùng chung trong process, người gọi không được đóng.
+func NewMessageBus(config *ServiceConfig) (MessageBus, error) {
+	switch config.Bus {
+	case "", BusKafka:
+		return NewSaramaBus(&SaramaBusConfig{
+			Brokers:     config.KafkaBrokers,
+			Async:       config.ProducerMode == ProducerModeAsync,
+			MaxInFlight: config.MaxInFlight,
+			Security:    config.Security,
+		})
+	case BusMemory:
+		return SharedMemoryBus(), nil
+	default:
+		return nil, fmt.Errorf("message bus không hỗ trợ: %q (hỗ trợ %q, %q)", config.Bus, BusKafka, BusMemory)
+	}
+}
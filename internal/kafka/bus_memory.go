@@ -0,0 +1,369 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrBusClosed được trả về khi publish vào bus đã đóng
+var ErrBusClosed = errors.New("message bus đã đóng")
+
+// defaultMemoryBusRetention số record giữ tối đa trên mỗi partition khi không cấu hình
+const defaultMemoryBusRetention = 100_000
+
+// MemoryBusConfig cấu hình cho MemoryBus
+type MemoryBusConfig struct {
+	Partitions    int   // Số partition của mỗi topic (mặc định 3)
+	InitialOffset int64 // OffsetOldest (mặc định) hoặc OffsetNewest cho group mới
+	// MaxRecords số record giữ tối đa trên mỗi partition kể cả khi chưa group nào consume
+	// (mặc định 100000). Group chậm hơn bị bỏ qua các record đã bị xóa, như retention của Kafka.
+	MaxRecords int
+}
+
+// MemoryBus là MessageBus in-process, dùng cho test và chế độ all-in-one.
+// Record được giữ trong bộ nhớ theo topic/partition; mỗi consumer group có
+// offset riêng và mỗi record chỉ được giao cho một thành viên của group.
+// Record mà mọi group đã commit qua được xóa khỏi bộ nhớ, offset không đổi.
+type MemoryBus struct {
+	config MemoryBusConfig
+
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	groups map[string]*memoryGroup
+	closed chan struct{}
+	once   sync.Once
+}
+
+type memoryTopic struct {
+	partitions []*memoryPartition
+	// notify được đóng và thay mới mỗi khi có record mới
+	notify chan struct{}
+}
+
+// memoryPartition giữ các record từ offset base tới high water mark
+type memoryPartition struct {
+	base    int64
+	records []*Record
+}
+
+// highWaterMark offset của record kế tiếp sẽ được publish
+func (p *memoryPartition) highWaterMark() int64 {
+	return p.base + int64(len(p.records))
+}
+
+// truncate xóa các record có offset nhỏ hơn offset
+func (p *memoryPartition) truncate(offset int64) {
+	n := int(min(offset, p.highWaterMark()) - p.base)
+	if n <= 0 {
+		return
+	}
+	// Bỏ tham chiếu để record được giải phóng dù mảng bên dưới chưa được cấp phát lại
+	clear(p.records[:n])
+	p.records = p.records[n:]
+	p.base += int64(n)
+}
+
+type memoryGroup struct {
+	// offsets lưu offset kế tiếp cần consume theo topic/partition
+	offsets map[string][]int64
+	// locks đảm bảo một partition chỉ được xử lý bởi một thành viên tại một thời điểm
+	locks map[string][]*sync.Mutex
+}
+
+var (
+	sharedMemoryBus     *MemoryBus
+	sharedMemoryBusOnce sync.Once
+)
+
+// SharedMemoryBus trả về MemoryBus dùng chung trong process,
+// để WebSocket server, outbox relay và worker chạy chung một binary
+func SharedMemoryBus() *MemoryBus {
+	sharedMemoryBusOnce.Do(func() {
+		sharedMemoryBus = NewMemoryBus(MemoryBusConfig{})
+	})
+	return sharedMemoryBus
+}
+
+// NewMemoryBus tạo MemoryBus mới
+func NewMemoryBus(config MemoryBusConfig) *MemoryBus {
+	if config.Partitions <= 0 {
+		config.Partitions = 3
+	}
+	if config.InitialOffset == 0 {
+		config.InitialOffset = OffsetOldest
+	}
+	if config.MaxRecords <= 0 {
+		config.MaxRecords = defaultMemoryBusRetention
+	}
+
+	return &MemoryBus{
+		config: config,
+		topics: make(map[string]*memoryTopic),
+		groups: make(map[string]*memoryGroup),
+		closed: make(chan struct{}),
+	}
+}
+
+// topic trả về topic, tạo mới nếu chưa có. Phải giữ b.mu khi gọi.
+func (b *MemoryBus) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{
+			partitions: make([]*memoryPartition, b.config.Partitions),
+			notify:     make(chan struct{}),
+		}
+		for i := range t.partitions {
+			t.partitions[i] = &memoryPartition{}
+		}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// group trả về trạng thái của group trên topic, khởi tạo offset nếu chưa có.
+// Phải giữ b.mu khi gọi.
+func (b *MemoryBus) group(name, topicName string) *memoryGroup {
+	g, ok := b.groups[name]
+	if !ok {
+		g = &memoryGroup{
+			offsets: make(map[string][]int64),
+			locks:   make(map[string][]*sync.Mutex),
+		}
+		b.groups[name] = g
+	}
+
+	if _, ok := g.offsets[topicName]; !ok {
+		t := b.topic(topicName)
+		offsets := make([]int64, len(t.partitions))
+		locks := make([]*sync.Mutex, len(t.partitions))
+		for i, p := range t.partitions {
+			offsets[i] = p.base
+			if b.config.InitialOffset == OffsetNewest {
+				offsets[i] = p.highWaterMark()
+			}
+			locks[i] = &sync.Mutex{}
+		}
+		g.offsets[topicName] = offsets
+		g.locks[topicName] = locks
+	}
+	return g
+}
+
+// Publish implements MessageBus
+func (b *MemoryBus) Publish(ctx context.Context, record *Record) (int32, int64, error) {
+	select {
+	case <-b.closed:
+		return 0, 0, ErrBusClosed
+	default:
+	}
+
+	b.mu.Lock()
+	t := b.topic(record.Topic)
+	partition := b.partitionFor(record.Key)
+	p := t.partitions[partition]
+
+	stored := *record
+	stored.Partition = partition
+	stored.Offset = p.highWaterMark()
+	if stored.Timestamp.IsZero() {
+		stored.Timestamp = time.Now()
+	}
+	p.records = append(p.records, &stored)
+	if len(p.records) > b.config.MaxRecords {
+		p.truncate(p.highWaterMark() - int64(b.config.MaxRecords))
+	}
+
+	close(t.notify)
+	t.notify = make(chan struct{})
+	b.mu.Unlock()
+
+	record.Partition, record.Offset = stored.Partition, stored.Offset
+	return stored.Partition, stored.Offset, nil
+}
+
+// PublishAsync implements MessageBus. Record được ghi ngay và callback được gọi đồng bộ.
+func (b *MemoryBus) PublishAsync(record *Record, callback PublishCallback) error {
+	_, _, err := b.Publish(context.Background(), record)
+	if callback != nil {
+		callback(record, err)
+	}
+	return nil
+}
+
+// partitionFor chọn partition theo hash của key giống default partitioner của Kafka
+func (b *MemoryBus) partitionFor(key []byte) int32 {
+	if len(key) == 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int32(h.Sum32() % uint32(b.config.Partitions))
+}
+
+// Subscribe implements MessageBus
+func (b *MemoryBus) Subscribe(ctx context.Context, sub Subscription) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-b.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if sub.OnAssign != nil {
+		sub.OnAssign()
+	}
+
+	var wg sync.WaitGroup
+	for _, topicName := range sub.Topics {
+		b.mu.Lock()
+		g := b.group(sub.Group, topicName)
+		locks := g.locks[topicName]
+		b.mu.Unlock()
+
+		for partition, lock := range locks {
+			wg.Add(1)
+			go func(topicName string, partition int, lock *sync.Mutex) {
+				defer wg.Done()
+				b.consumePartition(ctx, sub, g, topicName, partition, lock)
+			}(topicName, partition, lock)
+		}
+	}
+	wg.Wait()
+
+	if sub.OnRevoke != nil {
+		sub.OnRevoke()
+	}
+	return nil
+}
+
+// consumePartition giao lần lượt các record của một partition cho handler
+func (b *MemoryBus) consumePartition(ctx context.Context, sub Subscription, g *memoryGroup, topicName string, partition int, lock *sync.Mutex) {
+	for {
+		record, notify := b.next(g, topicName, partition)
+		if record == nil {
+			select {
+			case <-notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		lock.Lock()
+
+		// Thành viên khác trong group có thể đã xử lý record này
+		b.mu.Lock()
+		current := g.offsets[topicName][partition]
+		b.mu.Unlock()
+		if current != record.Offset {
+			lock.Unlock()
+			continue
+		}
+
+		err := sub.Handler(ctx, record)
+		if err != nil && ctx.Err() != nil {
+			lock.Unlock()
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Lỗi xử lý record", recordAttrs(record, err)...)
+		}
+
+		b.mu.Lock()
+		g.offsets[topicName][partition] = record.Offset + 1
+		b.compact(topicName, partition)
+		b.mu.Unlock()
+		lock.Unlock()
+	}
+}
+
+// next trả về record kế tiếp của group trên partition,
+// hoặc channel để đợi nếu chưa có record mới
+func (b *MemoryBus) next(g *memoryGroup, topicName string, partition int) (*Record, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topicName)
+	p := t.partitions[partition]
+	offset := g.offsets[topicName][partition]
+	if offset < p.base {
+		// Record chưa consume đã bị xóa do vượt MaxRecords
+		slog.Warn("Bỏ qua record đã bị xóa khỏi memory bus", "topic", topicName, "partition", partition,
+			"from_offset", offset, "to_offset", p.base)
+		offset = p.base
+		g.offsets[topicName][partition] = offset
+	}
+	if offset < p.highWaterMark() {
+		return p.records[offset-p.base], nil
+	}
+	return nil, t.notify
+}
+
+// compact xóa các record mà mọi group của topic đã commit qua. Phải giữ b.mu khi gọi.
+func (b *MemoryBus) compact(topicName string, partition int) {
+	p := b.topic(topicName).partitions[partition]
+	committed := p.highWaterMark()
+	for _, g := range b.groups {
+		if offsets, ok := g.offsets[topicName]; ok {
+			committed = min(committed, offsets[partition])
+		}
+	}
+	p.truncate(committed)
+}
+
+// PartitionCount trả về số partition của mỗi topic
+func (b *MemoryBus) PartitionCount() int {
+	return b.config.Partitions
+}
+
+// Lag trả về lag của group trên từng partition của topic
+func (b *MemoryBus) Lag(groupName, topicName string) []PartitionLag {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topicName)
+	g := b.group(groupName, topicName)
+
+	lags := make([]PartitionLag, len(t.partitions))
+	for i, p := range t.partitions {
+		highWaterMark := p.highWaterMark()
+		committed := max(g.offsets[topicName][i], p.base)
+		lags[i] = PartitionLag{
+			Topic:         topicName,
+			Partition:     int32(i),
+			Committed:     committed,
+			HighWaterMark: highWaterMark,
+			Lag:           highWaterMark - committed,
+		}
+	}
+	return lags
+}
+
+// Records trả về bản sao các record còn giữ của một partition (dùng để kiểm tra nội dung bus)
+func (b *MemoryBus) Records(topicName string, partition int32) []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topicName)
+	if int(partition) >= len(t.partitions) {
+		return nil
+	}
+	records := make([]Record, len(t.partitions[partition].records))
+	for i, record := range t.partitions[partition].records {
+		records[i] = *record
+	}
+	return records
+}
+
+// Close implements MessageBus, dừng tất cả subscription
+func (b *MemoryBus) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
@@ -0,0 +1,193 @@
+package kafka
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+// totalLag cộng committed, high water mark và lag trên mọi partition
+func totalLag(lags []PartitionLag) (committed, highWaterMark, lag int64) {
+	for _, l := range lags {
+		committed += l.Committed
+		highWaterMark += l.HighWaterMark
+		lag += l.Lag
+	}
+	return committed, highWaterMark, lag
+}
+
+func publishN(t *testing.T, bus *MemoryBus, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		record := &Record{Topic: testTopic, Key: []byte{byte(i)}, Value: []byte("v")}
+		if _, _, err := bus.Publish(context.Background(), record); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+}
+
+// consume subscribe group và đợi group xử lý hết want record
+func consume(t *testing.T, bus *MemoryBus, group string, want int64) {
+	t.Helper()
+	var handled atomic.Int64
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bus.Subscribe(ctx, Subscription{
+			Group:   group,
+			Topics:  []string{testTopic},
+			Handler: func(ctx context.Context, record *Record) error { handled.Add(1); return nil },
+		})
+	}()
+
+	waitFor(t, func() bool {
+		_, _, lag := totalLag(bus.Lag(group, testTopic))
+		return handled.Load() == want && lag == 0
+	})
+	cancel()
+	<-done
+}
+
+func TestMemoryBusGroupOffsets(t *testing.T) {
+	tests := []struct {
+		name          string
+		initialOffset int64
+		before        int // record có trước khi group subscribe lần đầu
+		after         int // record publish sau lần consume đầu
+		wantFirst     int64
+	}{
+		{name: "oldest", initialOffset: OffsetOldest, before: 5, after: 3, wantFirst: 5},
+		{name: "newest", initialOffset: OffsetNewest, before: 5, after: 3, wantFirst: 0},
+		{name: "topic rỗng", initialOffset: OffsetOldest, before: 0, after: 4, wantFirst: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewMemoryBus(MemoryBusConfig{Partitions: 3, InitialOffset: tt.initialOffset})
+			defer bus.Close()
+
+			publishN(t, bus, tt.before)
+			consume(t, bus, testGroup, tt.wantFirst)
+
+			committed, highWaterMark, lag := totalLag(bus.Lag(testGroup, testTopic))
+			if committed != int64(tt.before) || highWaterMark != int64(tt.before) || lag != 0 {
+				t.Fatalf("sau lần đầu: committed=%d hwm=%d lag=%d, muốn %d/%d/0", committed, highWaterMark, lag, tt.before, tt.before)
+			}
+
+			// Offset đã commit được giữ khi group subscribe lại
+			publishN(t, bus, tt.after)
+			if _, _, lag := totalLag(bus.Lag(testGroup, testTopic)); lag != int64(tt.after) {
+				t.Fatalf("lag trước khi subscribe lại = %d, muốn %d", lag, tt.after)
+			}
+			consume(t, bus, testGroup, int64(tt.after))
+
+			total := int64(tt.before + tt.after)
+			if committed, _, lag := totalLag(bus.Lag(testGroup, testTopic)); committed != total || lag != 0 {
+				t.Fatalf("sau lần hai: committed=%d lag=%d, muốn %d/0", committed, lag, total)
+			}
+		})
+	}
+}
+
+func TestMemoryBusGroupsAreIndependent(t *testing.T) {
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 2})
+	defer bus.Close()
+
+	publishN(t, bus, 6)
+	// Lag đăng ký group-b trên topic nên record được giữ tới khi group-b commit
+	if _, _, lag := totalLag(bus.Lag("group-b", testTopic)); lag != 6 {
+		t.Fatalf("group-b lag ban đầu = %d, muốn 6", lag)
+	}
+	consume(t, bus, "group-a", 6)
+
+	if _, _, lag := totalLag(bus.Lag("group-a", testTopic)); lag != 0 {
+		t.Errorf("group-a lag = %d, muốn 0", lag)
+	}
+	committed, highWaterMark, lag := totalLag(bus.Lag("group-b", testTopic))
+	if committed != 0 || highWaterMark != 6 || lag != 6 {
+		t.Errorf("group-b committed=%d hwm=%d lag=%d, muốn 0/6/6", committed, highWaterMark, lag)
+	}
+
+	consume(t, bus, "group-b", 6)
+}
+
+func TestMemoryBusCommitsFailedRecords(t *testing.T) {
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 1})
+	defer bus.Close()
+
+	publishN(t, bus, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bus.Subscribe(ctx, Subscription{
+		Group:   testGroup,
+		Topics:  []string{testTopic},
+		Handler: func(ctx context.Context, record *Record) error { return ErrUnknownEventType },
+	})
+
+	waitFor(t, func() bool {
+		_, _, lag := totalLag(bus.Lag(testGroup, testTopic))
+		return lag == 0
+	})
+}
+
+// retained đếm số record bus còn giữ trên mọi partition của topic
+func retained(bus *MemoryBus) int {
+	n := 0
+	for partition := 0; partition < bus.PartitionCount(); partition++ {
+		n += len(bus.Records(testTopic, int32(partition)))
+	}
+	return n
+}
+
+func TestMemoryBusCompactsCommittedRecords(t *testing.T) {
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 3})
+	defer bus.Close()
+
+	// Hai group đã đăng ký: record chỉ được xóa khi group chậm nhất commit
+	bus.Lag("group-a", testTopic)
+	bus.Lag("group-b", testTopic)
+
+	const rounds, perRound = 20, 50
+	for round := 1; round <= rounds; round++ {
+		publishN(t, bus, perRound)
+		consume(t, bus, "group-a", perRound)
+		if got := retained(bus); got != perRound {
+			t.Fatalf("vòng %d: group-a đã commit, bus giữ %d record, muốn %d cho group-b", round, got, perRound)
+		}
+		consume(t, bus, "group-b", perRound)
+		if got := retained(bus); got != 0 {
+			t.Fatalf("vòng %d: mọi group đã commit, bus vẫn giữ %d record", round, got)
+		}
+	}
+
+	// Offset vẫn tăng liên tục sau khi record bị xóa
+	committed, highWaterMark, lag := totalLag(bus.Lag("group-b", testTopic))
+	if total := int64(rounds * perRound); committed != total || highWaterMark != total || lag != 0 {
+		t.Errorf("committed=%d hwm=%d lag=%d, muốn %d/%d/0", committed, highWaterMark, lag, total, total)
+	}
+}
+
+func TestMemoryBusMaxRecords(t *testing.T) {
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 1, MaxRecords: 10})
+	defer bus.Close()
+
+	// Group đăng ký từ đầu nhưng chưa consume bị bỏ qua các record vượt MaxRecords
+	bus.Lag("slow", testTopic)
+	publishN(t, bus, 25)
+
+	records := bus.Records(testTopic, 0)
+	if len(records) != 10 || records[0].Offset != 15 {
+		t.Fatalf("bus giữ %d record, muốn 10 record từ offset 15", len(records))
+	}
+	committed, highWaterMark, lag := totalLag(bus.Lag("slow", testTopic))
+	if committed != 15 || highWaterMark != 25 || lag != 10 {
+		t.Errorf("committed=%d hwm=%d lag=%d, muốn 15/25/10", committed, highWaterMark, lag)
+	}
+
+	consume(t, bus, "slow", 10)
+	consume(t, bus, "new", 0)
+	if got := retained(bus); got != 0 {
+		t.Errorf("bus vẫn giữ %d record sau khi mọi group đã commit", got)
+	}
+}
@@ -0,0 +1,308 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vibeta/internal/logging"
+
+	"github.com/IBM/sarama"
+)
+
+// SaramaBusConfig cấu hình cho SaramaBus
+type SaramaBusConfig struct {
+	Brokers     []string
+	Async       bool // Dùng sarama.AsyncProducer thay vì SyncProducer
+	MaxInFlight int  // Kích thước buffer của async producer
+
+	// Security cấu hình TLS/SASL/client ID; nil sẽ kết nối plaintext
+	Security *SecurityConfig
+}
+
+// SaramaBus là MessageBus dùng Kafka thông qua sarama
+type SaramaBus struct {
+	client        sarama.Client
+	producer      sarama.SyncProducer
+	asyncProducer sarama.AsyncProducer
+	wg            sync.WaitGroup
+}
+
+// NewSaramaBus kết nối tới Kafka và tạo producer.
+// Trả về lỗi ngay nếu không kết nối được brokers.
+func NewSaramaBus(config *SaramaBusConfig) (*SaramaBus, error) {
+	saramaConfig, err := newSaramaConfig(config.Security)
+	if err != nil {
+		return nil, err
+	}
+	if config.Async {
+		if config.MaxInFlight <= 0 {
+			return nil, fmt.Errorf("MaxInFlight phải lớn hơn 0 ở chế độ async, nhận %d", config.MaxInFlight)
+		}
+		saramaConfig.ChannelBufferSize = config.MaxInFlight
+	}
+
+	client, err := sarama.NewClient(config.Brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	bus := &SaramaBus{client: client}
+
+	if config.Async {
+		producer, err := sarama.NewAsyncProducerFromClient(client)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		bus.asyncProducer = producer
+
+		bus.wg.Add(2)
+		go bus.handleSuccesses()
+		go bus.handleErrors()
+	} else {
+		producer, err := sarama.NewSyncProducerFromClient(client)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		bus.producer = producer
+	}
+
+	return bus, nil
+}
+
+// newSaramaConfig cấu hình chung cho producer và consumer group
+func newSaramaConfig(security *SecurityConfig) (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	if err := security.apply(saramaConfig); err != nil {
+		return nil, err
+	}
+
+	// Producer
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll // Đợi confirmation từ tất cả replicas
+	saramaConfig.Producer.Retry.Max = 3                    // Retry tối đa 3 lần
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+
+	// Cải thiện performance và reliability
+	saramaConfig.Producer.Flush.Frequency = 100 * time.Millisecond
+	saramaConfig.Producer.Flush.Messages = 100
+	saramaConfig.Producer.MaxMessageBytes = 1000000
+
+	// Compression để giảm network traffic
+	saramaConfig.Producer.Compression = sarama.CompressionSnappy
+
+	// Consumer group
+	saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
+	saramaConfig.Consumer.Group.Session.Timeout = 10 * time.Second
+	saramaConfig.Consumer.Group.Heartbeat.Interval = 3 * time.Second
+	saramaConfig.Consumer.MaxProcessingTime = 1 * time.Minute
+	saramaConfig.Consumer.Return.Errors = true
+
+	// Tối ưu performance
+	saramaConfig.Consumer.Fetch.Min = 1024 * 1024      // 1MB minimum fetch
+	saramaConfig.Consumer.Fetch.Default = 1024 * 1024  // 1MB default fetch
+	saramaConfig.Consumer.Fetch.Max = 10 * 1024 * 1024 // 10MB maximum fetch
+
+	return saramaConfig, nil
+}
+
+// Publish implements MessageBus
+func (b *SaramaBus) Publish(ctx context.Context, record *Record) (int32, int64, error) {
+	if b.asyncProducer == nil {
+		return b.producer.SendMessage(toSaramaMessage(record))
+	}
+
+	done := make(chan error, 1)
+	if err := b.PublishAsync(record, func(_ *Record, err error) { done <- err }); err != nil {
+		return 0, 0, err
+	}
+
+	select {
+	case err := <-done:
+		return record.Partition, record.Offset, err
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+}
+
+// PublishAsync implements MessageBus
+func (b *SaramaBus) PublishAsync(record *Record, callback PublishCallback) error {
+	if b.asyncProducer == nil {
+		partition, offset, err := b.producer.SendMessage(toSaramaMessage(record))
+		record.Partition, record.Offset = partition, offset
+		if callback != nil {
+			callback(record, err)
+		}
+		return nil
+	}
+
+	msg := toSaramaMessage(record)
+	msg.Metadata = &saramaPending{record: record, callback: callback}
+	b.asyncProducer.Input() <- msg
+	return nil
+}
+
+// saramaPending được gắn vào message async để route kết quả về callback
+type saramaPending struct {
+	record   *Record
+	callback PublishCallback
+}
+
+func (b *SaramaBus) handleSuccesses() {
+	defer b.wg.Done()
+	for msg := range b.asyncProducer.Successes() {
+		b.complete(msg, nil)
+	}
+}
+
+func (b *SaramaBus) handleErrors() {
+	defer b.wg.Done()
+	for producerErr := range b.asyncProducer.Errors() {
+		b.complete(producerErr.Msg, producerErr.Err)
+	}
+}
+
+func (b *SaramaBus) complete(msg *sarama.ProducerMessage, err error) {
+	pending, ok := msg.Metadata.(*saramaPending)
+	if !ok || pending.callback == nil {
+		return
+	}
+	pending.record.Partition, pending.record.Offset = msg.Partition, msg.Offset
+	pending.callback(pending.record, err)
+}
+
+// Subscribe implements MessageBus
+func (b *SaramaBus) Subscribe(ctx context.Context, sub Subscription) error {
+	group, err := sarama.NewConsumerGroupFromClient(sub.Group, b.client)
+	if err != nil {
+		return fmt.Errorf("lỗi tạo consumer group: %w", err)
+	}
+	defer group.Close()
+
+	// Xử lý các lỗi từ consumer group
+	go func() {
+		for err := range group.Errors() {
+			slog.Error("Lỗi consumer", logging.Err(err))
+		}
+	}()
+
+	handler := &saramaGroupHandler{sub: sub}
+	for ctx.Err() == nil {
+		if err := group.Consume(ctx, sub.Topics, handler); err != nil {
+			slog.Error("Lỗi consumer group", logging.Err(err))
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+// Client trả về sarama client bên dưới (dùng cho health check và admin)
+func (b *SaramaBus) Client() sarama.Client {
+	return b.client
+}
+
+// Close implements MessageBus.
+// Async producer được flush và đợi callback của các message còn lại.
+func (b *SaramaBus) Close() error {
+	var err error
+	if b.asyncProducer != nil {
+		b.asyncProducer.AsyncClose()
+		b.wg.Wait()
+	} else if b.producer != nil {
+		err = b.producer.Close()
+	}
+
+	if closeErr := b.client.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// saramaGroupHandler implements sarama.ConsumerGroupHandler cho một Subscription
+type saramaGroupHandler struct {
+	sub Subscription
+}
+
+// Setup implements sarama.ConsumerGroupHandler
+func (h *saramaGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	slog.Info("Consumer group setup", "group", h.sub.Group)
+	if h.sub.OnAssign != nil {
+		h.sub.OnAssign()
+	}
+	return nil
+}
+
+// Cleanup implements sarama.ConsumerGroupHandler
+func (h *saramaGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	slog.Info("Consumer group cleanup", "group", h.sub.Group)
+	if h.sub.OnRevoke != nil {
+		h.sub.OnRevoke()
+	}
+	return nil
+}
+
+// ConsumeClaim implements sarama.ConsumerGroupHandler
+func (h *saramaGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok || message == nil {
+				return nil
+			}
+
+			if err := h.sub.Handler(session.Context(), fromSaramaMessage(message)); err != nil {
+				if session.Context().Err() != nil {
+					return nil
+				}
+				slog.ErrorContext(session.Context(), "Lỗi xử lý record", recordAttrs(fromSaramaMessage(message), err)...)
+			}
+
+			// Mark message as processed
+			session.MarkMessage(message, "")
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// toSaramaMessage chuyển Record thành sarama.ProducerMessage
+func toSaramaMessage(record *Record) *sarama.ProducerMessage {
+	headers := make([]sarama.RecordHeader, 0, len(record.Headers))
+	for _, header := range record.Headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(header.Key), Value: []byte(header.Value)})
+	}
+
+	return &sarama.ProducerMessage{
+		Topic:     record.Topic,
+		Key:       sarama.ByteEncoder(record.Key),
+		Value:     sarama.ByteEncoder(record.Value),
+		Headers:   headers,
+		Timestamp: record.Timestamp,
+	}
+}
+
+// fromSaramaMessage chuyển sarama.ConsumerMessage thành Record
+func fromSaramaMessage(message *sarama.ConsumerMessage) *Record {
+	headers := make([]RecordHeader, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		if header != nil {
+			headers = append(headers, RecordHeader{Key: string(header.Key), Value: string(header.Value)})
+		}
+	}
+
+	return &Record{
+		Topic:     message.Topic,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Key:       message.Key,
+		Value:     message.Value,
+		Headers:   headers,
+		Timestamp: message.Timestamp,
+	}
+}
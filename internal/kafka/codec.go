@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Các header được gắn vào mỗi Kafka record
+const (
+	HeaderEventType      = "event_type"
+	HeaderConversationID = "conversation_id"
+	HeaderEventID        = "event_id"
+	HeaderSchemaVersion  = "schema_version"
+	HeaderContentType    = "content_type"
+)
+
+// Các encoding được hỗ trợ cho envelope
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// Codec encode/decode envelope thành bytes của Kafka record
+type Codec interface {
+	// ContentType giá trị của header content_type
+	ContentType() string
+	Encode(event *Event) ([]byte, error)
+	Decode(data []byte, registry *EventRegistry) (*Event, error)
+}
+
+// CodecFor trả về codec tương ứng với tên encoding trong cấu hình
+func CodecFor(encoding string) (Codec, error) {
+	switch encoding {
+	case "", EncodingJSON:
+		return JSONCodec{}, nil
+	case EncodingProtobuf:
+		return ProtobufCodec{}, nil
+	default:
+		return nil, fmt.Errorf("encoding không hỗ trợ: %q (hỗ trợ %q, %q)", encoding, EncodingJSON, EncodingProtobuf)
+	}
+}
+
+// codecForContentType chọn codec dựa trên header content_type của record
+func codecForContentType(contentType string) (Codec, error) {
+	switch contentType {
+	case "", JSONCodec{}.ContentType():
+		return JSONCodec{}, nil
+	case ProtobufCodec{}.ContentType():
+		return ProtobufCodec{}, nil
+	default:
+		return nil, fmt.Errorf("content type không hỗ trợ: %q", contentType)
+	}
+}
+
+// DecodeRecord decode value của một Kafka record thành envelope.
+// Record không có header schema_version được coi là định dạng JSON cũ (MessageEvent).
+func DecodeRecord(headers map[string]string, value []byte, registry *EventRegistry) (*Event, error) {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	versionHeader := headers[HeaderSchemaVersion]
+	if versionHeader == "" {
+		var legacy MessageEvent
+		if err := json.Unmarshal(value, &legacy); err != nil {
+			return nil, fmt.Errorf("lỗi parse message định dạng cũ: %w", err)
+		}
+		return legacy.toEvent()
+	}
+
+	version, err := strconv.Atoi(versionHeader)
+	if err != nil {
+		return nil, fmt.Errorf("header schema_version không hợp lệ: %q", versionHeader)
+	}
+	if version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("schema version %d mới hơn version hỗ trợ %d", version, CurrentSchemaVersion)
+	}
+
+	codec, err := codecForContentType(headers[HeaderContentType])
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(value, registry)
+}
+
+// recordHeaders trả về các header cần gắn cho event khi publish
+func recordHeaders(event *Event, codec Codec) []RecordHeader {
+	return []RecordHeader{
+		{HeaderEventType, string(event.Type)},
+		{HeaderConversationID, event.ConversationID},
+		{HeaderEventID, event.ID},
+		{HeaderSchemaVersion, strconv.Itoa(event.SchemaVersion)},
+		{HeaderContentType, codec.ContentType()},
+	}
+}
+
+// JSONCodec encode envelope dưới dạng JSON
+type JSONCodec struct{}
+
+// ContentType implements Codec
+func (JSONCodec) ContentType() string { return "application/json" }
+
+// Encode implements Codec
+func (JSONCodec) Encode(event *Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Decode implements Codec
+func (JSONCodec) Decode(data []byte, registry *EventRegistry) (*Event, error) {
+	var raw struct {
+		ID             string          `json:"id"`
+		Type           EventType       `json:"type"`
+		SchemaVersion  int             `json:"schema_version"`
+		ConversationID string          `json:"conversation_id"`
+		Timestamp      time.Time       `json:"timestamp"`
+		Payload        json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("lỗi parse envelope: %w", err)
+	}
+
+	payload, err := registry.NewPayload(raw.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw.Payload) > 0 {
+		if err := json.Unmarshal(raw.Payload, payload); err != nil {
+			return nil, fmt.Errorf("lỗi parse payload %s: %w", raw.Type, err)
+		}
+	}
+
+	event := &Event{
+		ID:             raw.ID,
+		Type:           raw.Type,
+		SchemaVersion:  raw.SchemaVersion,
+		ConversationID: raw.ConversationID,
+		Timestamp:      raw.Timestamp,
+		Payload:        payload,
+	}
+	return event, event.validate()
+}
@@ -0,0 +1,206 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoPayload được implement bởi các payload hỗ trợ encoding Protobuf.
+// Field numbers phải khớp với định nghĩa trong events.proto.
+type protoPayload interface {
+	appendProto(b []byte) []byte
+	unmarshalProto(b []byte) error
+}
+
+// ProtobufCodec encode envelope theo message EventEnvelope trong events.proto
+type ProtobufCodec struct{}
+
+// ContentType implements Codec
+func (ProtobufCodec) ContentType() string { return "application/x-protobuf" }
+
+// Encode implements Codec
+func (ProtobufCodec) Encode(event *Event) ([]byte, error) {
+	payload, ok := event.Payload.(protoPayload)
+	if !ok {
+		return nil, fmt.Errorf("payload %s không hỗ trợ protobuf", event.Type)
+	}
+
+	var b []byte
+	b = appendStringField(b, 1, event.ID)
+	b = appendStringField(b, 2, string(event.Type))
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(event.SchemaVersion))
+	b = appendStringField(b, 4, event.ConversationID)
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(event.Timestamp.UnixNano()))
+	b = protowire.AppendTag(b, 6, protowire.BytesType)
+	b = protowire.AppendBytes(b, payload.appendProto(nil))
+	return b, nil
+}
+
+// Decode implements Codec
+func (ProtobufCodec) Decode(data []byte, registry *EventRegistry) (*Event, error) {
+	var (
+		id, eventType, conversationID, payloadBytes string
+		schemaVersion, timestamp                    uint64
+	)
+	err := parseProto(data,
+		map[protowire.Number]*string{1: &id, 2: &eventType, 4: &conversationID, 6: &payloadBytes},
+		map[protowire.Number]*uint64{3: &schemaVersion, 5: &timestamp},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi parse envelope protobuf: %w", err)
+	}
+
+	payload, err := registry.NewPayload(EventType(eventType))
+	if err != nil {
+		return nil, err
+	}
+	decoder, ok := payload.(protoPayload)
+	if !ok {
+		return nil, fmt.Errorf("payload %s không hỗ trợ protobuf", eventType)
+	}
+	if err := decoder.unmarshalProto([]byte(payloadBytes)); err != nil {
+		return nil, fmt.Errorf("lỗi parse payload %s: %w", eventType, err)
+	}
+
+	event := &Event{
+		ID:             id,
+		Type:           EventType(eventType),
+		SchemaVersion:  int(schemaVersion),
+		ConversationID: conversationID,
+		Timestamp:      time.Unix(0, int64(timestamp)),
+		Payload:        payload,
+	}
+	return event, event.validate()
+}
+
+func (p *ChatMessagePayload) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.MessageID)
+	b = appendStringField(b, 2, p.SenderID)
+	b = appendStringField(b, 3, p.Content)
+	b = appendStringField(b, 4, p.MessageType)
+	return b
+}
+
+func (p *ChatMessagePayload) unmarshalProto(b []byte) error {
+	return parseProto(b, map[protowire.Number]*string{
+		1: &p.MessageID, 2: &p.SenderID, 3: &p.Content, 4: &p.MessageType,
+	}, nil)
+}
+
+func (p *ReactionPayload) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.MessageID)
+	b = appendStringField(b, 2, p.UserID)
+	b = appendStringField(b, 3, p.Emoji)
+	b = appendStringField(b, 4, p.Action)
+	return b
+}
+
+func (p *ReactionPayload) unmarshalProto(b []byte) error {
+	return parseProto(b, map[protowire.Number]*string{
+		1: &p.MessageID, 2: &p.UserID, 3: &p.Emoji, 4: &p.Action,
+	}, nil)
+}
+
+func (p *MessageEditedPayload) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.MessageID)
+	b = appendStringField(b, 2, p.EditorID)
+	b = appendStringField(b, 3, p.Content)
+	return b
+}
+
+func (p *MessageEditedPayload) unmarshalProto(b []byte) error {
+	return parseProto(b, map[protowire.Number]*string{
+		1: &p.MessageID, 2: &p.EditorID, 3: &p.Content,
+	}, nil)
+}
+
+func (p *MessageDeletedPayload) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.MessageID)
+	b = appendStringField(b, 2, p.DeletedBy)
+	return b
+}
+
+func (p *MessageDeletedPayload) unmarshalProto(b []byte) error {
+	return parseProto(b, map[protowire.Number]*string{
+		1: &p.MessageID, 2: &p.DeletedBy,
+	}, nil)
+}
+
+func (p *UserErasedPayload) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.UserID)
+	b = appendStringField(b, 2, p.Mode)
+	return b
+}
+
+func (p *UserErasedPayload) unmarshalProto(b []byte) error {
+	return parseProto(b, map[protowire.Number]*string{
+		1: &p.UserID, 2: &p.Mode,
+	}, nil)
+}
+
+func (p *AuditLoggedPayload) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.ActorID)
+	b = appendStringField(b, 2, p.Action)
+	b = appendStringField(b, 3, p.TargetType)
+	b = appendStringField(b, 4, p.TargetID)
+	b = appendStringField(b, 5, p.Before)
+	b = appendStringField(b, 6, p.After)
+	b = appendStringField(b, 7, p.RequestID)
+	b = appendStringField(b, 8, p.IP)
+	b = appendStringField(b, 9, p.UserAgent)
+	return b
+}
+
+func (p *AuditLoggedPayload) unmarshalProto(b []byte) error {
+	return parseProto(b, map[protowire.Number]*string{
+		1: &p.ActorID, 2: &p.Action, 3: &p.TargetType, 4: &p.TargetID, 5: &p.Before,
+		6: &p.After, 7: &p.RequestID, 8: &p.IP, 9: &p.UserAgent,
+	}, nil)
+}
+
+// appendStringField ghi một field string, bỏ qua giá trị rỗng như proto3
+func appendStringField(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// parseProto đọc các field string/varint đã biết, bỏ qua các field lạ
+// để giữ tương thích khi schema thêm field mới.
+func parseProto(b []byte, strings map[protowire.Number]*string, varints map[protowire.Number]*uint64) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.BytesType && strings[num] != nil:
+			var value string
+			value, n = protowire.ConsumeString(b)
+			if n >= 0 {
+				*strings[num] = value
+			}
+		case typ == protowire.VarintType && varints[num] != nil:
+			var value uint64
+			value, n = protowire.ConsumeVarint(b)
+			if n >= 0 {
+				*varints[num] = value
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
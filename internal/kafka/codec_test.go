@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeRecordLegacyJSON(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    *Event
+		wantErr error
+	}{
+		{
+			name:  "message",
+			value: `{"type":"message","message_id":"msg_1","conversation_id":"general","sender_id":"alice","content":"xin chào","message_type":"text","timestamp":"2024-05-01T10:00:00Z"}`,
+			want: &Event{
+				ID:             "msg_1",
+				Type:           EventTypeMessage,
+				SchemaVersion:  LegacySchemaVersion,
+				ConversationID: "general",
+				Timestamp:      timestamp,
+				Payload:        &ChatMessagePayload{MessageID: "msg_1", SenderID: "alice", Content: "xin chào", MessageType: "text"},
+			},
+		},
+		{
+			name:  "reaction",
+			value: `{"type":"reaction","message_id":"msg_1","conversation_id":"general","sender_id":"bob","metadata":{"emoji":"👍","action":"add"},"timestamp":"2024-05-01T10:00:00Z"}`,
+			want: &Event{
+				ID:             "msg_1",
+				Type:           EventTypeReaction,
+				SchemaVersion:  LegacySchemaVersion,
+				ConversationID: "general",
+				Timestamp:      timestamp,
+				Payload:        &ReactionPayload{MessageID: "msg_1", UserID: "bob", Emoji: "👍", Action: "add"},
+			},
+		},
+		{
+			name:    "type lạ",
+			value:   `{"type":"typing","message_id":"msg_1","conversation_id":"general"}`,
+			wantErr: ErrUnknownEventType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := DecodeRecord(map[string]string{}, []byte(tt.value), nil)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DecodeRecord error = %v, muốn %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeRecord: %v", err)
+			}
+			if !reflect.DeepEqual(event, tt.want) {
+				t.Errorf("DecodeRecord = %+v (payload %+v), muốn %+v (payload %+v)", event, event.Payload, tt.want, tt.want.Payload)
+			}
+		})
+	}
+}
+
+func TestDecodeRecordRoundTrip(t *testing.T) {
+	for _, encoding := range []string{EncodingJSON, EncodingProtobuf} {
+		t.Run(encoding, func(t *testing.T) {
+			codec, err := CodecFor(encoding)
+			if err != nil {
+				t.Fatalf("CodecFor: %v", err)
+			}
+			event := NewEvent("general", &ReactionPayload{MessageID: "msg_1", UserID: "bob", Emoji: "👍", Action: "remove"})
+			value, err := codec.Encode(event)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			record := &Record{Value: value, Headers: recordHeaders(event, codec)}
+			decoded, err := DecodeRecord(record.HeaderMap(), record.Value, nil)
+			if err != nil {
+				t.Fatalf("DecodeRecord: %v", err)
+			}
+			if decoded.ID != event.ID || decoded.Type != event.Type || decoded.ConversationID != event.ConversationID {
+				t.Errorf("envelope = %+v, muốn %+v", decoded, event)
+			}
+			if !reflect.DeepEqual(decoded.Payload, event.Payload) {
+				t.Errorf("payload = %+v, muốn %+v", decoded.Payload, event.Payload)
+			}
+		})
+	}
+}
+
+func TestDecodeRecordRejectsNewerSchema(t *testing.T) {
+	headers := map[string]string{HeaderSchemaVersion: "99", HeaderContentType: JSONCodec{}.ContentType()}
+	if _, err := DecodeRecord(headers, []byte(`{}`), nil); err == nil {
+		t.Fatal("DecodeRecord chấp nhận schema version mới hơn version hỗ trợ")
+	}
+}
@@ -2,25 +2,40 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/metrics"
 	"vibeta/internal/models"
+	"vibeta/internal/tracing"
 
-	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Consumer xử lý messages từ Kafka queue
 type Consumer struct {
-	consumer       sarama.Consumer
-	consumerGroup  sarama.ConsumerGroup
-	config         *ConsumerConfig
-	db             *db.Database
-	processingPool *ProcessingPool
+	bus      MessageBus
+	ownsBus  bool
+	config   *ConsumerConfig
+	store    *db.Store
+	registry *EventRegistry
+	pools    map[string]*ProcessingPool // theo topic
+
+	// cancel/done dùng để dừng subscription trước khi đóng processing pool
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Trạng thái dùng cho health check
+	started       atomic.Bool
+	sessionActive atomic.Bool
 }
 
 // ConsumerConfig cấu hình cho Kafka consumer
@@ -29,145 +44,226 @@ type ConsumerConfig struct {
 	Topic         string
 	ConsumerGroup string
 	WorkerCount   int
+	// Topics subscribe nhiều topic, mỗi topic một worker pool riêng.
+	// Rỗng sẽ chỉ consume Topic với WorkerCount workers.
+	Topics   []TopicConfig
+	Registry *EventRegistry  // nil sẽ dùng DefaultRegistry
+	Security *SecurityConfig // TLS/SASL/client ID khi consumer tự tạo bus
+
+	// Bus dùng để subscribe; nil sẽ tạo SaramaBus từ Brokers.
+	// Consumer chỉ đóng bus do chính nó tạo.
+	Bus MessageBus
 }
 
-// ProcessingPool quản lý workers để xử lý messages
+// priorityYieldTimeout thời gian tối đa một worker priority thấp nhường cho mỗi event
+const priorityYieldTimeout = time.Second
+
+// ProcessingPool quản lý workers để xử lý messages của một topic
 type ProcessingPool struct {
+	topic     string
+	priority  int
 	workers   int
-	taskQueue chan *MessageEvent
+	taskQueue chan processingTask
 	wg        sync.WaitGroup
-	db        *db.Database
+	store     *db.Store
+	registry  *EventRegistry
+
+	// higher là các pool có priority cao hơn, worker nhường khi chúng còn backlog
+	higher []*ProcessingPool
 }
 
+// processingTask event cùng trace context của record chứa nó
+type processingTask struct {
+	ctx   context.Context
+	event *Event
+}
+
+// EventHandler xử lý một loại event cụ thể
+type EventHandler func(ctx context.Context, event *Event) error
+
+// Ghi nhận event đã xử lý được giữ bằng retention mặc định của Kafka; event gửi lại
+// sau thời gian này không còn trên topic
+const (
+	processedEventRetention     = 7 * 24 * time.Hour
+	processedEventPurgeInterval = time.Hour
+)
+
 // MessageProcessor định nghĩa handler cho từng loại message
 type MessageProcessor struct {
-	db *db.Database
+	messages     db.MessageRepository
+	participants db.ParticipantRepository
+	reactions    db.ReactionRepository
+	search       db.SearchRepository
+	processed    db.ProcessedEventRepository
+	registry     *EventRegistry
+	handlers     map[EventType]EventHandler
+
+	// lastPurge lần cuối xóa ghi nhận event đã xử lý quá processedEventRetention
+	lastPurge time.Time
+}
+
+// NewMessageProcessor tạo processor với handler cho các event type mặc định
+func NewMessageProcessor(store *db.Store, registry *EventRegistry) *MessageProcessor {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	mp := &MessageProcessor{
+		messages:     store.Messages,
+		participants: store.Participants,
+		reactions:    store.Reactions,
+		search:       store.Search,
+		processed:    store.ProcessedEvents,
+		registry:     registry,
+		handlers:     make(map[EventType]EventHandler),
+	}
+	mp.Handle(EventTypeMessage, mp.processMessage)
+	mp.Handle(EventTypeReaction, mp.processReaction)
+	mp.Handle(EventTypeMessageEdited, mp.processMessageEdited)
+	mp.Handle(EventTypeMessageDeleted, mp.processMessageDeleted)
+	mp.Handle(EventTypeUserErased, mp.processUserErased)
+	mp.Handle(EventTypeAuditLogged, mp.processAuditLogged)
+	return mp
+}
+
+// Handle đăng ký handler cho một event type đã có trong registry
+func (mp *MessageProcessor) Handle(eventType EventType, handler EventHandler) {
+	if !mp.registry.IsRegistered(eventType) {
+		panic(fmt.Sprintf("kafka: không thể đăng ký handler cho event type %q chưa có trong registry", eventType))
+	}
+	mp.handlers[eventType] = handler
 }
 
 // NewConsumer tạo một Kafka consumer mới
-func NewConsumer(config *ConsumerConfig, database *db.Database) (*Consumer, error) {
-	// Cấu hình Sarama consumer
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
-	saramaConfig.Consumer.Group.Session.Timeout = 10 * time.Second
-	saramaConfig.Consumer.Group.Heartbeat.Interval = 3 * time.Second
-	saramaConfig.Consumer.MaxProcessingTime = 1 * time.Minute
-	saramaConfig.Consumer.Return.Errors = true
-
-	// Tối ưu performance
-	saramaConfig.Consumer.Fetch.Min = 1024 * 1024      // 1MB minimum fetch
-	saramaConfig.Consumer.Fetch.Default = 1024 * 1024  // 1MB default fetch
-	saramaConfig.Consumer.Fetch.Max = 10 * 1024 * 1024 // 10MB maximum fetch
-
-	consumerGroup, err := sarama.NewConsumerGroup(config.Brokers, config.ConsumerGroup, saramaConfig)
-	if err != nil {
-		return nil, fmt.Errorf("lỗi tạo consumer group: %w", err)
+func NewConsumer(config *ConsumerConfig, store *db.Store) (*Consumer, error) {
+	bus, ownsBus := config.Bus, false
+	if bus == nil {
+		saramaBus, err := NewSaramaBus(&SaramaBusConfig{Brokers: config.Brokers, Security: config.Security})
+		if err != nil {
+			return nil, fmt.Errorf("lỗi kết nối Kafka: %w", err)
+		}
+		bus, ownsBus = saramaBus, true
 	}
 
-	// Tạo processing pool
-	pool := &ProcessingPool{
-		workers:   config.WorkerCount,
-		taskQueue: make(chan *MessageEvent, config.WorkerCount*10), // Buffer 10x số workers
-		db:        database,
+	registry := config.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	topics := config.Topics
+	if len(topics) == 0 {
+		topics = []TopicConfig{{Name: config.Topic, Workers: config.WorkerCount}}
+	}
+
+	// Tạo processing pool cho từng topic
+	pools := make(map[string]*ProcessingPool, len(topics))
+	for _, topic := range topics {
+		if topic.Workers <= 0 {
+			return nil, fmt.Errorf("topic %s phải có ít nhất 1 worker", topic.Name)
+		}
+		pools[topic.Name] = &ProcessingPool{
+			topic:     topic.Name,
+			priority:  topic.Priority,
+			workers:   topic.Workers,
+			taskQueue: make(chan processingTask, topic.Workers*10), // Buffer 10x số workers
+			store:     store,
+			registry:  registry,
+		}
+	}
+	for _, pool := range pools {
+		for _, other := range pools {
+			if other.priority > pool.priority {
+				pool.higher = append(pool.higher, other)
+			}
+		}
 	}
 
 	return &Consumer{
-		consumerGroup:  consumerGroup,
-		config:         config,
-		db:             database,
-		processingPool: pool,
+		bus:      bus,
+		ownsBus:  ownsBus,
+		config:   config,
+		store:    store,
+		registry: registry,
+		pools:    pools,
 	}, nil
 }
 
+// Topics trả về danh sách topic consumer đang subscribe
+func (c *Consumer) Topics() []string {
+	topics := make([]string, 0, len(c.pools))
+	for topic := range c.pools {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
 // Start bắt đầu consumer để lắng nghe messages
 func (c *Consumer) Start(ctx context.Context) error {
-	log.Printf("Bắt đầu Kafka consumer với %d workers", c.config.WorkerCount)
-
-	// Khởi động processing pool
-	c.processingPool.Start()
+	// Khởi động processing pool của từng topic
+	for _, topic := range c.Topics() {
+		pool := c.pools[topic]
+		slog.Info("Bắt đầu consume topic", logging.KeyTopic, topic, "workers", pool.workers, "priority", pool.priority)
+		pool.Start()
+	}
 
-	// Khởi động error handler
-	go c.handleErrors(ctx)
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
 
 	// Khởi động consumer group
 	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				err := c.consumerGroup.Consume(ctx, []string{c.config.Topic}, c)
-				if err != nil {
-					log.Printf("Lỗi consumer group: %v", err)
-				}
-			}
+		defer close(c.done)
+		err := c.bus.Subscribe(ctx, Subscription{
+			Group:    c.config.ConsumerGroup,
+			Topics:   c.Topics(),
+			Handler:  c.handleRecord,
+			OnAssign: func() { c.sessionActive.Store(true) },
+			OnRevoke: func() { c.sessionActive.Store(false) },
+		})
+		if err != nil {
+			slog.Error("Lỗi consumer group", logging.Err(err))
 		}
 	}()
 
-	log.Printf("Kafka consumer đã khởi động thành công")
+	c.started.Store(true)
+	slog.Info("Kafka consumer đã khởi động thành công", "group", c.config.ConsumerGroup)
 	return nil
 }
 
-// Setup implements sarama.ConsumerGroupHandler
-func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
-	log.Println("Consumer group setup")
-	return nil
-}
-
-// Cleanup implements sarama.ConsumerGroupHandler
-func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
-	log.Println("Consumer group cleanup")
-	return nil
-}
-
-// ConsumeClaim implements sarama.ConsumerGroupHandler
-func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for {
-		select {
-		case message := <-claim.Messages():
-			if message == nil {
-				return nil
-			}
-
-			// Parse message event
-			var event MessageEvent
-			if err := json.Unmarshal(message.Value, &event); err != nil {
-				log.Printf("Lỗi parse message: %v", err)
-				session.MarkMessage(message, "")
-				continue
-			}
+// handleRecord decode record và đẩy event vào processing pool của topic.
+// Block khi pool đầy để tạo backpressure thay vì bỏ message; mỗi topic có pool
+// riêng nên một topic bị nghẽn không chặn các topic khác.
+func (c *Consumer) handleRecord(ctx context.Context, record *Record) error {
+	pool, ok := c.pools[record.Topic]
+	if !ok {
+		return fmt.Errorf("không có processing pool cho topic %s", record.Topic)
+	}
 
-			// Đẩy vào processing pool
-			select {
-			case c.processingPool.taskQueue <- &event:
-				// Message đã được đẩy vào queue để xử lý
-			case <-session.Context().Done():
-				return nil
-			default:
-				log.Printf("Processing pool đầy, dropping message %s", event.MessageID)
-			}
+	spanCtx, span := startConsumeSpan(ctx, record)
+	defer span.End()
 
-			// Mark message as processed
-			session.MarkMessage(message, "")
+	// Decode envelope (hỗ trợ cả định dạng JSON cũ)
+	event, err := DecodeRecord(record.HeaderMap(), record.Value, c.registry)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("lỗi parse message: %w", err)
+	}
+	span.SetAttributes(eventAttributes(event)...)
 
-		case <-session.Context().Done():
-			return nil
-		}
+	// Task chỉ giữ span context, không giữ ctx của subscription,
+	// để event đã vào pool vẫn được xử lý xong khi consumer đang dừng
+	task := processingTask{
+		ctx:   trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(spanCtx)),
+		event: event,
 	}
-}
 
-// handleErrors xử lý các lỗi từ consumer
-func (c *Consumer) handleErrors(ctx context.Context) {
-	for {
-		select {
-		case err := <-c.consumerGroup.Errors():
-			if err != nil {
-				log.Printf("Consumer error: %v", err)
-			}
-		case <-ctx.Done():
-			return
-		}
+	// Đẩy vào processing pool
+	select {
+	case pool.taskQueue <- task:
+		metrics.QueueDepth.WithLabelValues(pool.topic).Set(float64(len(pool.taskQueue)))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -189,84 +285,307 @@ func (p *ProcessingPool) Stop() {
 func (p *ProcessingPool) worker(workerID int) {
 	defer p.wg.Done()
 
-	processor := &MessageProcessor{db: p.db}
+	processor := NewMessageProcessor(p.store, p.registry)
 
-	log.Printf("Worker %d đã khởi động", workerID)
+	logger := slog.With(logging.KeyTopic, p.topic, "worker", workerID)
+	logger.Debug("Worker đã khởi động")
 
-	for event := range p.taskQueue {
-		start := time.Now()
+	queueDepth := metrics.QueueDepth.WithLabelValues(p.topic)
 
-		if err := processor.ProcessEvent(event); err != nil {
-			log.Printf("Worker %d: Lỗi xử lý message %s: %v", workerID, event.MessageID, err)
+	for task := range p.taskQueue {
+		queueDepth.Set(float64(len(p.taskQueue)))
+		p.yieldToHigherPriority()
+		start := time.Now()
+		event := task.event
+
+		ctx := logging.With(task.ctx, "event_id", event.ID, logging.KeyEventType, event.Type,
+			logging.KeyConversationID, event.ConversationID)
+		ctx, span := tracing.Tracer().Start(ctx, processSpanName(event),
+			trace.WithAttributes(eventAttributes(event)...))
+		err := processor.ProcessEvent(ctx, event)
+		tracing.RecordError(span, err)
+		span.End()
+		metrics.ObserveProcessing(p.topic, string(event.Type), time.Since(start).Seconds(), err)
+
+		if err != nil {
+			logger.ErrorContext(ctx, "Lỗi xử lý event", logging.Err(err))
 		} else {
-			duration := time.Since(start)
-			log.Printf("Worker %d: Đã xử lý message %s trong %v", workerID, event.MessageID, duration)
+			logger.DebugContext(ctx, "Đã xử lý event", "duration", time.Since(start))
 		}
 	}
 
-	log.Printf("Worker %d đã dừng", workerID)
+	logger.Debug("Worker đã dừng")
 }
 
-// ProcessEvent xử lý một message event
-func (mp *MessageProcessor) ProcessEvent(event *MessageEvent) error {
-	switch event.Type {
-	case "message":
-		return mp.processMessage(event)
-	case "reaction":
-		return mp.processReaction(event)
-	default:
-		log.Printf("Không hỗ trợ message type: %s", event.Type)
+// yieldToHigherPriority đợi trong khi các pool priority cao hơn còn backlog,
+// tối đa priorityYieldTimeout để topic priority thấp không bị bỏ đói hoàn toàn
+func (p *ProcessingPool) yieldToHigherPriority() {
+	if len(p.higher) == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(priorityYieldTimeout)
+	for time.Now().Before(deadline) {
+		backlog := false
+		for _, pool := range p.higher {
+			if len(pool.taskQueue) > 0 {
+				backlog = true
+				break
+			}
+		}
+		if !backlog {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ProcessEvent xử lý một event theo handler đã đăng ký cho type của nó.
+// Outbox relay và consumer đảm bảo at-least-once nên event có thể đến nhiều lần với cùng
+// event_id; event đã xử lý thành công được ghi nhận và các lần sau bị bỏ qua. Record định
+// dạng cũ không có event_id riêng (ID là message_id) nên không được dedupe.
+func (mp *MessageProcessor) ProcessEvent(ctx context.Context, event *Event) error {
+	handler, ok := mp.handlers[event.Type]
+	if !ok {
+		slog.WarnContext(ctx, "Không có handler cho event type", logging.KeyEventType, event.Type)
+		return nil
+	}
+
+	dedupe := event.SchemaVersion != LegacySchemaVersion && event.ID != ""
+	if dedupe {
+		processed, err := mp.processed.IsProcessed(ctx, event.ID)
+		if err != nil {
+			return fmt.Errorf("lỗi kiểm tra event đã xử lý: %w", err)
+		}
+		if processed {
+			slog.DebugContext(ctx, "Bỏ qua event đã xử lý", "event_id", event.ID)
+			return nil
+		}
+	}
+
+	if err := handler(ctx, event); err != nil {
+		return err
+	}
+	if !dedupe {
 		return nil
 	}
+
+	// Dừng giữa handler và bước ghi nhận thì event gửi lại vẫn được xử lý thêm một lần
+	now := time.Now()
+	if err := mp.processed.MarkProcessed(ctx, &models.ProcessedEvent{EventID: event.ID, EventType: string(event.Type), ProcessedAt: now}); err != nil {
+		return fmt.Errorf("lỗi ghi nhận event đã xử lý: %w", err)
+	}
+	if now.Sub(mp.lastPurge) >= processedEventPurgeInterval {
+		mp.lastPurge = now
+		if _, err := mp.processed.PurgeBefore(ctx, now.Add(-processedEventRetention)); err != nil {
+			slog.WarnContext(ctx, "Lỗi xóa ghi nhận event đã xử lý", logging.Err(err))
+		}
+	}
+	return nil
 }
 
 // processMessage xử lý chat message
-func (mp *MessageProcessor) processMessage(event *MessageEvent) error {
+func (mp *MessageProcessor) processMessage(ctx context.Context, event *Event) error {
+	payload, ok := event.Payload.(*ChatMessagePayload)
+	if !ok {
+		return fmt.Errorf("payload không hợp lệ cho event %s", event.ID)
+	}
+
 	message := &models.Message{
-		ID:             event.MessageID,
+		ID:             payload.MessageID,
 		ConversationID: event.ConversationID,
-		SenderID:       event.SenderID,
-		Content:        event.Content,
-		Type:           models.MessageType(event.MessageType),
+		SenderID:       payload.SenderID,
+		Content:        payload.Content,
+		Type:           models.MessageType(payload.MessageType),
 		Status:         models.MessageStatusSent,
 		CreatedAt:      event.Timestamp,
 		UpdatedAt:      time.Now(),
 	}
 
-	err := mp.db.SaveMessage(message)
+	// Event có thể đến nhiều lần (outbox relay, retry), bỏ qua message đã lưu.
+	// ID trùng với tin nhắn của người gửi khác là lỗi, không phải event lặp lại.
+	_, span := tracing.Tracer().Start(ctx, "db.save_message", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation.name", "INSERT"), attribute.String("db.collection.name", "messages")))
+	err := mp.messages.CreateIfAbsent(ctx, message)
+	tracing.RecordError(span, err)
+	span.End()
 	if err != nil {
 		return fmt.Errorf("lỗi lưu message vào DB: %w", err)
 	}
 
-	log.Printf("Đã lưu message %s vào database", event.MessageID)
+	// Index cả khi message đã tồn tại: bản lưu trực tiếp qua outbox chưa được index
+	if err := mp.search.Index(ctx, message); err != nil {
+		return fmt.Errorf("lỗi index message: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Đã lưu message vào database", logging.KeyMessageID, payload.MessageID)
+	return nil
+}
+
+// processMessageEdited cập nhật nội dung tin nhắn và search index.
+// Chỉ người gửi được sửa; event của người khác hoặc tin nhắn không còn tồn tại bị bỏ qua.
+func (mp *MessageProcessor) processMessageEdited(ctx context.Context, event *Event) error {
+	payload, ok := event.Payload.(*MessageEditedPayload)
+	if !ok {
+		return fmt.Errorf("payload không hợp lệ cho event %s", event.ID)
+	}
+
+	message, err := mp.messages.Get(ctx, payload.MessageID)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		slog.WarnContext(ctx, "Bỏ qua sửa message không tồn tại", logging.KeyMessageID, payload.MessageID)
+		return nil
+	case err != nil:
+		return fmt.Errorf("lỗi đọc message: %w", err)
+	case message.SenderID != payload.EditorID:
+		slog.WarnContext(ctx, "Bỏ qua sửa message của người khác", logging.KeyMessageID, payload.MessageID,
+			logging.KeyUserID, payload.EditorID, "sender_id", message.SenderID)
+		return nil
+	}
+
+	editedAt := event.Timestamp
+	changes := &models.Message{Content: payload.Content, EditedAt: &editedAt, CreatedAt: message.CreatedAt}
+	if err := mp.messages.Update(ctx, message.ID, changes); err != nil {
+		return fmt.Errorf("lỗi cập nhật message: %w", err)
+	}
+
+	message.Content = payload.Content
+	message.EditedAt = &editedAt
+	if err := mp.search.Index(ctx, message); err != nil {
+		return fmt.Errorf("lỗi index message: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Đã sửa message", logging.KeyMessageID, payload.MessageID)
+	return nil
+}
+
+// processMessageDeleted xóa mềm tin nhắn và xóa khỏi search index. Người gửi, owner và admin
+// của conversation được xóa; event gửi lại sau khi đã xóa chỉ dọn lại index.
+func (mp *MessageProcessor) processMessageDeleted(ctx context.Context, event *Event) error {
+	payload, ok := event.Payload.(*MessageDeletedPayload)
+	if !ok {
+		return fmt.Errorf("payload không hợp lệ cho event %s", event.ID)
+	}
+
+	message, err := mp.messages.Get(ctx, payload.MessageID)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		// Đã xóa ở lần xử lý trước, có thể lỗi trước khi kịp xóa khỏi index
+	case err != nil:
+		return fmt.Errorf("lỗi đọc message: %w", err)
+	case message.SenderID != payload.DeletedBy:
+		allowed, err := mp.canDeleteOthers(ctx, message.ConversationID, payload.DeletedBy)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			slog.WarnContext(ctx, "Bỏ qua xóa message của người khác", logging.KeyMessageID, payload.MessageID,
+				logging.KeyUserID, payload.DeletedBy, "sender_id", message.SenderID)
+			return nil
+		}
+		fallthrough
+	default:
+		if err := mp.messages.Delete(ctx, message.ID); err != nil {
+			return fmt.Errorf("lỗi xóa message: %w", err)
+		}
+	}
+
+	if err := mp.search.Remove(ctx, payload.MessageID); err != nil {
+		return fmt.Errorf("lỗi xóa message khỏi index: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Đã xóa message", logging.KeyMessageID, payload.MessageID)
+	return nil
+}
+
+// canDeleteOthers kiểm tra vai trò hiện tại của user trong conversation có quyền xóa tin nhắn của người khác
+func (mp *MessageProcessor) canDeleteOthers(ctx context.Context, conversationID, userID string) (bool, error) {
+	participant, err := mp.participants.Get(ctx, conversationID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("lỗi đọc vai trò thành viên: %w", err)
+	}
+	return participant.Role.Can(models.PermissionDeleteOthersMessages), nil
+}
+
+// processUserErased xóa tin nhắn của user khỏi search index. Database đã được xóa trong cùng
+// transaction với event; handler dọn lại các tin nhắn được index muộn từ event đang trên đường
+// (ví dụ tin nhắn user gửi ngay trước khi bị xóa dữ liệu).
+func (mp *MessageProcessor) processUserErased(ctx context.Context, event *Event) error {
+	payload, ok := event.Payload.(*UserErasedPayload)
+	if !ok {
+		return fmt.Errorf("payload không hợp lệ cho event %s", event.ID)
+	}
+
+	if err := mp.search.RemoveBySender(ctx, payload.UserID); err != nil {
+		return fmt.Errorf("lỗi xóa tin nhắn của user khỏi index: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Đã xóa dữ liệu suy ra của user", logging.KeyUserID, payload.UserID, "mode", payload.Mode)
+	return nil
+}
+
+// processAuditLogged bỏ qua event audit_logged: bản ghi đã nằm trong bảng audit_logs,
+// event chỉ dành cho hệ thống bên ngoài subscribe topic audit
+func (mp *MessageProcessor) processAuditLogged(ctx context.Context, event *Event) error {
+	slog.DebugContext(ctx, "Bỏ qua event audit_logged", "event_id", event.ID)
 	return nil
 }
 
 // processReaction xử lý reaction
-func (mp *MessageProcessor) processReaction(event *MessageEvent) error {
-	emoji, _ := event.Metadata["emoji"].(string)
-	action, _ := event.Metadata["action"].(string)
+func (mp *MessageProcessor) processReaction(ctx context.Context, event *Event) error {
+	payload, ok := event.Payload.(*ReactionPayload)
+	if !ok {
+		return fmt.Errorf("payload không hợp lệ cho event %s", event.ID)
+	}
 
-	// TODO: Implement reaction processing
-	// Hiện tại chỉ log để tracking
-	log.Printf("Xử lý reaction: user %s %s emoji %s cho message %s",
-		event.SenderID, action, emoji, event.MessageID)
+	// Event gửi lại đã được ProcessEvent bỏ qua theo event_id
+	var err error
+	switch payload.Action {
+	case "add":
+		err = mp.reactions.Add(ctx, &models.MessageReaction{
+			MessageID:      payload.MessageID,
+			UserID:         payload.UserID,
+			Emoji:          payload.Emoji,
+			ConversationID: event.ConversationID,
+			CreatedAt:      event.Timestamp,
+		})
+	case "remove":
+		err = mp.reactions.Remove(ctx, payload.MessageID, payload.UserID, payload.Emoji)
+	default:
+		slog.WarnContext(ctx, "Bỏ qua reaction với action không hợp lệ", "action", payload.Action, logging.KeyMessageID, payload.MessageID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lỗi lưu reaction vào DB: %w", err)
+	}
 
+	slog.DebugContext(ctx, "Đã xử lý reaction", logging.KeyUserID, payload.UserID, "action", payload.Action,
+		"emoji", payload.Emoji, logging.KeyMessageID, payload.MessageID)
 	return nil
 }
 
 // Close đóng consumer
 func (c *Consumer) Close() error {
-	log.Println("Đang đóng Kafka consumer...")
-
-	// Stop processing pool
-	c.processingPool.Stop()
+	slog.Info("Đang đóng Kafka consumer...")
+
+	// Dừng subscription trước để không còn event được đẩy vào pool
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+		for _, pool := range c.pools {
+			pool.Stop()
+		}
+	}
 
-	// Close consumer group
-	if err := c.consumerGroup.Close(); err != nil {
-		return fmt.Errorf("lỗi đóng consumer group: %w", err)
+	if c.ownsBus {
+		if err := c.bus.Close(); err != nil {
+			return fmt.Errorf("lỗi đóng consumer group: %w", err)
+		}
 	}
 
-	log.Println("Kafka consumer đã đóng")
+	slog.Info("Kafka consumer đã đóng")
 	return nil
 }
@@ -0,0 +1,359 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/models"
+)
+
+const (
+	testTopic        = "chat-messages"
+	testGroup        = "chat-workers"
+	testConversation = "conv-test"
+)
+
+// pipeline nối Producer → MemoryBus → Consumer → MessageProcessor → memory store
+type pipeline struct {
+	bus      *MemoryBus
+	producer *Producer
+	store    *db.Store
+}
+
+// newPipeline dựng pipeline với bus một partition và một worker
+// để các event được xử lý đúng thứ tự publish
+func newPipeline(t *testing.T) *pipeline {
+	t.Helper()
+
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 1})
+	t.Cleanup(func() { bus.Close() })
+
+	producer, err := NewProducer(&ProducerConfig{Topic: testTopic, Bus: bus})
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	t.Cleanup(func() { producer.Close() })
+
+	store := db.NewMemoryStore()
+	consumer, err := NewConsumer(&ConsumerConfig{Topic: testTopic, ConsumerGroup: testGroup, WorkerCount: 1, Bus: bus}, store)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	if err := consumer.Start(context.Background()); err != nil {
+		t.Fatalf("consumer.Start: %v", err)
+	}
+	t.Cleanup(func() { consumer.Close() })
+
+	return &pipeline{bus: bus, producer: producer, store: store}
+}
+
+// publishMessage gửi event "message" qua producer
+func (p *pipeline) publishMessage(t *testing.T, messageID, content string) {
+	t.Helper()
+	event := NewEvent(testConversation, &ChatMessagePayload{
+		MessageID:   messageID,
+		SenderID:    "alice",
+		Content:     content,
+		MessageType: string(models.MessageTypeText),
+	})
+	if err := p.producer.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish message %s: %v", messageID, err)
+	}
+}
+
+// publishRaw ghi record thẳng vào bus, dùng cho record định dạng cũ hoặc không hợp lệ
+func (p *pipeline) publishRaw(t *testing.T, value []byte) {
+	t.Helper()
+	if _, _, err := p.bus.Publish(context.Background(), &Record{Topic: testTopic, Value: value}); err != nil {
+		t.Fatalf("Publish raw record: %v", err)
+	}
+}
+
+// drain publish một tin nhắn đánh dấu và đợi nó được lưu. Bus một partition và một
+// worker xử lý theo thứ tự nên mọi event publish trước đó cũng đã được xử lý.
+func (p *pipeline) drain(t *testing.T) {
+	t.Helper()
+	p.publishMessage(t, "msg_drain", "drain")
+	waitFor(t, func() bool {
+		_, err := p.store.Messages.Get(context.Background(), "msg_drain")
+		return err == nil
+	})
+}
+
+// waitFor đợi cond trả về true, fail test sau 5 giây
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("hết thời gian chờ")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func legacyRecord(t *testing.T, event MessageEvent) []byte {
+	t.Helper()
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return value
+}
+
+func TestPipelinePersistsMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish func(t *testing.T, p *pipeline)
+	}{
+		{
+			name: "envelope",
+			publish: func(t *testing.T, p *pipeline) {
+				p.publishMessage(t, "msg_1", "xin chào")
+			},
+		},
+		{
+			name: "envelope gửi lại",
+			publish: func(t *testing.T, p *pipeline) {
+				p.publishMessage(t, "msg_1", "xin chào")
+				p.publishMessage(t, "msg_1", "xin chào")
+			},
+		},
+		{
+			name: "định dạng JSON cũ",
+			publish: func(t *testing.T, p *pipeline) {
+				p.publishRaw(t, legacyRecord(t, MessageEvent{
+					Type:           string(EventTypeMessage),
+					MessageID:      "msg_1",
+					ConversationID: testConversation,
+					SenderID:       "alice",
+					Content:        "xin chào",
+					MessageType:    string(models.MessageTypeText),
+					Timestamp:      time.Now(),
+				}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPipeline(t)
+			tt.publish(t, p)
+			p.drain(t)
+
+			message, err := p.store.Messages.Get(context.Background(), "msg_1")
+			if err != nil {
+				t.Fatalf("Messages.Get: %v", err)
+			}
+			if message.ConversationID != testConversation || message.SenderID != "alice" || message.Content != "xin chào" {
+				t.Errorf("message = %+v, muốn conversation %s, sender alice, content %q", message, testConversation, "xin chào")
+			}
+			if message.Status != models.MessageStatusSent {
+				t.Errorf("status = %s, muốn %s", message.Status, models.MessageStatusSent)
+			}
+		})
+	}
+}
+
+func TestPipelineReactionIdempotent(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []string
+		want    int
+	}{
+		{name: "add", actions: []string{"add"}, want: 1},
+		{name: "add gửi lại", actions: []string{"add", "add", "add"}, want: 1},
+		{name: "remove", actions: []string{"add", "remove"}, want: 0},
+		{name: "remove gửi lại", actions: []string{"add", "remove", "remove"}, want: 0},
+		{name: "remove khi chưa có", actions: []string{"remove"}, want: 0},
+		{name: "add lại sau remove", actions: []string{"add", "remove", "add"}, want: 1},
+		{name: "action không hợp lệ bị bỏ qua", actions: []string{"add", "toggle"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPipeline(t)
+			p.publishMessage(t, "msg_1", "xin chào")
+			for _, action := range tt.actions {
+				if err := p.producer.PublishReaction(context.Background(), "msg_1", "bob", "👍", action, testConversation); err != nil {
+					t.Fatalf("PublishReaction %s: %v", action, err)
+				}
+			}
+			p.drain(t)
+
+			reactions, err := p.store.Reactions.ListByMessage(context.Background(), "msg_1")
+			if err != nil {
+				t.Fatalf("Reactions.ListByMessage: %v", err)
+			}
+			if len(reactions) != tt.want {
+				t.Fatalf("có %d reaction, muốn %d", len(reactions), tt.want)
+			}
+			if tt.want == 1 && (reactions[0].UserID != "bob" || reactions[0].Emoji != "👍") {
+				t.Errorf("reaction = %+v, muốn bob 👍", reactions[0])
+			}
+		})
+	}
+}
+
+func TestPipelineSkipsUnknownEventType(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{name: "type lạ định dạng cũ", value: []byte(`{"type":"typing","message_id":"msg_x","conversation_id":"conv-test"}`)},
+		{name: "JSON không hợp lệ", value: []byte(`{`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPipeline(t)
+			p.publishRaw(t, tt.value)
+			p.publishMessage(t, "msg_1", "sau record lỗi")
+			p.drain(t)
+
+			if _, err := p.store.Messages.Get(context.Background(), "msg_1"); err != nil {
+				t.Fatalf("message sau record lỗi chưa được lưu: %v", err)
+			}
+			// Record lỗi vẫn được commit để không chặn partition
+			for _, lag := range p.bus.Lag(testGroup, testTopic) {
+				if lag.Lag != 0 {
+					t.Errorf("partition %d còn lag %d", lag.Partition, lag.Lag)
+				}
+			}
+		})
+	}
+}
+
+// testPayload là event type chỉ có trong registry của test, không có handler
+type testPayload struct{}
+
+func (testPayload) EventType() EventType { return "test_event" }
+
+func TestProcessEventWithoutHandler(t *testing.T) {
+	registry := newDefaultRegistry()
+	registry.Register("test_event", func() EventPayload { return testPayload{} })
+
+	processor := NewMessageProcessor(db.NewMemoryStore(), registry)
+	if err := processor.ProcessEvent(context.Background(), NewEvent(testConversation, testPayload{})); err != nil {
+		t.Fatalf("ProcessEvent = %v, muốn nil", err)
+	}
+}
+
+func TestMessageProcessorRejectsForeignMessageID(t *testing.T) {
+	store := db.NewMemoryStore()
+	processor := NewMessageProcessor(store, nil)
+	ctx := context.Background()
+
+	event := NewEvent(testConversation, &ChatMessagePayload{MessageID: "msg_1", SenderID: "alice", Content: "của alice"})
+	if err := processor.ProcessEvent(ctx, event); err != nil {
+		t.Fatalf("ProcessEvent alice: %v", err)
+	}
+
+	forged := NewEvent(testConversation, &ChatMessagePayload{MessageID: "msg_1", SenderID: "mallory", Content: "giả mạo"})
+	if err := processor.ProcessEvent(ctx, forged); !errors.Is(err, db.ErrDuplicate) {
+		t.Fatalf("ProcessEvent mallory = %v, muốn ErrDuplicate", err)
+	}
+
+	message, err := store.Messages.Get(ctx, "msg_1")
+	if err != nil {
+		t.Fatalf("Messages.Get: %v", err)
+	}
+	if message.SenderID != "alice" || message.Content != "của alice" {
+		t.Errorf("message bị ghi đè: %+v", message)
+	}
+}
+
+func TestProcessEventSkipsRedeliveredEvent(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	processor := NewMessageProcessor(store, nil)
+
+	process := func(event *Event) {
+		t.Helper()
+		if err := processor.ProcessEvent(ctx, event); err != nil {
+			t.Fatalf("ProcessEvent %s: %v", event.Type, err)
+		}
+	}
+
+	process(NewEvent(testConversation, &ChatMessagePayload{MessageID: "msg_1", SenderID: "alice", Content: "bản đầu"}))
+
+	// Reaction add gửi lại sau remove không thêm lại reaction
+	add := NewEvent(testConversation, &ReactionPayload{MessageID: "msg_1", UserID: "bob", Emoji: "👍", Action: "add"})
+	process(add)
+	process(NewEvent(testConversation, &ReactionPayload{MessageID: "msg_1", UserID: "bob", Emoji: "👍", Action: "remove"}))
+	process(add)
+	if reactions, _ := store.Reactions.ListByMessage(ctx, "msg_1"); len(reactions) != 0 {
+		t.Errorf("add gửi lại sau remove: có %d reaction, muốn 0", len(reactions))
+	}
+
+	// Bản sửa cũ gửi lại không ghi đè bản sửa mới hơn
+	first := NewEvent(testConversation, &MessageEditedPayload{MessageID: "msg_1", EditorID: "alice", Content: "sửa lần một"})
+	process(first)
+	process(NewEvent(testConversation, &MessageEditedPayload{MessageID: "msg_1", EditorID: "alice", Content: "sửa lần hai"}))
+	process(first)
+	if message, err := store.Messages.Get(ctx, "msg_1"); err != nil || message.Content != "sửa lần hai" {
+		t.Errorf("sau khi gửi lại bản sửa cũ: %+v (err %v), muốn nội dung %q", message, err, "sửa lần hai")
+	}
+
+	for _, event := range []*Event{add, first} {
+		if processed, err := store.ProcessedEvents.IsProcessed(ctx, event.ID); err != nil || !processed {
+			t.Errorf("event %s chưa được ghi nhận đã xử lý (err %v)", event.ID, err)
+		}
+	}
+}
+
+func TestProcessEventLegacyRecordsNotDeduped(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	processor := NewMessageProcessor(store, nil)
+
+	// Record định dạng cũ dùng message_id làm ID nên add và remove có cùng ID
+	for _, action := range []string{"add", "remove"} {
+		event, err := (&MessageEvent{
+			Type:           string(EventTypeReaction),
+			MessageID:      "msg_1",
+			ConversationID: testConversation,
+			SenderID:       "bob",
+			Metadata:       map[string]interface{}{"emoji": "👍", "action": action},
+			Timestamp:      time.Now(),
+		}).toEvent()
+		if err != nil {
+			t.Fatalf("toEvent: %v", err)
+		}
+		if err := processor.ProcessEvent(ctx, event); err != nil {
+			t.Fatalf("ProcessEvent %s: %v", action, err)
+		}
+	}
+
+	if reactions, _ := store.Reactions.ListByMessage(ctx, "msg_1"); len(reactions) != 0 {
+		t.Errorf("có %d reaction, muốn 0: remove định dạng cũ bị bỏ qua như event trùng", len(reactions))
+	}
+}
+
+func TestProcessEventPurgesOldProcessedEvents(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	processor := NewMessageProcessor(store, nil)
+
+	old := &models.ProcessedEvent{EventID: "evt_old", EventType: string(EventTypeReaction), ProcessedAt: time.Now().Add(-processedEventRetention - time.Hour)}
+	recent := &models.ProcessedEvent{EventID: "evt_recent", EventType: string(EventTypeReaction), ProcessedAt: time.Now().Add(-time.Hour)}
+	for _, event := range []*models.ProcessedEvent{old, recent} {
+		if err := store.ProcessedEvents.MarkProcessed(ctx, event); err != nil {
+			t.Fatalf("MarkProcessed: %v", err)
+		}
+	}
+
+	if err := processor.ProcessEvent(ctx, NewEvent(testConversation, &ChatMessagePayload{MessageID: "msg_1", SenderID: "alice"})); err != nil {
+		t.Fatalf("ProcessEvent: %v", err)
+	}
+	if processed, _ := store.ProcessedEvents.IsProcessed(ctx, old.EventID); processed {
+		t.Error("ghi nhận quá thời gian giữ chưa bị xóa")
+	}
+	if processed, _ := store.ProcessedEvents.IsProcessed(ctx, recent.EventID); !processed {
+		t.Error("ghi nhận còn trong thời gian giữ bị xóa")
+	}
+}
@@ -0,0 +1,224 @@
+package kafka
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Các phiên bản schema của envelope.
+// Version 1 là định dạng JSON cũ (MessageEvent) không có header schema_version.
+const (
+	LegacySchemaVersion  = 1
+	CurrentSchemaVersion = 2
+)
+
+// EventType định danh loại event trong envelope
+type EventType string
+
+const (
+	EventTypeMessage        EventType = "message"
+	EventTypeReaction       EventType = "reaction"
+	EventTypeMessageEdited  EventType = "message_edited"
+	EventTypeMessageDeleted EventType = "message_deleted"
+	EventTypeUserErased     EventType = "user_erased"
+	EventTypeAuditLogged    EventType = "audit_logged"
+)
+
+// EventPayload là payload có kiểu của một event.
+// Mỗi loại event đăng ký một payload riêng trong EventRegistry.
+type EventPayload interface {
+	EventType() EventType
+}
+
+// Event là envelope có version bao quanh payload của từng loại event
+type Event struct {
+	ID             string       `json:"id"`
+	Type           EventType    `json:"type"`
+	SchemaVersion  int          `json:"schema_version"`
+	ConversationID string       `json:"conversation_id"`
+	Timestamp      time.Time    `json:"timestamp"`
+	Payload        EventPayload `json:"payload"`
+}
+
+// NewEvent tạo envelope mới với event ID ngẫu nhiên và schema version hiện tại
+func NewEvent(conversationID string, payload EventPayload) *Event {
+	return &Event{
+		ID:             generateEventID(),
+		Type:           payload.EventType(),
+		SchemaVersion:  CurrentSchemaVersion,
+		ConversationID: conversationID,
+		Timestamp:      time.Now(),
+		Payload:        payload,
+	}
+}
+
+// partitionKey trả về key dùng để partition event trong Kafka
+func (e *Event) partitionKey() string {
+	if keyed, ok := e.Payload.(interface{ PartitionKey() string }); ok {
+		if key := keyed.PartitionKey(); key != "" {
+			return key
+		}
+	}
+	return e.ID
+}
+
+// validate kiểm tra envelope có đầy đủ thông tin và payload khớp với type
+func (e *Event) validate() error {
+	if e.ID == "" {
+		return fmt.Errorf("event thiếu id")
+	}
+	if e.Payload == nil {
+		return fmt.Errorf("event %s thiếu payload", e.ID)
+	}
+	if e.Payload.EventType() != e.Type {
+		return fmt.Errorf("event %s có type %q nhưng payload là %q", e.ID, e.Type, e.Payload.EventType())
+	}
+	return nil
+}
+
+// ChatMessagePayload payload của event "message"
+type ChatMessagePayload struct {
+	MessageID   string `json:"message_id"`
+	SenderID    string `json:"sender_id"`
+	Content     string `json:"content"`
+	MessageType string `json:"message_type"`
+}
+
+// EventType implements EventPayload
+func (p *ChatMessagePayload) EventType() EventType { return EventTypeMessage }
+
+// PartitionKey dùng message_id làm key để partition
+func (p *ChatMessagePayload) PartitionKey() string { return p.MessageID }
+
+// ReactionPayload payload của event "reaction"
+type ReactionPayload struct {
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+	Emoji     string `json:"emoji"`
+	Action    string `json:"action"` // "add" hoặc "remove"
+}
+
+// EventType implements EventPayload
+func (p *ReactionPayload) EventType() EventType { return EventTypeReaction }
+
+// PartitionKey dùng message_id làm key để partition
+func (p *ReactionPayload) PartitionKey() string { return p.MessageID }
+
+// MessageEditedPayload payload của event "message_edited"
+type MessageEditedPayload struct {
+	MessageID string `json:"message_id"`
+	EditorID  string `json:"editor_id"`
+	Content   string `json:"content"`
+}
+
+// EventType implements EventPayload
+func (p *MessageEditedPayload) EventType() EventType { return EventTypeMessageEdited }
+
+// PartitionKey dùng message_id để event sửa cùng partition với event tạo tin nhắn
+func (p *MessageEditedPayload) PartitionKey() string { return p.MessageID }
+
+// MessageDeletedPayload payload của event "message_deleted"
+type MessageDeletedPayload struct {
+	MessageID string `json:"message_id"`
+	DeletedBy string `json:"deleted_by"`
+}
+
+// EventType implements EventPayload
+func (p *MessageDeletedPayload) EventType() EventType { return EventTypeMessageDeleted }
+
+// PartitionKey dùng message_id để event xóa cùng partition với event tạo tin nhắn
+func (p *MessageDeletedPayload) PartitionKey() string { return p.MessageID }
+
+// UserErasedPayload payload của event "user_erased", publish khi dữ liệu của user bị xóa
+// theo yêu cầu của chủ thể dữ liệu. Mọi consumer giữ dữ liệu suy ra từ tin nhắn hoặc
+// hồ sơ user (index, cache, bản sao) phải xóa phần thuộc về user.
+type UserErasedPayload struct {
+	UserID string `json:"user_id"`
+	// Mode "redact" (nội dung tin nhắn bị xóa trắng) hoặc "delete" (tin nhắn bị xóa hẳn)
+	Mode string `json:"mode"`
+}
+
+// EventType implements EventPayload
+func (p *UserErasedPayload) EventType() EventType { return EventTypeUserErased }
+
+// PartitionKey dùng user_id để các event xóa của cùng user theo thứ tự
+func (p *UserErasedPayload) PartitionKey() string { return p.UserID }
+
+// AuditLoggedPayload payload của event "audit_logged", bản sao của một bản ghi audit log
+// cho hệ thống bên ngoài (SIEM, lưu trữ dài hạn). Chỉ publish khi AUDIT_KAFKA_ENABLED=true.
+type AuditLoggedPayload struct {
+	ActorID    string `json:"actor_id"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Before     string `json:"before,omitempty"` // JSON
+	After      string `json:"after,omitempty"`  // JSON
+	RequestID  string `json:"request_id,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// EventType implements EventPayload
+func (p *AuditLoggedPayload) EventType() EventType { return EventTypeAuditLogged }
+
+// PartitionKey dùng đối tượng bị tác động để các thao tác trên cùng đối tượng theo thứ tự
+func (p *AuditLoggedPayload) PartitionKey() string { return p.TargetType + ":" + p.TargetID }
+
+// MessageEvent là định dạng JSON cũ (schema version 1).
+// Chỉ còn được dùng để decode các record được publish trước khi có envelope.
+type MessageEvent struct {
+	Type           string                 `json:"type"`
+	MessageID      string                 `json:"message_id"`
+	ConversationID string                 `json:"conversation_id"`
+	SenderID       string                 `json:"sender_id"`
+	Content        string                 `json:"content"`
+	MessageType    string                 `json:"message_type"`
+	Reactions      map[string][]string    `json:"reactions,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+}
+
+// toEvent chuyển MessageEvent cũ sang envelope
+func (m *MessageEvent) toEvent() (*Event, error) {
+	var payload EventPayload
+	switch EventType(m.Type) {
+	case EventTypeMessage:
+		payload = &ChatMessagePayload{
+			MessageID:   m.MessageID,
+			SenderID:    m.SenderID,
+			Content:     m.Content,
+			MessageType: m.MessageType,
+		}
+	case EventTypeReaction:
+		emoji, _ := m.Metadata["emoji"].(string)
+		action, _ := m.Metadata["action"].(string)
+		payload = &ReactionPayload{
+			MessageID: m.MessageID,
+			UserID:    m.SenderID,
+			Emoji:     emoji,
+			Action:    action,
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, m.Type)
+	}
+
+	return &Event{
+		ID:             m.MessageID,
+		Type:           payload.EventType(),
+		SchemaVersion:  LegacySchemaVersion,
+		ConversationID: m.ConversationID,
+		Timestamp:      m.Timestamp,
+		Payload:        payload,
+	}, nil
+}
+
+// generateEventID tạo event ID ngẫu nhiên
+func generateEventID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("evt_%d", time.Now().UnixNano())
+	}
+	return "evt_" + hex.EncodeToString(buf)
+}
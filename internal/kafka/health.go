@@ -0,0 +1,301 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Các trạng thái trong HealthReport
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// BrokerHealth trạng thái kết nối tới một broker
+type BrokerHealth struct {
+	ID        int32  `json:"id"`
+	Addr      string `json:"addr"`
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PartitionLag lag của consumer group trên một partition
+type PartitionLag struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Committed     int64  `json:"committed_offset"` // -1 nếu group chưa commit
+	HighWaterMark int64  `json:"high_water_mark"`
+	Lag           int64  `json:"lag"`
+}
+
+// ConsumerHealth trạng thái và lag của consumer group
+type ConsumerHealth struct {
+	Group         string         `json:"group"`
+	Started       bool           `json:"started"`
+	SessionActive bool           `json:"session_active"`
+	TotalLag      int64          `json:"total_lag"`
+	Partitions    []PartitionLag `json:"partitions,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// TopicHealth số partition hiện có của một topic
+type TopicHealth struct {
+	Name       string `json:"name"`
+	Partitions int    `json:"partitions"`
+}
+
+// HealthReport kết quả kiểm tra Kafka
+type HealthReport struct {
+	Status          string               `json:"status"`
+	Timestamp       time.Time            `json:"timestamp"`
+	ProducerEnabled bool                 `json:"producer_enabled"`
+	ConsumerEnabled bool                 `json:"consumer_enabled"`
+	Topic           string               `json:"topic"`      // Topic mặc định (KAFKA_MESSAGE_TOPIC)
+	Partitions      int                  `json:"partitions"` // Số partition của topic mặc định
+	Topics          []TopicHealth        `json:"topics"`
+	Routes          map[EventType]string `json:"routes"`
+	EventEncoding   string               `json:"event_encoding"`
+	SchemaVersion   int                  `json:"schema_version"`
+	EventTypes      []EventType          `json:"event_types"`
+	Brokers         []BrokerHealth       `json:"brokers"`
+	Producer        *ProducerStats       `json:"producer,omitempty"`
+	Consumer        *ConsumerHealth      `json:"consumer,omitempty"`
+	Error           string               `json:"error,omitempty"`
+}
+
+// HealthCheck kiểm tra kết nối tới các broker, metadata của topic,
+// thống kê producer và lag của consumer group (nếu consumer được bật)
+func (ms *MessageService) HealthCheck(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status:          HealthStatusHealthy,
+		Timestamp:       time.Now(),
+		ProducerEnabled: ms.config.EnableProducer,
+		ConsumerEnabled: ms.config.EnableConsumer,
+		Topic:           ms.config.MessageTopic,
+		Routes:          ms.config.Routes,
+		EventEncoding:   ms.config.EventEncoding,
+		SchemaVersion:   CurrentSchemaVersion,
+		EventTypes:      DefaultRegistry.Types(),
+	}
+
+	if ms.producer != nil {
+		stats := ms.producer.Stats()
+		report.Producer = &stats
+	}
+
+	// MemoryBus không có broker, chỉ báo cáo lag từ offset trong bộ nhớ
+	if memoryBus, ok := ms.bus.(*MemoryBus); ok {
+		report.Partitions = memoryBus.PartitionCount()
+		for _, topic := range ms.config.Topics {
+			report.Topics = append(report.Topics, TopicHealth{Name: topic.Name, Partitions: memoryBus.PartitionCount()})
+		}
+		if ms.consumer != nil {
+			report.Consumer = &ConsumerHealth{
+				Group:         ms.config.ConsumerGroup,
+				Started:       ms.consumer.started.Load(),
+				SessionActive: ms.consumer.sessionActive.Load(),
+			}
+			for _, topic := range ms.consumer.Topics() {
+				for _, lag := range memoryBus.Lag(ms.config.ConsumerGroup, topic) {
+					report.Consumer.TotalLag += lag.Lag
+					report.Consumer.Partitions = append(report.Consumer.Partitions, lag)
+				}
+			}
+		}
+		return report
+	}
+
+	// Kết quả được ghi vào biến riêng vì fn có thể còn chạy sau khi ctx hết hạn
+	var (
+		brokers  []BrokerHealth
+		topics   []TopicHealth
+		consumer *ConsumerHealth
+	)
+	err := runWithContext(ctx, func() error {
+		client, admin, err := ms.adminClients()
+		if err != nil {
+			return err
+		}
+
+		brokers = checkBrokers(client)
+
+		partitions := make(map[string][]int32, len(ms.config.Topics))
+		for _, topic := range ms.config.Topics {
+			topicPartitions, err := topicPartitions(client, topic.Name)
+			if err != nil {
+				return err
+			}
+			partitions[topic.Name] = topicPartitions
+			topics = append(topics, TopicHealth{Name: topic.Name, Partitions: len(topicPartitions)})
+		}
+
+		if ms.consumer != nil {
+			consumer = ms.consumerLag(client, admin, partitions)
+		}
+		return nil
+	})
+	if err != nil {
+		report.Status = HealthStatusUnhealthy
+		report.Error = err.Error()
+		return report
+	}
+
+	report.Brokers = brokers
+	report.Topics = topics
+	for _, topic := range topics {
+		if topic.Name == ms.config.MessageTopic {
+			report.Partitions = topic.Partitions
+		}
+	}
+	report.Consumer = consumer
+	return report
+}
+
+// CheckConnectivity dùng cho readiness: broker phải reachable và mọi topic phải tồn tại
+func (ms *MessageService) CheckConnectivity(ctx context.Context) error {
+	if _, ok := ms.bus.(*MemoryBus); ok {
+		return nil
+	}
+
+	return runWithContext(ctx, func() error {
+		client, _, err := ms.adminClients()
+		if err != nil {
+			return err
+		}
+		for _, topic := range ms.config.Topics {
+			if _, err := topicPartitions(client, topic.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// adminClients trả về client và cluster admin dùng cho health check, tạo mới nếu chưa có
+func (ms *MessageService) adminClients() (sarama.Client, sarama.ClusterAdmin, error) {
+	ms.adminMu.Lock()
+	defer ms.adminMu.Unlock()
+
+	if ms.admin != nil {
+		return ms.adminClient, ms.admin, nil
+	}
+
+	config := sarama.NewConfig()
+	if err := ms.config.Security.apply(config); err != nil {
+		return nil, nil, err
+	}
+	config.Net.DialTimeout = 5 * time.Second
+	config.Metadata.Retry.Max = 1
+
+	client, err := sarama.NewClient(ms.config.KafkaBrokers, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("không kết nối được Kafka brokers %v: %w", ms.config.KafkaBrokers, err)
+	}
+
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("lỗi tạo Kafka cluster admin: %w", err)
+	}
+
+	ms.adminClient, ms.admin = client, admin
+	return client, admin, nil
+}
+
+// topicPartitions refresh metadata và trả về danh sách partition của topic
+func topicPartitions(client sarama.Client, topic string) ([]int32, error) {
+	if err := client.RefreshMetadata(topic); err != nil {
+		return nil, fmt.Errorf("lỗi lấy metadata topic %s: %w", topic, err)
+	}
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lấy partitions của topic %s: %w", topic, err)
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %s không có partition nào", topic)
+	}
+	return partitions, nil
+}
+
+// checkBrokers kiểm tra kết nối tới từng broker trong metadata
+func checkBrokers(client sarama.Client) []BrokerHealth {
+	brokers := client.Brokers()
+	result := make([]BrokerHealth, 0, len(brokers))
+
+	for _, broker := range brokers {
+		health := BrokerHealth{ID: broker.ID(), Addr: broker.Addr()}
+
+		if connected, _ := broker.Connected(); !connected {
+			if err := broker.Open(client.Config()); err != nil && !errors.Is(err, sarama.ErrAlreadyConnected) {
+				health.Error = err.Error()
+			}
+		}
+		connected, err := broker.Connected()
+		health.Connected = connected
+		if err != nil {
+			health.Error = err.Error()
+		}
+
+		result = append(result, health)
+	}
+	return result
+}
+
+// consumerLag tính lag của consumer group trên từng partition của các topic được consume
+func (ms *MessageService) consumerLag(client sarama.Client, admin sarama.ClusterAdmin, topicPartitions map[string][]int32) *ConsumerHealth {
+	health := &ConsumerHealth{
+		Group:         ms.config.ConsumerGroup,
+		Started:       ms.consumer.started.Load(),
+		SessionActive: ms.consumer.sessionActive.Load(),
+	}
+
+	consumed := make(map[string][]int32)
+	for _, topic := range ms.consumer.Topics() {
+		consumed[topic] = topicPartitions[topic]
+	}
+
+	offsets, err := admin.ListConsumerGroupOffsets(ms.config.ConsumerGroup, consumed)
+	if err != nil {
+		health.Error = fmt.Sprintf("lỗi lấy offsets của group: %v", err)
+		return health
+	}
+
+	for _, topic := range ms.consumer.Topics() {
+		for _, partition := range consumed[topic] {
+			highWaterMark, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				health.Error = fmt.Sprintf("lỗi lấy high water mark %s/%d: %v", topic, partition, err)
+				continue
+			}
+
+			lag := PartitionLag{Topic: topic, Partition: partition, Committed: -1, HighWaterMark: highWaterMark}
+			if block := offsets.GetBlock(topic, partition); block != nil && block.Offset >= 0 {
+				lag.Committed = block.Offset
+				lag.Lag = highWaterMark - block.Offset
+			}
+
+			health.TotalLag += lag.Lag
+			health.Partitions = append(health.Partitions, lag)
+		}
+	}
+	return health
+}
+
+// runWithContext chạy fn và trả về ctx.Err() nếu fn chưa xong khi ctx hết hạn.
+// Các API của sarama không nhận context nên fn có thể tiếp tục chạy nền.
+func runWithContext(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,31 @@
+package kafka
+
+import (
+	"vibeta/internal/logging"
+)
+
+// recordAttrs các field log mô tả một record, kèm lỗi nếu có
+func recordAttrs(record *Record, err error) []any {
+	attrs := []any{
+		logging.KeyTopic, record.Topic,
+		"partition", record.Partition,
+		"offset", record.Offset,
+	}
+	if err != nil {
+		attrs = append(attrs, logging.Err(err))
+	}
+	return attrs
+}
+
+// eventAttrs các field log mô tả một event, kèm lỗi nếu có
+func eventAttrs(event *Event, err error) []any {
+	attrs := []any{
+		"event_id", event.ID,
+		logging.KeyEventType, event.Type,
+		logging.KeyConversationID, event.ConversationID,
+	}
+	if err != nil {
+		attrs = append(attrs, logging.Err(err))
+	}
+	return attrs
+}
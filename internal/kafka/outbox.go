@@ -0,0 +1,284 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+	"vibeta/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OutboxRelay ghi event vào bảng outbox khi không publish trực tiếp được
+// và định kỳ publish các outbox event đó vào Kafka khi broker sẵn sàng.
+//
+// Relay đảm bảo at-least-once: nếu bị dừng giữa lúc publish và đánh dấu,
+// event có thể được gửi lại với cùng event_id. MessageProcessor ghi event_id đã xử lý
+// vào processed_events (giữ 7 ngày) và bỏ qua event trùng; worker bị dừng giữa lúc
+// xử lý và ghi event_id vẫn có thể xử lý lại event một lần.
+type OutboxRelay struct {
+	outbox db.OutboxRepository
+	config *ServiceConfig
+	codec  Codec
+
+	mu       sync.Mutex
+	producer *Producer
+}
+
+// NewOutboxRelay tạo relay mới. Kafka producer được kết nối lazily trong Run,
+// vì vậy relay vẫn dùng được để ghi outbox khi Kafka chưa sẵn sàng.
+func NewOutboxRelay(outbox db.OutboxRepository, config *ServiceConfig) (*OutboxRelay, error) {
+	codec, err := CodecFor(config.EventEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxRelay{
+		outbox: outbox,
+		config: config,
+		codec:  codec,
+	}, nil
+}
+
+// SaveMessage lưu tin nhắn cùng event "message" tương ứng trong một transaction.
+// Trace context của ctx được lưu cùng headers để relay publish tiếp trace.
+func (r *OutboxRelay) SaveMessage(ctx context.Context, message *models.Message) error {
+	event := NewEvent(message.ConversationID, &ChatMessagePayload{
+		MessageID:   message.ID,
+		SenderID:    message.SenderID,
+		Content:     message.Content,
+		MessageType: string(message.Type),
+	})
+	event.Timestamp = message.CreatedAt
+
+	outboxEvent, err := r.NewOutboxEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+	return r.outbox.SaveWithMessage(ctx, message, outboxEvent)
+}
+
+// Enqueue ghi một event vào outbox để relay publish sau
+func (r *OutboxRelay) Enqueue(ctx context.Context, event *Event) error {
+	outboxEvent, err := r.NewOutboxEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+	return r.outbox.Save(ctx, outboxEvent)
+}
+
+// NewOutboxEvent encode event giống hệt record mà producer sẽ gửi, để lưu vào outbox
+// cùng transaction với dữ liệu nghiệp vụ của repository khác
+func (r *OutboxRelay) NewOutboxEvent(ctx context.Context, event *Event) (*models.OutboxEvent, error) {
+	if !DefaultRegistry.IsRegistered(event.Type) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, event.Type)
+	}
+	if err := event.validate(); err != nil {
+		return nil, err
+	}
+
+	value, err := r.codec.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+
+	eventHeaders := recordHeaders(event, r.codec)
+	tracing.Inject(ctx, headerCarrier{&eventHeaders})
+
+	headers, err := json.Marshal(eventHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutboxEvent{
+		EventID:        event.ID,
+		EventType:      string(event.Type),
+		ConversationID: event.ConversationID,
+		PartitionKey:   event.partitionKey(),
+		Payload:        value,
+		Headers:        string(headers),
+		CreatedAt:      event.Timestamp,
+	}, nil
+}
+
+// Run chạy vòng lặp relay cho đến khi ctx bị hủy
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.OutboxPollInterval)
+	defer ticker.Stop()
+
+	slog.Info("Outbox relay đã khởi động",
+		"poll_interval", r.config.OutboxPollInterval, "batch_size", r.config.OutboxBatchSize,
+		"max_attempts", r.config.OutboxMaxAttempts)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.relayPending(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Lỗi relay outbox", logging.Err(err))
+			}
+		}
+	}
+}
+
+// relayPending claim và publish các outbox event đang chờ cho đến khi hết hoặc gặp lỗi.
+// Event được publish ngoài transaction của database; event publish lỗi được hẹn thử lại
+// với backoff và chuyển sang dead-letter sau OutboxMaxAttempts lần lỗi. Batch dừng ở event
+// lỗi đầu tiên để khi broker gặp sự cố chỉ một event bị tính thêm một lần thử.
+func (r *OutboxRelay) relayPending(ctx context.Context) error {
+	producer, err := r.getProducer()
+	if err != nil {
+		// Kafka chưa sẵn sàng, thử lại ở lần poll sau
+		return nil
+	}
+
+	for {
+		events, err := r.outbox.ClaimBatch(ctx, r.config.OutboxBatchSize, r.config.OutboxLease)
+		if err != nil {
+			return fmt.Errorf("lỗi claim outbox event: %w", err)
+		}
+
+		for i := range events {
+			event := &events[i]
+			if err := r.publish(producer, event); err != nil {
+				r.release(events[i+1:])
+				return r.markFailed(event, err)
+			}
+			if err := r.outbox.MarkPublished(context.WithoutCancel(ctx), event.ID); err != nil {
+				r.release(events[i+1:])
+				return fmt.Errorf("lỗi đánh dấu outbox event %s đã publish: %w", event.EventID, err)
+			}
+		}
+
+		if len(events) > 0 {
+			slog.Debug("Outbox relay đã publish events", "count", len(events))
+		}
+		if len(events) < r.config.OutboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// publish gửi một outbox event vào topic theo event type, tiếp tục trace đã lưu trong headers
+func (r *OutboxRelay) publish(producer *Producer, event *models.OutboxEvent) error {
+	var headers []RecordHeader
+	if err := json.Unmarshal([]byte(event.Headers), &headers); err != nil {
+		return fmt.Errorf("lỗi parse headers của outbox event %s: %w", event.EventID, err)
+	}
+
+	record := &Record{
+		Topic:     producer.TopicFor(EventType(event.EventType)),
+		Key:       []byte(event.PartitionKey),
+		Value:     event.Payload,
+		Headers:   headers,
+		Timestamp: event.CreatedAt,
+	}
+
+	// Tiếp tục trace đã lưu trong headers lúc ghi outbox
+	ctx := tracing.Extract(context.Background(), headerCarrier{&record.Headers})
+	ctx, span := startPublishSpan(ctx, record, nil)
+	span.SetAttributes(attribute.String("event.id", event.EventID), attribute.Bool("outbox", true))
+	defer span.End()
+
+	partition, offset, err := producer.publishRecord(ctx, record)
+	recordSpanResult(span, partition, offset, err)
+	return err
+}
+
+// markFailed ghi lỗi publish của event: hẹn thử lại sau backoff, hoặc chuyển sang
+// dead-letter khi đã đủ OutboxMaxAttempts lần lỗi. Trả về lỗi publish cho người gọi.
+func (r *OutboxRelay) markFailed(event *models.OutboxEvent, publishErr error) error {
+	// Ghi trạng thái cả khi ctx bị hủy giữa lúc publish, để event không giữ lease tới hết hạn
+	ctx := context.Background()
+	attempts := event.Attempts + 1
+	publishErr = fmt.Errorf("lỗi publish outbox event %s (lần %d): %w", event.EventID, attempts, publishErr)
+
+	if attempts >= r.config.OutboxMaxAttempts {
+		if err := r.outbox.MarkDeadLettered(ctx, event.ID, publishErr.Error()); err != nil {
+			return errors.Join(publishErr, err)
+		}
+		slog.Error("Outbox event chuyển sang dead-letter sau quá số lần thử",
+			"event_id", event.EventID, logging.KeyEventType, event.EventType,
+			"attempts", attempts, logging.Err(publishErr))
+		return publishErr
+	}
+
+	if err := r.outbox.MarkFailed(ctx, event.ID, publishErr.Error(), time.Now().Add(r.retryBackoff(attempts))); err != nil {
+		return errors.Join(publishErr, err)
+	}
+	return publishErr
+}
+
+// retryBackoff thời gian chờ sau lần lỗi thứ attempts: OutboxRetryBackoff nhân đôi sau
+// mỗi lần lỗi, tối đa OutboxMaxRetryBackoff
+func (r *OutboxRelay) retryBackoff(attempts int) time.Duration {
+	backoff := r.config.OutboxRetryBackoff
+	for i := 1; i < attempts && backoff < r.config.OutboxMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.config.OutboxMaxRetryBackoff)
+}
+
+// release trả lease của các event đã claim nhưng chưa publish để lần poll sau claim lại
+func (r *OutboxRelay) release(events []models.OutboxEvent) {
+	if len(events) == 0 {
+		return
+	}
+	ids := make([]uint, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+	if err := r.outbox.Release(context.Background(), ids); err != nil {
+		slog.Warn("Lỗi trả lease outbox event, event được claim lại khi lease hết hạn", logging.Err(err))
+	}
+}
+
+// getProducer trả về producer của relay, kết nối Kafka nếu chưa có
+func (r *OutboxRelay) getProducer() (*Producer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.producer != nil {
+		return r.producer, nil
+	}
+
+	producerConfig := &ProducerConfig{
+		Brokers:  r.config.KafkaBrokers,
+		Topic:    r.config.MessageTopic,
+		Routes:   r.config.Routes,
+		Encoding: r.config.EventEncoding,
+		Security: r.config.Security,
+	}
+	if r.config.Bus == BusMemory {
+		producerConfig.Bus = SharedMemoryBus()
+	}
+
+	producer, err := NewProducer(producerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Outbox relay đã kết nối Kafka")
+	r.producer = producer
+	return producer, nil
+}
+
+// Close đóng producer của relay
+func (r *OutboxRelay) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.producer == nil {
+		return nil
+	}
+	return r.producer.Close()
+}
@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/models"
+)
+
+var errRejected = errors.New("broker từ chối record")
+
+// rejectingBus là MemoryBus từ chối publish record mà reject trả về true
+type rejectingBus struct {
+	*MemoryBus
+	reject func(record *Record) bool
+}
+
+func (b *rejectingBus) Publish(ctx context.Context, record *Record) (int32, int64, error) {
+	if b.reject(record) {
+		return 0, 0, errRejected
+	}
+	return b.MemoryBus.Publish(ctx, record)
+}
+
+// newTestRelay dựng relay trên memory store, publish qua bus một partition
+// từ chối các record mà reject trả về true
+func newTestRelay(t *testing.T, config *ServiceConfig, reject func(record *Record) bool) (*OutboxRelay, *MemoryBus) {
+	t.Helper()
+
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 1})
+	t.Cleanup(func() { bus.Close() })
+	producer, err := NewProducer(&ProducerConfig{Topic: testTopic, Bus: &rejectingBus{MemoryBus: bus, reject: reject}})
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+
+	config.Bus = BusMemory
+	config.EventEncoding = EncodingJSON
+	relay, err := NewOutboxRelay(db.NewMemoryStore().Outbox, config)
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	relay.producer = producer
+	t.Cleanup(func() { relay.Close() })
+	return relay, bus
+}
+
+// enqueue ghi event có payload vào outbox, partition_key là message_id của payload
+func enqueue(t *testing.T, relay *OutboxRelay, payload EventPayload) {
+	t.Helper()
+	if err := relay.Enqueue(context.Background(), NewEvent(testConversation, payload)); err != nil {
+		t.Fatalf("Enqueue %s: %v", payload.EventType(), err)
+	}
+}
+
+func chatMessage(messageID string) *ChatMessagePayload {
+	return &ChatMessagePayload{
+		MessageID:   messageID,
+		SenderID:    "alice",
+		Content:     "xin chào",
+		MessageType: string(models.MessageTypeText),
+	}
+}
+
+// publishedKeys trả về key của các record relay đã publish, theo thứ tự
+func publishedKeys(bus *MemoryBus) []string {
+	var keys []string
+	for _, record := range bus.Records(testTopic, 0) {
+		keys = append(keys, string(record.Key))
+	}
+	return keys
+}
+
+func TestOutboxRelayBacksOffFailedEvent(t *testing.T) {
+	relay, bus := newTestRelay(t, &ServiceConfig{
+		OutboxBatchSize:       10,
+		OutboxLease:           time.Minute,
+		OutboxRetryBackoff:    time.Hour,
+		OutboxMaxRetryBackoff: time.Hour,
+		OutboxMaxAttempts:     3,
+	}, func(record *Record) bool { return string(record.Key) == "msg_poison" })
+
+	enqueue(t, relay, chatMessage("msg_poison"))
+	enqueue(t, relay, &MessageEditedPayload{MessageID: "msg_poison", EditorID: "alice", Content: "đã sửa"})
+	enqueue(t, relay, chatMessage("msg_a"))
+	enqueue(t, relay, chatMessage("msg_b"))
+
+	// Lỗi publish được trả về và batch dừng ở event lỗi
+	if err := relay.relayPending(context.Background()); !errors.Is(err, errRejected) {
+		t.Fatalf("relayPending lần đầu = %v, muốn %v", err, errRejected)
+	}
+	if keys := publishedKeys(bus); len(keys) != 0 {
+		t.Fatalf("đã publish %v, muốn batch dừng ở event lỗi", keys)
+	}
+
+	// Event lỗi chờ backoff và chặn event sau cùng partition_key, partition khác vẫn được publish
+	if err := relay.relayPending(context.Background()); err != nil {
+		t.Fatalf("relayPending lần hai: %v", err)
+	}
+	if keys := publishedKeys(bus); len(keys) != 2 || keys[0] != "msg_a" || keys[1] != "msg_b" {
+		t.Fatalf("đã publish %v, muốn [msg_a msg_b]", keys)
+	}
+}
+
+func TestOutboxRelayDeadLettersAfterMaxAttempts(t *testing.T) {
+	relay, bus := newTestRelay(t, &ServiceConfig{
+		OutboxBatchSize:       10,
+		OutboxLease:           time.Minute,
+		OutboxRetryBackoff:    time.Nanosecond,
+		OutboxMaxRetryBackoff: time.Nanosecond,
+		OutboxMaxAttempts:     3,
+	}, func(record *Record) bool { return record.HeaderMap()[HeaderEventType] == string(EventTypeMessage) })
+
+	enqueue(t, relay, chatMessage("msg_poison"))
+	enqueue(t, relay, &MessageEditedPayload{MessageID: "msg_poison", EditorID: "alice", Content: "đã sửa"})
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := relay.relayPending(context.Background()); !errors.Is(err, errRejected) {
+			t.Fatalf("relayPending lần %d = %v, muốn %v", attempt, err, errRejected)
+		}
+	}
+
+	// Event lỗi đã chuyển sang dead-letter, event sau cùng partition_key được publish
+	if err := relay.relayPending(context.Background()); err != nil {
+		t.Fatalf("relayPending sau dead-letter: %v", err)
+	}
+	records := bus.Records(testTopic, 0)
+	if len(records) != 1 || records[0].HeaderMap()[HeaderEventType] != string(EventTypeMessageEdited) {
+		t.Fatalf("đã publish %v, muốn event message_edited của msg_poison", publishedKeys(bus))
+	}
+	if events, err := relay.outbox.ClaimBatch(context.Background(), 10, time.Minute); err != nil || len(events) != 0 {
+		t.Errorf("ClaimBatch sau khi relay xong = %d event (err %v), muốn 0", len(events), err)
+	}
+}
+
+func TestOutboxRetryBackoff(t *testing.T) {
+	relay := &OutboxRelay{config: &ServiceConfig{
+		OutboxRetryBackoff:    time.Second,
+		OutboxMaxRetryBackoff: time.Minute,
+	}}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 7, want: time.Minute},
+		{attempts: 100, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := relay.retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %s, muốn %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -1,132 +1,348 @@
 package kafka
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"vibeta/internal/logging"
+	"vibeta/internal/metrics"
 	"vibeta/internal/models"
+	"vibeta/internal/tracing"
+)
 
-	"github.com/IBM/sarama"
+// Các chế độ gửi của producer
+const (
+	ProducerModeSync  = "sync"
+	ProducerModeAsync = "async"
 )
 
+// ErrProducerBufferFull được trả về khi số message đang chờ xác nhận đã đạt MaxInFlight
+var ErrProducerBufferFull = errors.New("kafka producer buffer đầy")
+
 // Producer cung cấp interface để gửi messages vào Kafka
 type Producer struct {
-	producer sarama.SyncProducer
+	bus      MessageBus
+	ownsBus  bool
 	config   *ProducerConfig
+	codec    Codec
+	registry *EventRegistry
+	router   *TopicRouter
+
+	// inFlight giới hạn số message async đang chờ broker xác nhận
+	inFlight chan struct{}
+	metrics  producerMetrics
 }
 
 // ProducerConfig cấu hình cho Kafka producer
 type ProducerConfig struct {
-	Brokers []string
-	Topic   string
+	Brokers     []string
+	Topic       string // Topic mặc định cho event type không có trong Routes
+	Routes      map[EventType]string
+	Encoding    string          // "json" (mặc định) hoặc "protobuf"
+	Registry    *EventRegistry  // nil sẽ dùng DefaultRegistry
+	Mode        string          // "sync" (mặc định) hoặc "async"
+	MaxInFlight int             // Số message async tối đa chờ xác nhận
+	Security    *SecurityConfig // TLS/SASL/client ID khi producer tự tạo bus
+
+	// Bus dùng để publish; nil sẽ tạo SaramaBus từ Brokers và Mode.
+	// Producer chỉ đóng bus do chính nó tạo.
+	Bus MessageBus
+}
+
+// DeliveryResult kết quả gửi một event, được trả về qua DeliveryCallback
+type DeliveryResult struct {
+	Event     *Event
+	Partition int32
+	Offset    int64
+	Latency   time.Duration
+	Err       error
 }
 
-// MessageEvent định nghĩa cấu trúc message sẽ được gửi qua Kafka
-type MessageEvent struct {
-	Type           string                 `json:"type"`
-	MessageID      string                 `json:"message_id"`
-	ConversationID string                 `json:"conversation_id"`
-	SenderID       string                 `json:"sender_id"`
-	Content        string                 `json:"content"`
-	MessageType    string                 `json:"message_type"`
-	Reactions      map[string][]string    `json:"reactions,omitempty"`
-	Metadata       map[string]interface{} `json:"metadata,omitempty"`
-	Timestamp      time.Time              `json:"timestamp"`
+// DeliveryCallback được gọi khi broker xác nhận hoặc từ chối một event.
+// Ở chế độ async callback chạy trên goroutine của producer nên không được block lâu.
+type DeliveryCallback func(result DeliveryResult)
+
+// producerMetrics các bộ đếm của producer
+type producerMetrics struct {
+	enqueued     atomic.Int64
+	delivered    atomic.Int64
+	failed       atomic.Int64
+	rejected     atomic.Int64
+	totalLatency atomic.Int64 // nanoseconds của các message đã delivered
+	lastError    atomic.Value // string
+}
+
+// ProducerStats snapshot metrics của producer
+type ProducerStats struct {
+	Mode         string  `json:"mode"`
+	Enqueued     int64   `json:"enqueued"`
+	Delivered    int64   `json:"delivered"`
+	Failed       int64   `json:"failed"`
+	Rejected     int64   `json:"rejected"`
+	InFlight     int     `json:"in_flight"`
+	MaxInFlight  int     `json:"max_in_flight"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	LastError    string  `json:"last_error,omitempty"`
 }
 
 // NewProducer tạo một Kafka producer mới
 func NewProducer(config *ProducerConfig) (*Producer, error) {
-	// Cấu hình Sarama
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll // Đợi confirmation từ tất cả replicas
-	saramaConfig.Producer.Retry.Max = 3                    // Retry tối đa 3 lần
-	saramaConfig.Producer.Return.Successes = true
-	saramaConfig.Producer.Return.Errors = true
-
-	// Cải thiện performance và reliability
-	saramaConfig.Producer.Flush.Frequency = 100 * time.Millisecond
-	saramaConfig.Producer.Flush.Messages = 100
-	saramaConfig.Producer.MaxMessageBytes = 1000000
-
-	// Compression để giảm network traffic
-	saramaConfig.Producer.Compression = sarama.CompressionSnappy
-
-	producer, err := sarama.NewSyncProducer(config.Brokers, saramaConfig)
+	codec, err := CodecFor(config.Encoding)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Producer{
-		producer: producer,
+	registry := config.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	switch config.Mode {
+	case "", ProducerModeSync:
+	case ProducerModeAsync:
+		if config.MaxInFlight <= 0 {
+			return nil, fmt.Errorf("MaxInFlight phải lớn hơn 0 ở chế độ async, nhận %d", config.MaxInFlight)
+		}
+	default:
+		return nil, fmt.Errorf("producer mode không hỗ trợ: %q (hỗ trợ %q, %q)", config.Mode, ProducerModeSync, ProducerModeAsync)
+	}
+
+	p := &Producer{
+		bus:      config.Bus,
 		config:   config,
-	}, nil
+		codec:    codec,
+		registry: registry,
+		router:   NewTopicRouter(config.Topic, config.Routes),
+	}
+
+	if p.bus == nil {
+		bus, err := NewSaramaBus(&SaramaBusConfig{
+			Brokers:     config.Brokers,
+			Async:       config.Mode == ProducerModeAsync,
+			MaxInFlight: config.MaxInFlight,
+			Security:    config.Security,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.bus = bus
+		p.ownsBus = true
+	}
+
+	if config.Mode == ProducerModeAsync {
+		p.inFlight = make(chan struct{}, config.MaxInFlight)
+	}
+
+	return p, nil
 }
 
-// PublishMessage gửi một message event vào Kafka queue
-func (p *Producer) PublishMessage(event *MessageEvent) error {
-	// Serialize message event thành JSON
-	messageBytes, err := json.Marshal(event)
+// Publish gửi một event envelope vào Kafka queue và đợi broker xác nhận.
+// Event phải thuộc loại đã đăng ký trong registry của producer.
+// Trace context của ctx được ghi vào headers của record.
+func (p *Producer) Publish(ctx context.Context, event *Event) error {
+	record, err := p.newEventRecord(event)
 	if err != nil {
 		return err
 	}
 
-	// Tạo Kafka message
-	msg := &sarama.ProducerMessage{
-		Topic: p.config.Topic,
-		Key:   sarama.StringEncoder(event.MessageID), // Sử dụng message_id làm key để partition
-		Value: sarama.ByteEncoder(messageBytes),
-		Headers: []sarama.RecordHeader{
-			{
-				Key:   []byte("event_type"),
-				Value: []byte(event.Type),
-			},
-			{
-				Key:   []byte("conversation_id"),
-				Value: []byte(event.ConversationID),
-			},
-		},
-		Timestamp: event.Timestamp,
-	}
+	ctx, span := startPublishSpan(ctx, record, event)
+	defer span.End()
 
-	// Gửi message
-	partition, offset, err := p.producer.SendMessage(msg)
+	partition, offset, err := p.publishRecord(ctx, record)
+	recordSpanResult(span, partition, offset, err)
 	if err != nil {
-		log.Printf("Lỗi gửi message vào Kafka: %v", err)
+		slog.ErrorContext(ctx, "Lỗi gửi event vào Kafka", eventAttrs(event, err)...)
 		return err
 	}
 
-	log.Printf("Message %s được gửi thành công vào partition %d với offset %d",
-		event.MessageID, partition, offset)
+	slog.DebugContext(ctx, "Event được gửi thành công", append(eventAttrs(event, nil),
+		"partition", partition, "offset", offset)...)
 	return nil
 }
 
-// PublishReaction gửi một reaction event vào Kafka queue
-func (p *Producer) PublishReaction(messageID, userID, emoji, action string, conversationID string) error {
-	event := &MessageEvent{
-		Type:           "reaction",
-		MessageID:      messageID,
-		ConversationID: conversationID,
-		SenderID:       userID,
-		MessageType:    "reaction",
-		Metadata: map[string]interface{}{
-			"emoji":  emoji,
-			"action": action, // "add" hoặc "remove"
-		},
-		Timestamp: time.Now(),
+// PublishAsync gửi event mà không đợi broker xác nhận; kết quả được trả về qua callback.
+// Trả về ErrProducerBufferFull ngay lập tức nếu đã có MaxInFlight message đang chờ.
+// Ở chế độ sync, event được gửi đồng bộ và callback được gọi trước khi hàm trả về.
+func (p *Producer) PublishAsync(ctx context.Context, event *Event, callback DeliveryCallback) error {
+	record, err := p.newEventRecord(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := startPublishSpan(ctx, record, event)
+
+	if p.inFlight == nil {
+		start := time.Now()
+		partition, offset, err := p.publishRecord(ctx, record)
+		recordSpanResult(span, partition, offset, err)
+		span.End()
+		if callback != nil {
+			callback(DeliveryResult{Event: event, Partition: partition, Offset: offset, Latency: time.Since(start), Err: err})
+		}
+		return nil
+	}
+
+	select {
+	case p.inFlight <- struct{}{}:
+	default:
+		p.metrics.rejected.Add(1)
+		tracing.RecordError(span, ErrProducerBufferFull)
+		span.End()
+		return ErrProducerBufferFull
+	}
+
+	start := time.Now()
+	p.metrics.enqueued.Add(1)
+	return p.bus.PublishAsync(record, func(record *Record, err error) {
+		<-p.inFlight
+		latency := time.Since(start)
+		p.recordResult(record.Topic, latency, err)
+		recordSpanResult(span, record.Partition, record.Offset, err)
+		span.End()
+		if err != nil {
+			slog.ErrorContext(ctx, "Lỗi gửi event vào Kafka", eventAttrs(event, err)...)
+		}
+
+		if callback != nil {
+			callback(DeliveryResult{
+				Event:     event,
+				Partition: record.Partition,
+				Offset:    record.Offset,
+				Latency:   latency,
+				Err:       err,
+			})
+		}
+	})
+}
+
+// newEventRecord validate và encode event thành record của topic
+func (p *Producer) newEventRecord(event *Event) (*Record, error) {
+	if !p.registry.IsRegistered(event.Type) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, event.Type)
+	}
+	if err := event.validate(); err != nil {
+		return nil, err
+	}
+
+	// Serialize envelope theo encoding đã cấu hình
+	messageBytes, err := p.codec.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Topic:     p.router.TopicFor(event.Type),
+		Key:       []byte(event.partitionKey()),
+		Value:     messageBytes,
+		Headers:   recordHeaders(event, p.codec),
+		Timestamp: event.Timestamp,
+	}, nil
+}
+
+// TopicFor trả về topic mà producer gửi event type tới
+func (p *Producer) TopicFor(eventType EventType) string {
+	return p.router.TopicFor(eventType)
+}
+
+// publishRecord gửi record và đợi xác nhận.
+// Topic rỗng sẽ dùng topic mặc định của producer.
+func (p *Producer) publishRecord(ctx context.Context, record *Record) (int32, int64, error) {
+	if record.Topic == "" {
+		record.Topic = p.config.Topic
+	}
+
+	if p.inFlight != nil {
+		p.inFlight <- struct{}{}
+		defer func() { <-p.inFlight }()
+	}
+
+	start := time.Now()
+	p.metrics.enqueued.Add(1)
+	partition, offset, err := p.bus.Publish(ctx, record)
+	p.recordResult(record.Topic, time.Since(start), err)
+	return partition, offset, err
+}
+
+// recordResult cập nhật metrics sau khi broker trả kết quả
+func (p *Producer) recordResult(topic string, latency time.Duration, err error) {
+	metrics.ObservePublish(topic, latency.Seconds(), err)
+	if err != nil {
+		p.metrics.failed.Add(1)
+		p.metrics.lastError.Store(err.Error())
+		return
+	}
+	p.metrics.delivered.Add(1)
+	p.metrics.totalLatency.Add(int64(latency))
+}
+
+// Stats trả về snapshot metrics của producer
+func (p *Producer) Stats() ProducerStats {
+	stats := ProducerStats{
+		Mode:        ProducerModeSync,
+		Enqueued:    p.metrics.enqueued.Load(),
+		Delivered:   p.metrics.delivered.Load(),
+		Failed:      p.metrics.failed.Load(),
+		Rejected:    p.metrics.rejected.Load(),
+		MaxInFlight: cap(p.inFlight),
+		InFlight:    len(p.inFlight),
+	}
+	if p.inFlight != nil {
+		stats.Mode = ProducerModeAsync
 	}
+	if stats.Delivered > 0 {
+		stats.AvgLatencyMs = float64(p.metrics.totalLatency.Load()) / float64(stats.Delivered) / float64(time.Millisecond)
+	}
+	if lastError, ok := p.metrics.lastError.Load().(string); ok {
+		stats.LastError = lastError
+	}
+	return stats
+}
+
+// PublishReaction gửi một reaction event vào Kafka queue
+func (p *Producer) PublishReaction(ctx context.Context, messageID, userID, emoji, action string, conversationID string) error {
+	event := NewEvent(conversationID, &ReactionPayload{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Action:    action,
+	})
 
-	return p.PublishMessage(event)
+	return p.Publish(ctx, event)
 }
 
 // PublishChatMessage gửi một chat message vào Kafka queue
-func (p *Producer) PublishChatMessage(wsMsg models.WebSocketMessage, userID string) error {
-	data, ok := wsMsg.Data.(map[string]interface{})
+func (p *Producer) PublishChatMessage(ctx context.Context, wsMsg models.WebSocketMessage, userID string) error {
+	event, ok := newChatMessageEvent(wsMsg, userID)
 	if !ok {
-		log.Printf("Lỗi parse message data")
+		slog.WarnContext(ctx, "Lỗi parse message data", logging.KeyUserID, userID)
 		return nil // Không return error để không block WebSocket
 	}
 
+	return p.Publish(ctx, event)
+}
+
+// PublishChatMessageAsync gửi chat message mà không block người gọi,
+// kết quả delivery được trả về qua callback
+func (p *Producer) PublishChatMessageAsync(ctx context.Context, wsMsg models.WebSocketMessage, userID string, callback DeliveryCallback) error {
+	event, ok := newChatMessageEvent(wsMsg, userID)
+	if !ok {
+		return fmt.Errorf("message data không hợp lệ")
+	}
+
+	return p.PublishAsync(ctx, event, callback)
+}
+
+// newChatMessageEvent tạo event "message" từ tin nhắn WebSocket
+func newChatMessageEvent(wsMsg models.WebSocketMessage, userID string) (*Event, bool) {
+	data, ok := wsMsg.Data.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
 	content, _ := data["content"].(string)
 	messageType, _ := data["type"].(string)
 	messageID, _ := data["message_id"].(string)
@@ -136,22 +352,21 @@ func (p *Producer) PublishChatMessage(wsMsg models.WebSocketMessage, userID stri
 		messageID = generateMessageID(userID)
 	}
 
-	event := &MessageEvent{
-		Type:           "message",
-		MessageID:      messageID,
-		ConversationID: wsMsg.ConvID,
-		SenderID:       userID,
-		Content:        content,
-		MessageType:    messageType,
-		Timestamp:      time.Now(),
-	}
-
-	return p.PublishMessage(event)
+	return NewEvent(wsMsg.ConvID, &ChatMessagePayload{
+		MessageID:   messageID,
+		SenderID:    userID,
+		Content:     content,
+		MessageType: messageType,
+	}), true
 }
 
-// Close đóng Kafka producer
+// Close đóng Kafka producer.
+// Ở chế độ async sẽ flush các message còn trong buffer và đợi callback của chúng.
 func (p *Producer) Close() error {
-	return p.producer.Close()
+	if !p.ownsBus {
+		return nil
+	}
+	return p.bus.Close()
 }
 
 // Helper function để tạo message ID
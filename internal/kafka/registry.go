@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownEventType được trả về khi event type chưa được đăng ký
+var ErrUnknownEventType = errors.New("event type chưa được đăng ký")
+
+// EventRegistry lưu danh sách các event type hợp lệ và payload tương ứng.
+// Producer dùng registry để từ chối event lạ, consumer dùng để decode payload.
+type EventRegistry struct {
+	mu        sync.RWMutex
+	factories map[EventType]func() EventPayload
+}
+
+// DefaultRegistry chứa các event type mà hệ thống chat hỗ trợ
+var DefaultRegistry = newDefaultRegistry()
+
+// NewEventRegistry tạo một registry rỗng
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		factories: make(map[EventType]func() EventPayload),
+	}
+}
+
+func newDefaultRegistry() *EventRegistry {
+	registry := NewEventRegistry()
+	registry.Register(EventTypeMessage, func() EventPayload { return &ChatMessagePayload{} })
+	registry.Register(EventTypeReaction, func() EventPayload { return &ReactionPayload{} })
+	registry.Register(EventTypeMessageEdited, func() EventPayload { return &MessageEditedPayload{} })
+	registry.Register(EventTypeMessageDeleted, func() EventPayload { return &MessageDeletedPayload{} })
+	registry.Register(EventTypeUserErased, func() EventPayload { return &UserErasedPayload{} })
+	registry.Register(EventTypeAuditLogged, func() EventPayload { return &AuditLoggedPayload{} })
+	return registry
+}
+
+// Register đăng ký một event type cùng factory tạo payload rỗng
+func (r *EventRegistry) Register(eventType EventType, factory func() EventPayload) {
+	if payload := factory(); payload.EventType() != eventType {
+		panic(fmt.Sprintf("kafka: payload của event type %q trả về type %q", eventType, payload.EventType()))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[eventType] = factory
+}
+
+// IsRegistered kiểm tra event type đã được đăng ký chưa
+func (r *EventRegistry) IsRegistered(eventType EventType) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.factories[eventType]
+	return ok
+}
+
+// NewPayload tạo payload rỗng cho event type để decode vào
+func (r *EventRegistry) NewPayload(eventType EventType) (EventPayload, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[eventType]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+	}
+	return factory(), nil
+}
+
+// Types trả về danh sách event type đã đăng ký, sắp xếp theo tên
+func (r *EventRegistry) Types() []EventType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]EventType, 0, len(r.factories))
+	for eventType := range r.factories {
+		types = append(types, eventType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
@@ -0,0 +1,313 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+
+	"github.com/IBM/sarama"
+)
+
+// ReplayConfig cấu hình cho việc đọc lại một topic.
+// Khoảng đọc được giới hạn bởi offset hoặc timestamp (không dùng cả hai cùng lúc);
+// mặc định từ đầu topic tới high water mark lúc bắt đầu replay.
+type ReplayConfig struct {
+	Brokers    []string
+	Security   *SecurityConfig
+	Topic      string
+	Partitions []int32 // rỗng sẽ replay tất cả partition
+
+	StartOffset int64 // offset bắt đầu áp dụng cho mọi partition; 0 hoặc OffsetOldest là đầu partition
+	EndOffset   int64 // offset kết thúc (không bao gồm); 0 hoặc OffsetNewest là high water mark
+	StartTime   time.Time
+	EndTime     time.Time
+
+	ConversationID string // chỉ replay event của conversation này nếu khác rỗng
+	DryRun         bool   // chỉ đọc và decode, không ghi database
+
+	Registry *EventRegistry // nil sẽ dùng DefaultRegistry
+
+	// Progress được gọi định kỳ mỗi ProgressInterval và một lần khi kết thúc
+	ProgressInterval time.Duration
+	Progress         func(ReplayStats)
+}
+
+// ReplayStats thống kê tiến độ replay
+type ReplayStats struct {
+	Total         int64 `json:"total"` // Số record trong khoảng cần đọc
+	Read          int64 `json:"read"`
+	Processed     int64 `json:"processed"`
+	Filtered      int64 `json:"filtered"`
+	DecodeErrors  int64 `json:"decode_errors"`
+	ProcessErrors int64 `json:"process_errors"`
+}
+
+// replayRange khoảng offset [start, end) cần đọc trên một partition
+type replayRange struct {
+	partition  int32
+	start, end int64
+}
+
+// Replayer đọc lại các event trong topic và chạy chúng qua MessageProcessor
+type Replayer struct {
+	config    *ReplayConfig
+	client    sarama.Client
+	registry  *EventRegistry
+	processor *MessageProcessor
+
+	total, read, processed, filtered, decodeErrors, processErrors atomic.Int64
+}
+
+// NewReplayer kết nối Kafka và tạo replayer. store có thể nil khi DryRun.
+func NewReplayer(config *ReplayConfig, store *db.Store) (*Replayer, error) {
+	if config.Topic == "" {
+		return nil, fmt.Errorf("topic không được để trống")
+	}
+	hasTimeRange := !config.StartTime.IsZero() || !config.EndTime.IsZero()
+	if hasTimeRange && (config.StartOffset > 0 || config.EndOffset > 0) {
+		return nil, fmt.Errorf("không thể dùng đồng thời khoảng offset và khoảng thời gian")
+	}
+	if !config.StartTime.IsZero() && !config.EndTime.IsZero() && !config.EndTime.After(config.StartTime) {
+		return nil, fmt.Errorf("thời điểm kết thúc (%v) phải sau thời điểm bắt đầu (%v)", config.EndTime, config.StartTime)
+	}
+	if !config.DryRun && store == nil {
+		return nil, fmt.Errorf("cần database đích khi không chạy dry-run")
+	}
+	if config.EndOffset == 0 {
+		config.EndOffset = OffsetNewest
+	}
+
+	registry := config.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	saramaConfig, err := newSaramaConfig(config.Security)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(config.Brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("không kết nối được Kafka brokers %v: %w", config.Brokers, err)
+	}
+
+	r := &Replayer{
+		config:   config,
+		client:   client,
+		registry: registry,
+	}
+	if !config.DryRun {
+		r.processor = NewMessageProcessor(store, registry)
+	}
+	return r, nil
+}
+
+// Run replay các partition lần lượt theo thứ tự offset cho đến khi hết khoảng đọc
+// hoặc ctx bị hủy. Lỗi decode/xử lý từng event chỉ được đếm, không dừng replay.
+func (r *Replayer) Run(ctx context.Context) (ReplayStats, error) {
+	ranges, err := r.resolveRanges()
+	if err != nil {
+		return r.Stats(), err
+	}
+	for _, rng := range ranges {
+		r.total.Add(rng.end - rng.start)
+	}
+	slog.Info("Bắt đầu replay", logging.KeyTopic, r.config.Topic, "records", r.total.Load(),
+		"partitions", len(ranges), "dry_run", r.config.DryRun)
+
+	consumer, err := sarama.NewConsumerFromClient(r.client)
+	if err != nil {
+		return r.Stats(), fmt.Errorf("lỗi tạo Kafka consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	stopProgress := r.reportProgress()
+	defer stopProgress()
+
+	for _, rng := range ranges {
+		if err := r.replayPartition(ctx, consumer, rng); err != nil {
+			return r.Stats(), err
+		}
+	}
+	return r.Stats(), nil
+}
+
+// resolveRanges tính khoảng offset cần đọc trên từng partition
+func (r *Replayer) resolveRanges() ([]replayRange, error) {
+	partitions := r.config.Partitions
+	if len(partitions) == 0 {
+		var err error
+		if partitions, err = r.client.Partitions(r.config.Topic); err != nil {
+			return nil, fmt.Errorf("lỗi lấy partitions của topic %s: %w", r.config.Topic, err)
+		}
+	}
+
+	ranges := make([]replayRange, 0, len(partitions))
+	for _, partition := range partitions {
+		oldest, err := r.client.GetOffset(r.config.Topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, fmt.Errorf("lỗi lấy offset đầu của partition %d: %w", partition, err)
+		}
+		newest, err := r.client.GetOffset(r.config.Topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("lỗi lấy high water mark của partition %d: %w", partition, err)
+		}
+
+		start, err := r.resolveOffset(partition, r.config.StartOffset, r.config.StartTime, oldest, newest)
+		if err != nil {
+			return nil, err
+		}
+		end, err := r.resolveOffset(partition, r.config.EndOffset, r.config.EndTime, newest, newest)
+		if err != nil {
+			return nil, err
+		}
+
+		start, end = max(start, oldest), min(end, newest)
+		if start < end {
+			ranges = append(ranges, replayRange{partition: partition, start: start, end: end})
+		}
+	}
+	return ranges, nil
+}
+
+// resolveOffset chuyển offset hoặc timestamp thành offset cụ thể.
+// fallback được dùng khi không chỉ định, hoặc khi không có record nào sau timestamp.
+func (r *Replayer) resolveOffset(partition int32, offset int64, at time.Time, fallback, newest int64) (int64, error) {
+	if !at.IsZero() {
+		resolved, err := r.client.GetOffset(r.config.Topic, partition, at.UnixMilli())
+		if err != nil {
+			return 0, fmt.Errorf("lỗi tìm offset theo thời gian %v trên partition %d: %w", at, partition, err)
+		}
+		if resolved < 0 {
+			// Không có record nào từ thời điểm này trở đi
+			return newest, nil
+		}
+		return resolved, nil
+	}
+
+	switch offset {
+	case OffsetOldest, OffsetNewest:
+		return fallback, nil
+	default:
+		return offset, nil
+	}
+}
+
+// replayPartition đọc một partition trong khoảng [start, end)
+func (r *Replayer) replayPartition(ctx context.Context, consumer sarama.Consumer, rng replayRange) error {
+	partitionConsumer, err := consumer.ConsumePartition(r.config.Topic, rng.partition, rng.start)
+	if err != nil {
+		return fmt.Errorf("lỗi đọc partition %d từ offset %d: %w", rng.partition, rng.start, err)
+	}
+	defer partitionConsumer.Close()
+
+	for {
+		select {
+		case message, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return fmt.Errorf("partition %d bị đóng trước khi đọc tới offset %d", rng.partition, rng.end)
+			}
+			if message.Offset >= rng.end {
+				return nil
+			}
+
+			r.replayRecord(ctx, fromSaramaMessage(message))
+			if message.Offset+1 >= rng.end {
+				return nil
+			}
+
+		case consumerErr := <-partitionConsumer.Errors():
+			if consumerErr != nil {
+				return fmt.Errorf("lỗi đọc partition %d: %w", rng.partition, consumerErr.Err)
+			}
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// replayRecord decode, lọc và xử lý một record
+func (r *Replayer) replayRecord(ctx context.Context, record *Record) {
+	r.read.Add(1)
+
+	event, err := DecodeRecord(record.HeaderMap(), record.Value, r.registry)
+	if err != nil {
+		r.decodeErrors.Add(1)
+		if !errors.Is(err, ErrUnknownEventType) {
+			slog.WarnContext(ctx, "Replay: lỗi decode record", recordAttrs(record, err)...)
+		}
+		return
+	}
+
+	if r.config.ConversationID != "" && event.ConversationID != r.config.ConversationID {
+		r.filtered.Add(1)
+		return
+	}
+
+	if r.processor != nil {
+		if err := r.processor.ProcessEvent(ctx, event); err != nil {
+			r.processErrors.Add(1)
+			slog.ErrorContext(ctx, "Replay: lỗi xử lý event", append(recordAttrs(record, err), "event_id", event.ID)...)
+			return
+		}
+	}
+	r.processed.Add(1)
+}
+
+// reportProgress gọi Progress định kỳ, trả về hàm dừng báo cáo và gửi thống kê cuối cùng
+func (r *Replayer) reportProgress() func() {
+	if r.config.Progress == nil {
+		return func() {}
+	}
+
+	interval := r.config.ProgressInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.config.Progress(r.Stats())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		r.config.Progress(r.Stats())
+	}
+}
+
+// Stats trả về snapshot tiến độ replay
+func (r *Replayer) Stats() ReplayStats {
+	return ReplayStats{
+		Total:         r.total.Load(),
+		Read:          r.read.Load(),
+		Processed:     r.processed.Load(),
+		Filtered:      r.filtered.Load(),
+		DecodeErrors:  r.decodeErrors.Load(),
+		ProcessErrors: r.processErrors.Load(),
+	}
+}
+
+// Close đóng kết nối Kafka của replayer
+func (r *Replayer) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,270 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"vibeta/internal/config"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// ErrInvalidConfig được wrap bởi mọi lỗi cấu hình Kafka không hợp lệ
+var ErrInvalidConfig = errors.New("cấu hình Kafka không hợp lệ")
+
+// Các SASL mechanism được hỗ trợ
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	SASLMechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// DefaultClientID client ID gửi tới broker khi không cấu hình KAFKA_CLIENT_ID
+const DefaultClientID = "vibeta"
+
+// validClientID giống quy tắc sarama dùng để kiểm tra Config.ClientID
+var validClientID = regexp.MustCompile(`\A[A-Za-z0-9._-]+\z`)
+
+// SecurityConfig cấu hình TLS, SASL và client ID dùng chung cho producer,
+// consumer và admin client
+type SecurityConfig struct {
+	ClientID string
+
+	TLSEnabled            bool
+	TLSCAFile             string // PEM CA để verify broker; rỗng sẽ dùng system roots
+	TLSCertFile           string // PEM client certificate (mTLS)
+	TLSKeyFile            string // PEM client private key (mTLS)
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+
+	SASLMechanism string // "", PLAIN, SCRAM-SHA-256 hoặc SCRAM-SHA-512
+	SASLUsername  string
+	SASLPassword  string
+
+	// tlsConfig được build một lần khi Validate thành công
+	tlsConfig *tls.Config
+}
+
+// NewSecurityConfig tạo cấu hình bảo mật Kafka từ config và validate.
+// Password có thể được đọc từ file qua KAFKA_SASL_PASSWORD_FILE (ví dụ Docker/K8s secret).
+func NewSecurityConfig(settings config.KafkaConfig) (*SecurityConfig, error) {
+	security := &SecurityConfig{
+		ClientID:              settings.ClientID,
+		TLSEnabled:            settings.TLSEnabled,
+		TLSCAFile:             settings.TLSCAFile,
+		TLSCertFile:           settings.TLSCertFile,
+		TLSKeyFile:            settings.TLSKeyFile,
+		TLSServerName:         settings.TLSServerName,
+		TLSInsecureSkipVerify: settings.TLSInsecureSkipVerify,
+		SASLMechanism:         strings.ToUpper(strings.TrimSpace(settings.SASLMechanism)),
+		SASLUsername:          settings.SASLUsername,
+		SASLPassword:          settings.SASLPassword,
+	}
+	if security.ClientID == "" {
+		security.ClientID = DefaultClientID
+	}
+
+	if passwordFile := settings.SASLPasswordFile; passwordFile != "" {
+		if security.SASLPassword != "" {
+			return nil, fmt.Errorf("%w: KAFKA_SASL_PASSWORD và KAFKA_SASL_PASSWORD_FILE không được đặt cùng lúc", ErrInvalidConfig)
+		}
+		password, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: KAFKA_SASL_PASSWORD_FILE: không đọc được %s: %v", ErrInvalidConfig, passwordFile, err)
+		}
+		security.SASLPassword = strings.TrimRight(string(password), "\r\n")
+	}
+
+	if err := security.Validate(); err != nil {
+		return nil, err
+	}
+	return security, nil
+}
+
+// Validate kiểm tra cấu hình và load các file TLS.
+// Lỗi trả về nêu rõ biến môi trường bị sai và wrap ErrInvalidConfig.
+func (c *SecurityConfig) Validate() error {
+	if !validClientID.MatchString(c.ClientID) {
+		return fmt.Errorf("%w: KAFKA_CLIENT_ID=%q chỉ được chứa chữ, số, '.', '_' và '-'", ErrInvalidConfig, c.ClientID)
+	}
+
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+	return c.validateSASL()
+}
+
+func (c *SecurityConfig) validateTLS() error {
+	if !c.TLSEnabled {
+		for _, setting := range []struct{ key, value string }{
+			{"KAFKA_TLS_CA_FILE", c.TLSCAFile},
+			{"KAFKA_TLS_CERT_FILE", c.TLSCertFile},
+			{"KAFKA_TLS_KEY_FILE", c.TLSKeyFile},
+			{"KAFKA_TLS_SERVER_NAME", c.TLSServerName},
+		} {
+			if setting.value != "" {
+				return fmt.Errorf("%w: %s được đặt nhưng KAFKA_TLS_ENABLED không bật", ErrInvalidConfig, setting.key)
+			}
+		}
+		if c.TLSInsecureSkipVerify {
+			return fmt.Errorf("%w: KAFKA_TLS_INSECURE_SKIP_VERIFY được bật nhưng KAFKA_TLS_ENABLED không bật", ErrInvalidConfig)
+		}
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+
+	if c.TLSCAFile != "" {
+		caPEM, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("%w: KAFKA_TLS_CA_FILE: không đọc được %s: %v", ErrInvalidConfig, c.TLSCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("%w: KAFKA_TLS_CA_FILE: %s không chứa certificate PEM hợp lệ", ErrInvalidConfig, c.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case c.TLSCertFile != "" && c.TLSKeyFile == "":
+		return fmt.Errorf("%w: KAFKA_TLS_KEY_FILE bắt buộc khi đặt KAFKA_TLS_CERT_FILE", ErrInvalidConfig)
+	case c.TLSCertFile == "" && c.TLSKeyFile != "":
+		return fmt.Errorf("%w: KAFKA_TLS_CERT_FILE bắt buộc khi đặt KAFKA_TLS_KEY_FILE", ErrInvalidConfig)
+	case c.TLSCertFile != "":
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("%w: KAFKA_TLS_CERT_FILE/KAFKA_TLS_KEY_FILE: không load được client certificate: %v", ErrInvalidConfig, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.TLSInsecureSkipVerify {
+		slog.Warn("KAFKA_TLS_INSECURE_SKIP_VERIFY=true, certificate của broker không được kiểm tra")
+	}
+
+	c.tlsConfig = tlsConfig
+	return nil
+}
+
+func (c *SecurityConfig) validateSASL() error {
+	switch c.SASLMechanism {
+	case "":
+		if c.SASLUsername != "" || c.SASLPassword != "" {
+			return fmt.Errorf("%w: KAFKA_SASL_MECHANISM bắt buộc khi đặt KAFKA_SASL_USERNAME hoặc KAFKA_SASL_PASSWORD", ErrInvalidConfig)
+		}
+		return nil
+	case SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512:
+	default:
+		return fmt.Errorf("%w: KAFKA_SASL_MECHANISM=%q không hỗ trợ (hỗ trợ %s, %s, %s)", ErrInvalidConfig,
+			c.SASLMechanism, SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512)
+	}
+
+	if c.SASLUsername == "" {
+		return fmt.Errorf("%w: KAFKA_SASL_USERNAME bắt buộc khi KAFKA_SASL_MECHANISM=%s", ErrInvalidConfig, c.SASLMechanism)
+	}
+	if c.SASLPassword == "" {
+		return fmt.Errorf("%w: KAFKA_SASL_PASSWORD hoặc KAFKA_SASL_PASSWORD_FILE bắt buộc khi KAFKA_SASL_MECHANISM=%s", ErrInvalidConfig, c.SASLMechanism)
+	}
+	if !c.TLSEnabled {
+		slog.Warn("SASL được bật mà không có TLS, credentials được gửi qua kết nối không mã hóa", "mechanism", c.SASLMechanism)
+	}
+	return nil
+}
+
+// apply áp dụng client ID, TLS và SASL vào sarama config.
+// c phải đã được Validate (NewSecurityConfig tự validate).
+func (c *SecurityConfig) apply(saramaConfig *sarama.Config) error {
+	if c == nil {
+		saramaConfig.ClientID = DefaultClientID
+		return nil
+	}
+	if c.TLSEnabled && c.tlsConfig == nil {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	saramaConfig.ClientID = c.ClientID
+
+	if c.TLSEnabled {
+		saramaConfig.Net.TLS.Enable = true
+		saramaConfig.Net.TLS.Config = c.tlsConfig.Clone()
+	}
+
+	if c.SASLMechanism == "" {
+		return nil
+	}
+
+	saramaConfig.Net.SASL.Enable = true
+	saramaConfig.Net.SASL.Handshake = true
+	saramaConfig.Net.SASL.User = c.SASLUsername
+	saramaConfig.Net.SASL.Password = c.SASLPassword
+
+	switch c.SASLMechanism {
+	case SASLMechanismPlain:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case SASLMechanismSCRAMSHA256:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: scram.SHA256}
+		}
+	case SASLMechanismSCRAMSHA512:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: scram.SHA512}
+		}
+	default:
+		return fmt.Errorf("%w: KAFKA_SASL_MECHANISM=%q không hỗ trợ", ErrInvalidConfig, c.SASLMechanism)
+	}
+	return nil
+}
+
+// String mô tả cấu hình để log, không bao gồm password
+func (c *SecurityConfig) String() string {
+	if c == nil {
+		return "client_id=" + DefaultClientID + ", tls=false, sasl=none"
+	}
+	sasl := "none"
+	if c.SASLMechanism != "" {
+		sasl = c.SASLMechanism + "(" + c.SASLUsername + ")"
+	}
+	return fmt.Sprintf("client_id=%s, tls=%t, sasl=%s", c.ClientID, c.TLSEnabled, sasl)
+}
+
+// scramClient implements sarama.SCRAMClient bằng xdg-go/scram
+type scramClient struct {
+	hashGenerator scram.HashGeneratorFcn
+	conversation  *scram.ClientConversation
+}
+
+// Begin implements sarama.SCRAMClient
+func (s *scramClient) Begin(userName, password, authzID string) error {
+	client, err := s.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	s.conversation = client.NewConversation()
+	return nil
+}
+
+// Step implements sarama.SCRAMClient
+func (s *scramClient) Step(challenge string) (string, error) {
+	return s.conversation.Step(challenge)
+}
+
+// Done implements sarama.SCRAMClient
+func (s *scramClient) Done() bool {
+	return s.conversation.Done()
+}
@@ -0,0 +1,247 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeta/internal/config"
+
+	"github.com/IBM/sarama"
+)
+
+// tlsFiles các file PEM tự ký dùng cho test TLS
+type tlsFiles struct {
+	ca, cert, key, notPEM string
+}
+
+// writeTLSFiles sinh certificate tự ký (dùng làm cả CA và client certificate) vào thư mục tạm
+func writeTLSFiles(t *testing.T) tlsFiles {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "vibeta-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := tlsFiles{
+		ca:     filepath.Join(dir, "ca.pem"),
+		cert:   filepath.Join(dir, "client.pem"),
+		key:    filepath.Join(dir, "client-key.pem"),
+		notPEM: filepath.Join(dir, "not.pem"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	for path, content := range map[string][]byte{
+		files.ca:     certPEM,
+		files.cert:   certPEM,
+		files.key:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		files.notPEM: []byte("không phải PEM"),
+	} {
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatalf("WriteFile %s: %v", path, err)
+		}
+	}
+	return files
+}
+
+func TestSecurityConfigValidate(t *testing.T) {
+	files := writeTLSFiles(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name    string
+		config  SecurityConfig
+		wantErr string // biến môi trường phải có trong lỗi, rỗng nếu hợp lệ
+	}{
+		// Hợp lệ
+		{name: "không bảo mật", config: SecurityConfig{}},
+		{name: "TLS với system roots", config: SecurityConfig{TLSEnabled: true}},
+		{name: "TLS với CA", config: SecurityConfig{TLSEnabled: true, TLSCAFile: files.ca, TLSServerName: "kafka"}},
+		{name: "mTLS", config: SecurityConfig{TLSEnabled: true, TLSCAFile: files.ca, TLSCertFile: files.cert, TLSKeyFile: files.key}},
+		{name: "TLS bỏ qua verify", config: SecurityConfig{TLSEnabled: true, TLSInsecureSkipVerify: true}},
+		{name: "PLAIN", config: SecurityConfig{SASLMechanism: SASLMechanismPlain, SASLUsername: "vibeta", SASLPassword: "secret"}},
+		{name: "SCRAM-SHA-256 qua TLS", config: SecurityConfig{TLSEnabled: true, SASLMechanism: SASLMechanismSCRAMSHA256, SASLUsername: "vibeta", SASLPassword: "secret"}},
+		{name: "SCRAM-SHA-512 qua mTLS", config: SecurityConfig{TLSEnabled: true, TLSCertFile: files.cert, TLSKeyFile: files.key,
+			SASLMechanism: SASLMechanismSCRAMSHA512, SASLUsername: "vibeta", SASLPassword: "secret"}},
+
+		// Client ID
+		{name: "client ID có khoảng trắng", config: SecurityConfig{ClientID: "vibeta chat"}, wantErr: "KAFKA_CLIENT_ID"},
+
+		// File TLS khi không bật TLS
+		{name: "CA khi tắt TLS", config: SecurityConfig{TLSCAFile: files.ca}, wantErr: "KAFKA_TLS_CA_FILE"},
+		{name: "cert khi tắt TLS", config: SecurityConfig{TLSCertFile: files.cert}, wantErr: "KAFKA_TLS_CERT_FILE"},
+		{name: "key khi tắt TLS", config: SecurityConfig{TLSKeyFile: files.key}, wantErr: "KAFKA_TLS_KEY_FILE"},
+		{name: "server name khi tắt TLS", config: SecurityConfig{TLSServerName: "kafka"}, wantErr: "KAFKA_TLS_SERVER_NAME"},
+		{name: "bỏ qua verify khi tắt TLS", config: SecurityConfig{TLSInsecureSkipVerify: true}, wantErr: "KAFKA_TLS_INSECURE_SKIP_VERIFY"},
+
+		// File TLS không dùng được
+		{name: "CA không tồn tại", config: SecurityConfig{TLSEnabled: true, TLSCAFile: missing}, wantErr: "KAFKA_TLS_CA_FILE"},
+		{name: "CA không phải PEM", config: SecurityConfig{TLSEnabled: true, TLSCAFile: files.notPEM}, wantErr: "KAFKA_TLS_CA_FILE"},
+		{name: "cert thiếu key", config: SecurityConfig{TLSEnabled: true, TLSCertFile: files.cert}, wantErr: "KAFKA_TLS_KEY_FILE"},
+		{name: "key thiếu cert", config: SecurityConfig{TLSEnabled: true, TLSKeyFile: files.key}, wantErr: "KAFKA_TLS_CERT_FILE"},
+		{name: "key không hợp lệ", config: SecurityConfig{TLSEnabled: true, TLSCertFile: files.cert, TLSKeyFile: files.notPEM}, wantErr: "KAFKA_TLS_CERT_FILE/KAFKA_TLS_KEY_FILE"},
+
+		// SASL
+		{name: "mechanism không hỗ trợ", config: SecurityConfig{SASLMechanism: "GSSAPI", SASLUsername: "vibeta", SASLPassword: "secret"}, wantErr: "KAFKA_SASL_MECHANISM"},
+		{name: "username thiếu mechanism", config: SecurityConfig{SASLUsername: "vibeta"}, wantErr: "KAFKA_SASL_MECHANISM"},
+		{name: "password thiếu mechanism", config: SecurityConfig{SASLPassword: "secret"}, wantErr: "KAFKA_SASL_MECHANISM"},
+		{name: "mechanism thiếu username", config: SecurityConfig{SASLMechanism: SASLMechanismPlain, SASLPassword: "secret"}, wantErr: "KAFKA_SASL_USERNAME"},
+		{name: "mechanism thiếu password", config: SecurityConfig{SASLMechanism: SASLMechanismSCRAMSHA256, SASLUsername: "vibeta"}, wantErr: "KAFKA_SASL_PASSWORD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			security := tt.config
+			if security.ClientID == "" {
+				security.ClientID = DefaultClientID
+			}
+
+			err := security.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("Validate = %v, muốn lỗi wrap ErrInvalidConfig", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %q, muốn nêu %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecurityConfigApply(t *testing.T) {
+	files := writeTLSFiles(t)
+
+	tests := []struct {
+		name          string
+		config        *SecurityConfig
+		wantTLS       bool
+		wantCerts     int
+		wantMechanism sarama.SASLMechanism // rỗng nếu không bật SASL
+	}{
+		{name: "nil", config: nil},
+		{name: "mTLS", config: &SecurityConfig{TLSEnabled: true, TLSCAFile: files.ca, TLSCertFile: files.cert, TLSKeyFile: files.key},
+			wantTLS: true, wantCerts: 1},
+		{name: "PLAIN", config: &SecurityConfig{SASLMechanism: SASLMechanismPlain, SASLUsername: "vibeta", SASLPassword: "secret"},
+			wantMechanism: sarama.SASLTypePlaintext},
+		{name: "SCRAM-SHA-256", config: &SecurityConfig{TLSEnabled: true, SASLMechanism: SASLMechanismSCRAMSHA256, SASLUsername: "vibeta", SASLPassword: "secret"},
+			wantTLS: true, wantMechanism: sarama.SASLTypeSCRAMSHA256},
+		{name: "SCRAM-SHA-512", config: &SecurityConfig{SASLMechanism: SASLMechanismSCRAMSHA512, SASLUsername: "vibeta", SASLPassword: "secret"},
+			wantMechanism: sarama.SASLTypeSCRAMSHA512},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config != nil {
+				tt.config.ClientID = "vibeta-test"
+				if err := tt.config.Validate(); err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+			}
+
+			saramaConfig := sarama.NewConfig()
+			if err := tt.config.apply(saramaConfig); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if err := saramaConfig.Validate(); err != nil {
+				t.Fatalf("sarama config không hợp lệ: %v", err)
+			}
+
+			wantClientID := DefaultClientID
+			if tt.config != nil {
+				wantClientID = tt.config.ClientID
+			}
+			if saramaConfig.ClientID != wantClientID {
+				t.Errorf("ClientID = %q, muốn %q", saramaConfig.ClientID, wantClientID)
+			}
+			if saramaConfig.Net.TLS.Enable != tt.wantTLS {
+				t.Errorf("TLS.Enable = %v, muốn %v", saramaConfig.Net.TLS.Enable, tt.wantTLS)
+			}
+			if tt.wantTLS && len(saramaConfig.Net.TLS.Config.Certificates) != tt.wantCerts {
+				t.Errorf("có %d client certificate, muốn %d", len(saramaConfig.Net.TLS.Config.Certificates), tt.wantCerts)
+			}
+
+			sasl := saramaConfig.Net.SASL
+			if sasl.Enable != (tt.wantMechanism != "") {
+				t.Fatalf("SASL.Enable = %v, muốn %v", sasl.Enable, tt.wantMechanism != "")
+			}
+			if tt.wantMechanism == "" {
+				return
+			}
+			if sasl.Mechanism != tt.wantMechanism || sasl.User != "vibeta" || sasl.Password != "secret" {
+				t.Errorf("SASL = %s %s/%s, muốn %s vibeta/secret", sasl.Mechanism, sasl.User, sasl.Password, tt.wantMechanism)
+			}
+			if scram := tt.wantMechanism != sarama.SASLTypePlaintext; scram != (sasl.SCRAMClientGeneratorFunc != nil) {
+				t.Errorf("SCRAMClientGeneratorFunc đặt = %v, muốn %v", sasl.SCRAMClientGeneratorFunc != nil, scram)
+			}
+		})
+	}
+}
+
+func TestNewSecurityConfigPasswordFile(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "kafka_password")
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	settings := config.KafkaConfig{
+		SASLMechanism:    "scram-sha-512",
+		SASLUsername:     "vibeta",
+		SASLPasswordFile: passwordFile,
+	}
+
+	security, err := NewSecurityConfig(settings)
+	if err != nil {
+		t.Fatalf("NewSecurityConfig: %v", err)
+	}
+	if security.SASLPassword != "secret" || security.SASLMechanism != SASLMechanismSCRAMSHA512 || security.ClientID != DefaultClientID {
+		t.Errorf("SecurityConfig = %s password %q, muốn SCRAM-SHA-512, client ID mặc định, password không có xuống dòng",
+			security, security.SASLPassword)
+	}
+	if strings.Contains(security.String(), "secret") {
+		t.Errorf("String() lộ password: %s", security)
+	}
+
+	settings.SASLPassword = "other"
+	if _, err := NewSecurityConfig(settings); !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "KAFKA_SASL_PASSWORD_FILE") {
+		t.Errorf("NewSecurityConfig với cả password và password file = %v, muốn lỗi KAFKA_SASL_PASSWORD_FILE", err)
+	}
+
+	settings.SASLPassword = ""
+	settings.SASLPasswordFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := NewSecurityConfig(settings); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewSecurityConfig với password file không tồn tại = %v, muốn ErrInvalidConfig", err)
+	}
+}
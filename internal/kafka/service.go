@@ -3,53 +3,95 @@ package kafka
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
+	"vibeta/internal/config"
 	"vibeta/internal/db"
+
+	"github.com/IBM/sarama"
 )
 
 // MessageService quản lý việc gửi và nhận messages qua Kafka
 type MessageService struct {
+	bus MessageBus
+	// ownsBus false với SharedMemoryBus: bus dùng chung trong process (outbox relay,
+	// service khác) nên không được đóng cùng service
+	ownsBus  bool
 	producer *Producer
 	consumer *Consumer
 	config   *ServiceConfig
+
+	// Client dùng cho health check, được tạo lazily
+	adminMu     sync.Mutex
+	adminClient sarama.Client
+	admin       sarama.ClusterAdmin
 }
 
 // ServiceConfig cấu hình cho message service
 type ServiceConfig struct {
+	Bus            string // "kafka" (mặc định) hoặc "memory"
 	KafkaBrokers   []string
-	MessageTopic   string
+	MessageTopic   string // Topic mặc định, nhận các event type không có trong Routes
+	Routes         map[EventType]string
+	Topics         []TopicConfig // Tất cả topic: MessageTopic và các topic trong Routes
 	ConsumerGroup  string
 	WorkerCount    int
+	EventEncoding  string
+	ProducerMode   string
+	MaxInFlight    int
 	EnableProducer bool
 	EnableConsumer bool
+
+	// TLS, SASL và client ID áp dụng cho mọi kết nối Kafka
+	Security *SecurityConfig
+
+	// Outbox relay
+	OutboxPollInterval    time.Duration
+	OutboxBatchSize       int
+	OutboxLease           time.Duration
+	OutboxRetryBackoff    time.Duration
+	OutboxMaxRetryBackoff time.Duration
+	OutboxMaxAttempts     int
 }
 
-// NewMessageService tạo một message service mới
-func NewMessageService(database *db.Database) (*MessageService, error) {
-	config := loadServiceConfig()
+// NewMessageService tạo một message service mới với producer/consumer theo
+// config.EnableProducer và config.EnableConsumer
+func NewMessageService(store *db.Store, config *ServiceConfig) (*MessageService, error) {
+	// Producer và consumer dùng chung một bus
+	bus, err := NewMessageBus(config)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi kết nối message bus: %w", err)
+	}
 
 	service := &MessageService{
-		config: config,
+		bus:     bus,
+		ownsBus: config.Bus != BusMemory,
+		config:  config,
 	}
 
 	// Khởi tạo producer nếu được enable
 	if config.EnableProducer {
 		producerConfig := &ProducerConfig{
-			Brokers: config.KafkaBrokers,
-			Topic:   config.MessageTopic,
+			Brokers:     config.KafkaBrokers,
+			Topic:       config.MessageTopic,
+			Routes:      config.Routes,
+			Encoding:    config.EventEncoding,
+			Mode:        config.ProducerMode,
+			MaxInFlight: config.MaxInFlight,
+			Security:    config.Security,
+			Bus:         bus,
 		}
 
 		producer, err := NewProducer(producerConfig)
 		if err != nil {
+			service.closeBus()
 			return nil, fmt.Errorf("lỗi tạo Kafka producer: %w", err)
 		}
 		service.producer = producer
-		log.Println("Kafka producer đã được khởi tạo")
+		slog.Info("Kafka producer đã được khởi tạo", "mode", config.ProducerMode)
 	}
 
 	// Khởi tạo consumer nếu được enable
@@ -59,14 +101,18 @@ func NewMessageService(database *db.Database) (*MessageService, error) {
 			Topic:         config.MessageTopic,
 			ConsumerGroup: config.ConsumerGroup,
 			WorkerCount:   config.WorkerCount,
+			Topics:        config.Topics,
+			Security:      config.Security,
+			Bus:           bus,
 		}
 
-		consumer, err := NewConsumer(consumerConfig, database)
+		consumer, err := NewConsumer(consumerConfig, store)
 		if err != nil {
+			service.closeBus()
 			return nil, fmt.Errorf("lỗi tạo Kafka consumer: %w", err)
 		}
 		service.consumer = consumer
-		log.Println("Kafka consumer đã được khởi tạo")
+		slog.Info("Kafka consumer đã được khởi tạo")
 	}
 
 	return service, nil
@@ -86,6 +132,11 @@ func (ms *MessageService) GetProducer() *Producer {
 	return ms.producer
 }
 
+// GetBus trả về message bus dùng chung của service
+func (ms *MessageService) GetBus() MessageBus {
+	return ms.bus
+}
+
 // GetConsumer trả về Kafka consumer
 func (ms *MessageService) GetConsumer() *Consumer {
 	return ms.consumer
@@ -107,6 +158,20 @@ func (ms *MessageService) Close() error {
 		}
 	}
 
+	if err := ms.closeBus(); err != nil {
+		errors = append(errors, fmt.Sprintf("bus error: %v", err))
+	}
+
+	ms.adminMu.Lock()
+	if ms.admin != nil {
+		// Đóng cluster admin cũng đóng client bên dưới
+		if err := ms.admin.Close(); err != nil {
+			errors = append(errors, fmt.Sprintf("admin error: %v", err))
+		}
+		ms.admin, ms.adminClient = nil, nil
+	}
+	ms.adminMu.Unlock()
+
 	if len(errors) > 0 {
 		return fmt.Errorf("errors closing message service: %s", strings.Join(errors, ", "))
 	}
@@ -114,69 +179,84 @@ func (ms *MessageService) Close() error {
 	return nil
 }
 
-// loadServiceConfig load cấu hình từ environment variables
-func loadServiceConfig() *ServiceConfig {
-	config := &ServiceConfig{
-		KafkaBrokers:   getEnvStringSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
-		MessageTopic:   getEnvString("KAFKA_MESSAGE_TOPIC", "chat_messages"),
-		ConsumerGroup:  getEnvString("KAFKA_CONSUMER_GROUP", "chat_message_processors"),
-		WorkerCount:    getEnvInt("KAFKA_WORKER_COUNT", 4),
-		EnableProducer: getEnvBool("KAFKA_ENABLE_PRODUCER", true),
-		EnableConsumer: getEnvBool("KAFKA_ENABLE_CONSUMER", true),
+// closeBus đóng bus nếu service sở hữu nó
+func (ms *MessageService) closeBus() error {
+	if !ms.ownsBus {
+		return nil
 	}
+	return ms.bus.Close()
+}
 
-	log.Printf("Kafka config loaded: brokers=%v, topic=%s, consumer_group=%s, workers=%d",
-		config.KafkaBrokers, config.MessageTopic, config.ConsumerGroup, config.WorkerCount)
+// NewServiceConfig tạo cấu hình message service từ config (dùng chung cho các command).
+// Producer và consumer mặc định tắt, người gọi bật phần mình cần.
+// Trả về lỗi wrap ErrInvalidConfig nếu cấu hình không hợp lệ.
+func NewServiceConfig(settings config.KafkaConfig) (*ServiceConfig, error) {
+	serviceConfig := &ServiceConfig{
+		Bus:           settings.Bus,
+		KafkaBrokers:  settings.Brokers,
+		MessageTopic:  settings.MessageTopic,
+		ConsumerGroup: settings.ConsumerGroup,
+		WorkerCount:   settings.WorkerCount,
+		EventEncoding: settings.EventEncoding,
+		ProducerMode:  settings.ProducerMode,
+		MaxInFlight:   settings.MaxInFlight,
 
-	return config
-}
+		OutboxPollInterval:    settings.OutboxPollInterval,
+		OutboxBatchSize:       settings.OutboxBatchSize,
+		OutboxLease:           settings.OutboxLease,
+		OutboxRetryBackoff:    settings.OutboxRetryBackoff,
+		OutboxMaxRetryBackoff: settings.OutboxMaxRetryBackoff,
+		OutboxMaxAttempts:     settings.OutboxMaxAttempts,
+	}
 
-// Helper functions để đọc environment variables
-func getEnvString(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if err := serviceConfig.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
 	}
-	return defaultValue
-}
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+	routes, topics, err := newTopicConfigs(settings)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
 	}
-	return defaultValue
-}
+	serviceConfig.Routes, serviceConfig.Topics = routes, topics
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+	if serviceConfig.Bus != BusMemory {
+		security, err := NewSecurityConfig(settings)
+		if err != nil {
+			return nil, err
 		}
+		serviceConfig.Security = security
 	}
-	return defaultValue
+
+	slog.Info("Kafka config loaded", "bus", serviceConfig.Bus, "brokers", serviceConfig.KafkaBrokers,
+		"topics", topicNames(serviceConfig.Topics), "routes", serviceConfig.Routes, "consumer_group", serviceConfig.ConsumerGroup,
+		"encoding", serviceConfig.EventEncoding, "security", serviceConfig.Security.String())
+
+	return serviceConfig, nil
 }
 
-func getEnvStringSlice(key string, defaultValue []string) []string {
-	if value := os.Getenv(key); value != "" {
-		return strings.Split(value, ",")
+// validate kiểm tra các giá trị enum của cấu hình
+func (c *ServiceConfig) validate() error {
+	switch c.Bus {
+	case BusKafka, BusMemory:
+	default:
+		return fmt.Errorf("MESSAGE_BUS=%q không hỗ trợ (hỗ trợ %s, %s)", c.Bus, BusKafka, BusMemory)
+	}
+	if _, err := CodecFor(c.EventEncoding); err != nil {
+		return fmt.Errorf("KAFKA_EVENT_ENCODING: %w", err)
+	}
+	switch c.ProducerMode {
+	case ProducerModeSync, ProducerModeAsync:
+	default:
+		return fmt.Errorf("KAFKA_PRODUCER_MODE=%q không hỗ trợ (hỗ trợ %s, %s)", c.ProducerMode, ProducerModeSync, ProducerModeAsync)
 	}
-	return defaultValue
+	return nil
 }
 
-// HealthCheck kiểm tra trạng thái của Kafka connections
-func (ms *MessageService) HealthCheck() map[string]interface{} {
-	health := map[string]interface{}{
-		"timestamp": time.Now(),
-		"kafka": map[string]interface{}{
-			"producer_enabled": ms.config.EnableProducer,
-			"consumer_enabled": ms.config.EnableConsumer,
-			"brokers":          ms.config.KafkaBrokers,
-			"topic":            ms.config.MessageTopic,
-		},
+// topicNames trả về tên của các topic
+func topicNames(topics []TopicConfig) []string {
+	names := make([]string, len(topics))
+	for i, topic := range topics {
+		names[i] = topic.Name
 	}
-
-	// TODO: Thêm logic kiểm tra connection thực tế
-
-	return health
+	return names
 }
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/IBM/sarama"
+)
+
+// Kết quả của EnsureTopics cho từng topic
+const (
+	TopicCreated          = "created"
+	TopicExists           = "exists"
+	TopicGrown            = "grown"
+	TopicTooFewPartitions = "too_few_partitions"
+)
+
+// TopicResult kết quả tạo/kiểm tra một topic
+type TopicResult struct {
+	Name       string
+	Action     string
+	Partitions int32 // Số partition sau khi xử lý
+	Configured int32 // Số partition theo cấu hình
+}
+
+// TopicInfo thông tin một topic hiện có trên cluster
+type TopicInfo struct {
+	Name              string
+	Partitions        int32
+	ReplicationFactor int16
+}
+
+// TopicAdmin tạo và kiểm tra các topic theo TopicConfig
+type TopicAdmin struct {
+	admin sarama.ClusterAdmin
+}
+
+// NewTopicAdmin kết nối tới cluster với cùng cấu hình bảo mật như producer/consumer
+func NewTopicAdmin(brokers []string, security *SecurityConfig) (*TopicAdmin, error) {
+	saramaConfig, err := newSaramaConfig(security)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := sarama.NewClusterAdmin(brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("không kết nối được Kafka brokers %v: %w", brokers, err)
+	}
+	return &TopicAdmin{admin: admin}, nil
+}
+
+// EnsureTopics tạo các topic chưa có với số partition đã cấu hình.
+// Topic đã tồn tại nhưng ít partition hơn cấu hình chỉ được tăng partition khi grow=true,
+// vì tăng partition làm thay đổi phân phối key (thứ tự theo conversation).
+func (a *TopicAdmin) EnsureTopics(topics []TopicConfig, grow bool) ([]TopicResult, error) {
+	existing, err := a.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]TopicResult, 0, len(topics))
+	for _, topic := range topics {
+		result := TopicResult{Name: topic.Name, Configured: topic.Partitions}
+
+		info, ok := existing[topic.Name]
+		switch {
+		case !ok:
+			err := a.admin.CreateTopic(topic.Name, &sarama.TopicDetail{
+				NumPartitions:     topic.Partitions,
+				ReplicationFactor: topic.ReplicationFactor,
+			}, false)
+			if err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+				return results, fmt.Errorf("lỗi tạo topic %s: %w", topic.Name, err)
+			}
+			result.Action, result.Partitions = TopicCreated, topic.Partitions
+
+		case info.Partitions >= topic.Partitions:
+			result.Action, result.Partitions = TopicExists, info.Partitions
+
+		case grow:
+			if err := a.admin.CreatePartitions(topic.Name, topic.Partitions, nil, false); err != nil {
+				return results, fmt.Errorf("lỗi tăng partition của topic %s lên %d: %w", topic.Name, topic.Partitions, err)
+			}
+			result.Action, result.Partitions = TopicGrown, topic.Partitions
+
+		default:
+			result.Action, result.Partitions = TopicTooFewPartitions, info.Partitions
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ListTopics trả về các topic hiện có trên cluster theo tên
+func (a *TopicAdmin) ListTopics() (map[string]TopicInfo, error) {
+	details, err := a.admin.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lấy danh sách topic: %w", err)
+	}
+
+	topics := make(map[string]TopicInfo, len(details))
+	for name, detail := range details {
+		topics[name] = TopicInfo{
+			Name:              name,
+			Partitions:        detail.NumPartitions,
+			ReplicationFactor: detail.ReplicationFactor,
+		}
+	}
+	return topics, nil
+}
+
+// SortedTopicNames trả về tên topic đã sắp xếp
+func SortedTopicNames(topics map[string]TopicInfo) []string {
+	names := make([]string, 0, len(topics))
+	for name := range topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close đóng kết nối admin
+func (a *TopicAdmin) Close() error {
+	return a.admin.Close()
+}
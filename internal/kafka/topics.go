@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+
+	"vibeta/internal/config"
+)
+
+// Topic mặc định cho reaction, tách khỏi chat_messages để burst reaction
+// không làm chậm việc lưu tin nhắn
+const DefaultReactionTopic = "chat_reactions"
+
+// TopicConfig cấu hình của một topic: số partition khi tạo topic,
+// và worker pool riêng khi consume
+type TopicConfig struct {
+	Name              string
+	Partitions        int32
+	ReplicationFactor int16
+	Workers           int
+
+	// Priority cao hơn được ưu tiên: khi pool có priority cao hơn còn backlog,
+	// worker của topic priority thấp tạm nhường trước khi xử lý event tiếp theo
+	Priority int
+}
+
+// TopicRouter chọn topic cho event theo event type
+type TopicRouter struct {
+	defaultTopic string
+	routes       map[EventType]string
+}
+
+// NewTopicRouter tạo router; event type không có trong routes sẽ vào defaultTopic
+func NewTopicRouter(defaultTopic string, routes map[EventType]string) *TopicRouter {
+	copied := make(map[EventType]string, len(routes))
+	for eventType, topic := range routes {
+		copied[eventType] = topic
+	}
+	return &TopicRouter{defaultTopic: defaultTopic, routes: copied}
+}
+
+// TopicFor trả về topic của event type
+func (r *TopicRouter) TopicFor(eventType EventType) string {
+	if topic, ok := r.routes[eventType]; ok && topic != "" {
+		return topic
+	}
+	return r.defaultTopic
+}
+
+// Topics trả về danh sách topic (không trùng, đã sắp xếp) mà router có thể chọn
+func (r *TopicRouter) Topics() []string {
+	seen := map[string]bool{r.defaultTopic: true}
+	topics := []string{r.defaultTopic}
+	for _, topic := range r.routes {
+		if !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics[1:])
+	return topics
+}
+
+// newTopicConfigs tạo routing và cấu hình từng topic:
+//
+//	KAFKA_TOPIC_ROUTES=reaction=chat_reactions
+//	KAFKA_TOPIC_PARTITIONS=chat_messages=6,chat_reactions=3
+//	KAFKA_TOPIC_WORKERS=chat_messages=8,chat_reactions=2
+//	KAFKA_TOPIC_PRIORITIES=chat_messages=10,chat_reactions=1
+func newTopicConfigs(settings config.KafkaConfig) (map[EventType]string, []TopicConfig, error) {
+	messageTopic := settings.MessageTopic
+	routes := make(map[EventType]string, len(settings.TopicRoutes))
+	for eventType, topic := range settings.TopicRoutes {
+		if !DefaultRegistry.IsRegistered(EventType(eventType)) {
+			return nil, nil, fmt.Errorf("%w: KAFKA_TOPIC_ROUTES: %s", ErrUnknownEventType, eventType)
+		}
+		routes[EventType(eventType)] = topic
+	}
+
+	partitions, workers, priorities := settings.TopicPartitions, settings.TopicWorkers, settings.TopicPriorities
+	replicationFactor := settings.ReplicationFactor
+
+	var topics []TopicConfig
+	for _, name := range NewTopicRouter(messageTopic, routes).Topics() {
+		topic := TopicConfig{
+			Name:              name,
+			Partitions:        3,
+			ReplicationFactor: int16(replicationFactor),
+			Workers:           settings.WorkerCount,
+		}
+		if value, ok := partitions[name]; ok {
+			topic.Partitions = int32(value)
+		}
+		if value, ok := workers[name]; ok {
+			topic.Workers = value
+		}
+		if value, ok := priorities[name]; ok {
+			topic.Priority = value
+		} else if name == messageTopic {
+			// Tin nhắn chat luôn được ưu tiên hơn các event phụ nếu không cấu hình
+			topic.Priority = 1
+		}
+
+		if topic.Partitions <= 0 {
+			return nil, nil, fmt.Errorf("KAFKA_TOPIC_PARTITIONS: topic %s phải có ít nhất 1 partition", name)
+		}
+		if topic.Workers <= 0 {
+			return nil, nil, fmt.Errorf("KAFKA_TOPIC_WORKERS: topic %s phải có ít nhất 1 worker", name)
+		}
+		topics = append(topics, topic)
+	}
+	return routes, topics, nil
+}
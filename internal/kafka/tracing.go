@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"vibeta/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier implements propagation.TextMapCarrier trên headers của record,
+// để trace context W3C (traceparent/tracestate) đi cùng event_type/conversation_id
+type headerCarrier struct {
+	headers *[]RecordHeader
+}
+
+// Get implements propagation.TextMapCarrier
+func (c headerCarrier) Get(key string) string {
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// Set implements propagation.TextMapCarrier, ghi đè header cùng key nếu đã có
+func (c headerCarrier) Set(key, value string) {
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = value
+			return
+		}
+	}
+	*c.headers = append(*c.headers, RecordHeader{Key: key, Value: value})
+}
+
+// Keys implements propagation.TextMapCarrier
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, header := range *c.headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
+
+// startPublishSpan tạo span producer cho record và ghi trace context vào headers
+func startPublishSpan(ctx context.Context, record *Record, event *Event) (context.Context, trace.Span) {
+	ctx, span := tracing.Tracer().Start(ctx, "kafka.publish "+record.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", record.Topic),
+		),
+	)
+	if event != nil {
+		span.SetAttributes(eventAttributes(event)...)
+	}
+
+	tracing.Inject(ctx, headerCarrier{&record.Headers})
+	return ctx, span
+}
+
+// startConsumeSpan tạo span consumer với parent lấy từ headers của record
+func startConsumeSpan(ctx context.Context, record *Record) (context.Context, trace.Span) {
+	ctx = tracing.Extract(ctx, headerCarrier{&record.Headers})
+	return tracing.Tracer().Start(ctx, "kafka.consume "+record.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", record.Topic),
+			attribute.Int("messaging.kafka.destination.partition", int(record.Partition)),
+			attribute.Int64("messaging.kafka.message.offset", record.Offset),
+		),
+	)
+}
+
+// recordSpanResult ghi partition/offset hoặc lỗi publish vào span
+func recordSpanResult(span trace.Span, partition int32, offset int64, err error) {
+	if err != nil {
+		tracing.RecordError(span, err)
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("messaging.kafka.destination.partition", int(partition)),
+		attribute.Int64("messaging.kafka.message.offset", offset),
+	)
+}
+
+// eventAttributes các attribute mô tả event
+func eventAttributes(event *Event) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String("event.id", event.ID),
+		attribute.String("event.type", string(event.Type)),
+		attribute.String("conversation.id", event.ConversationID),
+	}
+	if payload, ok := event.Payload.(*ChatMessagePayload); ok {
+		attributes = append(attributes, attribute.String("message.id", payload.MessageID))
+	}
+	return attributes
+}
+
+// processSpanName tên span khi worker xử lý event
+func processSpanName(event *Event) string {
+	return fmt.Sprintf("process %s", event.Type)
+}
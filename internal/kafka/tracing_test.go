@@ -0,0 +1,188 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"vibeta/internal/config"
+	"vibeta/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupTracing cài propagator W3C như tracing.Setup và tracer provider ghi span vào recorder
+func setupTracing(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	if _, err := tracing.Setup(context.Background(), "vibeta-test", config.TracingConfig{Exporter: tracing.ExporterNone}); err != nil {
+		t.Fatalf("tracing.Setup: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := tracing.NewProvider("vibeta-test", sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	otel.SetTracerProvider(provider)
+	return recorder
+}
+
+// endedSpan trả về span đã kết thúc có tên name, nil nếu chưa có
+func endedSpan(recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestTracePropagatesFromFrameToDatabase(t *testing.T) {
+	recorder := setupTracing(t)
+	p := newPipeline(t)
+
+	// Span gốc giống span handleFrame tạo cho mỗi WebSocket frame
+	ctx, frameSpan := tracing.Tracer().Start(context.Background(), "ws.receive message", trace.WithSpanKind(trace.SpanKindServer))
+	event := NewEvent(testConversation, &ChatMessagePayload{MessageID: "msg_1", SenderID: "alice", Content: "xin chào"})
+	if err := p.producer.Publish(ctx, event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	frameSpan.End()
+
+	waitFor(t, func() bool { return endedSpan(recorder, "db.save_message") != nil })
+
+	// Mỗi span là con của span đứng trước trong chuỗi
+	chain := []struct {
+		name string
+		kind trace.SpanKind
+	}{
+		{"ws.receive message", trace.SpanKindServer},
+		{"kafka.publish " + testTopic, trace.SpanKindProducer},
+		{"kafka.consume " + testTopic, trace.SpanKindConsumer},
+		{processSpanName(event), trace.SpanKindInternal},
+		{"db.save_message", trace.SpanKindClient},
+	}
+
+	var parent sdktrace.ReadOnlySpan
+	for _, link := range chain {
+		waitFor(t, func() bool { return endedSpan(recorder, link.name) != nil })
+		span := endedSpan(recorder, link.name)
+
+		if span.SpanKind() != link.kind {
+			t.Errorf("span %q kind = %v, muốn %v", link.name, span.SpanKind(), link.kind)
+		}
+		if span.SpanContext().TraceID() != frameSpan.SpanContext().TraceID() {
+			t.Errorf("span %q thuộc trace %s, muốn %s", link.name, span.SpanContext().TraceID(), frameSpan.SpanContext().TraceID())
+		}
+		if parent == nil {
+			if span.Parent().IsValid() {
+				t.Errorf("span %q có parent %s, muốn là span gốc", link.name, span.Parent().SpanID())
+			}
+		} else if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q có parent %s, muốn %q (%s)", link.name, span.Parent().SpanID(), parent.Name(), parent.SpanContext().SpanID())
+		}
+		parent = span
+	}
+
+	// kafka.consume nhận parent từ header của record nên parent là remote
+	if consume := endedSpan(recorder, "kafka.consume "+testTopic); !consume.Parent().IsRemote() {
+		t.Error("parent của kafka.consume không được đọc từ header của record")
+	}
+}
+
+func TestPublishWritesW3CHeaders(t *testing.T) {
+	recorder := setupTracing(t)
+
+	// Bus không có consumer để record chưa bị xóa sau khi được commit
+	bus := NewMemoryBus(MemoryBusConfig{Partitions: 1})
+	t.Cleanup(func() { bus.Close() })
+	producer, err := NewProducer(&ProducerConfig{Topic: testTopic, Bus: bus})
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	t.Cleanup(func() { producer.Close() })
+
+	ctx, frameSpan := tracing.Tracer().Start(context.Background(), "ws.receive message")
+	event := NewEvent(testConversation, &ChatMessagePayload{MessageID: "msg_1", SenderID: "alice", Content: "xin chào"})
+	if err := producer.Publish(ctx, event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	frameSpan.End()
+
+	publish := endedSpan(recorder, "kafka.publish "+testTopic)
+	if publish == nil {
+		t.Fatal("không có span kafka.publish")
+	}
+
+	records := bus.Records(testTopic, 0)
+	if len(records) != 1 {
+		t.Fatalf("có %d record, muốn 1", len(records))
+	}
+	headers := records[0].HeaderMap()
+
+	// traceparent trỏ tới span publish để consumer nối tiếp đúng chỗ
+	want := fmt.Sprintf("00-%s-%s-01", publish.SpanContext().TraceID(), publish.SpanContext().SpanID())
+	if got := headers["traceparent"]; got != want {
+		t.Errorf("traceparent = %q, muốn %q", got, want)
+	}
+	// Header của envelope không bị propagator ghi đè
+	if headers[HeaderEventType] != string(EventTypeMessage) || headers[HeaderEventID] != event.ID {
+		t.Errorf("headers = %v, thiếu event_type/event_id của event", headers)
+	}
+}
+
+func TestHeaderCarrier(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  []RecordHeader
+		set      map[string]string
+		wantGet  map[string]string
+		wantKeys []string
+	}{
+		{
+			name:     "thêm header mới",
+			headers:  []RecordHeader{{HeaderEventType, "message"}},
+			set:      map[string]string{"traceparent": "00-a-b-01"},
+			wantGet:  map[string]string{HeaderEventType: "message", "traceparent": "00-a-b-01", "tracestate": ""},
+			wantKeys: []string{HeaderEventType, "traceparent"},
+		},
+		{
+			name:     "ghi đè header cùng key",
+			headers:  []RecordHeader{{"traceparent", "00-old-old-01"}, {HeaderEventType, "message"}},
+			set:      map[string]string{"traceparent": "00-new-new-01"},
+			wantGet:  map[string]string{"traceparent": "00-new-new-01"},
+			wantKeys: []string{"traceparent", HeaderEventType},
+		},
+		{
+			name:     "không có header",
+			wantGet:  map[string]string{"traceparent": ""},
+			wantKeys: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := append([]RecordHeader(nil), tt.headers...)
+			carrier := headerCarrier{&headers}
+			for key, value := range tt.set {
+				carrier.Set(key, value)
+			}
+			for key, want := range tt.wantGet {
+				if got := carrier.Get(key); got != want {
+					t.Errorf("Get(%q) = %q, muốn %q", key, got, want)
+				}
+			}
+			if keys := carrier.Keys(); !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Keys() = %v, muốn %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
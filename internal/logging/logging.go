@@ -0,0 +1,231 @@
+// Package logging cấu hình structured logging (log/slog) cho WebSocket server,
+// worker và các tool dòng lệnh.
+//
+// Log được ghi dạng JSON ra stderr, level lấy từ LOG_LEVEL. Các field theo request
+// (request_id, user_id, conversation_id) được gắn vào context bằng With và tự động
+// xuất hiện trong mọi log ghi bằng slog.*Context, cùng trace_id/span_id nếu có span.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"vibeta/internal/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tên các field dùng chung
+const (
+	KeyService        = "service"
+	KeyRequestID      = "request_id"
+	KeyUserID         = "user_id"
+	KeyConversationID = "conversation_id"
+	KeyMessageID      = "message_id"
+	KeyEventType      = "event_type"
+	KeyTopic          = "topic"
+	KeyError          = "error"
+)
+
+// Các giá trị của LOG_FORMAT được hỗ trợ
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// level là level hiện tại của handler
+var level = new(slog.LevelVar)
+
+// Setup cài đặt logger mặc định theo cấu hình:
+//
+//	LOG_LEVEL=debug|info|warn|error (mặc định info)
+//	LOG_FORMAT=json|text (mặc định json)
+//
+// Logger mặc định của package log cũng được chuyển qua slog, nên các log.Printf
+// còn sót lại (ví dụ trong thư viện) vẫn ra JSON với level info.
+func Setup(service string, settings config.LogConfig) error {
+	parsed, err := ParseLevel(settings.Level)
+	if err != nil {
+		return err
+	}
+
+	format := settings.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	handler, err := newHandler(os.Stderr, format)
+	if err != nil {
+		return err
+	}
+
+	level.Set(parsed)
+	slog.SetDefault(slog.New(handler).With(KeyService, service))
+	return nil
+}
+
+// newHandler tạo handler theo format, bọc contextHandler để đọc field từ context
+func newHandler(w io.Writer, format string) (slog.Handler, error) {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}
+	switch format {
+	case FormatJSON:
+		return contextHandler{slog.NewJSONHandler(w, options)}, nil
+	case FormatText:
+		return contextHandler{slog.NewTextHandler(w, options)}, nil
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT không hỗ trợ: %q (hỗ trợ %q, %q)", format, FormatJSON, FormatText)
+	}
+}
+
+// replaceAttr ghi duration dạng chuỗi ("1.5s") thay vì số nanosecond
+func replaceAttr(_ []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() == slog.KindDuration {
+		return slog.String(attr.Key, attr.Value.Duration().String())
+	}
+	return attr
+}
+
+// ParseLevel đọc level từ chuỗi; chuỗi rỗng là info
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("LOG_LEVEL không hỗ trợ: %q (hỗ trợ debug, info, warn, error)", value)
+	}
+}
+
+// Err trả về field error chuẩn
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// Fatal ghi log level error rồi thoát với exit code 1, thay cho log.Fatal
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextKey key lưu field log trong context
+type contextKey struct{}
+
+// With trả về context mang thêm các field log (cặp key/value hoặc slog.Attr).
+// Field đã có trong context được giữ lại, field mới được thêm vào sau.
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	attrs := attrsFromContext(ctx)
+	combined := make([]slog.Attr, len(attrs), len(attrs)+len(args))
+	copy(combined, attrs)
+	combined = append(combined, argsToAttrs(args)...)
+	return context.WithValue(ctx, contextKey{}, combined)
+}
+
+// attrsFromContext các field đã gắn vào context bằng With
+func attrsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// argsToAttrs chuyển args kiểu slog (key, value, ... hoặc slog.Attr) thành []slog.Attr
+func argsToAttrs(args []any) []slog.Attr {
+	record := slog.Record{}
+	record.Add(args...)
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
+}
+
+// contextHandler thêm field từ context và trace_id/span_id của span hiện tại vào record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := attrsFromContext(ctx); len(attrs) > 0 {
+		record.AddAttrs(attrs...)
+	}
+	if ctx != nil {
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", spanContext.TraceID().String()),
+				slog.String("span_id", spanContext.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// StdLogger trả về *log.Logger ghi vào slog với level chỉ định,
+// dùng cho các thư viện nhận *log.Logger (ví dụ http.Server.ErrorLog, sarama.Logger)
+func StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), level)
+}
+
+// RequestIDHeader header chứa request ID, được nhận từ client/proxy hoặc tạo mới
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey key lưu request ID trong context
+type requestIDKey struct{}
+
+// Middleware gắn request ID vào context của request (field request_id trong log)
+// và trả lại trong response header. Không bọc ResponseWriter để WebSocket upgrade
+// vẫn dùng được http.Hijacker.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		ctx = With(ctx, KeyRequestID, requestID)
+		slog.DebugContext(ctx, "HTTP request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestID trả về request ID do Middleware gắn vào context, rỗng nếu không có
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// newRequestID tạo request ID ngẫu nhiên 16 ký tự hex
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,197 @@
+// Package metrics định nghĩa các Prometheus metrics của WebSocket server,
+// Kafka producer/consumer và database. Cả ws server và worker expose /metrics.
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "vibeta"
+
+// Kết quả xử lý dùng làm label "result"
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// knownFrameTypes giới hạn label "type" của frame để client không tạo ra label tùy ý
+var knownFrameTypes = map[string]bool{
+	"message":              true,
+	"typing":               true,
+	"reaction":             true,
+	"edit_message":         true,
+	"delete_message":       true,
+	"join_conversation":    true,
+	"leave_conversation":   true,
+	"create_conversation":  true,
+	"conversation_list":    true,
+	"conversation_created": true,
+	"message_ack":          true,
+	"message_nack":         true,
+	"user_joined":          true,
+	"user_left":            true,
+}
+
+var (
+	// WebSocket hub
+	ConnectedClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "ws",
+		Name: "connected_clients",
+		Help: "Số client WebSocket đang kết nối.",
+	})
+	ActiveConversations = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "ws",
+		Name: "active_conversations",
+		Help: "Số conversation có ít nhất một client đang tham gia.",
+	})
+	inboundFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "ws",
+		Name: "inbound_frames_total",
+		Help: "Số frame nhận từ client theo type.",
+	}, []string{"type"})
+	outboundFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "ws",
+		Name: "outbound_frames_total",
+		Help: "Số frame gửi tới client theo type.",
+	}, []string{"type"})
+	SendBufferDrops = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "ws",
+		Name: "send_buffer_drops_total",
+		Help: "Số lần client bị ngắt vì buffer gửi đầy.",
+	})
+
+	// Kafka producer
+	publishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "kafka",
+		Name:    "publish_duration_seconds",
+		Help:    "Thời gian từ lúc publish tới khi broker xác nhận.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms .. ~8s
+	}, []string{"topic", "result"})
+	publishErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "kafka",
+		Name: "publish_errors_total",
+		Help: "Số record publish thất bại.",
+	}, []string{"topic"})
+
+	// Worker
+	processingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "worker",
+		Name:    "processing_duration_seconds",
+		Help:    "Thời gian worker xử lý một event.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms .. ~4s
+	}, []string{"topic", "event_type", "result"})
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "worker",
+		Name: "queue_depth",
+		Help: "Số event đang chờ trong taskQueue của processing pool.",
+	}, []string{"topic"})
+
+	// Retention
+	retentionPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "retention",
+		Name: "purged_messages_total",
+		Help: "Số tin nhắn bị xóa vĩnh viễn theo lý do (expired, deleted).",
+	}, []string{"reason"})
+
+	// Database
+	dbWriteErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "db",
+		Name: "write_errors_total",
+		Help: "Số lỗi ghi database theo thao tác.",
+	}, []string{"operation"})
+	dbReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "db",
+		Name: "routed_reads_total",
+		Help: "Số truy vấn chỉ đọc theo nơi xử lý (primary, replica) khi có read replica.",
+	}, []string{"target"})
+	dbReplicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "db",
+		Name: "replica_healthy",
+		Help: "1 nếu read replica đang nhận truy vấn đọc, 0 nếu bị bỏ qua.",
+	}, []string{"replica"})
+	dbReplicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "db",
+		Name: "replica_lag_seconds",
+		Help: "Độ trễ replay của read replica so với primary ở lần kiểm tra gần nhất.",
+	}, []string{"replica"})
+)
+
+// frameType chuẩn hóa type của frame thành label
+func frameType(frameType string) string {
+	if knownFrameTypes[frameType] {
+		return frameType
+	}
+	return "other"
+}
+
+// InboundFrame đếm frame nhận từ client
+func InboundFrame(messageType string) {
+	inboundFrames.WithLabelValues(frameType(messageType)).Inc()
+}
+
+// OutboundFrame đếm frame gửi tới client; type được đọc từ JSON của frame
+func OutboundFrame(message []byte) {
+	var frame struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(message, &frame)
+	outboundFrames.WithLabelValues(frameType(frame.Type)).Inc()
+}
+
+// ObservePublish ghi latency và lỗi của một lần publish
+func ObservePublish(topic string, seconds float64, err error) {
+	result := ResultOK
+	if err != nil {
+		result = ResultError
+		publishErrors.WithLabelValues(topic).Inc()
+	}
+	publishDuration.WithLabelValues(topic, result).Observe(seconds)
+}
+
+// ObserveProcessing ghi thời gian worker xử lý một event
+func ObserveProcessing(topic, eventType string, seconds float64, err error) {
+	result := ResultOK
+	if err != nil {
+		result = ResultError
+	}
+	processingDuration.WithLabelValues(topic, eventType, result).Observe(seconds)
+}
+
+// DBWrite đếm lỗi ghi database và trả lại err để dùng trực tiếp trong return
+func DBWrite(operation string, err error) error {
+	if err != nil {
+		dbWriteErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
+
+// DBRead đếm truy vấn chỉ đọc được chuyển tới target (primary hoặc replica)
+func DBRead(target string) {
+	dbReads.WithLabelValues(target).Inc()
+}
+
+// SetReplicaHealth ghi trạng thái và độ trễ của read replica
+func SetReplicaHealth(replica string, healthy bool, lag time.Duration) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	dbReplicaHealthy.WithLabelValues(replica).Set(value)
+	dbReplicaLag.WithLabelValues(replica).Set(lag.Seconds())
+}
+
+// RetentionPurged đếm số tin nhắn bị job retention xóa vĩnh viễn
+func RetentionPurged(reason string, count int) {
+	retentionPurged.WithLabelValues(reason).Add(float64(count))
+}
+
+// Handler trả về HTTP handler cho /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
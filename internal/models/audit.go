@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AuditLog một thao tác quản trị hoặc liên quan bảo mật. Bảng chỉ được thêm, không sửa, không xóa.
+type AuditLog struct {
+	ID             uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	OccurredAt     time.Time `json:"occurred_at" gorm:"not null;index"`
+	ActorID        string    `json:"actor_id" gorm:"not null;index"` // User thực hiện, "cli:<tên>" với công cụ dòng lệnh
+	Action         string    `json:"action" gorm:"not null;index"`
+	TargetType     string    `json:"target_type" gorm:"not null"` // conversation, message, user, ...
+	TargetID       string    `json:"target_id" gorm:"not null"`
+	ConversationID string    `json:"conversation_id,omitempty" gorm:"index"`
+	Before         string    `json:"before,omitempty" gorm:"type:text"` // JSON trạng thái trước thao tác
+	After          string    `json:"after,omitempty" gorm:"type:text"`  // JSON trạng thái sau thao tác
+	RequestID      string    `json:"request_id,omitempty"`
+	IP             string    `json:"ip,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty" gorm:"type:text"`
+}
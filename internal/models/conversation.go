@@ -22,9 +22,13 @@ type Conversation struct {
 	Description string           `json:"description,omitempty"`
 	Avatar      string           `json:"avatar,omitempty"`
 	CreatedBy   string           `json:"created_by" gorm:"not null;index"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt   `json:"-" gorm:"index"`
+	// RetentionDays số ngày giữ tin nhắn của conversation, nil dùng mặc định của workspace, 0 là giữ vĩnh viễn
+	RetentionDays *int `json:"retention_days,omitempty"`
+	// LegalHold giữ toàn bộ tin nhắn, kể cả đã xóa mềm, khỏi job retention
+	LegalHold bool           `json:"legal_hold" gorm:"not null;default:false"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
 	Creator      User                      `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
@@ -34,17 +38,32 @@ type Conversation struct {
 
 // ConversationParticipant người tham gia cuộc trò chuyện
 type ConversationParticipant struct {
-	ID             uint       `json:"id" gorm:"primaryKey;autoIncrement"`
-	ConversationID string     `json:"conversation_id" gorm:"not null;index"`
-	UserID         string     `json:"user_id" gorm:"not null;index"`
-	JoinedAt       time.Time  `json:"joined_at" gorm:"default:CURRENT_TIMESTAMP"`
-	LeftAt         *time.Time `json:"left_at,omitempty"`
+	ID             uint            `json:"id" gorm:"primaryKey;autoIncrement"`
+	ConversationID string          `json:"conversation_id" gorm:"not null;index;index:idx_conversation_participants_user_conversation,priority:2"`
+	UserID         string          `json:"user_id" gorm:"not null;index;index:idx_conversation_participants_user_conversation,priority:1"`
+	Role           ParticipantRole `json:"role" gorm:"not null;default:member"` // Quyết định các thao tác được phép
+	JoinedAt       time.Time       `json:"joined_at" gorm:"default:CURRENT_TIMESTAMP"`
+	LeftAt         *time.Time      `json:"left_at,omitempty"`
 
 	// Relations
 	Conversation Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID"`
 	User         User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// ReadState vị trí đã đọc của user trong một conversation
+type ReadState struct {
+	ConversationID    string    `json:"conversation_id" gorm:"primaryKey"`
+	UserID            string    `json:"user_id" gorm:"primaryKey"`
+	LastReadMessageID string    `json:"last_read_message_id"`
+	LastReadAt        time.Time `json:"last_read_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TableName tên bảng của ReadState
+func (ReadState) TableName() string {
+	return "conversation_read_states"
+}
+
 // LastMessage thông tin tin nhắn cuối cùng trong conversation
 type LastMessage struct {
 	ID        string    `json:"id"`
@@ -30,7 +30,7 @@ const (
 // Message đại diện cho một tin nhắn
 type Message struct {
 	ID             string         `json:"id" gorm:"primaryKey"`
-	ConversationID string         `json:"conversation_id" gorm:"not null;index"`
+	ConversationID string         `json:"conversation_id" gorm:"not null;index:idx_messages_conversation_created_at,priority:1"`
 	SenderID       string         `json:"sender_id" gorm:"not null;index"`
 	Content        string         `json:"content"`
 	Type           MessageType    `json:"type" gorm:"not null"`
@@ -39,7 +39,7 @@ type Message struct {
 	Attachments    string         `json:"attachments,omitempty" gorm:"type:text"` // JSON string
 	Reactions      string         `json:"reactions,omitempty" gorm:"type:text"`   // JSON string
 	EditedAt       *time.Time     `json:"edited_at,omitempty"`
-	CreatedAt      time.Time      `json:"created_at"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"index:idx_messages_conversation_created_at,priority:2"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 
@@ -63,6 +63,15 @@ type Reaction struct {
 	Emoji  string `json:"emoji"`
 }
 
+// MessageReaction một reaction của user lên tin nhắn, mỗi (message, user, emoji) một bản ghi
+type MessageReaction struct {
+	MessageID      string    `json:"message_id" gorm:"primaryKey"`
+	UserID         string    `json:"user_id" gorm:"primaryKey"`
+	Emoji          string    `json:"emoji" gorm:"primaryKey"`
+	ConversationID string    `json:"conversation_id" gorm:"not null;index"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 // SendMessageRequest request gửi tin nhắn
 type SendMessageRequest struct {
 	ConversationID string       `json:"conversation_id" validate:"required"`
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// OutboxEvent event đã được encode, chờ relay publish vào Kafka.
+// Được ghi cùng transaction với dữ liệu nghiệp vụ (transactional outbox).
+type OutboxEvent struct {
+	ID             uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventID        string     `json:"event_id" gorm:"uniqueIndex;not null"`
+	EventType      string     `json:"event_type" gorm:"not null"`
+	ConversationID string     `json:"conversation_id" gorm:"index"`
+	PartitionKey   string     `json:"partition_key"`
+	Payload        []byte     `json:"payload" gorm:"not null"`
+	Headers        string     `json:"headers" gorm:"type:text"` // JSON string
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+	CreatedAt      time.Time  `json:"created_at"`
+	PublishedAt    *time.Time `json:"published_at,omitempty" gorm:"index"`
+	// LockedUntil hạn lease của relay đã claim event; relay khác chỉ claim lại sau thời điểm này
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	// NextAttemptAt thời điểm sớm nhất được publish lại sau lần publish lỗi
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	// DeadLetteredAt khác nil khi event bị bỏ qua sau quá số lần thử tối đa
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+}
+
+// ProcessedEvent event consumer đã xử lý. Relay và consumer đảm bảo at-least-once nên
+// một event có thể đến nhiều lần với cùng event_id; bản ghi này để bỏ qua các lần sau.
+type ProcessedEvent struct {
+	EventID     string    `json:"event_id" gorm:"primaryKey"`
+	EventType   string    `json:"event_type" gorm:"not null"`
+	ProcessedAt time.Time `json:"processed_at" gorm:"index;not null"`
+}
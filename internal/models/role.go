@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// ParticipantRole vai trò của thành viên trong conversation
+type ParticipantRole string
+
+const (
+	RoleOwner    ParticipantRole = "owner"     // Người tạo hoặc được chuyển quyền sở hữu, mỗi conversation một người
+	RoleAdmin    ParticipantRole = "admin"     // Quản lý thành viên và nội dung
+	RoleMember   ParticipantRole = "member"    // Thành viên thường
+	RoleReadOnly ParticipantRole = "read_only" // Chỉ đọc
+)
+
+// Permission một quyền trong conversation
+type Permission string
+
+const (
+	PermissionRead                 Permission = "read"                   // Tham gia và đọc tin nhắn
+	PermissionPost                 Permission = "post"                   // Gửi, sửa tin nhắn của mình và react
+	PermissionPin                  Permission = "pin"                    // Ghim và bỏ ghim tin nhắn
+	PermissionRename               Permission = "rename"                 // Đổi tên conversation
+	PermissionManageMembers        Permission = "manage_members"         // Thêm, xóa thành viên và đổi vai trò
+	PermissionDeleteOthersMessages Permission = "delete_others_messages" // Xóa tin nhắn của người khác
+)
+
+// rolePermissions quyền của từng vai trò
+var rolePermissions = map[ParticipantRole][]Permission{
+	RoleOwner:    {PermissionRead, PermissionPost, PermissionPin, PermissionRename, PermissionManageMembers, PermissionDeleteOthersMessages},
+	RoleAdmin:    {PermissionRead, PermissionPost, PermissionPin, PermissionRename, PermissionManageMembers, PermissionDeleteOthersMessages},
+	RoleMember:   {PermissionRead, PermissionPost},
+	RoleReadOnly: {PermissionRead},
+}
+
+// roleRanks thứ bậc của vai trò, dùng để quyết định ai được quản lý ai
+var roleRanks = map[ParticipantRole]int{
+	RoleReadOnly: 1,
+	RoleMember:   2,
+	RoleAdmin:    3,
+	RoleOwner:    4,
+}
+
+// Valid kiểm tra role có phải một trong các vai trò đã định nghĩa
+func (r ParticipantRole) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Can kiểm tra vai trò có quyền permission
+func (r ParticipantRole) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManage kiểm tra vai trò được xóa hoặc đổi vai trò của thành viên có vai trò target:
+// cần quyền quản lý thành viên và thứ bậc cao hơn target (admin không quản lý được admin khác)
+func (r ParticipantRole) CanManage(target ParticipantRole) bool {
+	return r.Can(PermissionManageMembers) && roleRanks[r] > roleRanks[target]
+}
+
+// CanAssign kiểm tra vai trò được gán role cho thành viên khác. Owner gán được mọi vai trò
+// (gán owner là chuyển quyền sở hữu), admin chỉ gán được vai trò thấp hơn admin.
+func (r ParticipantRole) CanAssign(role ParticipantRole) bool {
+	if !r.Can(PermissionManageMembers) || !role.Valid() {
+		return false
+	}
+	return r == RoleOwner || roleRanks[r] > roleRanks[role]
+}
+
+// PinnedMessage tin nhắn được ghim trong conversation
+type PinnedMessage struct {
+	ConversationID string    `json:"conversation_id" gorm:"primaryKey"`
+	MessageID      string    `json:"message_id" gorm:"primaryKey"`
+	PinnedBy       string    `json:"pinned_by" gorm:"not null"`
+	PinnedAt       time.Time `json:"pinned_at" gorm:"not null"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import "testing"
+
+var allRoles = []ParticipantRole{RoleOwner, RoleAdmin, RoleMember, RoleReadOnly}
+
+func TestRoleCan(t *testing.T) {
+	// Các quyền được cấp, quyền không có trong danh sách phải bị từ chối
+	granted := map[ParticipantRole][]Permission{
+		RoleOwner:    {PermissionRead, PermissionPost, PermissionPin, PermissionRename, PermissionManageMembers, PermissionDeleteOthersMessages},
+		RoleAdmin:    {PermissionRead, PermissionPost, PermissionPin, PermissionRename, PermissionManageMembers, PermissionDeleteOthersMessages},
+		RoleMember:   {PermissionRead, PermissionPost},
+		RoleReadOnly: {PermissionRead},
+		"":           {},
+		"superuser":  {},
+	}
+	permissions := []Permission{PermissionRead, PermissionPost, PermissionPin, PermissionRename,
+		PermissionManageMembers, PermissionDeleteOthersMessages, "drop_database"}
+
+	for role, allowed := range granted {
+		want := make(map[Permission]bool, len(allowed))
+		for _, permission := range allowed {
+			want[permission] = true
+		}
+		for _, permission := range permissions {
+			if got := role.Can(permission); got != want[permission] {
+				t.Errorf("%q.Can(%s) = %v, muốn %v", role, permission, got, want[permission])
+			}
+		}
+	}
+}
+
+func TestRoleValid(t *testing.T) {
+	for _, role := range allRoles {
+		if !role.Valid() {
+			t.Errorf("%q.Valid() = false", role)
+		}
+	}
+	for _, role := range []ParticipantRole{"", "Owner", "superuser"} {
+		if role.Valid() {
+			t.Errorf("%q.Valid() = true", role)
+		}
+	}
+}
+
+func TestRoleCanManage(t *testing.T) {
+	// manageable[actor] là các vai trò actor được xóa hoặc đổi vai trò
+	manageable := map[ParticipantRole][]ParticipantRole{
+		RoleOwner:    {RoleAdmin, RoleMember, RoleReadOnly},
+		RoleAdmin:    {RoleMember, RoleReadOnly},
+		RoleMember:   {},
+		RoleReadOnly: {},
+	}
+
+	for actor, targets := range manageable {
+		want := make(map[ParticipantRole]bool, len(targets))
+		for _, target := range targets {
+			want[target] = true
+		}
+		for _, target := range allRoles {
+			if got := actor.CanManage(target); got != want[target] {
+				t.Errorf("%s.CanManage(%s) = %v, muốn %v", actor, target, got, want[target])
+			}
+		}
+	}
+}
+
+func TestRoleCanAssign(t *testing.T) {
+	tests := []struct {
+		actor ParticipantRole
+		role  ParticipantRole
+		want  bool
+	}{
+		{RoleOwner, RoleOwner, true}, // Chuyển quyền sở hữu
+		{RoleOwner, RoleAdmin, true},
+		{RoleOwner, RoleMember, true},
+		{RoleOwner, RoleReadOnly, true},
+		{RoleOwner, "superuser", false},
+		{RoleAdmin, RoleOwner, false},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleAdmin, RoleMember, true},
+		{RoleAdmin, RoleReadOnly, true},
+		{RoleMember, RoleReadOnly, false},
+		{RoleMember, RoleMember, false},
+		{RoleReadOnly, RoleReadOnly, false},
+		{"", RoleReadOnly, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.actor.CanAssign(tt.role); got != tt.want {
+			t.Errorf("%q.CanAssign(%q) = %v, muốn %v", tt.actor, tt.role, got, tt.want)
+		}
+	}
+}
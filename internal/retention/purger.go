@@ -0,0 +1,132 @@
+// Package retention xóa vĩnh viễn tin nhắn hết hạn lưu trữ theo retention của workspace
+// (RETENTION_DEFAULT_DAYS) hoặc của từng conversation, trừ conversation đang legal hold.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/metrics"
+)
+
+// Lý do xóa, dùng làm label của metric
+const (
+	ReasonExpired = "expired"
+	ReasonDeleted = "deleted"
+)
+
+// Result số tin nhắn đã xóa vĩnh viễn trong một lượt
+type Result struct {
+	Expired int // Quá hạn retention
+	Deleted int // Đã xóa mềm quá RETENTION_DELETED_GRACE
+}
+
+// Purger xóa tin nhắn theo từng batch giới hạn, nghỉ giữa các batch để không giữ lock lâu.
+// Nhiều worker cùng chạy vẫn an toàn vì xóa một tin nhắn đã bị xóa không có tác dụng.
+type Purger struct {
+	repository db.RetentionRepository
+	config     config.RetentionConfig
+	now        func() time.Time
+}
+
+// NewPurger tạo purger với cấu hình RETENTION_*
+func NewPurger(repository db.RetentionRepository, settings config.RetentionConfig) *Purger {
+	return &Purger{repository: repository, config: settings, now: time.Now}
+}
+
+// Run chạy một lượt purge ngay rồi lặp lại mỗi RETENTION_INTERVAL cho tới khi ctx bị hủy
+func (p *Purger) Run(ctx context.Context) {
+	slog.Info("Retention job đã khởi động", "default_days", p.config.DefaultDays,
+		"deleted_grace", p.config.DeletedGrace, "interval", p.config.Interval, "batch_size", p.config.BatchSize)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.PurgeOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Lỗi chạy retention job", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce xóa toàn bộ tin nhắn đã hết hạn tại thời điểm gọi
+func (p *Purger) PurgeOnce(ctx context.Context) (Result, error) {
+	var result Result
+	now := p.now()
+
+	policies, err := p.repository.ListPolicies(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, policy := range policies {
+		if policy.LegalHold || policy.RetentionDays == nil || *policy.RetentionDays == 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -*policy.RetentionDays)
+		purged, err := p.drain(ctx, ReasonExpired, func(limit int) (int, error) {
+			return p.repository.PurgeExpired(ctx, policy.ConversationID, before, limit)
+		})
+		result.Expired += purged
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if p.config.DefaultDays > 0 {
+		before := now.AddDate(0, 0, -p.config.DefaultDays)
+		purged, err := p.drain(ctx, ReasonExpired, func(limit int) (int, error) {
+			return p.repository.PurgeExpired(ctx, "", before, limit)
+		})
+		result.Expired += purged
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if p.config.DeletedGrace > 0 {
+		before := now.Add(-p.config.DeletedGrace)
+		purged, err := p.drain(ctx, ReasonDeleted, func(limit int) (int, error) {
+			return p.repository.PurgeDeleted(ctx, before, limit)
+		})
+		result.Deleted += purged
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if result.Expired > 0 || result.Deleted > 0 {
+		slog.Info("Retention job đã xóa tin nhắn", "expired", result.Expired, "deleted", result.Deleted,
+			"duration", time.Since(now))
+	}
+	return result, nil
+}
+
+// drain gọi purge theo batch cho tới khi batch cuối không đầy
+func (p *Purger) drain(ctx context.Context, reason string, purge func(limit int) (int, error)) (int, error) {
+	total := 0
+	for {
+		purged, err := purge(p.config.BatchSize)
+		total += purged
+		metrics.RetentionPurged(reason, purged)
+		if err != nil || purged < p.config.BatchSize {
+			return total, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(p.config.BatchPause):
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/db/dbtest"
+	"vibeta/internal/models"
+)
+
+// fixture dữ liệu của một test purge
+type fixture struct {
+	t     *testing.T
+	store *db.Store
+	now   time.Time
+}
+
+func (f *fixture) conversation(id string, retentionDays *int, legalHold bool) {
+	f.t.Helper()
+	ctx := context.Background()
+	if err := f.store.Conversations.Create(ctx, &models.Conversation{ID: id, Type: models.ConversationTypeGroup, CreatedBy: "alice"}); err != nil {
+		f.t.Fatalf("Conversations.Create %s: %v", id, err)
+	}
+	if err := f.store.Participants.Add(ctx, id, "alice", models.RoleOwner); err != nil {
+		f.t.Fatalf("Participants.Add %s: %v", id, err)
+	}
+	if retentionDays != nil {
+		if err := f.store.Retention.SetRetention(ctx, id, retentionDays); err != nil {
+			f.t.Fatalf("SetRetention %s: %v", id, err)
+		}
+	}
+	if legalHold {
+		if err := f.store.Retention.SetLegalHold(ctx, id, true); err != nil {
+			f.t.Fatalf("SetLegalHold %s: %v", id, err)
+		}
+	}
+}
+
+// message tạo tin nhắn ageDays ngày tuổi kèm reaction và search index, xóa mềm nếu deleted
+func (f *fixture) message(id, conversationID string, ageDays int, deleted bool) {
+	f.t.Helper()
+	ctx := context.Background()
+	message := &models.Message{
+		ID:             id,
+		ConversationID: conversationID,
+		SenderID:       "alice",
+		Content:        "nội dung " + id,
+		Type:           models.MessageTypeText,
+		CreatedAt:      f.now.AddDate(0, 0, -ageDays),
+	}
+	if err := f.store.Messages.Create(ctx, message); err != nil {
+		f.t.Fatalf("Messages.Create %s: %v", id, err)
+	}
+	if err := f.store.Search.Index(ctx, message); err != nil {
+		f.t.Fatalf("Search.Index %s: %v", id, err)
+	}
+	if err := f.store.Reactions.Add(ctx, &models.MessageReaction{MessageID: id, UserID: "alice", Emoji: "👍", ConversationID: conversationID}); err != nil {
+		f.t.Fatalf("Reactions.Add %s: %v", id, err)
+	}
+	if deleted {
+		if err := f.store.Messages.Delete(ctx, id); err != nil {
+			f.t.Fatalf("Messages.Delete %s: %v", id, err)
+		}
+	}
+}
+
+// remaining trả về các tin nhắn trong ids còn trong database (kể cả đã xóa mềm), theo thứ tự tên
+func (f *fixture) remaining(ids []string) []string {
+	f.t.Helper()
+	messages, err := f.store.Privacy.ListSentMessages(context.Background(), "alice", time.Time{}, "", 1000)
+	if err != nil {
+		f.t.Fatalf("ListSentMessages: %v", err)
+	}
+
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	kept := []string{}
+	for _, message := range messages {
+		if want[message.ID] {
+			kept = append(kept, message.ID)
+		}
+	}
+	sort.Strings(kept)
+	return kept
+}
+
+func days(n int) *int { return &n }
+
+func TestPurgeOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    config.RetentionConfig
+		wantExpired int
+		wantDeleted int
+		wantKept    []string
+	}{
+		{
+			name:        "mặc định 30 ngày",
+			settings:    config.RetentionConfig{DefaultDays: 30, DeletedGrace: 30 * time.Minute, BatchSize: 2},
+			wantExpired: 4, // general-old-1..3 và short-old
+			wantDeleted: 1, // general-deleted
+			wantKept:    []string{"forever-old", "general-new", "held-deleted", "held-old", "short-new"},
+		},
+		{
+			name:        "mặc định giữ vĩnh viễn",
+			settings:    config.RetentionConfig{DefaultDays: 0, DeletedGrace: 30 * time.Minute, BatchSize: 2},
+			wantExpired: 1,
+			wantDeleted: 1,
+			wantKept: []string{"forever-old", "general-new", "general-old-1", "general-old-2", "general-old-3",
+				"held-deleted", "held-old", "short-new"},
+		},
+		{
+			name:        "giữ tin nhắn đã xóa mềm",
+			settings:    config.RetentionConfig{DefaultDays: 30, DeletedGrace: 0, BatchSize: 100},
+			wantExpired: 4,
+			wantDeleted: 0,
+			wantKept:    []string{"forever-old", "general-deleted", "general-new", "held-deleted", "held-old", "short-new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := dbtest.NewStore(t)
+			if err := store.Users.Create(ctx, &models.User{ID: "alice", Username: "alice", Email: "alice@example.com", FullName: "Alice"}); err != nil {
+				t.Fatalf("Users.Create: %v", err)
+			}
+			f := &fixture{t: t, store: store, now: time.Now()}
+
+			f.conversation("general", nil, false)
+			f.conversation("short", days(7), false)
+			f.conversation("forever", days(0), false)
+			f.conversation("held", days(1), true)
+
+			f.message("general-old-1", "general", 40, false)
+			f.message("general-old-2", "general", 50, false)
+			f.message("general-old-3", "general", 60, false)
+			f.message("general-new", "general", 5, false)
+			f.message("general-deleted", "general", 1, true)
+			f.message("short-old", "short", 10, false)
+			f.message("short-new", "short", 3, false)
+			f.message("forever-old", "forever", 400, false)
+			f.message("held-old", "held", 400, false)
+			f.message("held-deleted", "held", 400, true)
+			all := []string{"general-old-1", "general-old-2", "general-old-3", "general-new", "general-deleted",
+				"short-old", "short-new", "forever-old", "held-old", "held-deleted"}
+
+			// Lùi đồng hồ của purger một giờ để tin nhắn vừa xóa mềm đã quá DeletedGrace
+			purger := NewPurger(store.Retention, tt.settings)
+			purger.now = func() time.Time { return f.now.Add(time.Hour) }
+
+			result, err := purger.PurgeOnce(ctx)
+			if err != nil {
+				t.Fatalf("PurgeOnce: %v", err)
+			}
+			if result.Expired != tt.wantExpired || result.Deleted != tt.wantDeleted {
+				t.Errorf("PurgeOnce = %+v, muốn expired %d, deleted %d", result, tt.wantExpired, tt.wantDeleted)
+			}
+			if kept := f.remaining(all); !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("còn lại %v, muốn %v", kept, tt.wantKept)
+			}
+
+			// Reaction và search index chỉ còn với tin nhắn còn lại
+			var withReactions []string
+			for _, id := range all {
+				reactions, err := store.Reactions.ListByMessage(ctx, id)
+				if err != nil {
+					t.Fatalf("ListByMessage: %v", err)
+				}
+				if len(reactions) > 0 {
+					withReactions = append(withReactions, id)
+				}
+			}
+			if sort.Strings(withReactions); !reflect.DeepEqual(withReactions, tt.wantKept) {
+				t.Errorf("reaction còn trên %v, muốn %v", withReactions, tt.wantKept)
+			}
+			page, err := store.Search.Search(ctx, db.SearchQuery{UserID: "alice", Text: "nội dung", Limit: db.MaxSearchLimit})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			var indexed []string
+			for _, result := range page.Results {
+				indexed = append(indexed, result.MessageID)
+			}
+			sort.Strings(indexed)
+			if !reflect.DeepEqual(indexed, tt.wantKept) {
+				t.Errorf("search index còn %v, muốn %v", indexed, tt.wantKept)
+			}
+
+			// Lượt sau không còn gì để xóa
+			if again, err := purger.PurgeOnce(ctx); err != nil || again != (Result{}) {
+				t.Errorf("PurgeOnce lần hai = %+v (err %v), muốn rỗng", again, err)
+			}
+		})
+	}
+}
+
+func TestPurgeRespectsLegalHold(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore(t)
+	if err := store.Users.Create(ctx, &models.User{ID: "alice", Username: "alice", Email: "alice@example.com", FullName: "Alice"}); err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	f := &fixture{t: t, store: store, now: time.Now()}
+	f.conversation("held", nil, true)
+	f.message("held-old", "held", 400, false)
+	f.message("held-deleted", "held", 400, true)
+
+	purger := NewPurger(store.Retention, config.RetentionConfig{DefaultDays: 30, DeletedGrace: time.Nanosecond, BatchSize: 10})
+	purger.now = func() time.Time { return f.now.Add(time.Hour) }
+
+	// Gọi thẳng repository với conversation cụ thể cũng không xóa được khi đang legal hold
+	if purged, err := store.Retention.PurgeExpired(ctx, "held", f.now, 10); err != nil || purged != 0 {
+		t.Fatalf("PurgeExpired(held) = %d (err %v), muốn 0", purged, err)
+	}
+	if result, err := purger.PurgeOnce(ctx); err != nil || result != (Result{}) {
+		t.Fatalf("PurgeOnce khi legal hold = %+v (err %v), muốn rỗng", result, err)
+	}
+	if kept := f.remaining([]string{"held-deleted", "held-old"}); len(kept) != 2 {
+		t.Fatalf("còn lại %v, muốn cả hai tin nhắn", kept)
+	}
+
+	// Bỏ legal hold thì conversation theo retention mặc định
+	if err := store.Retention.SetLegalHold(ctx, "held", false); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	result, err := purger.PurgeOnce(ctx)
+	if err != nil {
+		t.Fatalf("PurgeOnce: %v", err)
+	}
+	if result.Expired+result.Deleted != 2 {
+		t.Errorf("PurgeOnce sau khi bỏ legal hold = %+v, muốn xóa 2 tin nhắn", result)
+	}
+	if kept := f.remaining([]string{"held-deleted", "held-old"}); len(kept) != 0 {
+		t.Errorf("còn lại %v sau khi bỏ legal hold", kept)
+	}
+}
+
+func TestRetentionPolicies(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.NewStore(t)
+	if err := store.Users.Create(ctx, &models.User{ID: "alice", Username: "alice", Email: "alice@example.com", FullName: "Alice"}); err != nil {
+		t.Fatalf("Users.Create: %v", err)
+	}
+	f := &fixture{t: t, store: store, now: time.Now()}
+	f.conversation("general", nil, false)
+	f.conversation("short", days(7), false)
+	f.conversation("held", nil, true)
+
+	policies, err := store.Retention.ListPolicies(ctx)
+	if err != nil {
+		t.Fatalf("ListPolicies: %v", err)
+	}
+	want := []db.RetentionPolicy{
+		{ConversationID: "held", LegalHold: true},
+		{ConversationID: "short", RetentionDays: days(7)},
+	}
+	if !reflect.DeepEqual(policies, want) {
+		t.Errorf("ListPolicies = %+v, muốn %+v", policies, want)
+	}
+
+	if err := store.Retention.SetLegalHold(ctx, "missing", true); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("SetLegalHold(missing) = %v, muốn ErrNotFound", err)
+	}
+	if err := store.Retention.SetRetention(ctx, "missing", days(1)); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("SetRetention(missing) = %v, muốn ErrNotFound", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package tracing cấu hình OpenTelemetry tracing cho WebSocket server và worker.
+//
+// Trace context được truyền theo chuẩn W3C (traceparent/tracestate), qua Kafka
+// record headers, để theo dõi một tin nhắn từ WebSocket frame tới lúc ghi database.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"vibeta/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Các giá trị của OTEL_TRACES_EXPORTER được hỗ trợ
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// instrumentationName tên tracer của ứng dụng
+const instrumentationName = "vibeta"
+
+// ShutdownFunc flush các span còn lại và dừng exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup cấu hình tracer provider toàn cục theo cấu hình:
+//
+//	OTEL_TRACES_EXPORTER=otlp|none (mặc định none)
+//	OTEL_EXPORTER_OTLP_ENDPOINT (mặc định http://localhost:4318; OTEL_EXPORTER_OTLP_TRACES_ENDPOINT vẫn được exporter đọc)
+//	OTEL_TRACES_SAMPLER_ARG tỉ lệ sample 0..1 (mặc định 1)
+//
+// Propagator W3C luôn được cài đặt để trace context từ client vẫn được truyền tiếp
+// kể cả khi không export span.
+func Setup(ctx context.Context, serviceName string, settings config.TracingConfig) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporterName := settings.Exporter
+	switch exporterName {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER không hỗ trợ: %q (hỗ trợ %q, %q)", exporterName, ExporterOTLP, ExporterNone)
+	}
+
+	// otlptracehttp tự đọc các OTEL_EXPORTER_OTLP_* còn lại (headers, TLS)
+	var options []otlptracehttp.Option
+	if settings.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(settings.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi tạo OTLP exporter: %w", err)
+	}
+
+	ratio := settings.SamplerRatio
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG=%v phải là số trong khoảng 0..1", ratio)
+	}
+
+	provider := NewProvider(serviceName, sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))))
+	otel.SetTracerProvider(provider)
+
+	slog.Info("Tracing đã bật", "exporter", ExporterOTLP, "sample_ratio", ratio)
+	return provider.Shutdown, nil
+}
+
+// NewProvider tạo tracer provider với resource service.name.
+// Dùng trực tiếp với tracetest.NewInMemoryExporter khi cần kiểm tra span trong test:
+//
+//	exporter := tracetest.NewInMemoryExporter()
+//	otel.SetTracerProvider(tracing.NewProvider("test", sdktrace.WithSyncer(exporter)))
+func NewProvider(serviceName string, options ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, options...)...)
+}
+
+// Tracer trả về tracer của ứng dụng từ provider toàn cục
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject ghi trace context của ctx vào carrier (ví dụ Kafka headers)
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract đọc trace context từ carrier
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// RecordError đánh dấu span lỗi nếu err khác nil
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -1,9 +1,8 @@
--- Khởi tạo database cho VibeTA chat application
-CREATE DATABASE IF NOT EXISTS vibeta_chat;
+-- Khởi tạo database cho VibeTA chat application.
+-- Database vibeta_chat được tạo bởi POSTGRES_DB trong docker-compose.
 
 -- Tạo extension cho UUID generation nếu cần
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
--- Indexes để tối ưu performance
--- Sẽ được tạo tự động bởi GORM khi application khởi động
--- Nhưng có thể tạo thêm indexes custom ở đây nếu cần
+-- Tables và indexes được quản lý bởi migration trong internal/db/migrations,
+-- áp dụng khi application khởi động (DB_AUTO_MIGRATE) hoặc bằng `make migrate`
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/db"
+	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Giới hạn của admin API
+const (
+	maxAnnouncementLength = 2000
+	workerHealthTimeout   = 5 * time.Second
+)
+
+// adminKey key lưu tên admin đã xác thực trong context
+type adminKey struct{}
+
+// requireAdmin bọc handler của admin API: yêu cầu header "Authorization: Bearer <token>"
+// với token trong ADMIN_TOKENS. Request không có bearer token nhận 401, token không phải
+// admin token nhận 403. Admin API bị tắt khi ADMIN_TOKENS rỗng.
+func (h *Hub) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(h.admin.Tokens) == 0 {
+			writeJSONError(w, http.StatusServiceUnavailable, "admin API chưa được bật (ADMIN_TOKENS)")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vibeta-admin"`)
+			writeJSONError(w, http.StatusUnauthorized, "cần admin token")
+			return
+		}
+		name, ok := h.authenticateAdmin(token)
+		if !ok {
+			slog.WarnContext(r.Context(), "Admin token không hợp lệ", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			writeJSONError(w, http.StatusForbidden, "token không có quyền admin")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), adminKey{}, name)
+		ctx = logging.With(ctx, "admin", name)
+		ctx = audit.WithRequest(ctx, audit.NewRequest(r))
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// registerAdminRoutes đăng ký các route của admin API vào mux, mọi route đều qua requireAdmin
+func (h *Hub) registerAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/admin/audit", h.requireAdmin(h.handleAudit))
+	mux.HandleFunc("/api/admin/clients", h.requireAdmin(h.handleAdminClients))
+	mux.HandleFunc("/api/admin/clients/{id}/disconnect", h.requireAdmin(h.handleAdminDisconnectClient))
+	mux.HandleFunc("/api/admin/users/{id}/disconnect", h.requireAdmin(h.handleAdminDisconnectUser))
+	mux.HandleFunc("/api/admin/conversations", h.requireAdmin(h.handleAdminConversations))
+	mux.HandleFunc("/api/admin/announcements", h.requireAdmin(h.handleAdminAnnouncement))
+	mux.HandleFunc("/api/admin/status", h.requireAdmin(h.handleAdminStatus))
+}
+
+// authenticateAdmin trả về tên admin có token khớp với bearer token
+func (h *Hub) authenticateAdmin(token string) (string, bool) {
+	// So sánh với mọi token để thời gian phản hồi không cho biết token nào gần khớp
+	matched := ""
+	for name, expected := range h.admin.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			matched = name
+		}
+	}
+	return matched, matched != ""
+}
+
+// adminActor actor ghi vào audit log cho admin đã xác thực
+func adminActor(ctx context.Context) string {
+	name, _ := ctx.Value(adminKey{}).(string)
+	return "admin:" + name
+}
+
+// do chạy fn trên goroutine của hub và chờ fn xong. Mọi truy cập clients, conversationClients
+// và Client.conversationIDs từ goroutine khác phải đi qua do. Không gọi từ goroutine của hub.
+func (h *Hub) do(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	select {
+	case h.inspect <- func() { fn(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-done
+	return nil
+}
+
+// clientInfo một kết nối WebSocket đang mở
+type clientInfo struct {
+	ID            string    `json:"id"` // Request ID của HTTP request mở kết nối
+	UserID        string    `json:"user_id"`
+	RemoteAddr    string    `json:"remote_addr"`
+	Conversations []string  `json:"conversations"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	LastActivity  time.Time `json:"last_activity"`
+	SendQueued    int       `json:"send_queued"`   // Số frame đang chờ gửi
+	SendCapacity  int       `json:"send_capacity"` // HUB_SEND_BUFFER_SIZE
+}
+
+// handleAdminClients xử lý GET /api/admin/clients, liệt kê kết nối đang mở.
+// Query parameter user_id lọc theo user.
+func (h *Hub) handleAdminClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+
+	userID := r.URL.Query().Get("user_id")
+	clients := []clientInfo{}
+	err := h.do(r.Context(), func() {
+		for client := range h.clients {
+			if userID != "" && client.userID != userID {
+				continue
+			}
+			info := clientInfo{
+				ID:            client.requestID,
+				UserID:        client.userID,
+				RemoteAddr:    client.remoteAddr,
+				Conversations: make([]string, 0, len(client.conversationIDs)),
+				ConnectedAt:   client.connectedAt,
+				LastActivity:  time.Unix(0, client.lastActivity.Load()),
+				SendQueued:    len(client.send),
+				SendCapacity:  cap(client.send),
+			}
+			for conversationID := range client.conversationIDs {
+				info.Conversations = append(info.Conversations, conversationID)
+			}
+			sort.Strings(info.Conversations)
+			clients = append(clients, info)
+		}
+	})
+	if err != nil {
+		return
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ConnectedAt.Before(clients[j].ConnectedAt) })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"clients": clients})
+}
+
+// handleAdminDisconnectClient xử lý POST /api/admin/clients/{id}/disconnect, đóng một kết nối
+func (h *Hub) handleAdminDisconnectClient(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	h.disconnect(w, r, audit.TargetConnection, id, func(client *Client) bool { return client.requestID == id })
+}
+
+// handleAdminDisconnectUser xử lý POST /api/admin/users/{id}/disconnect, đóng mọi kết nối của user
+func (h *Hub) handleAdminDisconnectUser(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	h.disconnect(w, r, audit.TargetUser, userID, func(client *Client) bool { return client.userID == userID })
+}
+
+// disconnect đóng các kết nối khớp match. Client nhận close frame 4000 "disconnected by admin"
+// rồi được hủy đăng ký như khi tự ngắt kết nối.
+func (h *Hub) disconnect(w http.ResponseWriter, r *http.Request, targetType, targetID string, match func(*Client) bool) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ POST")
+		return
+	}
+
+	ctx := r.Context()
+	var matched []*Client
+	var users []string
+	if err := h.do(ctx, func() {
+		for client := range h.clients {
+			if match(client) {
+				matched = append(matched, client)
+				users = append(users, client.userID)
+			}
+		}
+	}); err != nil {
+		return
+	}
+	if len(matched) == 0 {
+		writeJSONError(w, http.StatusNotFound, "không có kết nối nào khớp")
+		return
+	}
+
+	closeFrame := websocket.FormatCloseMessage(4000, "disconnected by admin")
+	for _, client := range matched {
+		client.conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(time.Second))
+		client.conn.Close()
+		client.logger.Warn("Admin đã ngắt kết nối client", "admin", adminActor(ctx))
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:    adminActor(ctx),
+		Action:     audit.ActionClientDisconnect,
+		TargetType: targetType,
+		TargetID:   targetID,
+		After:      map[string]interface{}{"connections": len(matched), "users": users},
+	})
+	writeJSON(w, http.StatusOK, map[string]int{"disconnected": len(matched)})
+}
+
+// conversationInfo một conversation đang có client kết nối
+type conversationInfo struct {
+	ID            string                  `json:"id"`
+	Name          string                  `json:"name,omitempty"`
+	Type          models.ConversationType `json:"type,omitempty"`
+	OnlineClients int                     `json:"online_clients"`
+	OnlineUsers   int                     `json:"online_users"`
+	// Members số thành viên trong database, nil nếu conversation chưa được lưu
+	Members *int `json:"members"`
+}
+
+// handleAdminConversations xử lý GET /api/admin/conversations, liệt kê các conversation
+// đang có client tham gia kèm số thành viên, đông nhất trước
+func (h *Hub) handleAdminConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+
+	ctx := r.Context()
+	conversations := []conversationInfo{}
+	if err := h.do(ctx, func() {
+		for conversationID, clients := range h.conversationClients {
+			users := make(map[string]bool, len(clients))
+			for client := range clients {
+				users[client.userID] = true
+			}
+			conversations = append(conversations, conversationInfo{
+				ID:            conversationID,
+				OnlineClients: len(clients),
+				OnlineUsers:   len(users),
+			})
+		}
+	}); err != nil {
+		return
+	}
+
+	// Đọc database sau khi rời goroutine của hub để không chặn việc gửi tin nhắn
+	for i := range conversations {
+		info := &conversations[i]
+		conversation, err := h.store.Conversations.Get(ctx, info.ID)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Lỗi đọc conversation", logging.KeyConversationID, info.ID, logging.Err(err))
+			writeJSONError(w, http.StatusInternalServerError, "lỗi đọc conversation")
+			return
+		}
+		participants, err := h.store.Participants.List(ctx, info.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Lỗi đọc thành viên", logging.KeyConversationID, info.ID, logging.Err(err))
+			writeJSONError(w, http.StatusInternalServerError, "lỗi đọc thành viên")
+			return
+		}
+		members := len(participants)
+		info.Name, info.Type, info.Members = conversation.Name, conversation.Type, &members
+	}
+
+	sort.Slice(conversations, func(i, j int) bool {
+		if conversations[i].OnlineClients != conversations[j].OnlineClients {
+			return conversations[i].OnlineClients > conversations[j].OnlineClients
+		}
+		return conversations[i].ID < conversations[j].ID
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversations": conversations})
+}
+
+// announcementRequest body của POST /api/admin/announcements
+type announcementRequest struct {
+	Message string `json:"message"`
+	// ConversationID chỉ gửi tới client trong conversation, rỗng để gửi tới mọi client
+	ConversationID string `json:"conversation_id"`
+}
+
+// handleAdminAnnouncement xử lý POST /api/admin/announcements, gửi frame
+// system_announcement tới mọi client (hoặc client trong một conversation)
+func (h *Hub) handleAdminAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ POST")
+		return
+	}
+
+	var request announcementRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "body phải là JSON {\"message\": ..., \"conversation_id\": ...}")
+		return
+	}
+	request.Message = strings.TrimSpace(request.Message)
+	if request.Message == "" || len([]rune(request.Message)) > maxAnnouncementLength {
+		writeJSONError(w, http.StatusBadRequest, "message không được rỗng và tối đa 2000 ký tự")
+		return
+	}
+
+	ctx := r.Context()
+	announcement, err := json.Marshal(models.WebSocketMessage{
+		Type:   "system_announcement",
+		ConvID: request.ConversationID,
+		Data: map[string]interface{}{
+			"message": request.Message,
+			"sent_at": time.Now(),
+		},
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "lỗi tạo thông báo")
+		return
+	}
+
+	recipients := 0
+	if err := h.do(ctx, func() {
+		if request.ConversationID == "" {
+			recipients = len(h.clients)
+		} else {
+			recipients = len(h.conversationClients[request.ConversationID])
+		}
+	}); err != nil {
+		return
+	}
+	select {
+	case h.broadcast <- announcement:
+	case <-ctx.Done():
+		return
+	}
+
+	targetType, targetID := audit.TargetWorkspace, "default"
+	if request.ConversationID != "" {
+		targetType, targetID = audit.TargetConversation, request.ConversationID
+	}
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        adminActor(ctx),
+		Action:         audit.ActionAnnouncement,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		ConversationID: request.ConversationID,
+		After:          map[string]interface{}{"message": request.Message, "recipients": recipients},
+	})
+	writeJSON(w, http.StatusAccepted, map[string]int{"recipients": recipients})
+}
+
+// workerStatus trạng thái một worker, đọc từ /health của worker
+type workerStatus struct {
+	URL    string          `json:"url"`
+	Status string          `json:"status"`
+	Report json.RawMessage `json:"report,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// handleAdminStatus xử lý GET /api/admin/status: số kết nối, trạng thái database,
+// Kafka (producer, topic, consumer lag ở chế độ all-in-one) và các worker trong ADMIN_WORKER_HEALTH_URLS
+func (h *Hub) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), workerHealthTimeout)
+	defer cancel()
+
+	var clients, conversations int
+	if err := h.do(ctx, func() {
+		clients, conversations = len(h.clients), len(h.conversationClients)
+	}); err != nil {
+		writeJSONError(w, http.StatusGatewayTimeout, "hub không phản hồi")
+		return
+	}
+
+	result := map[string]interface{}{
+		"timestamp":     time.Now(),
+		"clients":       clients,
+		"conversations": conversations,
+	}
+
+	workers := make([]workerStatus, len(h.admin.WorkerHealthURLs))
+	var wg sync.WaitGroup
+	for i, url := range h.admin.WorkerHealthURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workers[i] = fetchWorkerStatus(ctx, url)
+		}()
+	}
+
+	if h.messageService != nil {
+		result["kafka"] = h.messageService.HealthCheck(ctx)
+	} else {
+		result["kafka"] = map[string]interface{}{"status": "unavailable"}
+	}
+	if err := h.store.Ping(ctx); err != nil {
+		result["database"] = map[string]interface{}{"status": "unhealthy", "error": err.Error()}
+	} else {
+		result["database"] = map[string]interface{}{"status": "healthy"}
+	}
+
+	wg.Wait()
+	result["workers"] = workers
+	writeJSON(w, http.StatusOK, result)
+}
+
+// fetchWorkerStatus đọc /health của một worker; worker trả 503 khi Kafka không khỏe nhưng vẫn kèm báo cáo
+func fetchWorkerStatus(ctx context.Context, url string) workerStatus {
+	status := workerStatus{URL: url, Status: kafka.HealthStatusUnhealthy}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	var report struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		status.Error = "response không phải JSON: " + response.Status
+		return status
+	}
+	status.Status, status.Report = report.Status, body
+	return status
+}
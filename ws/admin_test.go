@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// testAdminToken admin token "ops" của hub trong newAdminTestHub
+const testAdminToken = "ops-token-0123456789abcdef"
+
+// adminRoutes mọi route của admin API
+var adminRoutes = []struct {
+	method string
+	target string
+}{
+	{http.MethodGet, "/api/admin/audit"},
+	{http.MethodGet, "/api/admin/clients"},
+	{http.MethodPost, "/api/admin/clients/conn-1/disconnect"},
+	{http.MethodPost, "/api/admin/users/member/disconnect"},
+	{http.MethodGet, "/api/admin/conversations"},
+	{http.MethodPost, "/api/admin/announcements"},
+	{http.MethodGet, "/api/admin/status"},
+}
+
+// newAdminTestHub tạo hub của newTestHub với admin "ops" và mux chứa các route của admin API
+func newAdminTestHub(t *testing.T) (*Hub, *db.Store, *http.ServeMux) {
+	t.Helper()
+	hub, store := newTestHub(t)
+	hub.admin.Tokens = map[string]string{"ops": testAdminToken}
+	hub.config.SendBufferSize = 16
+
+	mux := http.NewServeMux()
+	hub.registerAdminRoutes(mux)
+	return hub, store, mux
+}
+
+// serveAdmin gọi admin API qua mux, token rỗng thì không gửi header Authorization
+func serveAdmin(mux *http.ServeMux, method, target, token, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	request.Header.Set("User-Agent", "admin-test")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// connect mở kết nối WebSocket của userID tới hub với request ID cho trước
+func connect(t *testing.T, hub *Hub, userID, requestID string) *websocket.Conn {
+	t.Helper()
+
+	server := httptest.NewServer(logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWs(hub, w, r)
+	})))
+	t.Cleanup(server.Close)
+
+	header := http.Header{logging.RequestIDHeader: []string{requestID}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?user_id="+userID, header)
+	if err != nil {
+		t.Fatalf("kết nối WebSocket của %s: %v", userID, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// Chờ hub đăng ký kết nối
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		var registered bool
+		hub.do(context.Background(), func() { registered = hub.userClients[userID] != nil })
+		if registered {
+			return conn
+		}
+	}
+	t.Fatalf("hub chưa đăng ký kết nối của %s", userID)
+	return nil
+}
+
+// waitClosedByAdmin đọc kết nối cho tới close frame, báo lỗi nếu không phải close frame 4000
+func waitClosedByAdmin(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, 4000) {
+				t.Errorf("kết nối đóng với %v, muốn close frame 4000", err)
+			}
+			return
+		}
+	}
+}
+
+func TestAdminRoutesRejectNonAdmins(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		disabled   bool // ADMIN_TOKENS rỗng
+		wantStatus int
+	}{
+		{name: "không có token", wantStatus: http.StatusUnauthorized},
+		{name: "token không phải admin", token: "member-token-0123456789", wantStatus: http.StatusForbidden},
+		{name: "tiền tố của admin token", token: testAdminToken[:8], wantStatus: http.StatusForbidden},
+		{name: "admin API bị tắt", token: testAdminToken, disabled: true, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, store, mux := newAdminTestHub(t)
+			if tt.disabled {
+				hub.admin.Tokens = nil
+			}
+			connect(t, hub, "member", "conn-1")
+
+			for _, route := range adminRoutes {
+				recorder := serveAdmin(mux, route.method, route.target, tt.token, `{"message": "bảo trì"}`)
+				if recorder.Code != tt.wantStatus {
+					t.Errorf("%s %s = %d, muốn %d", route.method, route.target, recorder.Code, tt.wantStatus)
+				}
+				var body map[string]string
+				if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body["error"] == "" {
+					t.Errorf("%s %s: body lỗi không có field error (%v)", route.method, route.target, err)
+				}
+			}
+
+			if actions := auditActions(t, store); len(actions) != 0 {
+				t.Errorf("audit log = %v, muốn rỗng khi request bị từ chối", actions)
+			}
+			// Kết nối không bị ngắt
+			var connected bool
+			hub.do(context.Background(), func() { connected = hub.userClients["member"] != nil })
+			if !connected {
+				t.Error("kết nối của member bị ngắt bởi request không có quyền admin")
+			}
+		})
+	}
+}
+
+func TestAdminReadsAreNotAudited(t *testing.T) {
+	_, store, mux := newAdminTestHub(t)
+
+	for _, route := range adminRoutes {
+		if route.method != http.MethodGet {
+			continue
+		}
+		if recorder := serveAdmin(mux, route.method, route.target, testAdminToken, ""); recorder.Code != http.StatusOK {
+			t.Errorf("%s %s = %d, muốn 200: %s", route.method, route.target, recorder.Code, recorder.Body)
+		}
+	}
+	if actions := auditActions(t, store); len(actions) != 0 {
+		t.Errorf("audit log = %v, muốn rỗng sau các request chỉ đọc", actions)
+	}
+}
+
+func TestAdminActionsAreAudited(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+		want       models.AuditLog // Action, TargetType, TargetID, ConversationID mong đợi
+		wantAfter  map[string]interface{}
+		closed     bool // Kết nối của member bị admin đóng
+	}{
+		{
+			name: "ngắt một kết nối", target: "/api/admin/clients/conn-1/disconnect", wantStatus: http.StatusOK,
+			want:      models.AuditLog{Action: audit.ActionClientDisconnect, TargetType: audit.TargetConnection, TargetID: "conn-1"},
+			wantAfter: map[string]interface{}{"connections": float64(1), "users": []interface{}{"member"}},
+			closed:    true,
+		},
+		{
+			name: "ngắt mọi kết nối của user", target: "/api/admin/users/member/disconnect", wantStatus: http.StatusOK,
+			want:      models.AuditLog{Action: audit.ActionClientDisconnect, TargetType: audit.TargetUser, TargetID: "member"},
+			wantAfter: map[string]interface{}{"connections": float64(1), "users": []interface{}{"member"}},
+			closed:    true,
+		},
+		{
+			name: "thông báo toàn workspace", target: "/api/admin/announcements", body: `{"message": "Bảo trì lúc 22:00"}`,
+			wantStatus: http.StatusAccepted,
+			want:       models.AuditLog{Action: audit.ActionAnnouncement, TargetType: audit.TargetWorkspace, TargetID: "default"},
+			wantAfter:  map[string]interface{}{"message": "Bảo trì lúc 22:00", "recipients": float64(1)},
+		},
+		{
+			name: "thông báo trong conversation", target: "/api/admin/announcements",
+			body: `{"message": "Dọn dẹp kênh", "conversation_id": "project"}`, wantStatus: http.StatusAccepted,
+			want: models.AuditLog{Action: audit.ActionAnnouncement, TargetType: audit.TargetConversation, TargetID: "project",
+				ConversationID: "project"},
+			wantAfter: map[string]interface{}{"message": "Dọn dẹp kênh", "recipients": float64(0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, store, mux := newAdminTestHub(t)
+			conn := connect(t, hub, "member", "conn-1")
+
+			recorder := serveAdmin(mux, http.MethodPost, tt.target, testAdminToken, tt.body)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("POST %s = %d, muốn %d: %s", tt.target, recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.closed {
+				waitClosedByAdmin(t, conn)
+			}
+
+			page, err := store.Audit.List(context.Background(), db.AuditQuery{})
+			if err != nil {
+				t.Fatalf("Audit.List: %v", err)
+			}
+			if len(page.Entries) != 1 {
+				t.Fatalf("có %d bản ghi audit, muốn 1", len(page.Entries))
+			}
+			entry := page.Entries[0]
+			if entry.ActorID != "admin:ops" || entry.Action != tt.want.Action || entry.TargetType != tt.want.TargetType ||
+				entry.TargetID != tt.want.TargetID || entry.ConversationID != tt.want.ConversationID {
+				t.Errorf("bản ghi audit = %+v, muốn actor admin:ops, %+v", entry, tt.want)
+			}
+			if entry.IP == "" || entry.UserAgent != "admin-test" {
+				t.Errorf("bản ghi audit có IP %q, User-Agent %q, muốn thông tin request của admin", entry.IP, entry.UserAgent)
+			}
+
+			var after map[string]interface{}
+			if err := json.Unmarshal([]byte(entry.After), &after); err != nil {
+				t.Fatalf("After không phải JSON: %v", err)
+			}
+			for key, want := range tt.wantAfter {
+				if got, _ := json.Marshal(after[key]); string(got) != mustJSON(t, want) {
+					t.Errorf("After[%s] = %s, muốn %s", key, got, mustJSON(t, want))
+				}
+			}
+		})
+	}
+}
+
+func TestAdminDisconnectUnknownClient(t *testing.T) {
+	_, store, mux := newAdminTestHub(t)
+
+	recorder := serveAdmin(mux, http.MethodPost, "/api/admin/users/nobody/disconnect", testAdminToken, "")
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("ngắt kết nối user không online = %d, muốn 404", recorder.Code)
+	}
+	if actions := auditActions(t, store); len(actions) != 0 {
+		t.Errorf("audit log = %v, muốn rỗng khi không ngắt kết nối nào", actions)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(data)
+}
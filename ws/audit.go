@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// recordAudit ghi audit log; lỗi chỉ được log để không chặn thao tác của user
+func (h *Hub) recordAudit(ctx context.Context, entry audit.Entry) {
+	if err := h.audit.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "Lỗi ghi audit log", "action", entry.Action, "target_id", entry.TargetID, logging.Err(err))
+	}
+}
+
+// auditMessageDelete ghi yêu cầu xóa tin nhắn kèm trạng thái trước khi xóa. Worker áp dụng
+// cùng điều kiện (người gửi, hoặc owner/admin của conversation), yêu cầu bị worker bỏ qua
+// thì không được ghi. Nội dung tin nhắn không được sao vào audit log.
+func (h *Hub) auditMessageDelete(ctx context.Context, messageID, userID string) {
+	message, err := h.store.Messages.Get(ctx, messageID)
+	if errors.Is(err, db.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Lỗi đọc message để ghi audit log", logging.KeyMessageID, messageID, logging.Err(err))
+		return
+	}
+	if message.SenderID != userID {
+		if _, err := h.requirePermission(ctx, message.ConversationID, userID, models.PermissionDeleteOthersMessages); err != nil {
+			return
+		}
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        userID,
+		Action:         audit.ActionMessageDelete,
+		TargetType:     audit.TargetMessage,
+		TargetID:       messageID,
+		ConversationID: message.ConversationID,
+		Before: map[string]interface{}{
+			"sender_id":  message.SenderID,
+			"type":       message.Type,
+			"created_at": message.CreatedAt,
+			"edited_at":  message.EditedAt,
+		},
+	})
+}
+
+// handleAudit xử lý GET /api/admin/audit (qua requireAdmin), trả về audit log mới nhất trước.
+//
+// Query parameters:
+//
+//	actor_id         lọc theo người thực hiện
+//	action           lọc theo action (conversation.create, message.delete, ...)
+//	target_type      lọc theo loại đối tượng (conversation, message, user, workspace)
+//	target_id        lọc theo đối tượng
+//	conversation_id  lọc theo conversation
+//	from, to         khoảng thời gian RFC3339, from bao gồm, to không bao gồm
+//	limit            số bản ghi mỗi trang (mặc định 50, tối đa 500)
+//	cursor           next_cursor của trang trước
+func (h *Hub) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+
+	params := r.URL.Query()
+	query := db.AuditQuery{
+		ActorID:        params.Get("actor_id"),
+		Action:         params.Get("action"),
+		TargetType:     params.Get("target_type"),
+		TargetID:       params.Get("target_id"),
+		ConversationID: params.Get("conversation_id"),
+		Cursor:         params.Get("cursor"),
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from không hợp lệ, cần RFC3339")
+		return
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to không hợp lệ, cần RFC3339")
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			writeJSONError(w, http.StatusBadRequest, "limit phải là số nguyên dương")
+			return
+		}
+	}
+
+	page, err := h.store.Audit.List(r.Context(), query)
+	if errors.Is(err, db.ErrInvalidAuditQuery) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Lỗi đọc audit log", logging.Err(err))
+		writeJSONError(w, http.StatusInternalServerError, "lỗi đọc audit log")
+		return
+	}
+
+	if page.Entries == nil {
+		page.Entries = []models.AuditLog{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// REST API quản lý conversation. Người thực hiện lấy từ query parameter user_id
+// (giống /ws, tạm thời chưa có xác thực); quyền được kiểm tra giống các frame WebSocket
+// tương ứng và kết quả được broadcast tới thành viên đang kết nối.
+//
+//	PATCH  /api/conversations/{id}                         body {"name"}
+//	GET    /api/conversations/{id}/members
+//	POST   /api/conversations/{id}/members                 body {"user_id", "role"}
+//	DELETE /api/conversations/{id}/members/{user}
+//	PUT    /api/conversations/{id}/members/{user}/role     body {"role"}
+//	GET    /api/conversations/{id}/pins
+//	PUT    /api/conversations/{id}/pins/{message}
+//	DELETE /api/conversations/{id}/pins/{message}
+
+// memberRequest body của các request thêm thành viên và đổi vai trò
+type memberRequest struct {
+	UserID string                 `json:"user_id"`
+	Role   models.ParticipantRole `json:"role"`
+}
+
+// handleConversation xử lý PATCH /api/conversations/{id}, hiện chỉ đổi tên
+func (h *Hub) handleConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ PATCH")
+		return
+	}
+	actorID, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	var request models.UpdateConversationRequest
+	if !decodeBody(w, r, &request) {
+		return
+	}
+	ctx := audit.WithRequest(r.Context(), audit.NewRequest(r))
+	if err := h.renameConversation(ctx, actorID, r.PathValue("id"), request.Name); err != nil {
+		writeConversationError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMembers xử lý GET và POST /api/conversations/{id}/members
+func (h *Hub) handleMembers(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+	conversationID := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, err := h.requirePermission(r.Context(), conversationID, actorID, models.PermissionRead); err != nil {
+			writeConversationError(w, r, err)
+			return
+		}
+		participants, err := h.store.Participants.List(r.Context(), conversationID)
+		if err != nil {
+			writeConversationError(w, r, err)
+			return
+		}
+		members := make([]map[string]interface{}, 0, len(participants))
+		for _, participant := range participants {
+			members = append(members, map[string]interface{}{
+				"user_id":   participant.UserID,
+				"role":      participant.Role,
+				"joined_at": participant.JoinedAt,
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"members": members})
+
+	case http.MethodPost:
+		var request memberRequest
+		if !decodeBody(w, r, &request) {
+			return
+		}
+		ctx := audit.WithRequest(r.Context(), audit.NewRequest(r))
+		if err := h.addMember(ctx, actorID, conversationID, request.UserID, request.Role); err != nil {
+			writeConversationError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET và POST")
+	}
+}
+
+// handleMember xử lý DELETE /api/conversations/{id}/members/{user}
+func (h *Hub) handleMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ DELETE")
+		return
+	}
+	actorID, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := audit.WithRequest(r.Context(), audit.NewRequest(r))
+	if err := h.removeMember(ctx, actorID, r.PathValue("id"), r.PathValue("user")); err != nil {
+		writeConversationError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMemberRole xử lý PUT /api/conversations/{id}/members/{user}/role
+func (h *Hub) handleMemberRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ PUT")
+		return
+	}
+	actorID, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	var request memberRequest
+	if !decodeBody(w, r, &request) {
+		return
+	}
+	ctx := audit.WithRequest(r.Context(), audit.NewRequest(r))
+	if err := h.changeRole(ctx, actorID, r.PathValue("id"), r.PathValue("user"), request.Role); err != nil {
+		writeConversationError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePins xử lý GET /api/conversations/{id}/pins, mới ghim trước
+func (h *Hub) handlePins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+	actorID, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	conversationID := r.PathValue("id")
+	if _, err := h.requirePermission(r.Context(), conversationID, actorID, models.PermissionRead); err != nil {
+		writeConversationError(w, r, err)
+		return
+	}
+	pins, err := h.store.Pins.List(r.Context(), conversationID)
+	if err != nil {
+		writeConversationError(w, r, err)
+		return
+	}
+	if pins == nil {
+		pins = []models.PinnedMessage{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pins": pins})
+}
+
+// handlePin xử lý PUT (ghim) và DELETE (bỏ ghim) /api/conversations/{id}/pins/{message}
+func (h *Hub) handlePin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ PUT và DELETE")
+		return
+	}
+	actorID, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := audit.WithRequest(r.Context(), audit.NewRequest(r))
+	pinned := r.Method == http.MethodPut
+	if err := h.setPinned(ctx, actorID, r.PathValue("id"), r.PathValue("message"), pinned); err != nil {
+		writeConversationError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireActor đọc người thực hiện từ query parameter user_id
+func requireActor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actorID := r.URL.Query().Get("user_id")
+	if actorID == "" {
+		writeJSONError(w, http.StatusBadRequest, "thiếu user_id")
+		return "", false
+	}
+	return actorID, true
+}
+
+// decodeBody đọc body JSON (tối đa 64KB), ghi 400 nếu không hợp lệ
+func decodeBody(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "body JSON không hợp lệ")
+		return false
+	}
+	return true
+}
+
+// writeConversationError chuyển lỗi của các thao tác trong conversation thành HTTP status
+func writeConversationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errPermissionDenied), errors.Is(err, errNotMember):
+		writeJSONError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, db.ErrNotFound):
+		writeJSONError(w, http.StatusNotFound, "không tìm thấy conversation, thành viên hoặc tin nhắn")
+	case errors.Is(err, db.ErrDuplicate):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		slog.ErrorContext(r.Context(), "Lỗi thao tác trên conversation", logging.KeyConversationID, r.PathValue("id"), logging.Err(err))
+		writeJSONError(w, http.StatusInternalServerError, "lỗi hệ thống")
+	}
+}
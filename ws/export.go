@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/db"
+	"vibeta/internal/export"
+	"vibeta/internal/logging"
+)
+
+// handleExport xử lý GET /api/conversations/{id}/export, stream toàn bộ tin nhắn của
+// conversation theo định dạng yêu cầu. Chỉ thành viên của conversation được export.
+//
+// Query parameters:
+//
+//	user_id   người export (giống /ws, tạm thời chưa có xác thực)
+//	format    jsonl (mặc định), html hoặc text
+//	from, to  khoảng thời gian RFC3339, from bao gồm, to không bao gồm
+func (h *Hub) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+
+	params := r.URL.Query()
+	userID := params.Get("user_id")
+	options := export.Options{ConversationID: r.PathValue("id")}
+
+	var err error
+	if userID == "" {
+		writeJSONError(w, http.StatusBadRequest, "thiếu user_id")
+		return
+	}
+	if options.Format, err = export.ParseFormat(params.Get("format")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if options.From, err = parseTimeParam(params.Get("from")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from không hợp lệ, cần RFC3339")
+		return
+	}
+	if options.To, err = parseTimeParam(params.Get("to")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to không hợp lệ, cần RFC3339")
+		return
+	}
+	if !options.From.IsZero() && !options.To.IsZero() && !options.From.Before(options.To) {
+		writeJSONError(w, http.StatusBadRequest, "from phải trước to")
+		return
+	}
+
+	// Kiểm tra trước khi ghi byte đầu tiên, sau đó không đổi được status code nữa
+	ctx := r.Context()
+	if _, err := h.store.Conversations.Get(ctx, options.ConversationID); errors.Is(err, db.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "không tìm thấy conversation")
+		return
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Lỗi đọc conversation", logging.KeyConversationID, options.ConversationID, logging.Err(err))
+		writeJSONError(w, http.StatusInternalServerError, "lỗi export")
+		return
+	}
+	member, err := h.store.Participants.IsParticipant(ctx, options.ConversationID, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Lỗi kiểm tra thành viên", logging.KeyConversationID, options.ConversationID, logging.Err(err))
+		writeJSONError(w, http.StatusInternalServerError, "lỗi export")
+		return
+	}
+	if !member {
+		writeJSONError(w, http.StatusForbidden, "user không phải thành viên của conversation")
+		return
+	}
+
+	filename := "conversation-" + options.ConversationID + "." + options.Format.Extension()
+	w.Header().Set("Content-Type", options.Format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	count, err := export.NewExporter(h.store).Export(ctx, w, options)
+	if err != nil {
+		// Response đã bắt đầu, client nhận bản export bị cắt ngang
+		slog.ErrorContext(ctx, "Export conversation bị gián đoạn", logging.KeyConversationID, options.ConversationID,
+			logging.KeyUserID, userID, "messages", count, logging.Err(err))
+		return
+	}
+	slog.InfoContext(ctx, "Đã export conversation", logging.KeyConversationID, options.ConversationID,
+		logging.KeyUserID, userID, "format", options.Format, "messages", count)
+
+	h.recordAudit(audit.WithRequest(ctx, audit.NewRequest(r)), audit.Entry{
+		ActorID:        userID,
+		Action:         audit.ActionConversationExport,
+		TargetType:     audit.TargetConversation,
+		TargetID:       options.ConversationID,
+		ConversationID: options.ConversationID,
+		After:          map[string]interface{}{"format": options.Format, "messages": count},
+	})
+}
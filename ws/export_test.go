@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vibeta/internal/audit"
+)
+
+// serveExport gọi handleExport qua mux để r.PathValue("id") có giá trị
+func serveExport(hub *Hub, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/conversations/{id}/export", hub.handleExport)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestHandleExport(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		left       string // user rời conversation trước khi export
+		wantStatus int
+		wantType   string
+	}{
+		{name: "thành viên export jsonl", target: "/api/conversations/project/export?user_id=member",
+			wantStatus: http.StatusOK, wantType: "application/x-ndjson"},
+		{name: "read_only export html", target: "/api/conversations/project/export?user_id=reader&format=html",
+			wantStatus: http.StatusOK, wantType: "text/html; charset=utf-8"},
+		{name: "người ngoài bị từ chối", target: "/api/conversations/project/export?user_id=outsider", wantStatus: http.StatusForbidden},
+		{name: "user chưa tham gia", target: "/api/conversations/project/export?user_id=newbie", wantStatus: http.StatusForbidden},
+		{name: "user đã rời conversation", target: "/api/conversations/project/export?user_id=member", left: "member",
+			wantStatus: http.StatusForbidden},
+		{name: "conversation không tồn tại", target: "/api/conversations/missing/export?user_id=member", wantStatus: http.StatusNotFound},
+		{name: "thiếu user_id", target: "/api/conversations/project/export", wantStatus: http.StatusBadRequest},
+		{name: "format không hỗ trợ", target: "/api/conversations/project/export?user_id=member&format=pdf", wantStatus: http.StatusBadRequest},
+		{name: "from sau to", target: "/api/conversations/project/export?user_id=member&from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, store := newTestHub(t)
+			if tt.left != "" {
+				if err := store.Participants.Remove(context.Background(), "project", tt.left); err != nil {
+					t.Fatalf("Participants.Remove: %v", err)
+				}
+			}
+			recorder := serveExport(hub, tt.target)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, muốn %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			actions := auditActions(t, store)
+
+			if tt.wantStatus != http.StatusOK {
+				// Bị từ chối trước khi ghi bản export: không có file đính kèm và không có tin nhắn nào
+				if disposition := recorder.Header().Get("Content-Disposition"); disposition != "" {
+					t.Errorf("Content-Disposition = %q khi bị từ chối", disposition)
+				}
+				var body map[string]string
+				if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || body["error"] == "" {
+					t.Errorf("body = %q, muốn JSON lỗi", recorder.Body)
+				}
+				if strings.Contains(recorder.Body.String(), "m-member") {
+					t.Errorf("body chứa tin nhắn khi bị từ chối: %s", recorder.Body)
+				}
+				if len(actions) != 0 {
+					t.Errorf("audit log = %v khi bị từ chối, muốn rỗng", actions)
+				}
+				return
+			}
+
+			if got := recorder.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, muốn %q", got, tt.wantType)
+			}
+			if !strings.Contains(recorder.Header().Get("Content-Disposition"), "conversation-project.") {
+				t.Errorf("Content-Disposition = %q", recorder.Header().Get("Content-Disposition"))
+			}
+			for _, messageID := range []string{"m-member", "m-admin"} {
+				if !strings.Contains(recorder.Body.String(), messageID) {
+					t.Errorf("bản export thiếu tin nhắn %s", messageID)
+				}
+			}
+			if len(actions) != 1 || actions[0] != audit.ActionConversationExport {
+				t.Errorf("audit log = %v, muốn [%s]", actions, audit.ActionConversationExport)
+			}
+		})
+	}
+}
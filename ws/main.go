@@ -3,31 +3,44 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"vibeta/internal/audit"
+	"vibeta/internal/config"
 	"vibeta/internal/db"
+	"vibeta/internal/health"
 	"vibeta/internal/kafka"
+	"vibeta/internal/logging"
+	"vibeta/internal/metrics"
 	"vibeta/internal/models"
+	"vibeta/internal/retention"
+	"vibeta/internal/tracing"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Upgrader chuyển đổi một kết nối HTTP thông thường thành một kết nối WebSocket.
-// Chúng ta cần chỉ định kích thước bộ đệm đọc và ghi.
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	// Chúng ta cần kiểm tra nguồn gốc của kết nối để cho phép
-	// các kết nối từ giao diện người dùng web của chúng ta.
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Tạm thời cho phép tất cả các nguồn gốc
-	},
+// newUpgrader tạo Upgrader chuyển đổi một kết nối HTTP thông thường thành một kết nối WebSocket.
+// Kích thước bộ đệm đọc và ghi lấy từ HUB_READ_BUFFER_SIZE và HUB_WRITE_BUFFER_SIZE.
+func newUpgrader(settings config.HubConfig) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  settings.ReadBufferSize,
+		WriteBufferSize: settings.WriteBufferSize,
+		// Chúng ta cần kiểm tra nguồn gốc của kết nối để cho phép
+		// các kết nối từ giao diện người dùng web của chúng ta.
+		CheckOrigin: func(r *http.Request) bool {
+			return true // Tạm thời cho phép tất cả các nguồn gốc
+		},
+	}
 }
 
 // Client là một người dùng kết nối đến máy chủ.
@@ -44,14 +57,33 @@ type Client struct {
 	// userID là ID của người dùng
 	userID string
 
-	// conversationIDs là danh sách các cuộc trò chuyện mà user tham gia
+	// requestID là request ID của HTTP request mở kết nối
+	requestID string
+
+	// request thông tin HTTP request mở kết nối, ghi vào audit log
+	request audit.Request
+
+	// logger ghi log kèm request_id và user_id của kết nối
+	logger *slog.Logger
+
+	// remoteAddr địa chỉ của kết nối
+	remoteAddr string
+
+	// conversationIDs là danh sách các cuộc trò chuyện mà user tham gia, chỉ hub đọc/sửa
 	conversationIDs map[string]bool
 
-	// lastActivity thời gian hoạt động cuối
-	lastActivity time.Time
+	// connectedAt thời điểm kết nối
+	connectedAt time.Time
+
+	// lastActivity thời gian hoạt động cuối (UnixNano), admin API đọc từ goroutine khác
+	lastActivity atomic.Int64
 }
 
 // Hub quản lý tất cả các client và tin nhắn.
+//
+// clients, conversationClients, userClients và Client.conversationIDs chỉ được đọc/sửa
+// trên goroutine của hub (run). Goroutine khác (readPump, HTTP handler) gửi qua các kênh
+// bên dưới hoặc dùng do.
 type Hub struct {
 	// clients là danh sách các client đã đăng ký.
 	clients map[*Client]bool
@@ -65,49 +97,101 @@ type Hub struct {
 	// unregister là kênh để hủy đăng ký client.
 	unregister chan *Client
 
+	// deliveries là kênh nhận kết quả lưu tin nhắn để báo lại cho client gửi.
+	deliveries chan deliveryNotice
+
+	// inspect là kênh nhận hàm cần chạy trên goroutine của hub (xem do)
+	inspect chan func()
+
 	// conversationClients map conversation ID -> danh sách clients
 	conversationClients map[string]map[*Client]bool
 
 	// userClients map user ID -> client
 	userClients map[string]*Client
 
-	// database instance
-	db *db.Database
+	// store truy cập dữ liệu qua các repository
+	store *db.Store
 
 	// Kafka message service
 	messageService *kafka.MessageService
+
+	// outbox ghi event khi không publish trực tiếp được và relay vào Kafka sau
+	outbox *kafka.OutboxRelay
+
+	// audit ghi nhật ký các thao tác quản trị và liên quan bảo mật
+	audit *audit.Recorder
+
+	// config cấu hình buffer và lịch sử tin nhắn
+	config config.HubConfig
+
+	// admin cấu hình admin API
+	admin config.AdminConfig
+
+	// upgrader nâng cấp HTTP request thành kết nối WebSocket
+	upgrader websocket.Upgrader
 }
 
 // newHub tạo một Hub mới.
-func newHub() *Hub {
+func newHub(cfg *config.Config) *Hub {
+	// Cấu hình sai không tự khỏi khi Kafka online lại, dừng ngay
+	serviceConfig, err := kafka.NewServiceConfig(cfg.Kafka)
+	if err != nil {
+		logging.Fatal("Lỗi cấu hình Kafka", logging.Err(err))
+	}
+
 	// Khởi tạo database
-	database := db.NewDatabase()
+	database, err := db.NewDatabase(context.Background(), cfg.Database)
+	if err != nil {
+		logging.Fatal("Không thể kết nối database", "driver", cfg.Database.Driver, logging.Err(err))
+	}
+	store := db.NewStore(database)
 
-	// Khởi tạo Kafka message service (chỉ producer cho WebSocket server)
-	os.Setenv("KAFKA_ENABLE_PRODUCER", "true")
-	os.Setenv("KAFKA_ENABLE_CONSUMER", "false") // WebSocket server không cần consumer
+	// Khởi tạo Kafka message service (chỉ producer cho WebSocket server).
+	// Ở chế độ all-in-one (MESSAGE_BUS=memory) server chạy luôn worker trên bus in-process.
+	serviceConfig.EnableProducer = true
+	serviceConfig.EnableConsumer = serviceConfig.Bus == kafka.BusMemory
 
-	messageService, err := kafka.NewMessageService(database)
+	messageService, err := kafka.NewMessageService(store, serviceConfig)
 	if err != nil {
-		log.Printf("Lỗi khởi tạo Kafka message service: %v. Sử dụng database trực tiếp.", err)
+		slog.Warn("Lỗi khởi tạo Kafka message service, sử dụng database trực tiếp", logging.Err(err))
 		messageService = nil
 	}
 
+	outbox, err := kafka.NewOutboxRelay(store.Outbox, serviceConfig)
+	if err != nil {
+		logging.Fatal("Lỗi khởi tạo outbox relay", logging.Err(err))
+	}
+
+	// Event audit_logged dùng chung outbox relay với event chat
+	var auditOutbox *kafka.OutboxRelay
+	if cfg.Audit.KafkaEnabled {
+		auditOutbox = outbox
+	}
+
 	return &Hub{
 		broadcast:           make(chan []byte),
 		register:            make(chan *Client),
 		unregister:          make(chan *Client),
+		deliveries:          make(chan deliveryNotice, 256),
+		inspect:             make(chan func()),
 		clients:             make(map[*Client]bool),
 		conversationClients: make(map[string]map[*Client]bool),
 		userClients:         make(map[string]*Client),
-		db:                  database,
+		store:               store,
 		messageService:      messageService,
+		outbox:              outbox,
+		audit:               audit.NewRecorder(store.Audit, auditOutbox),
+		config:              cfg.Hub,
+		admin:               cfg.Admin,
+		upgrader:            newUpgrader(cfg.Hub),
 	}
 }
 
 // run khởi chạy hub để xử lý các sự kiện.
 func (h *Hub) run() {
 	for {
+		h.updateGauges()
+
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
@@ -116,25 +200,21 @@ func (h *Hub) run() {
 			// Gửi danh sách conversations hiện có cho client mới
 			h.sendConversationList(client)
 
-			log.Printf("Client đã kết nối: %s", client.userID)
+			client.logger.Info("Client đã kết nối")
 
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				delete(h.userClients, client.userID)
-
-				// Xóa khỏi tất cả conversations
-				for convID := range client.conversationIDs {
-					if clients, exists := h.conversationClients[convID]; exists {
-						delete(clients, client)
-						if len(clients) == 0 {
-							delete(h.conversationClients, convID)
-						}
-					}
-				}
+				h.removeClient(client)
+				client.logger.Info("Client đã ngắt kết nối")
+			}
+
+		case fn := <-h.inspect:
+			fn()
 
-				close(client.send)
-				log.Printf("Client đã ngắt kết nối: %s", client.userID)
+		case notice := <-h.deliveries:
+			// Client có thể đã ngắt kết nối trước khi có kết quả
+			if _, ok := h.clients[notice.client]; ok {
+				h.sendToClient(notice.client, notice.message)
 			}
 
 		case message := <-h.broadcast:
@@ -142,104 +222,144 @@ func (h *Hub) run() {
 			var wsMsg models.WebSocketMessage
 			if err := json.Unmarshal(message, &wsMsg); err == nil && wsMsg.ConvID != "" {
 				// Gửi tin nhắn chỉ đến các client trong conversation
-				if clients, exists := h.conversationClients[wsMsg.ConvID]; exists {
-					for client := range clients {
-						select {
-						case client.send <- message:
-						default:
-							close(client.send)
-							delete(h.clients, client)
-							delete(clients, client)
-						}
-					}
+				for client := range h.conversationClients[wsMsg.ConvID] {
+					h.sendToClient(client, message)
 				}
 			} else {
 				// Broadcast đến tất cả clients (tin nhắn hệ thống)
 				for client := range h.clients {
-					select {
-					case client.send <- message:
-					default:
-						close(client.send)
-						delete(h.clients, client)
-					}
+					h.sendToClient(client, message)
 				}
 			}
 		}
 	}
 }
 
-// JoinConversation thêm client vào conversation
-func (h *Hub) JoinConversation(client *Client, conversationID string) {
-	if h.conversationClients[conversationID] == nil {
-		h.conversationClients[conversationID] = make(map[*Client]bool)
+// sendToClient gửi frame vào buffer của client, hủy đăng ký client nếu buffer đầy.
+// Chỉ gọi trên goroutine của hub.
+func (h *Hub) sendToClient(client *Client, message []byte) bool {
+	select {
+	case client.send <- message:
+		return true
+	default:
+		metrics.SendBufferDrops.Inc()
+		h.removeClient(client)
+		return false
+	}
+}
+
+// removeClient xóa client khỏi hub và mọi conversation rồi đóng kênh send.
+// Chỉ gọi trên goroutine của hub.
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	if h.userClients[client.userID] == client {
+		delete(h.userClients, client.userID)
+	}
+
+	// Xóa khỏi tất cả conversations
+	for convID := range client.conversationIDs {
+		if clients, exists := h.conversationClients[convID]; exists {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(h.conversationClients, convID)
+			}
+		}
+	}
+
+	close(client.send)
+}
+
+// updateGauges cập nhật số client và conversation đang hoạt động
+func (h *Hub) updateGauges() {
+	metrics.ConnectedClients.Set(float64(len(h.clients)))
+	metrics.ActiveConversations.Set(float64(len(h.conversationClients)))
+}
+
+// JoinConversation thêm client vào conversation. Conversation đã lưu trong database
+// chỉ cho thành viên tham gia.
+func (h *Hub) JoinConversation(ctx context.Context, client *Client, conversationID string) {
+	if err := h.authorize(ctx, conversationID, client.userID, models.PermissionRead); err != nil {
+		client.logger.Warn("Từ chối tham gia conversation", logging.KeyConversationID, conversationID, logging.Err(err))
+		h.sendError(client, models.WebSocketMessage{Type: "join_conversation", ConvID: conversationID}, err)
+		return
 	}
-	h.conversationClients[conversationID][client] = true
-	client.conversationIDs[conversationID] = true
 
 	// Gửi thông báo user joined đến các clients khác trong conversation
-	joinMessage := models.WebSocketMessage{
+	joinMessage, err := json.Marshal(models.WebSocketMessage{
 		Type:   "user_joined",
 		UserID: client.userID,
 		ConvID: conversationID,
 		Data:   client.userID,
+	})
+	if err != nil {
+		client.logger.Error("Lỗi encode user_joined", logging.Err(err))
+		return
 	}
 
-	if messageData, err := json.Marshal(joinMessage); err == nil {
+	joined := false
+	h.do(ctx, func() {
+		// Client có thể đã bị hủy đăng ký do buffer đầy
+		if _, ok := h.clients[client]; !ok {
+			return
+		}
 		for otherClient := range h.conversationClients[conversationID] {
 			if otherClient != client {
-				select {
-				case otherClient.send <- messageData:
-				default:
-					close(otherClient.send)
-					delete(h.clients, otherClient)
-					delete(h.conversationClients[conversationID], otherClient)
-				}
+				h.sendToClient(otherClient, joinMessage)
 			}
 		}
+		if h.conversationClients[conversationID] == nil {
+			h.conversationClients[conversationID] = make(map[*Client]bool)
+		}
+		h.conversationClients[conversationID][client] = true
+		client.conversationIDs[conversationID] = true
+		joined = true
+	})
+	if !joined {
+		return
 	}
 
 	// Gửi lịch sử tin nhắn cho client mới join
-	h.sendMessageHistory(client, conversationID)
+	h.sendMessageHistory(ctx, client, conversationID)
 
-	log.Printf("Client %s đã tham gia conversation %s", client.userID, conversationID)
+	client.logger.Info("Client đã tham gia conversation", logging.KeyConversationID, conversationID)
 }
 
 // LeaveConversation xóa client khỏi conversation
-func (h *Hub) LeaveConversation(client *Client, conversationID string) {
-	if clients, exists := h.conversationClients[conversationID]; exists {
-		// Gửi thông báo user left đến các clients khác
-		leaveMessage := models.WebSocketMessage{
-			Type:   "user_left",
-			UserID: client.userID,
-			ConvID: conversationID,
-			Data:   client.userID,
-		}
-
-		if messageData, err := json.Marshal(leaveMessage); err == nil {
-			for otherClient := range clients {
-				if otherClient != client {
-					select {
-					case otherClient.send <- messageData:
-					default:
-						close(otherClient.send)
-						delete(h.clients, otherClient)
-						delete(clients, otherClient)
-					}
-				}
-			}
-		}
+func (h *Hub) LeaveConversation(ctx context.Context, client *Client, conversationID string) {
+	// Gửi thông báo user left đến các clients khác
+	leaveMessage, err := json.Marshal(models.WebSocketMessage{
+		Type:   "user_left",
+		UserID: client.userID,
+		ConvID: conversationID,
+		Data:   client.userID,
+	})
+	if err != nil {
+		client.logger.Error("Lỗi encode user_left", logging.Err(err))
+		return
+	}
 
+	h.do(ctx, func() {
+		clients, exists := h.conversationClients[conversationID]
+		if !exists {
+			return
+		}
 		delete(clients, client)
+		delete(client.conversationIDs, conversationID)
+		for otherClient := range clients {
+			h.sendToClient(otherClient, leaveMessage)
+		}
 		if len(clients) == 0 {
 			delete(h.conversationClients, conversationID)
 		}
-	}
-	delete(client.conversationIDs, conversationID)
+	})
 
-	log.Printf("Client %s đã rời conversation %s", client.userID, conversationID)
+	client.logger.Info("Client đã rời conversation", logging.KeyConversationID, conversationID)
 }
 
-// sendConversationList gửi danh sách conversations cho client
+// sendConversationList gửi danh sách conversations cho client. Chạy trên goroutine của hub.
 func (h *Hub) sendConversationList(client *Client) {
 	// Tạo danh sách conversations demo
 	conversations := map[string]interface{}{
@@ -273,21 +393,16 @@ func (h *Hub) sendConversationList(client *Client) {
 	}
 
 	if messageData, err := json.Marshal(conversationMessage); err == nil {
-		select {
-		case client.send <- messageData:
-		default:
-			close(client.send)
-			delete(h.clients, client)
-		}
+		h.sendToClient(client, messageData)
 	}
 }
 
 // CreateConversation tạo conversation mới
-func (h *Hub) CreateConversation(client *Client, wsMsg models.WebSocketMessage) {
+func (h *Hub) CreateConversation(ctx context.Context, client *Client, wsMsg models.WebSocketMessage) {
 	// Parse conversation data từ message
 	conversationData, ok := wsMsg.Data.(map[string]interface{})
 	if !ok {
-		log.Printf("Lỗi parse conversation data từ client %s", client.userID)
+		client.logger.Warn("Lỗi parse conversation data")
 		return
 	}
 
@@ -295,13 +410,34 @@ func (h *Hub) CreateConversation(client *Client, wsMsg models.WebSocketMessage)
 	convType, typeOk := conversationData["type"].(string)
 
 	if !nameOk || !typeOk || name == "" {
-		log.Printf("Thiếu thông tin conversation từ client %s", client.userID)
+		client.logger.Warn("Thiếu thông tin conversation")
+		return
+	}
+	if models.ConversationType(convType) != models.ConversationTypeDirect && models.ConversationType(convType) != models.ConversationTypeGroup {
+		client.logger.Warn("Loại conversation không hợp lệ", "type", convType)
 		return
 	}
 
 	// Tạo conversation ID unique
 	conversationID := fmt.Sprintf("conv_%s_%d", client.userID, time.Now().Unix())
 
+	// Lưu conversation, người tạo là owner
+	if err := h.store.Conversations.Create(ctx, &models.Conversation{
+		ID:        conversationID,
+		Type:      models.ConversationType(convType),
+		Name:      name,
+		CreatedBy: client.userID,
+	}); err != nil {
+		client.logger.Error("Lỗi lưu conversation", logging.KeyConversationID, conversationID, logging.Err(err))
+		h.sendError(client, wsMsg, err)
+		return
+	}
+	if err := h.store.Participants.Add(ctx, conversationID, client.userID, models.RoleOwner); err != nil {
+		client.logger.Error("Lỗi thêm owner vào conversation", logging.KeyConversationID, conversationID, logging.Err(err))
+		h.sendError(client, wsMsg, err)
+		return
+	}
+
 	// Tạo conversation object
 	conversation := map[string]interface{}{
 		"id":           conversationID,
@@ -321,107 +457,234 @@ func (h *Hub) CreateConversation(client *Client, wsMsg models.WebSocketMessage)
 	}
 
 	if messageData, err := json.Marshal(newConversationMessage); err == nil {
-		// Broadcast đến tất cả clients
-		for c := range h.clients {
-			select {
-			case c.send <- messageData:
-			default:
-				close(c.send)
-				delete(h.clients, c)
-			}
-		}
+		// Frame không có conversation_id được hub gửi đến tất cả clients
+		h.broadcast <- messageData
 	}
 
-	log.Printf("Client %s đã tạo conversation mới: %s (%s)", client.userID, name, conversationID)
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        client.userID,
+		Action:         audit.ActionConversationCreate,
+		TargetType:     audit.TargetConversation,
+		TargetID:       conversationID,
+		ConversationID: conversationID,
+		After:          conversation,
+	})
+
+	client.logger.Info("Client đã tạo conversation mới", logging.KeyConversationID, conversationID, "name", name)
+}
+
+// deliveryNotice thông báo kết quả lưu tin nhắn cho client đã gửi
+type deliveryNotice struct {
+	client  *Client
+	message []byte
 }
 
-// saveMessageToDB gửi tin nhắn vào Kafka queue thay vì lưu trực tiếp
-func (h *Hub) saveMessageToDB(wsMsg models.WebSocketMessage, userID string) {
+// saveMessageToDB gửi tin nhắn vào Kafka queue thay vì lưu trực tiếp.
+// Kết quả được báo lại cho client gửi bằng message_ack/message_nack.
+func (h *Hub) saveMessageToDB(ctx context.Context, client *Client, wsMsg models.WebSocketMessage) {
 	// Nếu có Kafka service, sử dụng queue
 	if h.messageService != nil && h.messageService.GetProducer() != nil {
-		err := h.messageService.GetProducer().PublishChatMessage(wsMsg, userID)
-		if err != nil {
-			log.Printf("Lỗi gửi message vào Kafka: %v. Fallback to direct DB save.", err)
-			h.saveMessageToDBDirect(wsMsg, userID)
-		} else {
-			log.Printf("Đã gửi message từ user %s vào Kafka queue", userID)
+		err := h.messageService.GetProducer().PublishChatMessageAsync(ctx, wsMsg, client.userID, func(result kafka.DeliveryResult) {
+			h.onMessageDelivered(ctx, client, wsMsg, result)
+		})
+		if err == nil {
+			return
 		}
-	} else {
-		// Fallback: lưu trực tiếp vào database
-		h.saveMessageToDBDirect(wsMsg, userID)
+		slog.WarnContext(ctx, "Lỗi gửi message vào Kafka, fallback lưu trực tiếp vào DB", logging.Err(err))
+	}
+
+	// Fallback: lưu trực tiếp vào database
+	h.notifyDelivery(client, wsMsg, "stored", h.saveMessageToDBDirect(ctx, wsMsg, client.userID))
+}
+
+// onMessageDelivered xử lý kết quả delivery từ producer.
+// Được gọi trên goroutine của producer nên fallback DB chạy ở goroutine riêng.
+func (h *Hub) onMessageDelivered(ctx context.Context, client *Client, wsMsg models.WebSocketMessage, result kafka.DeliveryResult) {
+	if result.Err == nil {
+		slog.DebugContext(ctx, "Đã gửi message vào Kafka queue", "latency", result.Latency)
+		h.notifyDelivery(client, wsMsg, "sent", nil)
+		return
 	}
+
+	slog.WarnContext(ctx, "Lỗi gửi message vào Kafka, fallback lưu trực tiếp vào DB", logging.Err(result.Err))
+	go func() {
+		h.notifyDelivery(client, wsMsg, "stored", h.saveMessageToDBDirect(ctx, wsMsg, client.userID))
+	}()
 }
 
-// saveMessageToDBDirect lưu tin nhắn trực tiếp vào database (fallback)
-func (h *Hub) saveMessageToDBDirect(wsMsg models.WebSocketMessage, userID string) {
+// notifyDelivery gửi message_ack (hoặc message_nack khi err != nil) cho client
+func (h *Hub) notifyDelivery(client *Client, wsMsg models.WebSocketMessage, status string, err error) {
+	var messageID, clientMessageID string
 	if data, ok := wsMsg.Data.(map[string]interface{}); ok {
-		content, _ := data["content"].(string)
-		messageType, _ := data["type"].(string)
-		messageID, _ := data["message_id"].(string)
-		conversationID := wsMsg.ConvID
+		messageID, _ = data["message_id"].(string)
+		clientMessageID, _ = data["client_message_id"].(string)
+	}
 
-		// Tạo ID nếu chưa có
-		if messageID == "" {
-			messageID = fmt.Sprintf("msg_%s_%d", userID, time.Now().UnixNano())
-		}
+	result := map[string]interface{}{
+		"message_id": messageID,
+		"status":     status,
+	}
+	if clientMessageID != "" {
+		result["client_message_id"] = clientMessageID
+	}
+	ack := models.WebSocketMessage{
+		Type:   "message_ack",
+		ConvID: wsMsg.ConvID,
+		Data:   result,
+	}
+	if err != nil {
+		ack.Type = "message_nack"
+		result["status"] = "failed"
+		result["error"] = err.Error()
+	}
 
-		message := &models.Message{
-			ID:             messageID,
-			ConversationID: conversationID,
-			SenderID:       userID,
-			Content:        content,
-			Type:           models.MessageType(messageType),
-			Status:         models.MessageStatusSent,
-			CreatedAt:      time.Now(),
-			UpdatedAt:      time.Now(),
-		}
+	if messageData, err := json.Marshal(ack); err == nil {
+		h.deliveries <- deliveryNotice{client: client, message: messageData}
+	}
+}
 
-		err := h.db.SaveMessage(message)
-		if err != nil {
-			log.Printf("Lỗi lưu tin nhắn trực tiếp vào DB: %v", err)
-		} else {
-			log.Printf("Đã lưu tin nhắn %s trực tiếp vào DB", messageID)
-		}
+// saveMessageToDBDirect lưu tin nhắn trực tiếp vào database (fallback).
+// Event tương ứng được ghi vào outbox cùng transaction để relay publish vào Kafka sau.
+func (h *Hub) saveMessageToDBDirect(ctx context.Context, wsMsg models.WebSocketMessage, userID string) error {
+	data, ok := wsMsg.Data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("message data không hợp lệ")
+	}
+
+	content, _ := data["content"].(string)
+	messageType, _ := data["type"].(string)
+	messageID, _ := data["message_id"].(string)
+	conversationID := wsMsg.ConvID
+
+	// Tạo ID nếu chưa có
+	if messageID == "" {
+		messageID = fmt.Sprintf("msg_%s_%d", userID, time.Now().UnixNano())
+	}
+
+	message := &models.Message{
+		ID:             messageID,
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Content:        content,
+		Type:           models.MessageType(messageType),
+		Status:         models.MessageStatusSent,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
+
+	if err := h.outbox.SaveMessage(ctx, message); err != nil {
+		slog.ErrorContext(ctx, "Lỗi lưu tin nhắn trực tiếp vào DB", logging.KeyMessageID, messageID, logging.Err(err))
+		return err
+	}
+
+	slog.DebugContext(ctx, "Đã lưu tin nhắn trực tiếp vào DB", logging.KeyMessageID, messageID)
+	return nil
 }
 
 // saveReactionToDB gửi reaction vào Kafka queue thay vì lưu trực tiếp
-func (h *Hub) saveReactionToDB(wsMsg models.WebSocketMessage, userID string) {
+func (h *Hub) saveReactionToDB(ctx context.Context, wsMsg models.WebSocketMessage, userID string) {
+	data, ok := wsMsg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	messageID, _ := data["message_id"].(string)
+	emoji, _ := data["emoji"].(string)
+	action, _ := data["action"].(string)
+	conversationID := wsMsg.ConvID
+
 	// Nếu có Kafka service, sử dụng queue
 	if h.messageService != nil && h.messageService.GetProducer() != nil {
-		if data, ok := wsMsg.Data.(map[string]interface{}); ok {
-			messageID, _ := data["message_id"].(string)
-			emoji, _ := data["emoji"].(string)
-			action, _ := data["action"].(string)
-			conversationID := wsMsg.ConvID
-
-			err := h.messageService.GetProducer().PublishReaction(messageID, userID, emoji, action, conversationID)
-			if err != nil {
-				log.Printf("Lỗi gửi reaction vào Kafka: %v", err)
-			} else {
-				log.Printf("Đã gửi reaction từ user %s vào Kafka queue", userID)
-			}
+		err := h.messageService.GetProducer().PublishReaction(ctx, messageID, userID, emoji, action, conversationID)
+		if err == nil {
+			slog.DebugContext(ctx, "Đã gửi reaction vào Kafka queue", logging.KeyMessageID, messageID)
+			return
 		}
+		slog.WarnContext(ctx, "Lỗi gửi reaction vào Kafka, fallback ghi vào outbox", logging.Err(err))
+	}
+
+	// Fallback: ghi vào outbox để relay publish khi Kafka sẵn sàng
+	event := kafka.NewEvent(conversationID, &kafka.ReactionPayload{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Action:    action,
+	})
+	if err := h.outbox.Enqueue(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "Lỗi ghi reaction vào outbox", logging.KeyMessageID, messageID, logging.Err(err))
 	} else {
-		// Fallback: log để tracking (chưa implement reaction DB)
-		if data, ok := wsMsg.Data.(map[string]interface{}); ok {
-			messageID, _ := data["message_id"].(string)
-			emoji, _ := data["emoji"].(string)
-			action, _ := data["action"].(string)
+		slog.DebugContext(ctx, "Đã ghi reaction vào outbox", logging.KeyMessageID, messageID, "action", action, "emoji", emoji)
+	}
+}
+
+// editMessage gửi event sửa tin nhắn; worker kiểm tra người sửa là người gửi,
+// cập nhật database và search index
+func (h *Hub) editMessage(ctx context.Context, wsMsg models.WebSocketMessage, userID string) {
+	data, ok := wsMsg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	messageID, _ := data["message_id"].(string)
+	content, _ := data["content"].(string)
+	if messageID == "" {
+		return
+	}
+
+	h.publishEvent(ctx, kafka.NewEvent(wsMsg.ConvID, &kafka.MessageEditedPayload{
+		MessageID: messageID,
+		EditorID:  userID,
+		Content:   content,
+	}))
+}
+
+// deleteMessage gửi event xóa tin nhắn đã qua authorizeFrame; worker kiểm tra lại
+// người xóa là người gửi hoặc owner/admin của conversation
+func (h *Hub) deleteMessage(ctx context.Context, wsMsg models.WebSocketMessage, userID string) {
+	data, ok := wsMsg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	messageID, _ := data["message_id"].(string)
+	if messageID == "" {
+		return
+	}
+
+	h.auditMessageDelete(ctx, messageID, userID)
+	h.publishEvent(ctx, kafka.NewEvent(wsMsg.ConvID, &kafka.MessageDeletedPayload{
+		MessageID: messageID,
+		DeletedBy: userID,
+	}))
+}
 
-			log.Printf("User %s %s reaction %s cho message %s (fallback mode)", userID, action, emoji, messageID)
+// publishEvent gửi event vào Kafka, fallback ghi vào outbox để relay publish khi Kafka sẵn sàng
+func (h *Hub) publishEvent(ctx context.Context, event *kafka.Event) {
+	if h.messageService != nil && h.messageService.GetProducer() != nil {
+		err := h.messageService.GetProducer().Publish(ctx, event)
+		if err == nil {
+			slog.DebugContext(ctx, "Đã gửi event vào Kafka queue", logging.KeyEventType, event.Type)
+			return
 		}
+		slog.WarnContext(ctx, "Lỗi gửi event vào Kafka, fallback ghi vào outbox", logging.KeyEventType, event.Type, logging.Err(err))
+	}
+
+	if err := h.outbox.Enqueue(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "Lỗi ghi event vào outbox", logging.KeyEventType, event.Type, logging.Err(err))
 	}
 }
 
 // sendMessageHistory gửi lịch sử tin nhắn cho client
-func (h *Hub) sendMessageHistory(client *Client, conversationID string) {
-	messages, err := h.db.GetMessages(conversationID, 50, 0) // Lấy 50 tin nhắn gần nhất
+func (h *Hub) sendMessageHistory(ctx context.Context, client *Client, conversationID string) {
+	// Đọc từ read replica nếu có, trừ khi client vừa gửi thay đổi
+	ctx = db.WithUser(ctx, client.userID)
+	messages, err := h.store.Messages.ListByConversation(ctx, conversationID, h.config.HistoryLimit, 0) // Lấy các tin nhắn gần nhất
 	if err != nil {
-		log.Printf("Lỗi lấy lịch sử tin nhắn: %v", err)
+		client.logger.Error("Lỗi lấy lịch sử tin nhắn", logging.KeyConversationID, conversationID, logging.Err(err))
 		return
 	}
 
+	frames := make([][]byte, 0, len(messages))
 	for _, message := range messages {
 		wsMsg := models.WebSocketMessage{
 			Type:   "message",
@@ -436,15 +699,20 @@ func (h *Hub) sendMessageHistory(client *Client, conversationID string) {
 		}
 
 		if messageData, err := json.Marshal(wsMsg); err == nil {
-			select {
-			case client.send <- messageData:
-			default:
-				close(client.send)
-				delete(h.clients, client)
+			frames = append(frames, messageData)
+		}
+	}
+
+	h.do(ctx, func() {
+		if _, ok := h.clients[client]; !ok {
+			return
+		}
+		for _, frame := range frames {
+			if !h.sendToClient(client, frame) {
 				return
 			}
 		}
-	}
+	})
 }
 
 // readPump đọc tin nhắn từ kết nối WebSocket của client.
@@ -457,55 +725,115 @@ func (c *Client) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				c.logger.Warn("Kết nối WebSocket đóng bất thường", logging.Err(err))
 			}
 			break
 		}
-		// Parse tin nhắn WebSocket
-		var wsMsg models.WebSocketMessage
-		if err := json.Unmarshal(message, &wsMsg); err != nil {
-			log.Printf("Lỗi parse tin nhắn: %v", err)
-			continue
-		}
+		c.handleFrame(message)
+	}
+}
 
-		// Cập nhật thời gian hoạt động
-		c.lastActivity = time.Now()
+// handleFrame xử lý một WebSocket frame. Mỗi frame là root span của trace,
+// được truyền tiếp qua Kafka tới worker ghi database.
+func (c *Client) handleFrame(message []byte) {
+	// Parse tin nhắn WebSocket
+	var wsMsg models.WebSocketMessage
+	if err := json.Unmarshal(message, &wsMsg); err != nil {
+		c.logger.Warn("Lỗi parse tin nhắn", logging.Err(err))
+		return
+	}
+	metrics.InboundFrame(wsMsg.Type)
 
-		// Xử lý các loại tin nhắn khác nhau
-		switch wsMsg.Type {
-		case "join_conversation":
-			if convID, ok := wsMsg.Data.(string); ok {
-				c.hub.JoinConversation(c, convID)
-			}
-		case "leave_conversation":
-			if convID, ok := wsMsg.Data.(string); ok {
-				c.hub.LeaveConversation(c, convID)
+	ctx := logging.With(context.Background(), logging.KeyRequestID, c.requestID, logging.KeyUserID, c.userID)
+	if wsMsg.ConvID != "" {
+		ctx = logging.With(ctx, logging.KeyConversationID, wsMsg.ConvID)
+	}
+	ctx = audit.WithRequest(ctx, c.request)
+	ctx, span := tracing.Tracer().Start(ctx, "ws.receive "+wsMsg.Type,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("user.id", c.userID),
+			attribute.String("conversation.id", wsMsg.ConvID),
+		),
+	)
+	defer span.End()
+
+	// Cập nhật thời gian hoạt động
+	c.lastActivity.Store(time.Now().UnixNano())
+
+	// Xử lý các loại tin nhắn khác nhau
+	switch wsMsg.Type {
+	case "join_conversation":
+		if convID, ok := wsMsg.Data.(string); ok {
+			c.hub.JoinConversation(ctx, c, convID)
+		}
+	case "leave_conversation":
+		if convID, ok := wsMsg.Data.(string); ok {
+			c.hub.LeaveConversation(ctx, c, convID)
+		}
+	case "create_conversation":
+		c.hub.CreateConversation(ctx, c, wsMsg)
+	case "rename_conversation", "add_member", "remove_member", "set_role", "pin_message", "unpin_message":
+		c.hub.handleConversationCommand(ctx, c, wsMsg)
+	case "message", "typing", "reaction", "edit_message", "delete_message":
+		// Server luôn gán message_id để broadcast, ack và nack dùng cùng một ID. ID do client
+		// gửi lên có thể trùng tin nhắn của người khác nên chỉ được trả lại trong
+		// client_message_id để client đối chiếu.
+		if data, ok := wsMsg.Data.(map[string]interface{}); ok && wsMsg.Type == "message" {
+			if clientMessageID, _ := data["message_id"].(string); clientMessageID != "" {
+				data["client_message_id"] = clientMessageID
 			}
-		case "create_conversation":
-			c.hub.CreateConversation(c, wsMsg)
-		case "message", "typing", "reaction":
-			// Gửi tin nhắn đến kênh broadcast
-			wsMsg.UserID = c.userID // Đảm bảo tin nhắn có thông tin người gửi
+			messageID := fmt.Sprintf("msg_%s_%d", c.userID, time.Now().UnixNano())
+			data["message_id"] = messageID
+			span.SetAttributes(attribute.String("message.id", messageID))
+		}
 
-			// Lưu tin nhắn vào database nếu là message
+		// Kiểm tra vai trò trước khi ghi hay broadcast
+		if err := c.hub.authorizeFrame(ctx, c.userID, wsMsg); err != nil {
+			c.logger.Warn("Từ chối frame", "type", wsMsg.Type, logging.Err(err))
 			if wsMsg.Type == "message" {
-				c.hub.saveMessageToDB(wsMsg, c.userID)
+				c.hub.notifyDelivery(c, wsMsg, "", err)
+			} else {
+				c.hub.sendError(c, wsMsg, err)
 			}
+			return
+		}
 
-			// Lưu reaction vào database
-			if wsMsg.Type == "reaction" {
-				c.hub.saveReactionToDB(wsMsg, c.userID)
-			}
+		// Gửi tin nhắn đến kênh broadcast
+		wsMsg.UserID = c.userID // Đảm bảo tin nhắn có thông tin người gửi
 
-			if updatedMessage, err := json.Marshal(wsMsg); err == nil {
-				c.hub.broadcast <- updatedMessage
-			} else {
-				c.hub.broadcast <- message
-			}
-		default:
-			// Gửi tin nhắn nhận được đến kênh broadcast của hub.
+		// Read-your-writes (best effort, chỉ trên server này): lần đọc tiếp theo của user
+		// đi primary thay vì replica còn trễ
+		if wsMsg.Type != "typing" {
+			c.hub.store.MarkWrite(c.userID)
+		}
+
+		// Lưu tin nhắn vào database nếu là message
+		if wsMsg.Type == "message" {
+			c.hub.saveMessageToDB(ctx, c, wsMsg)
+		}
+
+		// Lưu reaction vào database
+		if wsMsg.Type == "reaction" {
+			c.hub.saveReactionToDB(ctx, wsMsg, c.userID)
+		}
+
+		// Sửa/xóa tin nhắn được worker áp dụng vào database và search index
+		if wsMsg.Type == "edit_message" {
+			c.hub.editMessage(ctx, wsMsg, c.userID)
+		}
+		if wsMsg.Type == "delete_message" {
+			c.hub.deleteMessage(ctx, wsMsg, c.userID)
+		}
+
+		if updatedMessage, err := json.Marshal(wsMsg); err == nil {
+			c.hub.broadcast <- updatedMessage
+		} else {
 			c.hub.broadcast <- message
 		}
+	default:
+		// Gửi tin nhắn nhận được đến kênh broadcast của hub.
+		c.hub.broadcast <- message
 	}
 }
 
@@ -531,14 +859,15 @@ func (c *Client) writePump() {
 		if err := w.Close(); err != nil {
 			return
 		}
+		metrics.OutboundFrame(message)
 	}
 }
 
 // serveWs xử lý các yêu cầu WebSocket từ client.
 func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := hub.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
+		slog.WarnContext(r.Context(), "Lỗi upgrade WebSocket", logging.Err(err))
 		return
 	}
 	// Lấy userID từ query parameter hoặc header (tạm thời dùng cách đơn giản)
@@ -547,14 +876,20 @@ func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		userID = "anonymous" // Hoặc tạo một ID tạm thời
 	}
 
+	requestID := logging.RequestID(r.Context())
 	client := &Client{
 		hub:             hub,
 		conn:            conn,
-		send:            make(chan []byte, 256),
+		send:            make(chan []byte, hub.config.SendBufferSize),
 		userID:          userID,
+		requestID:       requestID,
+		request:         audit.NewRequest(r),
+		logger:          slog.With(logging.KeyRequestID, requestID, logging.KeyUserID, userID),
+		remoteAddr:      r.RemoteAddr,
 		conversationIDs: make(map[string]bool),
-		lastActivity:    time.Now(),
+		connectedAt:     time.Now(),
 	}
+	client.lastActivity.Store(client.connectedAt.UnixNano())
 	client.hub.register <- client
 
 	// Chạy goroutine để đọc và ghi tin nhắn đồng thời.
@@ -563,20 +898,57 @@ func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	configFlags := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.Load(configFlags.Options())
+	if err != nil {
+		logging.Fatal("Lỗi load cấu hình", logging.Err(err))
+	}
+	if configFlags.Print {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("vibeta-ws", cfg.Log); err != nil {
+		logging.Fatal("Lỗi cấu hình logging", logging.Err(err))
+	}
+
+	// Tracing phải được cấu hình trước khi producer tạo span đầu tiên
+	shutdownTracing, err := tracing.Setup(context.Background(), "vibeta-ws", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Lỗi cấu hình tracing", logging.Err(err))
+	}
+
 	// Tạo một hub mới và chạy nó trong một goroutine.
-	hub := newHub()
+	hub := newHub(cfg)
 	go hub.run()
 
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Relay các event trong outbox vào Kafka khi broker sẵn sàng
+	go hub.outbox.Run(ctx)
+
+	// Chế độ all-in-one: consumer chạy cùng process
+	if hub.messageService != nil && hub.messageService.GetConsumer() != nil {
+		if err := hub.messageService.StartConsumer(ctx); err != nil {
+			logging.Fatal("Lỗi khởi động consumer", logging.Err(err))
+		}
+		slog.Info("Chế độ all-in-one: worker chạy trong WebSocket server (in-memory bus)")
+
+		if cfg.Retention.Enabled {
+			go retention.NewPurger(hub.store.Retention, cfg.Retention).Run(ctx)
+		}
+	}
+
 	// Handle shutdown signals
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
-		log.Println("Nhận được signal shutdown...")
+		slog.Info("Nhận được signal shutdown...")
 		cancel()
 	}()
 
@@ -606,24 +978,76 @@ func main() {
 		http.ServeFile(w, r, "admin.html")
 	})
 
+	// Prometheus metrics
+	http.Handle("/metrics", metrics.Handler())
+
 	// Route "/health" để kiểm tra trạng thái system
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		health := map[string]interface{}{
+		checkCtx, checkCancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer checkCancel()
+
+		var clients, conversations int
+		if err := hub.do(checkCtx, func() {
+			clients, conversations = len(hub.clients), len(hub.conversationClients)
+		}); err != nil {
+			return
+		}
+
+		result := map[string]interface{}{
 			"status":        "healthy",
 			"timestamp":     time.Now(),
-			"clients":       len(hub.clients),
-			"conversations": len(hub.conversationClients),
+			"clients":       clients,
+			"conversations": conversations,
 		}
 
+		// Kafka lỗi không làm server ngừng hoạt động (fallback DB + outbox) nên chỉ là degraded
 		if hub.messageService != nil {
-			health["kafka"] = hub.messageService.HealthCheck()
+			report := hub.messageService.HealthCheck(checkCtx)
+			result["kafka"] = report
+			if report.Status != kafka.HealthStatusHealthy {
+				result["status"] = "degraded"
+			}
+		} else {
+			result["kafka"] = map[string]interface{}{"status": "unavailable"}
+			result["status"] = "degraded"
+		}
+
+		if err := hub.store.Ping(checkCtx); err != nil {
+			result["status"] = "unhealthy"
+			result["database"] = map[string]interface{}{"status": "unhealthy", "error": err.Error()}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			result["database"] = map[string]interface{}{"status": "healthy"}
 		}
 
-		json.NewEncoder(w).Encode(health)
+		json.NewEncoder(w).Encode(result)
 	})
 
+	// Route "/livez" và "/readyz" cho liveness/readiness probe.
+	// Server ready khi database sẵn sàng; Kafka không bắt buộc vì đã có outbox.
+	checker := health.NewChecker(5 * time.Second)
+	checker.AddReadinessCheck("database", hub.store.Ping)
+	checker.Register(http.DefaultServeMux)
+
+	// Tìm kiếm full-text tin nhắn
+	http.HandleFunc("/api/search", hub.handleSearch)
+
+	// Export conversation (JSON lines, HTML, text)
+	http.HandleFunc("/api/conversations/{id}/export", hub.handleExport)
+
+	// Quản lý conversation theo vai trò thành viên
+	http.HandleFunc("/api/conversations/{id}", hub.handleConversation)
+	http.HandleFunc("/api/conversations/{id}/members", hub.handleMembers)
+	http.HandleFunc("/api/conversations/{id}/members/{user}", hub.handleMember)
+	http.HandleFunc("/api/conversations/{id}/members/{user}/role", hub.handleMemberRole)
+	http.HandleFunc("/api/conversations/{id}/pins", hub.handlePins)
+	http.HandleFunc("/api/conversations/{id}/pins/{message}", hub.handlePin)
+
+	// Admin API cho admin.html, yêu cầu bearer token trong ADMIN_TOKENS
+	hub.registerAdminRoutes(http.DefaultServeMux)
+
 	// Route "/ws" sẽ xử lý các kết nối WebSocket.
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		serveWs(hub, w, r)
@@ -631,15 +1055,16 @@ func main() {
 
 	// Khởi động máy chủ web với graceful shutdown
 	server := &http.Server{
-		Addr: ":8080",
+		Addr:     cfg.Server.Addr(),
+		Handler:  logging.Middleware(http.DefaultServeMux),
+		ErrorLog: logging.StdLogger(slog.LevelError),
 	}
 
 	// Khởi động server trong goroutine
 	go func() {
-		log.Println("Máy chủ WebSocket đang chạy tại http://localhost:8080")
-		log.Println("Kafka integration: enabled")
+		slog.Info("Máy chủ WebSocket đang chạy", "addr", server.Addr, "env", cfg.Env, "kafka", hub.messageService != nil)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("ListenAndServe: %v", err)
+			logging.Fatal("ListenAndServe", logging.Err(err))
 		}
 	}()
 
@@ -650,19 +1075,27 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
-	log.Println("Đang shutdown server...")
+	slog.Info("Đang shutdown server...")
 
 	// Shutdown HTTP server
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		slog.Error("Server shutdown error", logging.Err(err))
 	}
 
 	// Close message service
 	if hub.messageService != nil {
 		if err := hub.messageService.Close(); err != nil {
-			log.Printf("Message service close error: %v", err)
+			slog.Error("Message service close error", logging.Err(err))
 		}
 	}
 
-	log.Println("Server đã shutdown thành công")
+	if err := hub.outbox.Close(); err != nil {
+		slog.Error("Outbox relay close error", logging.Err(err))
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Tracing shutdown error", logging.Err(err))
+	}
+
+	slog.Info("Server đã shutdown thành công")
 }
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// maxConversationNameLength độ dài tối đa của tên conversation (khớp UpdateConversationRequest)
+const maxConversationNameLength = 100
+
+// Lỗi của các thao tác trong conversation, được chuyển thành HTTP status hoặc frame error
+var (
+	errPermissionDenied = errors.New("không có quyền thực hiện thao tác")
+	errNotMember        = errors.New("user không phải thành viên của conversation")
+	errInvalidRequest   = errors.New("yêu cầu không hợp lệ")
+)
+
+// membership conversation cùng vai trò của một user trong đó, role rỗng nếu user không phải thành viên
+type membership struct {
+	conversation *models.Conversation
+	role         models.ParticipantRole
+}
+
+// lookupMembership đọc conversation và vai trò của user. Trả về db.ErrNotFound nếu
+// conversation không có trong database.
+func (h *Hub) lookupMembership(ctx context.Context, conversationID, userID string) (*membership, error) {
+	conversation, err := h.store.Conversations.Get(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	participant, err := h.store.Participants.Get(ctx, conversationID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return &membership{conversation: conversation}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lỗi đọc vai trò thành viên: %w", err)
+	}
+	return &membership{conversation: conversation, role: participant.Role}, nil
+}
+
+// check kiểm tra vai trò có permission
+func (m *membership) check(permission models.Permission) error {
+	if m.role == "" {
+		return errNotMember
+	}
+	if !m.role.Can(permission) {
+		return fmt.Errorf("%w: vai trò %s không có quyền %s", errPermissionDenied, m.role, permission)
+	}
+	return nil
+}
+
+// requirePermission kiểm tra user có permission trong conversation đã lưu trong database
+func (h *Hub) requirePermission(ctx context.Context, conversationID, userID string, permission models.Permission) (*membership, error) {
+	m, err := h.lookupMembership(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return m, m.check(permission)
+}
+
+// demoConversations conversation demo gửi trong sendConversationList. Chúng không có trong
+// database nên chưa có thành viên để phân quyền, mọi user được tham gia và gửi tin nhắn.
+var demoConversations = map[string]bool{"general": true, "tech-talk": true}
+
+// authorize như requirePermission nhưng cho phép mọi thao tác trong conversation demo chưa
+// lưu trong database. Conversation ID khác không có trong database bị từ chối.
+func (h *Hub) authorize(ctx context.Context, conversationID, userID string, permission models.Permission) error {
+	_, err := h.requirePermission(ctx, conversationID, userID, permission)
+	if errors.Is(err, db.ErrNotFound) && demoConversations[conversationID] {
+		return nil
+	}
+	return err
+}
+
+// authorizeFrame kiểm tra quyền gửi frame tin nhắn: read_only không gửi, gõ, sửa hay react được;
+// xóa tin nhắn của người khác cần quyền delete_others_messages trong conversation của tin nhắn
+func (h *Hub) authorizeFrame(ctx context.Context, userID string, wsMsg models.WebSocketMessage) error {
+	switch wsMsg.Type {
+	case "message", "typing", "reaction", "edit_message":
+		return h.authorize(ctx, wsMsg.ConvID, userID, models.PermissionPost)
+	case "delete_message":
+		data, _ := wsMsg.Data.(map[string]interface{})
+		messageID, _ := data["message_id"].(string)
+		message, err := h.store.Messages.Get(ctx, messageID)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil // Worker bỏ qua, chỉ dọn lại search index
+		}
+		if err != nil {
+			return fmt.Errorf("lỗi đọc message: %w", err)
+		}
+		if message.SenderID == userID {
+			return nil
+		}
+		_, err = h.requirePermission(ctx, message.ConversationID, userID, models.PermissionDeleteOthersMessages)
+		if errors.Is(err, db.ErrNotFound) {
+			return errPermissionDenied
+		}
+		return err
+	}
+	return nil
+}
+
+// renameConversation đổi tên conversation, cần quyền rename
+func (h *Hub) renameConversation(ctx context.Context, actorID, conversationID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxConversationNameLength {
+		return fmt.Errorf("%w: tên conversation phải từ 1 đến %d ký tự", errInvalidRequest, maxConversationNameLength)
+	}
+	m, err := h.requirePermission(ctx, conversationID, actorID, models.PermissionRename)
+	if err != nil {
+		return err
+	}
+	if err := h.store.Conversations.Update(ctx, conversationID, &models.Conversation{Name: name}); err != nil {
+		return err
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        actorID,
+		Action:         audit.ActionConversationRename,
+		TargetType:     audit.TargetConversation,
+		TargetID:       conversationID,
+		ConversationID: conversationID,
+		Before:         map[string]string{"name": m.conversation.Name},
+		After:          map[string]string{"name": name},
+	})
+	h.broadcastToConversation(conversationID, "conversation_renamed", actorID, map[string]interface{}{
+		"name":       name,
+		"renamed_by": actorID,
+	})
+	return nil
+}
+
+// addMember thêm user vào conversation với vai trò role (mặc định member). Cần quyền
+// manage_members và được gán role; quyền sở hữu chỉ chuyển được bằng changeRole.
+func (h *Hub) addMember(ctx context.Context, actorID, conversationID, userID string, role models.ParticipantRole) error {
+	if role == "" {
+		role = models.RoleMember
+	}
+	if userID == "" || !role.Valid() || role == models.RoleOwner {
+		return fmt.Errorf("%w: cần user_id và role là admin, member hoặc read_only", errInvalidRequest)
+	}
+	m, err := h.requirePermission(ctx, conversationID, actorID, models.PermissionManageMembers)
+	if err != nil {
+		return err
+	}
+	if !m.role.CanAssign(role) {
+		return fmt.Errorf("%w: vai trò %s không gán được vai trò %s", errPermissionDenied, m.role, role)
+	}
+
+	// Add không làm gì nếu user đã là thành viên, báo lỗi để không ghi nhầm vai trò
+	if _, err := h.store.Participants.Get(ctx, conversationID, userID); err == nil {
+		return fmt.Errorf("%w: %s đã là thành viên", db.ErrDuplicate, userID)
+	} else if !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+	if err := h.store.Participants.Add(ctx, conversationID, userID, role); err != nil {
+		return err
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        actorID,
+		Action:         audit.ActionMemberAdd,
+		TargetType:     audit.TargetUser,
+		TargetID:       userID,
+		ConversationID: conversationID,
+		After:          map[string]interface{}{"role": role},
+	})
+	h.broadcastToConversation(conversationID, "member_added", actorID, map[string]interface{}{
+		"user_id":  userID,
+		"role":     role,
+		"added_by": actorID,
+	})
+	return nil
+}
+
+// removeMember xóa user khỏi conversation. User tự rời được (trừ owner, phải chuyển quyền
+// sở hữu trước); xóa người khác cần quyền manage_members và vai trò cao hơn người bị xóa.
+func (h *Hub) removeMember(ctx context.Context, actorID, conversationID, userID string) error {
+	if userID == "" {
+		return fmt.Errorf("%w: thiếu user_id", errInvalidRequest)
+	}
+	m, err := h.lookupMembership(ctx, conversationID, actorID)
+	if err != nil {
+		return err
+	}
+	if m.role == "" {
+		return errNotMember
+	}
+	target, err := h.store.Participants.Get(ctx, conversationID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("%w: %s không phải thành viên", db.ErrNotFound, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case userID == actorID && target.Role == models.RoleOwner:
+		return fmt.Errorf("%w: owner phải chuyển quyền sở hữu trước khi rời", errPermissionDenied)
+	case userID != actorID && !m.role.CanManage(target.Role):
+		return fmt.Errorf("%w: vai trò %s không xóa được thành viên %s", errPermissionDenied, m.role, target.Role)
+	}
+	if err := h.store.Participants.Remove(ctx, conversationID, userID); err != nil {
+		return err
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        actorID,
+		Action:         audit.ActionMemberRemove,
+		TargetType:     audit.TargetUser,
+		TargetID:       userID,
+		ConversationID: conversationID,
+		Before:         map[string]interface{}{"role": target.Role},
+	})
+	h.broadcastToConversation(conversationID, "member_removed", actorID, map[string]interface{}{
+		"user_id":    userID,
+		"removed_by": actorID,
+	})
+	h.unsubscribe(ctx, conversationID, userID)
+	return nil
+}
+
+// changeRole đổi vai trò của thành viên. Cần quyền manage_members, vai trò cao hơn vai trò
+// hiện tại của thành viên và được gán vai trò mới. Owner gán owner cho người khác là chuyển
+// quyền sở hữu: owner cũ trở thành admin.
+func (h *Hub) changeRole(ctx context.Context, actorID, conversationID, userID string, role models.ParticipantRole) error {
+	if userID == "" || !role.Valid() {
+		return fmt.Errorf("%w: cần user_id và role là owner, admin, member hoặc read_only", errInvalidRequest)
+	}
+	if userID == actorID {
+		return fmt.Errorf("%w: không tự đổi vai trò của mình", errPermissionDenied)
+	}
+	m, err := h.requirePermission(ctx, conversationID, actorID, models.PermissionManageMembers)
+	if err != nil {
+		return err
+	}
+	target, err := h.store.Participants.Get(ctx, conversationID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("%w: %s không phải thành viên", db.ErrNotFound, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if target.Role == role {
+		return nil
+	}
+	if !m.role.CanManage(target.Role) || !m.role.CanAssign(role) {
+		return fmt.Errorf("%w: vai trò %s không đổi được %s thành %s", errPermissionDenied, m.role, target.Role, role)
+	}
+
+	previous := map[string]models.ParticipantRole{userID: target.Role}
+	roles := map[string]models.ParticipantRole{userID: role}
+	if role == models.RoleOwner {
+		previous[actorID], roles[actorID] = m.role, models.RoleAdmin
+	}
+	if err := h.store.Participants.SetRoles(ctx, conversationID, roles); err != nil {
+		return err
+	}
+
+	// Người được đổi trước, owner cũ (khi chuyển quyền sở hữu) sau
+	for _, member := range []string{userID, actorID} {
+		newRole, changed := roles[member]
+		if !changed {
+			continue
+		}
+		h.recordAudit(ctx, audit.Entry{
+			ActorID:        actorID,
+			Action:         audit.ActionRoleChange,
+			TargetType:     audit.TargetUser,
+			TargetID:       member,
+			ConversationID: conversationID,
+			Before:         map[string]interface{}{"role": previous[member]},
+			After:          map[string]interface{}{"role": newRole},
+		})
+		h.broadcastToConversation(conversationID, "role_changed", actorID, map[string]interface{}{
+			"user_id":       member,
+			"role":          newRole,
+			"previous_role": previous[member],
+			"changed_by":    actorID,
+		})
+	}
+	return nil
+}
+
+// setPinned ghim hoặc bỏ ghim tin nhắn của conversation, cần quyền pin
+func (h *Hub) setPinned(ctx context.Context, actorID, conversationID, messageID string, pinned bool) error {
+	if messageID == "" {
+		return fmt.Errorf("%w: thiếu message_id", errInvalidRequest)
+	}
+	if _, err := h.requirePermission(ctx, conversationID, actorID, models.PermissionPin); err != nil {
+		return err
+	}
+	message, err := h.store.Messages.Get(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if message.ConversationID != conversationID {
+		return db.ErrNotFound
+	}
+
+	action, eventType, byKey := audit.ActionMessagePin, "message_pinned", "pinned_by"
+	if pinned {
+		err = h.store.Pins.Pin(ctx, &models.PinnedMessage{
+			ConversationID: conversationID,
+			MessageID:      messageID,
+			PinnedBy:       actorID,
+			PinnedAt:       time.Now().UTC(),
+		})
+	} else {
+		action, eventType, byKey = audit.ActionMessageUnpin, "message_unpinned", "unpinned_by"
+		err = h.store.Pins.Unpin(ctx, conversationID, messageID)
+	}
+	if err != nil {
+		return err
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		ActorID:        actorID,
+		Action:         action,
+		TargetType:     audit.TargetMessage,
+		TargetID:       messageID,
+		ConversationID: conversationID,
+	})
+	h.broadcastToConversation(conversationID, eventType, actorID, map[string]interface{}{
+		"message_id": messageID,
+		byKey:        actorID,
+	})
+	return nil
+}
+
+// broadcastToConversation gửi event tới các client đang tham gia conversation.
+// Không được gọi từ goroutine của hub.
+func (h *Hub) broadcastToConversation(conversationID, eventType, userID string, data interface{}) {
+	message, err := json.Marshal(models.WebSocketMessage{
+		Type:   eventType,
+		UserID: userID,
+		ConvID: conversationID,
+		Data:   data,
+	})
+	if err != nil {
+		slog.Error("Lỗi encode event", logging.KeyEventType, eventType, logging.Err(err))
+		return
+	}
+	h.broadcast <- message
+}
+
+// unsubscribe ngừng gửi event của conversation tới các kết nối của user đã bị xóa khỏi conversation
+func (h *Hub) unsubscribe(ctx context.Context, conversationID, userID string) {
+	h.do(ctx, func() {
+		clients := h.conversationClients[conversationID]
+		for client := range clients {
+			if client.userID == userID {
+				delete(clients, client)
+				delete(client.conversationIDs, conversationID)
+			}
+		}
+		if len(clients) == 0 {
+			delete(h.conversationClients, conversationID)
+		}
+	})
+}
+
+// handleConversationCommand xử lý các frame quản lý conversation:
+//
+//	rename_conversation  data {"name"}
+//	add_member           data {"user_id", "role"}
+//	remove_member        data {"user_id"}
+//	set_role             data {"user_id", "role"}
+//	pin_message          data {"message_id"}
+//	unpin_message        data {"message_id"}
+//
+// Kết quả được broadcast tới thành viên đang kết nối; lỗi được gửi lại cho client bằng frame error.
+func (h *Hub) handleConversationCommand(ctx context.Context, client *Client, wsMsg models.WebSocketMessage) {
+	data, _ := wsMsg.Data.(map[string]interface{})
+	field := func(name string) string {
+		value, _ := data[name].(string)
+		return value
+	}
+
+	var err error
+	switch wsMsg.Type {
+	case "rename_conversation":
+		err = h.renameConversation(ctx, client.userID, wsMsg.ConvID, field("name"))
+	case "add_member":
+		err = h.addMember(ctx, client.userID, wsMsg.ConvID, field("user_id"), models.ParticipantRole(field("role")))
+	case "remove_member":
+		err = h.removeMember(ctx, client.userID, wsMsg.ConvID, field("user_id"))
+	case "set_role":
+		err = h.changeRole(ctx, client.userID, wsMsg.ConvID, field("user_id"), models.ParticipantRole(field("role")))
+	case "pin_message", "unpin_message":
+		err = h.setPinned(ctx, client.userID, wsMsg.ConvID, field("message_id"), wsMsg.Type == "pin_message")
+	}
+	if err != nil {
+		client.logger.Warn("Từ chối thao tác trên conversation", "type", wsMsg.Type,
+			logging.KeyConversationID, wsMsg.ConvID, logging.Err(err))
+		h.sendError(client, wsMsg, err)
+	}
+}
+
+// sendError gửi frame error cho client khi thao tác bị từ chối hoặc lỗi
+func (h *Hub) sendError(client *Client, wsMsg models.WebSocketMessage, err error) {
+	message := err.Error()
+	if !isClientError(err) {
+		message = "lỗi hệ thống, vui lòng thử lại"
+	}
+	frame, encodeErr := json.Marshal(models.WebSocketMessage{
+		Type:   "error",
+		ConvID: wsMsg.ConvID,
+		Data: map[string]interface{}{
+			"request_type": wsMsg.Type,
+			"error":        message,
+		},
+	})
+	if encodeErr == nil {
+		h.deliveries <- deliveryNotice{client: client, message: frame}
+	}
+}
+
+// isClientError kiểm tra lỗi do yêu cầu của user (trả nguyên văn) thay vì lỗi hệ thống
+func isClientError(err error) bool {
+	for _, target := range []error{errPermissionDenied, errNotMember, errInvalidRequest, db.ErrNotFound, db.ErrDuplicate} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
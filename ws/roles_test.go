@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"vibeta/internal/audit"
+	"vibeta/internal/config"
+	"vibeta/internal/db"
+	"vibeta/internal/db/dbtest"
+	"vibeta/internal/models"
+)
+
+// Thành viên của conversation "project" trong newTestHub
+var projectMembers = map[string]models.ParticipantRole{
+	"owner":  models.RoleOwner,
+	"admin":  models.RoleAdmin,
+	"admin2": models.RoleAdmin,
+	"member": models.RoleMember,
+	"reader": models.RoleReadOnly,
+}
+
+// newTestHub tạo hub đang chạy trên database SQLite in-memory riêng cho test, với
+// conversation "project" (projectMembers), user "outsider" không thuộc conversation,
+// user "newbie" chưa tham gia và tin nhắn m-member, m-admin của member và admin.
+func newTestHub(t *testing.T) (*Hub, *db.Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	store := dbtest.NewStore(t)
+
+	for _, userID := range []string{"owner", "admin", "admin2", "member", "reader", "outsider", "newbie"} {
+		if err := store.Users.Create(ctx, &models.User{ID: userID, Username: userID, Email: userID + "@example.com", FullName: userID}); err != nil {
+			t.Fatalf("Users.Create %s: %v", userID, err)
+		}
+	}
+	if err := store.Conversations.Create(ctx, &models.Conversation{ID: "project", Type: models.ConversationTypeGroup, Name: "Project", CreatedBy: "owner"}); err != nil {
+		t.Fatalf("Conversations.Create: %v", err)
+	}
+	for userID, role := range projectMembers {
+		if err := store.Participants.Add(ctx, "project", userID, role); err != nil {
+			t.Fatalf("Participants.Add %s: %v", userID, err)
+		}
+	}
+	for _, messageID := range []string{"m-member", "m-admin"} {
+		message := &models.Message{ID: messageID, ConversationID: "project", SenderID: strings.TrimPrefix(messageID, "m-"),
+			Content: messageID, Type: models.MessageTypeText}
+		if err := store.Messages.Create(ctx, message); err != nil {
+			t.Fatalf("Messages.Create %s: %v", messageID, err)
+		}
+	}
+
+	hub := &Hub{
+		broadcast:           make(chan []byte),
+		register:            make(chan *Client),
+		unregister:          make(chan *Client),
+		deliveries:          make(chan deliveryNotice, 256),
+		inspect:             make(chan func()),
+		clients:             make(map[*Client]bool),
+		conversationClients: make(map[string]map[*Client]bool),
+		userClients:         make(map[string]*Client),
+		store:               store,
+		audit:               audit.NewRecorder(store.Audit, nil),
+		config:              config.HubConfig{HistoryLimit: 50},
+	}
+	go hub.run()
+	return hub, store
+}
+
+// roleOf trả về vai trò hiện tại của user trong "project", rỗng nếu không còn là thành viên
+func roleOf(t *testing.T, store *db.Store, userID string) models.ParticipantRole {
+	t.Helper()
+	participant, err := store.Participants.Get(context.Background(), "project", userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return ""
+	}
+	if err != nil {
+		t.Fatalf("Participants.Get %s: %v", userID, err)
+	}
+	return participant.Role
+}
+
+// auditActions trả về action của các bản ghi audit, cũ nhất trước
+func auditActions(t *testing.T, store *db.Store) []string {
+	t.Helper()
+	page, err := store.Audit.List(context.Background(), db.AuditQuery{})
+	if err != nil {
+		t.Fatalf("Audit.List: %v", err)
+	}
+	actions := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		actions[len(page.Entries)-1-i] = entry.Action
+	}
+	return actions
+}
+
+func TestAuthorize(t *testing.T) {
+	hub, _ := newTestHub(t)
+
+	tests := []struct {
+		name           string
+		conversationID string
+		userID         string
+		permission     models.Permission
+		wantErr        error
+	}{
+		{name: "member gửi tin", conversationID: "project", userID: "member", permission: models.PermissionPost},
+		{name: "read_only đọc", conversationID: "project", userID: "reader", permission: models.PermissionRead},
+		{name: "read_only không gửi tin", conversationID: "project", userID: "reader", permission: models.PermissionPost, wantErr: errPermissionDenied},
+		{name: "member không ghim", conversationID: "project", userID: "member", permission: models.PermissionPin, wantErr: errPermissionDenied},
+		{name: "admin ghim", conversationID: "project", userID: "admin", permission: models.PermissionPin},
+		{name: "người ngoài không đọc", conversationID: "project", userID: "outsider", permission: models.PermissionRead, wantErr: errNotMember},
+		{name: "conversation demo", conversationID: "general", userID: "outsider", permission: models.PermissionPost},
+		{name: "conversation chưa lưu", conversationID: "private-room", userID: "outsider", permission: models.PermissionRead, wantErr: db.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := hub.authorize(context.Background(), tt.conversationID, tt.userID, tt.permission)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("authorize = %v, muốn %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeFrame(t *testing.T) {
+	hub, _ := newTestHub(t)
+
+	tests := []struct {
+		name      string
+		userID    string
+		frameType string
+		convID    string
+		messageID string
+		wantErr   error
+	}{
+		{name: "member gửi tin", userID: "member", frameType: "message", convID: "project"},
+		{name: "read_only gửi tin", userID: "reader", frameType: "message", convID: "project", wantErr: errPermissionDenied},
+		{name: "read_only gõ", userID: "reader", frameType: "typing", convID: "project", wantErr: errPermissionDenied},
+		{name: "read_only react", userID: "reader", frameType: "reaction", convID: "project", wantErr: errPermissionDenied},
+		{name: "read_only sửa", userID: "reader", frameType: "edit_message", convID: "project", wantErr: errPermissionDenied},
+		{name: "người ngoài gửi tin", userID: "outsider", frameType: "message", convID: "project", wantErr: errNotMember},
+		{name: "gửi vào conversation chưa lưu", userID: "outsider", frameType: "message", convID: "private-room", wantErr: db.ErrNotFound},
+		{name: "xóa tin của mình", userID: "member", frameType: "delete_message", messageID: "m-member"},
+		{name: "member xóa tin người khác", userID: "member", frameType: "delete_message", messageID: "m-admin", wantErr: errPermissionDenied},
+		{name: "admin xóa tin member", userID: "admin", frameType: "delete_message", messageID: "m-member"},
+		{name: "người ngoài xóa tin", userID: "outsider", frameType: "delete_message", messageID: "m-member", wantErr: errNotMember},
+		{name: "xóa tin không tồn tại", userID: "outsider", frameType: "delete_message", messageID: "m-missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := models.WebSocketMessage{Type: tt.frameType, ConvID: tt.convID, Data: map[string]interface{}{"message_id": tt.messageID}}
+			err := hub.authorizeFrame(context.Background(), tt.userID, frame)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("authorizeFrame = %v, muốn %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		actorID  string
+		userID   string
+		role     models.ParticipantRole
+		wantErr  error
+		wantRole models.ParticipantRole
+	}{
+		{name: "owner thêm admin", actorID: "owner", userID: "newbie", role: models.RoleAdmin, wantRole: models.RoleAdmin},
+		{name: "mặc định member", actorID: "admin", userID: "newbie", wantRole: models.RoleMember},
+		{name: "admin thêm read_only", actorID: "admin", userID: "newbie", role: models.RoleReadOnly, wantRole: models.RoleReadOnly},
+		{name: "admin không thêm admin", actorID: "admin", userID: "newbie", role: models.RoleAdmin, wantErr: errPermissionDenied},
+		{name: "member không thêm", actorID: "member", userID: "newbie", wantErr: errPermissionDenied},
+		{name: "người ngoài không thêm", actorID: "outsider", userID: "newbie", wantErr: errNotMember},
+		{name: "không thêm owner", actorID: "owner", userID: "newbie", role: models.RoleOwner, wantErr: errInvalidRequest},
+		{name: "vai trò lạ", actorID: "owner", userID: "newbie", role: "superuser", wantErr: errInvalidRequest},
+		// Thêm lại không được ghi đè vai trò hiện tại
+		{name: "đã là thành viên", actorID: "owner", userID: "reader", role: models.RoleAdmin, wantErr: db.ErrDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, store := newTestHub(t)
+			err := hub.addMember(context.Background(), tt.actorID, "project", tt.userID, tt.role)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("addMember = %v, muốn %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if role := roleOf(t, store, "newbie"); role != "" {
+					t.Errorf("newbie có vai trò %s sau khi bị từ chối", role)
+				}
+				if role := roleOf(t, store, "reader"); role != models.RoleReadOnly {
+					t.Errorf("reader đổi thành %s", role)
+				}
+				if actions := auditActions(t, store); len(actions) != 0 {
+					t.Errorf("audit log %v cho thao tác bị từ chối", actions)
+				}
+				return
+			}
+			if role := roleOf(t, store, tt.userID); role != tt.wantRole {
+				t.Errorf("vai trò = %s, muốn %s", role, tt.wantRole)
+			}
+			if actions := auditActions(t, store); len(actions) != 1 || actions[0] != audit.ActionMemberAdd {
+				t.Errorf("audit log = %v, muốn [%s]", actions, audit.ActionMemberAdd)
+			}
+		})
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	tests := []struct {
+		name    string
+		actorID string
+		userID  string
+		wantErr error
+	}{
+		{name: "owner xóa admin", actorID: "owner", userID: "admin"},
+		{name: "admin xóa member", actorID: "admin", userID: "member"},
+		{name: "admin xóa read_only", actorID: "admin", userID: "reader"},
+		{name: "member tự rời", actorID: "member", userID: "member"},
+		{name: "admin tự rời", actorID: "admin", userID: "admin"},
+		{name: "owner không tự rời", actorID: "owner", userID: "owner", wantErr: errPermissionDenied},
+		{name: "admin không xóa admin khác", actorID: "admin", userID: "admin2", wantErr: errPermissionDenied},
+		{name: "admin không xóa owner", actorID: "admin", userID: "owner", wantErr: errPermissionDenied},
+		{name: "member không xóa read_only", actorID: "member", userID: "reader", wantErr: errPermissionDenied},
+		{name: "người ngoài không xóa", actorID: "outsider", userID: "member", wantErr: errNotMember},
+		{name: "xóa người không phải thành viên", actorID: "owner", userID: "outsider", wantErr: db.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, store := newTestHub(t)
+			err := hub.removeMember(context.Background(), tt.actorID, "project", tt.userID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("removeMember = %v, muốn %v", err, tt.wantErr)
+			}
+
+			role := roleOf(t, store, tt.userID)
+			if tt.wantErr == nil && role != "" {
+				t.Errorf("%s vẫn là thành viên với vai trò %s", tt.userID, role)
+			}
+			if tt.wantErr != nil && role != projectMembers[tt.userID] {
+				t.Errorf("vai trò của %s = %q sau khi bị từ chối, muốn %q", tt.userID, role, projectMembers[tt.userID])
+			}
+			wantAudit := 0
+			if tt.wantErr == nil {
+				wantAudit = 1
+			}
+			if actions := auditActions(t, store); len(actions) != wantAudit {
+				t.Errorf("audit log = %v, muốn %d bản ghi", actions, wantAudit)
+			}
+		})
+	}
+}
+
+func TestChangeRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		actorID   string
+		userID    string
+		role      models.ParticipantRole
+		wantErr   error
+		wantRoles map[string]models.ParticipantRole // Vai trò sau khi đổi, chỉ các user thay đổi
+		wantAudit int
+	}{
+		{name: "owner nâng member lên admin", actorID: "owner", userID: "member", role: models.RoleAdmin,
+			wantRoles: map[string]models.ParticipantRole{"member": models.RoleAdmin}, wantAudit: 1},
+		{name: "admin hạ member xuống read_only", actorID: "admin", userID: "member", role: models.RoleReadOnly,
+			wantRoles: map[string]models.ParticipantRole{"member": models.RoleReadOnly}, wantAudit: 1},
+		{name: "owner hạ admin", actorID: "owner", userID: "admin", role: models.RoleMember,
+			wantRoles: map[string]models.ParticipantRole{"admin": models.RoleMember}, wantAudit: 1},
+		// Chuyển quyền sở hữu: owner cũ thành admin, ghi audit cho cả hai
+		{name: "chuyển quyền sở hữu", actorID: "owner", userID: "admin", role: models.RoleOwner,
+			wantRoles: map[string]models.ParticipantRole{"admin": models.RoleOwner, "owner": models.RoleAdmin}, wantAudit: 2},
+		{name: "giữ nguyên vai trò", actorID: "owner", userID: "member", role: models.RoleMember},
+		{name: "admin không nâng lên admin", actorID: "admin", userID: "member", role: models.RoleAdmin, wantErr: errPermissionDenied},
+		{name: "admin không hạ admin khác", actorID: "admin", userID: "admin2", role: models.RoleMember, wantErr: errPermissionDenied},
+		{name: "admin không chuyển quyền sở hữu", actorID: "admin", userID: "member", role: models.RoleOwner, wantErr: errPermissionDenied},
+		{name: "admin không hạ owner", actorID: "admin", userID: "owner", role: models.RoleMember, wantErr: errPermissionDenied},
+		{name: "member không đổi vai trò", actorID: "member", userID: "reader", role: models.RoleMember, wantErr: errPermissionDenied},
+		{name: "read_only tự nâng", actorID: "reader", userID: "reader", role: models.RoleAdmin, wantErr: errPermissionDenied},
+		{name: "owner tự hạ", actorID: "owner", userID: "owner", role: models.RoleMember, wantErr: errPermissionDenied},
+		{name: "người ngoài", actorID: "outsider", userID: "member", role: models.RoleAdmin, wantErr: errNotMember},
+		{name: "không phải thành viên", actorID: "owner", userID: "outsider", role: models.RoleMember, wantErr: db.ErrNotFound},
+		{name: "vai trò lạ", actorID: "owner", userID: "member", role: "superuser", wantErr: errInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, store := newTestHub(t)
+			err := hub.changeRole(context.Background(), tt.actorID, "project", tt.userID, tt.role)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("changeRole = %v, muốn %v", err, tt.wantErr)
+			}
+
+			for userID, original := range projectMembers {
+				want, changed := tt.wantRoles[userID]
+				if !changed {
+					want = original
+				}
+				if role := roleOf(t, store, userID); role != want {
+					t.Errorf("vai trò của %s = %s, muốn %s", userID, role, want)
+				}
+			}
+			actions := auditActions(t, store)
+			if len(actions) != tt.wantAudit {
+				t.Fatalf("audit log = %v, muốn %d bản ghi", actions, tt.wantAudit)
+			}
+			for _, action := range actions {
+				if action != audit.ActionRoleChange {
+					t.Errorf("audit action = %s, muốn %s", action, audit.ActionRoleChange)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveMemberUnsubscribes(t *testing.T) {
+	ctx := context.Background()
+	hub, _ := newTestHub(t)
+
+	client := &Client{
+		hub:             hub,
+		send:            make(chan []byte, 64),
+		userID:          "member",
+		logger:          slog.Default(),
+		conversationIDs: make(map[string]bool),
+	}
+	hub.register <- client
+	hub.JoinConversation(ctx, client, "project")
+
+	subscribed := func() bool {
+		var ok bool
+		hub.do(ctx, func() { ok = hub.conversationClients["project"][client] && client.conversationIDs["project"] })
+		return ok
+	}
+	if !subscribed() {
+		t.Fatal("member chưa tham gia project")
+	}
+
+	if err := hub.removeMember(ctx, "admin", "project", "member"); err != nil {
+		t.Fatalf("removeMember: %v", err)
+	}
+	if subscribed() {
+		t.Error("kết nối của member vẫn nhận event của project sau khi bị xóa")
+	}
+
+	// Tham gia lại bị từ chối vì không còn là thành viên
+	hub.JoinConversation(ctx, client, "project")
+	if subscribed() {
+		t.Error("member tham gia lại được project sau khi bị xóa")
+	}
+}
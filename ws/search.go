@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vibeta/internal/db"
+	"vibeta/internal/logging"
+	"vibeta/internal/models"
+)
+
+// handleSearch xử lý GET /api/search, tìm tin nhắn trong các conversation user đang tham gia.
+//
+// Query parameters:
+//
+//	user_id          người tìm kiếm (giống /ws, tạm thời chưa có xác thực)
+//	q                các từ cần tìm, tin nhắn phải chứa tất cả các từ
+//	conversation_id  lọc theo conversation
+//	sender_id        lọc theo người gửi
+//	type             lọc theo loại tin nhắn (text, image, ...)
+//	from, to         khoảng thời gian RFC3339, from bao gồm, to không bao gồm
+//	limit            số kết quả mỗi trang (mặc định 20, tối đa 100)
+//	cursor           next_cursor của trang trước
+func (h *Hub) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "chỉ hỗ trợ GET")
+		return
+	}
+
+	params := r.URL.Query()
+	query := db.SearchQuery{
+		UserID:         params.Get("user_id"),
+		Text:           params.Get("q"),
+		ConversationID: params.Get("conversation_id"),
+		SenderID:       params.Get("sender_id"),
+		Type:           models.MessageType(params.Get("type")),
+		Cursor:         params.Get("cursor"),
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from không hợp lệ, cần RFC3339")
+		return
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to không hợp lệ, cần RFC3339")
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			writeJSONError(w, http.StatusBadRequest, "limit phải là số nguyên dương")
+			return
+		}
+	}
+
+	page, err := h.store.Search.Search(r.Context(), query)
+	if errors.Is(err, db.ErrInvalidSearchQuery) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Lỗi tìm kiếm tin nhắn", logging.KeyUserID, query.UserID, logging.Err(err))
+		writeJSONError(w, http.StatusInternalServerError, "lỗi tìm kiếm")
+		return
+	}
+
+	if page.Results == nil {
+		page.Results = []db.SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseTimeParam đọc thời gian RFC3339, chuỗi rỗng trả về zero time
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// writeJSON ghi body JSON với status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeJSONError ghi lỗi dạng {"error": message}
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}